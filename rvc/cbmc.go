@@ -0,0 +1,95 @@
+package rvc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//CBMCOptions sets what the CBMC harnesses check
+type CBMCOptions struct {
+	Ticks       int      //how many ticks each harness runs its monitor for (default 10), unless CBMC_TICKS is defined when running cbmc
+	Assumptions []string //guards over the interface (e.g. "!(A and B)") that are assumed to hold on every tick
+	Never       []string //states (written as policy.state) that cbmc checks are never reached
+}
+
+//CBMCHarness is what the CBMC harness of a function checks
+type CBMCHarness struct {
+	Ticks       int
+	Assumptions []CBMCAssumption
+	Never       []CBMCState
+	Reachable   [][]string //the states of each policy that can be reached from its initial state (if guards that can never hold are left out)
+}
+
+//CBMCAssumption is an assumption about the interface, as it was written and as C
+type CBMCAssumption struct {
+	Guard string
+	C     string
+}
+
+//CBMCState is a state of a policy
+type CBMCState struct {
+	Policy string
+	State  string
+}
+
+//harness works out what the CBMC harness for the (finalised) function f checks
+//The states in o.Never that belong to f's policies are marked in used.
+func (o CBMCOptions) harness(f rvdef.Monitor, used []bool) (*CBMCHarness, error) {
+	h := &CBMCHarness{Ticks: o.Ticks}
+	if h.Ticks <= 0 {
+		h.Ticks = 10
+	}
+
+	for _, guard := range o.Assumptions {
+		expr, perr := rvdef.ParseSTExpression("assumption", guard)
+		if perr != nil {
+			return nil, fmt.Errorf("can't parse assumption '%s': %s", guard, perr.Error())
+		}
+		c, err := cCompileExpression(f, -1, expr)
+		if err != nil {
+			return nil, fmt.Errorf("can't compile assumption '%s' (it can only use the interface): %s", guard, err.Error())
+		}
+		h.Assumptions = append(h.Assumptions, CBMCAssumption{Guard: guard, C: c})
+	}
+
+	for i, never := range o.Never {
+		dot := strings.Index(never, ".")
+		if dot == -1 {
+			return nil, fmt.Errorf("'%s' should be written as policy.state", never)
+		}
+		st := CBMCState{Policy: never[:dot], State: never[dot+1:]}
+		for _, p := range f.Policies {
+			if p.Name != st.Policy {
+				continue
+			}
+			if p.GetState(st.State) == nil {
+				return nil, fmt.Errorf("policy %s has no state %s", p.Name, st.State)
+			}
+			h.Never = append(h.Never, st)
+			used[i] = true
+		}
+	}
+
+	for i, report := range f.Analyse() {
+		var reachable []string
+		for _, st := range f.Policies[i].States {
+			if !stringSliceContains(report.Unreachable, st.Name) {
+				reachable = append(reachable, st.Name)
+			}
+		}
+		h.Reachable = append(h.Reachable, reachable)
+	}
+	return h, nil
+}
+
+//stringSliceContains returns true if slice contains s
+func stringSliceContains(slice []string, s string) bool {
+	for _, e := range slice {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package rvc
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+func TestCBMCHarness(t *testing.T) {
+	mon := rvdef.Monitor{
+		Name:          "m",
+		Enums:         []rvdef.Enum{{Name: "Mode", Members: []string{"IDLE", "ON"}}},
+		InterfaceList: []rvdef.Variable{{Name: "A", Type: "bool"}, {Name: "mode", Type: "Mode"}},
+		Policies: []rvdef.Policy{{
+			Name:         "P",
+			InternalVars: []rvdef.Variable{{Name: "v", Type: "dtimer_t"}},
+			States:       []rvdef.PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "bad"}, {Name: "unused"}},
+			Transitions: []rvdef.PTransition{
+				{Source: "s0", Destination: "s0", Condition: "!A or mode = IDLE"},
+				{Source: "s0", Destination: "bad", Else: true},
+			},
+		}},
+	}
+
+	tests := []struct {
+		opts CBMCOptions
+		err  string
+		want []string
+	}{
+		{
+			opts: CBMCOptions{Ticks: 4, Assumptions: []string{"mode = ON"}, Never: []string{"P.bad"}},
+			want: []string{
+				"--unwind 5 --unwinding-assertions",
+				"#define CBMC_TICKS 4",
+				"m_init_all_vars(me, io);",
+				"__CPROVER_assume(me->_policy_P_state == POLICY_STATE_m_P_s0 || me->_policy_P_state == POLICY_STATE_m_P_bad);",
+				"__CPROVER_assume((int)io->mode >= 0 && (int)io->mode < 2);",
+				"__CPROVER_assume((io->mode == ON)); //mode = ON",
+				"m_run_via_monitor(me, io);",
+				`__CPROVER_assert(me->_policy_P_state != POLICY_STATE_m_P_bad, "policy P is never in state bad");`,
+			},
+		},
+		{opts: CBMCOptions{}, want: []string{"#define CBMC_TICKS 10", `"policy P is in one of its states"`}},
+		{opts: CBMCOptions{Assumptions: []string{"A and"}}, err: "can't parse assumption 'A and'"},
+		{opts: CBMCOptions{Assumptions: []string{"v < 3"}}, err: "can't compile assumption 'v < 3'"},
+		{opts: CBMCOptions{Never: []string{"P.nope"}}, err: "policy P has no state nope"},
+		{opts: CBMCOptions{Never: []string{"Q.bad"}}, err: "there is no policy with a state Q.bad"},
+		{opts: CBMCOptions{Never: []string{"bad"}}, err: "'bad' should be written as policy.state"},
+	}
+
+	for i, test := range tests {
+		conv, _ := New("c")
+		conv.Funcs = []rvdef.Monitor{mon}
+		conv.CBMC = true
+		conv.CBMCOptions = test.opts
+		outputs, err := conv.ConvertAll()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Test %d: error was %v, it should have been '%s'", i, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: error '%s' occurred when it shouldn't have", i, err.Error())
+		}
+		harness := string(outputs[len(outputs)-1].Contents)
+		if outputs[len(outputs)-1].Name != "cbmc_main_m" {
+			t.Fatalf("Test %d: the last output was %s", i, outputs[len(outputs)-1].Name)
+		}
+		for _, want := range test.want {
+			if !strings.Contains(harness, want) {
+				t.Errorf("Test %d: harness doesn't have '%s':\n%s", i, want, harness)
+			}
+		}
+
+		//the harness is valid C (cbmc's builtins aren't, so they are left out)
+		if _, err := exec.LookPath("cc"); err != nil {
+			continue
+		}
+		dir, err := ioutil.TempDir("", "easy-rv-cbmc-test")
+		if err != nil {
+			t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+		}
+		defer os.RemoveAll(dir)
+		for _, out := range outputs {
+			ioutil.WriteFile(filepath.Join(dir, out.Name+"."+out.Extension), out.Contents, 0644)
+		}
+		for _, define := range []string{"-DCBMC_TICKS=3", "-DCBMC_ANY_STATE"} {
+			cmd := exec.Command("cc", "-c", define, "-D__CPROVER_assume(x)=(void)(x)", "-D__CPROVER_assert(x,y)=(void)(x)", "-o", "harness.o", "cbmc_main_m.c")
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Errorf("Test %d (%s): harness doesn't compile:\n%s", i, define, out)
+			}
+		}
+	}
+}
@@ -14,7 +14,20 @@ import (
 type Converter struct {
 	Funcs     []rvdef.Monitor
 	Language  string
+	Minimise  bool //if set, the bisimilar states of each policy are merged before converting (see rvdef.Monitor.MinimiseStates)
+	Coverage  bool //if set, the C counts how many times each transition is taken, and can export the counts (see ParseCoverage)
+	Tests     bool //if set, test traces are generated for each policy (see rvdef.Monitor.GenerateTests), as CSV and as a C test harness
+	CBMC      bool //if set, a harness for the CBMC model checker is generated for each function
+	ACSL      bool //if set, the C is annotated with ACSL contracts (for Frama-C) for each policy's run_monitor and check_rv_status functions
 	templates *template.Template
+
+	TestOptions rvdef.TestGenOptions //the limits used when generating test traces
+	CBMCOptions CBMCOptions          //what the CBMC harnesses check
+	Specs       []string             //with the smv language, CTL/LTL/invariant specs (e.g. "CTLSPEC AG(P_state != s_bad)") to append to each module
+
+	RemovedStates [][]int             //if Minimise is set, ConvertAll stores how many states were removed from each policy of each function here
+	TestSuites    [][]rvdef.TestSuite //if Tests is set, ConvertAll stores the test traces for each policy of each function here
+	Warnings      []string            //the problems that ConvertAll worked around, e.g. the policies that tests couldn't be generated for
 }
 
 //New returns a new instance of a Converter based on the provided language
@@ -22,6 +35,12 @@ func New(language string) (*Converter, error) {
 	switch strings.ToLower(language) {
 	case "c":
 		return &Converter{Funcs: make([]rvdef.Monitor, 0), Language: "c", templates: cTemplates}, nil
+	case "smv":
+		return &Converter{Funcs: make([]rvdef.Monitor, 0), Language: "smv", templates: smvTemplates}, nil
+	case "uppaal":
+		return &Converter{Funcs: make([]rvdef.Monitor, 0), Language: "uppaal", templates: uppaalTemplates}, nil
+	case "hoa":
+		return &Converter{Funcs: make([]rvdef.Monitor, 0), Language: "hoa", templates: hoaTemplates}, nil
 		//	case "verilog":
 		//		return &Converter{Funcs: make([]rvdef.Monitor, 0), Language: "verilog", templates: verilogTemplates}, nil
 	default:
@@ -52,21 +71,87 @@ type OutputFile struct {
 type TemplateData struct {
 	FunctionIndex int
 	Functions     []rvdef.Monitor
+	Coverage      bool
+	ACSL          bool
+	TestSuites    []rvdef.TestSuite //the test traces for each policy of the function (if they were asked for)
+	CBMC          CBMCHarness       //what the CBMC harness of the function checks (if one was asked for)
+	Specs         []string          //the specs to append to an SMV module
 }
 
 //ConvertAll converts iec61499 xml (stored as []FB) into vhdl []byte for each block (becomes []VHDLOutput struct)
 //Returns nil error on success
 func (c *Converter) ConvertAll() ([]OutputFile, error) {
 
-	//first, finalise the states
+	//first, make sure everything is valid
+	for i := 0; i < len(c.Funcs); i++ {
+		if err := c.Funcs[i].Validate(); err != nil {
+			return nil, errors.New("Monitor " + c.Funcs[i].Name + " is invalid: " + err.Error())
+		}
+	}
+
+	if c.Language == "smv" {
+		if err := validateSMVSpecs(c.Specs); err != nil {
+			return nil, err
+		}
+	}
+
+	//then, finalise the states
 	for i := 0; i < len(c.Funcs); i++ {
 		for j := 0; j < len(c.Funcs[i].Policies); j++ {
 			c.Funcs[i].Policies[j].FinaliseStates()
 		}
 	}
 
+	//then, merge any bisimilar states (if asked to)
+	if c.Minimise {
+		c.RemovedStates = make([][]int, len(c.Funcs))
+		for i := 0; i < len(c.Funcs); i++ {
+			c.RemovedStates[i] = c.Funcs[i].MinimiseStates()
+		}
+	}
+
 	finishedConversions := make([]OutputFile, 0, len(c.Funcs))
 
+	//then, generate the test traces (if asked to)
+	if c.Tests {
+		c.TestSuites = make([][]rvdef.TestSuite, len(c.Funcs))
+		for i := 0; i < len(c.Funcs); i++ {
+			for j := 0; j < len(c.Funcs[i].Policies); j++ {
+				//the rest of the conversion doesn't need the tests, so a policy that they can't be generated for is left out of them
+				suite, err := c.Funcs[i].GenerateTests(j, c.TestOptions)
+				if err != nil {
+					c.Warnings = append(c.Warnings, "Couldn't generate tests for monitor "+c.Funcs[i].Name+"'s policy "+c.Funcs[i].Policies[j].Name+" (it is left out of them): "+err.Error())
+					continue
+				}
+				c.TestSuites[i] = append(c.TestSuites[i], *suite)
+
+				output := &bytes.Buffer{}
+				if err := suite.WriteCSV(output); err != nil {
+					return nil, errors.New("Couldn't write tests for monitor " + c.Funcs[i].Name + ": " + err.Error())
+				}
+				finishedConversions = append(finishedConversions, OutputFile{Name: "test_" + c.Funcs[i].Name + "_" + suite.Policy, Extension: "csv", Contents: output.Bytes()})
+			}
+		}
+	}
+
+	//then, work out what the CBMC harnesses check (if asked to)
+	harnesses := make([]CBMCHarness, len(c.Funcs))
+	if c.CBMC {
+		used := make([]bool, len(c.CBMCOptions.Never))
+		for i := 0; i < len(c.Funcs); i++ {
+			harness, err := c.CBMCOptions.harness(c.Funcs[i], used)
+			if err != nil {
+				return nil, errors.New("Couldn't make the CBMC harness for monitor " + c.Funcs[i].Name + ": " + err.Error())
+			}
+			harnesses[i] = *harness
+		}
+		for i, never := range c.CBMCOptions.Never {
+			if !used[i] {
+				return nil, errors.New("Couldn't make the CBMC harnesses: there is no policy with a state " + never)
+			}
+		}
+	}
+
 	type templateInfo struct {
 		Prefix    string
 		Name      string
@@ -80,7 +165,34 @@ func (c *Converter) ConvertAll() ([]OutputFile, error) {
 		templates = []templateInfo{
 			{"F_", "functionC", "c"},
 			{"F_", "functionH", "h"},
-			//{"cbmc_main_", "mainCBMCC", "c"},
+		}
+		if c.Tests {
+			templates = append(templates, templateInfo{"test_", "testC", "c"})
+		}
+		if c.CBMC {
+			templates = append(templates, templateInfo{"cbmc_main_", "mainCBMCC", "c"})
+		}
+	}
+	if c.Language == "smv" {
+		templates = []templateInfo{
+			{"", "functionSMV", "smv"},
+		}
+	}
+	if c.Language == "uppaal" {
+		templates = []templateInfo{
+			{"uppaal_", "functionUPPAAL", "xml"},
+		}
+	}
+	if c.Language == "hoa" {
+		templates = []templateInfo{
+			{"", "functionHOA", "hoa"},
+		}
+		for i := 0; i < len(c.Funcs); i++ {
+			for j := 0; j < len(c.Funcs[i].Policies); j++ {
+				if internals := c.Funcs[i].GuardInternals(j); len(internals) > 0 {
+					c.Warnings = append(c.Warnings, "Monitor "+c.Funcs[i].Name+"'s policy "+c.Funcs[i].Policies[j].Name+" has guards that read the internals "+strings.Join(internals, ", ")+", whose comparisons can be true or false at any time in the HOA (as it has no assignments)")
+				}
+			}
 		}
 	}
 	// if c.Language == "verilog" {
@@ -92,7 +204,7 @@ func (c *Converter) ConvertAll() ([]OutputFile, error) {
 		for i := 0; i < len(c.Funcs); i++ {
 
 			output := &bytes.Buffer{}
-			if err := c.templates.ExecuteTemplate(output, template.Name, TemplateData{FunctionIndex: i, Functions: c.Funcs}); err != nil {
+			if err := c.templates.ExecuteTemplate(output, template.Name, TemplateData{FunctionIndex: i, Functions: c.Funcs, Coverage: c.Coverage, ACSL: c.ACSL, TestSuites: c.testSuites(i), CBMC: harnesses[i], Specs: c.Specs}); err != nil {
 				return nil, errors.New("Couldn't format template (fb) of" + c.Funcs[i].Name + ": " + err.Error())
 			}
 
@@ -102,3 +214,11 @@ func (c *Converter) ConvertAll() ([]OutputFile, error) {
 
 	return finishedConversions, nil
 }
+
+//testSuites returns the test traces for each policy of the function with index i (or nil if there aren't any)
+func (c *Converter) testSuites(i int) []rvdef.TestSuite {
+	if i < len(c.TestSuites) {
+		return c.TestSuites[i]
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package rvc
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//modelMonitor returns a monitor for the tests of the model checker outputs, with a policy P that has a dtimer v,
+// an internal n, a constant LAST, and the states s0 (whose initial condition goes to initialElse if it doesn't hold), s1 and bad
+func modelMonitor(initialElse string, transitions ...rvdef.PTransition) rvdef.Monitor {
+	return rvdef.Monitor{
+		Name:          "m",
+		Enums:         []rvdef.Enum{{Name: "Mode", Members: []string{"IDLE", "ON"}}},
+		InterfaceList: []rvdef.Variable{{Name: "A", Type: "bool"}, {Name: "mode", Type: "Mode"}, {Name: "h", Type: "uint8_t", ArraySize: "2"}},
+		Policies: []rvdef.Policy{{
+			Name: "P",
+			InternalVars: []rvdef.Variable{
+				{Name: "v", Type: "dtimer_t"},
+				{Name: "n", Type: "int8_t", InitialValue: "3"},
+				{Name: "LAST", Type: "uint8_t", Constant: true, InitialValue: "1"},
+			},
+			States:      []rvdef.PState{{Name: "s0", Accepting: true, Initial: true, InitialCondition: "mode = IDLE", InitialElse: initialElse}, {Name: "s1", Accepting: true}, {Name: "bad"}},
+			Transitions: transitions,
+		}},
+	}
+}
+
+func TestConvertTests(t *testing.T) {
+	mon := rvdef.Monitor{
+		Name:          "m",
+		InterfaceList: []rvdef.Variable{{Name: "A", Type: "bool"}},
+		Policies: []rvdef.Policy{{
+			Name:         "P",
+			InternalVars: []rvdef.Variable{{Name: "v", Type: "dtimer_t"}},
+			States:       []rvdef.PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "bad"}},
+			Transitions: []rvdef.PTransition{
+				{Source: "s0", Destination: "s0", Condition: "!A or v < 100"},
+				{Source: "s0", Destination: "bad", Else: true},
+			},
+		}},
+	}
+	monBytes, _ := xml.Marshal(mon)
+
+	conv, _ := New("c")
+	conv.Tests = true
+	if err := conv.AddFunction(monBytes); err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	outputs, err := conv.ConvertAll()
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+
+	files := make(map[string]string)
+	for _, out := range outputs {
+		files[out.Name+"."+out.Extension] = string(out.Contents)
+	}
+	if len(conv.TestSuites) != 1 || len(conv.TestSuites[0]) != 1 || conv.TestSuites[0][0].Coverage() != 100 {
+		t.Fatalf("Test suites were %v", conv.TestSuites)
+	}
+	if !strings.HasPrefix(files["test_m_P.csv"], "trace,tick,A,status\n") {
+		t.Errorf("CSV was:\n%s", files["test_m_P.csv"])
+	}
+
+	//the wait for the timer (until just before it gets near 100) is run as a loop
+	harness := files["test_m.c"]
+	for _, want := range []string{
+		"m_init_all_vars(&me, &io);",
+		"for(int tick = 1; tick <= 98; tick++) {",
+		"check_status(\"P\", 2, tick, m_check_rv_status_P(&me), 1);",
+		"check_status(\"P\", 2, 100, m_check_rv_status_P(&me), 3);",
+	} {
+		if !strings.Contains(harness, want) {
+			t.Errorf("Test harness doesn't have '%s':\n%s", want, harness)
+		}
+	}
+
+	//a policy that tests can't be generated for is left out of them, and the rest is still converted
+	conv, _ = New("c")
+	conv.Tests = true
+	conv.TestOptions.MaxInputs = 1
+	conv.Funcs = []rvdef.Monitor{mon}
+	outputs, err = conv.ConvertAll()
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	files = make(map[string]string)
+	for _, out := range outputs {
+		files[out.Name+"."+out.Extension] = string(out.Contents)
+	}
+	if files["F_m.c"] == "" || files["F_m.h"] == "" || files["test_m_P.csv"] != "" {
+		t.Errorf("Outputs were %v", outputs)
+	}
+	if len(conv.Warnings) != 1 || !strings.Contains(conv.Warnings[0], "Couldn't generate tests for monitor m's policy P") {
+		t.Errorf("Warnings were %v", conv.Warnings)
+	}
+}
+
+func TestConvertInternalArrays(t *testing.T) {
+	mon := rvdef.Monitor{
+		Name:          "m",
+		InterfaceList: []rvdef.Variable{{Name: "A", Type: "bool"}, {Name: "h", Type: "int16_t", ArraySize: "2"}},
+		Policies: []rvdef.Policy{{
+			Name: "P",
+			InternalVars: []rvdef.Variable{
+				{Name: "hist", Type: "int16_t", ArraySize: "2"},
+				{Name: "last", Type: "int16_t", ArraySize: "3", InitialValue: "[1,2]"},
+			},
+			States: []rvdef.PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "bad"}},
+			Transitions: []rvdef.PTransition{
+				{Source: "s0", Destination: "s0", Condition: "A", Expressions: []rvdef.PExpression{{VarName: "hist[1]", Value: "hist[0] + last[1]"}}},
+				{Source: "s0", Destination: "bad", Else: true},
+			},
+		}},
+	}
+	conv, _ := New("c")
+	conv.Funcs = []rvdef.Monitor{mon}
+	outputs, err := conv.ConvertAll()
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	files := make(map[string]string)
+	for _, out := range outputs {
+		files[out.Name+"."+out.Extension] = string(out.Contents)
+	}
+
+	//every element starts at 0, and then the ones with initial values are set
+	for _, want := range []string{
+		"for(int i = 0; i < 2; i++) {\n\t\tme->hist[i] = 0;\n\t}",
+		"for(int i = 0; i < 3; i++) {\n\t\tme->last[i] = 0;\n\t}\n\tme->last[0] = 1;\n\tme->last[1] = 2;",
+	} {
+		if !strings.Contains(files["F_m.c"], want) {
+			t.Errorf("F_m.c doesn't have '%s':\n%s", want, files["F_m.c"])
+		}
+	}
+	if strings.Contains(files["F_m.c"], "io->h") {
+		t.Errorf("An interface array without an initial value was initialised:\n%s", files["F_m.c"])
+	}
+
+	//the generated C compiles
+	if _, err := exec.LookPath("cc"); err != nil {
+		return
+	}
+	dir, err := ioutil.TempDir("", "easy-rv-c-test")
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	for _, out := range outputs {
+		ioutil.WriteFile(filepath.Join(dir, out.Name+"."+out.Extension), out.Contents, 0644)
+	}
+	cmd := exec.Command("cc", "-c", "-o", "F_m.o", "F_m.c")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("F_m.c doesn't compile:\n%s", out)
+	}
+}
+
+func TestConvertACSL(t *testing.T) {
+	mon := rvdef.Monitor{
+		Name:          "m",
+		InterfaceList: []rvdef.Variable{{Name: "A", Type: "bool"}},
+		Policies: []rvdef.Policy{{
+			Name: "P",
+			InternalVars: []rvdef.Variable{
+				{Name: "v", Type: "dtimer_t"},
+				{Name: "w", Type: "dtimer_t"},
+				{Name: "n", Type: "uint8_t", ArraySize: "2"},
+				{Name: "MAX", Type: "uint8_t", Constant: true, InitialValue: "5"},
+			},
+			States: []rvdef.PState{{Name: "s0", Accepting: true, Initial: true, InitialCondition: "!A", InitialElse: "bad"}, {Name: "bad"}},
+			Transitions: []rvdef.PTransition{
+				{Source: "s0", Destination: "s0", Condition: "!A or v < MAX", Expressions: []rvdef.PExpression{{VarName: "n[1]", Value: "n[1] + 1"}}},
+				{Source: "s0", Destination: "bad", Else: true, Expressions: []rvdef.PExpression{{VarName: "v", Value: "0"}}},
+			},
+		}},
+	}
+
+	for _, acsl := range []bool{false, true} {
+		conv, _ := New("c")
+		conv.Funcs = []rvdef.Monitor{mon}
+		conv.ACSL = acsl
+		outputs, err := conv.ConvertAll()
+		if err != nil {
+			t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+		}
+		files := make(map[string]string)
+		for _, out := range outputs {
+			files[out.Name+"."+out.Extension] = string(out.Contents)
+		}
+		for file, wants := range map[string][]string{
+			"F_m.h": {"predicate m_P_valid_state(monitorvars_m_t* me) =\n  @   POLICY_STATE_m_P_s0 <= me->_policy_P_state <= POLICY_STATE_m_P_bad;"},
+			"F_m.c": {
+				"@ requires P_v_no_overflow: me->v < 18446744073709551615;",
+				"@ requires P_w_no_overflow: me->w < 18446744073709551615;",
+				"@ assigns me->_policy_P_state, me->_policy_P_started, me->v, me->w, me->n[..];",
+				"@ ensures me->w == \\old(me->w) + 1;",
+				"@ behavior state_bad:\n  @   assumes me->_policy_P_state == POLICY_STATE_m_P_bad;\n  @   ensures \\result == 3; //rejecting and final",
+				"@ complete behaviors;",
+			},
+		} {
+			for _, want := range wants {
+				if strings.Contains(files[file], want) != acsl {
+					t.Errorf("With ACSL %v: %s has '%s' = %v", acsl, file, want, !acsl)
+				}
+			}
+		}
+		if strings.Contains(files["F_m.c"], "me->v == \\old(me->v)") {
+			t.Errorf("A dtimer that is assigned to is said to always count up")
+		}
+	}
+}
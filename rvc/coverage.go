@@ -0,0 +1,252 @@
+package rvc
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//CoverageLabel describes a transition in coverage dumps and reports, e.g. "s0 -> s1 on A and !B"
+func CoverageLabel(tr rvdef.PTransition) string {
+	if tr.Else {
+		return tr.Source + " -> " + tr.Destination + " else"
+	}
+	cond := strings.Join(strings.Fields(tr.Condition), " ")
+	if expr, err := rvdef.ParseSTExpression("", tr.Condition); err == nil {
+		cond = rvdef.FormatSTExpression(expr)
+	}
+	return tr.Source + " -> " + tr.Destination + " on " + cond
+}
+
+//CoverageCount is how many times one transition of a policy was taken, as written out by the coverage export function of the generated C
+type CoverageCount struct {
+	Monitor    string
+	Policy     string
+	Index      int    //the number of the transition in the policy
+	Transition string //what the transition is (see CoverageLabel)
+	rvdef.DebugInfo
+	Count uint64
+}
+
+//Location returns where the transition was defined, e.g. "ab5.erv:19"
+func (c CoverageCount) Location() string {
+	if c.SourceFile == "" {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s:%d", c.SourceFile, c.SourceLine)
+}
+
+//ParseCoverage reads the counts written out by the coverage export function of the generated C
+//(name is only used in error messages)
+func ParseCoverage(name string, r io.Reader) ([]CoverageCount, error) {
+	var counts []CoverageCount
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		fields := strings.SplitN(text, "\t", 7)
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("%s, line %d: expected 7 tab-separated fields, but there are %d", name, line, len(fields))
+		}
+		index, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s, line %d: bad transition number '%s'", name, line, fields[2])
+		}
+		count, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s, line %d: bad count '%s'", name, line, fields[3])
+		}
+		sourceLine, err := strconv.Atoi(fields[5])
+		if err != nil {
+			return nil, fmt.Errorf("%s, line %d: bad source line '%s'", name, line, fields[5])
+		}
+		counts = append(counts, CoverageCount{
+			Monitor:    fields[0],
+			Policy:     fields[1],
+			Index:      index,
+			Count:      count,
+			DebugInfo:  rvdef.DebugInfo{SourceFile: fields[4], SourceLine: sourceLine},
+			Transition: fields[6],
+		})
+	}
+	return counts, scanner.Err()
+}
+
+//MergeCoverage adds up the counts of each transition from several runs, and sorts them by monitor, policy, and transition number
+//It returns an error if a transition is described differently in two runs (i.e. they were using different versions of a monitor)
+func MergeCoverage(runs ...[]CoverageCount) ([]CoverageCount, error) {
+	var merged []CoverageCount
+	found := make(map[string]int)
+	for _, run := range runs {
+		for _, c := range run {
+			key := fmt.Sprintf("%s\t%s\t%d", c.Monitor, c.Policy, c.Index)
+			i, ok := found[key]
+			if !ok {
+				found[key] = len(merged)
+				merged = append(merged, c)
+				continue
+			}
+			if merged[i].Transition != c.Transition || merged[i].DebugInfo != c.DebugInfo {
+				return nil, fmt.Errorf("monitor %s's policy %s: transition %d is '%s' (%s) in one run and '%s' (%s) in another, so the runs used different versions of the monitor",
+					c.Monitor, c.Policy, c.Index, merged[i].Transition, merged[i].Location(), c.Transition, c.Location())
+			}
+			merged[i].Count += c.Count
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Monitor != merged[j].Monitor {
+			return merged[i].Monitor < merged[j].Monitor
+		}
+		if merged[i].Policy != merged[j].Policy {
+			return merged[i].Policy < merged[j].Policy
+		}
+		return merged[i].Index < merged[j].Index
+	})
+	return merged, nil
+}
+
+//PolicyCoverage is the coverage of the transitions of one policy
+type PolicyCoverage struct {
+	Monitor     string
+	Policy      string
+	Transitions []CoverageCount
+	Taken       int //how many of the transitions were taken at least once
+}
+
+//Percent returns the percentage of the transitions that were taken
+func (p PolicyCoverage) Percent() int {
+	if len(p.Transitions) == 0 {
+		return 100
+	}
+	return 100 * p.Taken / len(p.Transitions)
+}
+
+//GroupCoverage splits merged counts (see MergeCoverage) up by policy
+func GroupCoverage(counts []CoverageCount) []PolicyCoverage {
+	var pols []PolicyCoverage
+	for _, c := range counts {
+		if len(pols) == 0 || pols[len(pols)-1].Monitor != c.Monitor || pols[len(pols)-1].Policy != c.Policy {
+			pols = append(pols, PolicyCoverage{Monitor: c.Monitor, Policy: c.Policy})
+		}
+		pol := &pols[len(pols)-1]
+		pol.Transitions = append(pol.Transitions, c)
+		if c.Count > 0 {
+			pol.Taken++
+		}
+	}
+	return pols
+}
+
+//WriteCoverageText writes out a coverage report as text, listing how many times each transition was taken
+//For transitions that were never taken, the line that defined them is also shown if it is in sources (which maps file names to their lines).
+func WriteCoverageText(w io.Writer, counts []CoverageCount, sources map[string][]string) {
+	for _, pol := range GroupCoverage(counts) {
+		fmt.Fprintf(w, "Monitor %s, policy %s: %d of %d transition(s) taken (%d%%)\n", pol.Monitor, pol.Policy, pol.Taken, len(pol.Transitions), pol.Percent())
+		for _, c := range pol.Transitions {
+			if c.Count > 0 {
+				fmt.Fprintf(w, "\t%s: %s: taken %d time(s)\n", c.Location(), c.Transition, c.Count)
+				continue
+			}
+			fmt.Fprintf(w, "\t%s: %s: NEVER TAKEN\n", c.Location(), c.Transition)
+			if text, ok := sourceLine(sources, c.DebugInfo); ok {
+				fmt.Fprintf(w, "\t\t> %s\n", strings.TrimSpace(text))
+			}
+		}
+	}
+}
+
+//sourceLine returns the line of source that debug points to, if it is in sources
+func sourceLine(sources map[string][]string, debug rvdef.DebugInfo) (string, bool) {
+	lines, ok := sources[debug.SourceFile]
+	if !ok || debug.SourceLine < 1 || debug.SourceLine > len(lines) {
+		return "", false
+	}
+	return lines[debug.SourceLine-1], true
+}
+
+//coverageSourceLine is one line of a source file in the HTML report
+type coverageSourceLine struct {
+	Number int
+	Text   string
+	Counts []string //how many times each transition on this line was taken
+	Never  bool     //set if any transition on this line was never taken
+}
+
+//coverageSourceFile is a source file in the HTML report
+type coverageSourceFile struct {
+	Name  string
+	Lines []coverageSourceLine
+}
+
+//WriteCoverageHTML writes out a coverage report as a HTML page, with a summary of each policy,
+//and each source file in sources (which maps file names to their lines) with the transitions that were never taken highlighted
+func WriteCoverageHTML(w io.Writer, counts []CoverageCount, sources map[string][]string) error {
+	var files []coverageSourceFile
+	var names []string
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file := coverageSourceFile{Name: name}
+		for i, text := range sources[name] {
+			file.Lines = append(file.Lines, coverageSourceLine{Number: i + 1, Text: text})
+		}
+		for _, c := range counts {
+			if c.SourceFile != name || c.SourceLine < 1 || c.SourceLine > len(file.Lines) {
+				continue
+			}
+			line := &file.Lines[c.SourceLine-1]
+			line.Counts = append(line.Counts, strconv.FormatUint(c.Count, 10))
+			line.Never = line.Never || c.Count == 0
+		}
+		files = append(files, file)
+	}
+
+	return coverageHTMLTemplate.Execute(w, struct {
+		Policies []PolicyCoverage
+		Files    []coverageSourceFile
+	}{GroupCoverage(counts), files})
+}
+
+var coverageHTMLTemplate = template.Must(template.New("coverage").Funcs(template.FuncMap{"join": strings.Join}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Easy-rv transition coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { padding: 2px 8px; text-align: left; }
+.never { background: #f8c8c8; }
+.taken { background: #c8f0c8; }
+.source td { font-family: monospace; white-space: pre; padding: 0 8px; }
+.number { color: #888; text-align: right; }
+</style>
+</head>
+<body>
+<h1>Transition coverage</h1>
+{{range .Policies}}
+<h2>Monitor {{.Monitor}}, policy {{.Policy}}: {{.Taken}} of {{len .Transitions}} transition(s) taken ({{.Percent}}%)</h2>
+<table>
+<tr><th>Location</th><th>Transition</th><th>Times taken</th></tr>
+{{range .Transitions}}<tr class="{{if .Count}}taken{{else}}never{{end}}"><td>{{.Location}}</td><td>{{.Transition}}</td><td>{{if .Count}}{{.Count}}{{else}}never{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+{{range .Files}}
+<h2>{{.Name}}</h2>
+<table class="source">
+{{range .Lines}}<tr{{if .Counts}} class="{{if .Never}}never{{else}}taken{{end}}"{{end}}><td class="number">{{.Number}}</td><td class="number">{{join .Counts ", "}}</td><td>{{.Text}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,89 @@
+package rvc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+func TestCoverage(t *testing.T) {
+	run1 := "ab5\tAB5\t0\t6\tab5.erv\t17\ts0 -> s0 on !A and !B\n" +
+		"ab5\tAB5\t1\t0\tab5.erv\t20\ts0 -> s1 on A and !B\n"
+	run2 := "ab5\tAB5\t1\t0\tab5.erv\t20\ts0 -> s1 on A and !B\r\n" +
+		"ab5\tAB5\t0\t2\tab5.erv\t17\ts0 -> s0 on !A and !B\r\n" +
+		"\n"
+
+	c1, err := ParseCoverage("run1", strings.NewReader(run1))
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	c2, err := ParseCoverage("run2", strings.NewReader(run2))
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	merged, err := MergeCoverage(c1, c2)
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	if len(merged) != 2 || merged[0].Count != 8 || merged[1].Count != 0 || merged[1].SourceLine != 20 {
+		t.Errorf("Merged coverage was %v", merged)
+	}
+
+	out := &bytes.Buffer{}
+	WriteCoverageText(out, merged, map[string][]string{"ab5.erv": make([]string, 19), "other.erv": nil})
+	want := "Monitor ab5, policy AB5: 1 of 2 transition(s) taken (50%)\n" +
+		"\tab5.erv:17: s0 -> s0 on !A and !B: taken 8 time(s)\n" +
+		"\tab5.erv:20: s0 -> s1 on A and !B: NEVER TAKEN\n"
+	if out.String() != want {
+		t.Errorf("Text report was:\n%s\nit should have been:\n%s", out.String(), want)
+	}
+
+	//runs of different versions of the monitor can't be merged
+	changed, _ := ParseCoverage("run3", strings.NewReader("ab5\tAB5\t1\t0\tab5.erv\t21\ts0 -> s1 on A\n"))
+	if _, err := MergeCoverage(c1, changed); err == nil {
+		t.Errorf("Runs of different monitors were merged")
+	}
+
+	for _, bad := range []string{"ab5\tAB5\t0\t6\n", "ab5\tAB5\tx\t6\tab5.erv\t17\ts0 -> s0 on A\n", "ab5\tAB5\t0\t-1\tab5.erv\t17\ts0 -> s0 on A\n"} {
+		if _, err := ParseCoverage("bad", strings.NewReader(bad)); err == nil {
+			t.Errorf("'%s': Error didn't occur and it should have", bad)
+		}
+	}
+}
+
+func TestCoverageOutput(t *testing.T) {
+	mon := rvdef.Monitor{
+		Name:          "m",
+		InterfaceList: []rvdef.Variable{{Name: "A", Type: "bool"}},
+		Policies: []rvdef.Policy{{
+			Name:   "P",
+			States: []rvdef.PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "bad"}},
+			Transitions: []rvdef.PTransition{
+				{Source: "s0", Destination: "s0", Condition: "( A )", DebugInfo: rvdef.DebugInfo{SourceFile: "m \"1\".erv", SourceLine: 4}},
+				{Source: "s0", Destination: "bad", Else: true, DebugInfo: rvdef.DebugInfo{SourceFile: "m \"1\".erv", SourceLine: 5}},
+			},
+		}},
+	}
+	monBytes, _ := xml.Marshal(mon)
+
+	for _, coverage := range []bool{false, true} {
+		conv, _ := New("c")
+		conv.Coverage = coverage
+		if err := conv.AddFunction(monBytes); err != nil {
+			t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+		}
+		outputs, err := conv.ConvertAll()
+		if err != nil {
+			t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+		}
+		c := string(outputs[0].Contents)
+		for _, want := range []string{"me->_coverage_P[0]++;", "me->_coverage_P[1]++;", `"m \"1\".erv\t5\ts0 -> bad else"`, "void m_coverage_export("} {
+			if strings.Contains(c, want) != coverage {
+				t.Errorf("With coverage %v: output has '%s' = %v", coverage, want, !coverage)
+			}
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package rvc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//DiffTestOptions sets how DiffTest tests a Monitor (0 or "" means the default for each)
+type DiffTestOptions struct {
+	Compiler string //the C compiler to build the generated C with (default "cc")
+	Dir      string //the directory to build the generated C in (default a temporary directory, which is removed afterwards)
+	Traces   int    //how many random traces to run (default 100)
+	Length   int    //how many ticks each random trace has (default 100)
+	Seed     int64  //the seed for the random traces (the same seed gives the same traces)
+	Guided   bool   //if set, the traces made by rvdef.Monitor.GenerateTests for each policy are run as well
+}
+
+//DiffTestResult is what DiffTest found
+type DiffTestResult struct {
+	Traces   int           //how many traces were run
+	Ticks    int           //how many ticks they had between them
+	Mismatch *DiffMismatch //the first disagreement found (or nil if there wasn't one)
+
+	CutShort    int      //how many of the traces were cut short, before a tick that the reference couldn't evaluate
+	CutShortErr string   //why the first of them was cut short
+	Skipped     []string //the policies that GenerateTests couldn't make traces for (with Guided), so they only had random traces
+}
+
+//DiffMismatch is an input trace after which the generated C and the reference evaluation disagree about a policy
+type DiffMismatch struct {
+	Policy     string
+	Trace      []rvdef.Inputs //the inputs for each tick, up to the one after which they disagree
+	CState     string
+	CVerdict   int //see rvdef.VerdictName (or -1 if the C gave no status)
+	RefState   string
+	RefVerdict int
+	Found      int //how many ticks the trace had when the disagreement was found, before it was shortened
+}
+
+//String returns the mismatch and its trace, with a line for each tick (or for each run of ticks with the same inputs)
+func (m DiffMismatch) String() string {
+	s := fmt.Sprintf("Policy %s: after tick %d, the C is in state %s with status %d, but it should be in state %s with status %d\n",
+		m.Policy, len(m.Trace), m.CState, m.CVerdict, m.RefState, m.RefVerdict)
+	s += fmt.Sprintf("Trace (shortened from %d ticks):", m.Found)
+	for first := 0; first < len(m.Trace); {
+		in := m.Trace[first].String()
+		last := first
+		for last+1 < len(m.Trace) && m.Trace[last+1].String() == in {
+			last++
+		}
+		if first == last {
+			s += fmt.Sprintf("\n\t%d: %s", first+1, in)
+		} else {
+			s += fmt.Sprintf("\n\t%d-%d: %s", first+1, last+1, in)
+		}
+		first = last + 1
+	}
+	return s
+}
+
+//DiffTest runs input traces through the C generated for a Monitor (built with the system's C compiler), and through the reference
+//evaluation of the Monitor (rvdef.PolicySimulator), and compares the state and status of each policy after every tick
+//The traces are random (see rvdef.TraceGenerator), and can also include the ones made by GenerateTests. If the two disagree,
+//the first trace that they disagree on is shortened, by taking out as many ticks as can be while they still disagree.
+func DiffTest(mon rvdef.Monitor, opts DiffTestOptions) (*DiffTestResult, error) {
+	if opts.Compiler == "" {
+		opts.Compiler = "cc"
+	}
+	if opts.Traces <= 0 {
+		opts.Traces = 100
+	}
+	if opts.Length <= 0 {
+		opts.Length = 100
+	}
+	if err := mon.Validate(); err != nil {
+		return nil, errors.New("Monitor " + mon.Name + " is invalid: " + err.Error())
+	}
+
+	if opts.Dir == "" {
+		dir, err := ioutil.TempDir("", "easy-rv-fuzz")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		opts.Dir = dir
+	}
+	d, err := buildDiffTester(mon, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &DiffTestResult{}
+	var traces [][]rvdef.Inputs
+	if opts.Guided {
+		for i := range mon.Policies {
+			//policies with too many inputs to search through are left to the random traces
+			suite, err := mon.GenerateTests(i, rvdef.TestGenOptions{MaxStates: 100000})
+			if err != nil {
+				res.Skipped = append(res.Skipped, mon.Policies[i].Name)
+				continue
+			}
+			for _, tr := range suite.Traces {
+				traces = append(traces, tr.Inputs)
+			}
+		}
+	}
+	gen := mon.NewTraceGenerator(opts.Seed)
+	for i := 0; i < opts.Traces; i++ {
+		traces = append(traces, gen.Trace(opts.Length))
+	}
+
+	//the ticks that the reference can't evaluate (e.g. because of a division by zero) would be undefined behaviour in the C,
+	//so each trace stops before them
+	refs := make([][][]diffTick, len(traces))
+	cutErrs := make([]error, len(traces))
+	for i := range traces {
+		traces[i], refs[i], cutErrs[i] = d.reference(traces[i])
+	}
+	results, err := d.run(traces)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range traces {
+		res.Traces++
+		res.Ticks += len(traces[i])
+		if cutErrs[i] != nil {
+			if res.CutShort == 0 {
+				res.CutShortErr = fmt.Sprintf("tick %d: %s", len(traces[i])+1, cutErrs[i].Error())
+			}
+			res.CutShort++
+		}
+		if tick, _ := firstMismatch(refs[i], results[i]); tick != -1 {
+			res.Mismatch, err = d.shorten(traces[i][:tick+1])
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+//diffTick is the state and status of a policy after a tick
+type diffTick struct {
+	state   string
+	verdict int
+}
+
+//diffTester holds a Monitor, and the path of the program that runs its generated C (see the "fuzzC" template)
+type diffTester struct {
+	mon    rvdef.Monitor
+	sims   []*rvdef.PolicySimulator //the reference evaluation of each policy, before the first tick
+	leaves []rvdef.Variable
+	driver string
+}
+
+//buildDiffTester generates the C for a Monitor, and builds it (along with a driver program) in opts.Dir
+func buildDiffTester(mon rvdef.Monitor, opts DiffTestOptions) (*diffTester, error) {
+	//the converter finalises the states of the policies, so it gets its own copy of them
+	mon.Policies = append([]rvdef.Policy(nil), mon.Policies...)
+	for i := range mon.Policies {
+		mon.Policies[i].States = append([]rvdef.PState(nil), mon.Policies[i].States...)
+	}
+	conv, err := New("c")
+	if err != nil {
+		return nil, err
+	}
+	conv.Funcs = append(conv.Funcs, mon)
+	outputs, err := conv.ConvertAll()
+	if err != nil {
+		return nil, err
+	}
+	driver := &bytes.Buffer{}
+	if err := conv.templates.ExecuteTemplate(driver, "fuzzC", TemplateData{Functions: conv.Funcs}); err != nil {
+		return nil, errors.New("Couldn't format template (fuzz) of " + mon.Name + ": " + err.Error())
+	}
+	outputs = append(outputs, OutputFile{Name: "fuzz_" + mon.Name, Extension: "c", Contents: driver.Bytes()})
+	for _, out := range outputs {
+		if err := ioutil.WriteFile(filepath.Join(opts.Dir, out.Name+"."+out.Extension), out.Contents, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &diffTester{mon: conv.Funcs[0], leaves: mon.InterfaceLeaves(), driver: filepath.Join(opts.Dir, "fuzz_"+mon.Name)}
+	if runtime.GOOS == "windows" {
+		d.driver += ".exe"
+	}
+	for i := range d.mon.Policies {
+		sim, err := d.mon.NewPolicySimulator(i)
+		if err != nil {
+			return nil, fmt.Errorf("Policy %s can't be evaluated: %s", d.mon.Policies[i].Name, err.Error())
+		}
+		d.sims = append(d.sims, sim)
+	}
+	cmd := exec.Command(opts.Compiler, "-o", d.driver, "fuzz_"+mon.Name+".c", "F_"+mon.Name+".c", "-lm")
+	cmd.Dir = opts.Dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("the generated C doesn't compile (%s):\n%s", err.Error(), out)
+	}
+	return d, nil
+}
+
+//reference runs a trace through the reference evaluation, and returns the state and status of each policy after each tick
+//If a tick can't be evaluated, the trace is cut short before it, and the reason is returned.
+func (d *diffTester) reference(trace []rvdef.Inputs) ([]rvdef.Inputs, [][]diffTick, error) {
+	var sims []*rvdef.PolicySimulator
+	for _, sim := range d.sims {
+		sims = append(sims, sim.Copy())
+	}
+	var ticks [][]diffTick
+	for t, in := range trace {
+		var tick []diffTick
+		for _, sim := range sims {
+			if _, err := sim.Step(in); err != nil {
+				return trace[:t], ticks, fmt.Errorf("Policy %s: %s", sim.Policy().Name, err.Error())
+			}
+			tick = append(tick, diffTick{sim.State, sim.Verdict()})
+		}
+		ticks = append(ticks, tick)
+	}
+	return trace, ticks, nil
+}
+
+//run runs the traces through the generated C, and returns the state and status of each policy after each tick of each of them
+//If the C stops early (e.g. because it crashed), the ticks that it didn't get to have a verdict of -1.
+func (d *diffTester) run(traces [][]rvdef.Inputs) ([][][]diffTick, error) {
+	input := &bytes.Buffer{}
+	for _, trace := range traces {
+		input.WriteString("r\n")
+		for _, in := range trace {
+			input.WriteString("t")
+			for _, leaf := range d.leaves {
+				input.WriteString(" " + cInputValue(in[leaf.Name]))
+			}
+			input.WriteString("\n")
+		}
+	}
+	cmd := exec.Command(d.driver)
+	cmd.Stdin = input
+	out, err := cmd.Output()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return nil, err
+	}
+
+	lines := bufio.NewScanner(bytes.NewReader(out))
+	results := make([][][]diffTick, len(traces))
+	for i, trace := range traces {
+		for range trace {
+			var tick []diffTick
+			var fields []string
+			if lines.Scan() {
+				fields = strings.Fields(lines.Text())
+			}
+			for p, pol := range d.mon.Policies {
+				result := diffTick{"(none)", -1}
+				if len(fields) == 2*len(d.mon.Policies) {
+					state, _ := strconv.Atoi(fields[2*p])
+					result.verdict, _ = strconv.Atoi(fields[2*p+1])
+					result.state = fmt.Sprintf("(number %d)", state)
+					if state >= 0 && state < len(pol.States) {
+						result.state = pol.States[state].Name
+					}
+				}
+				tick = append(tick, result)
+			}
+			results[i] = append(results[i], tick)
+		}
+	}
+	return results, nil
+}
+
+//cInputValue returns a value as the driver program reads it
+func cInputValue(v rvdef.Value) string {
+	if v.Kind == rvdef.ValueFloat {
+		return strconv.FormatFloat(v.Float, 'g', -1, 64)
+	}
+	return strconv.FormatInt(v.Int, 10)
+}
+
+//firstMismatch returns the first tick (and the policy) where the reference and the C disagree, or -1 if they don't
+func firstMismatch(ref [][]diffTick, c [][]diffTick) (int, int) {
+	for t := range ref {
+		for p := range ref[t] {
+			if t >= len(c) || p >= len(c[t]) || ref[t][p] != c[t][p] {
+				return t, p
+			}
+		}
+	}
+	return -1, -1
+}
+
+//shorten takes ticks out of a trace that the reference and the C disagree at the end of, for as long as they still disagree
+//Like delta debugging, it first tries taking out large chunks of the trace, and then smaller and smaller ones.
+func (d *diffTester) shorten(trace []rvdef.Inputs) (*DiffMismatch, error) {
+	found := len(trace)
+	for chunk := len(trace) / 2; chunk > 0; {
+		var candidates [][]rvdef.Inputs
+		var refs [][][]diffTick
+		for start := 0; start < len(trace); start += chunk {
+			end := start + chunk
+			if end > len(trace) {
+				end = len(trace)
+			}
+			candidate := append(append([]rvdef.Inputs(nil), trace[:start]...), trace[end:]...)
+			candidate, ref, _ := d.reference(candidate)
+			candidates = append(candidates, candidate)
+			refs = append(refs, ref)
+		}
+		results, err := d.run(candidates)
+		if err != nil {
+			return nil, err
+		}
+		shorter := false
+		for i := range candidates {
+			if tick, _ := firstMismatch(refs[i], results[i]); tick != -1 {
+				trace = candidates[i][:tick+1]
+				shorter = true
+				break
+			}
+		}
+		if !shorter || chunk > len(trace) {
+			chunk /= 2
+		}
+	}
+
+	trace, ref, _ := d.reference(trace)
+	results, err := d.run([][]rvdef.Inputs{trace})
+	if err != nil {
+		return nil, err
+	}
+	tick, p := firstMismatch(ref, results[0])
+	if tick == -1 {
+		return nil, errors.New("the generated C and the reference stopped disagreeing while the trace was being shortened")
+	}
+	return &DiffMismatch{
+		Policy:     d.mon.Policies[p].Name,
+		Trace:      trace[:tick+1],
+		CState:     results[0][tick][p].state,
+		CVerdict:   results[0][tick][p].verdict,
+		RefState:   ref[tick][p].state,
+		RefVerdict: ref[tick][p].verdict,
+		Found:      found,
+	}, nil
+}
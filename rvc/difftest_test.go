@@ -0,0 +1,102 @@
+package rvc
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//deadlineMonitor returns a monitor where A must be followed by B before the deadline
+func deadlineMonitor(deadline string) rvdef.Monitor {
+	return rvdef.Monitor{
+		Name:          "m",
+		InterfaceList: []rvdef.Variable{{Name: "A", Type: "bool", InitialValue: "true"}, {Name: "B", Type: "bool"}, {Name: "h", Type: "int16_t", ArraySize: "2", InitialValue: "[1,2]"}},
+		Policies: []rvdef.Policy{{
+			Name:         "P",
+			InternalVars: []rvdef.Variable{{Name: "v", Type: "dtimer_t"}},
+			States:       []rvdef.PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "s1"}, {Name: "violation"}},
+			Transitions: []rvdef.PTransition{
+				{Source: "s0", Destination: "s1", Condition: "A and !B and h[1] > 2", Expressions: []rvdef.PExpression{{VarName: "v", Value: "0"}}},
+				{Source: "s0", Destination: "s0", Else: true},
+				{Source: "s1", Destination: "s0", Condition: "B"},
+				{Source: "s1", Destination: "s1", Condition: "v < " + deadline},
+				{Source: "s1", Destination: "violation", Else: true},
+			},
+		}},
+	}
+}
+
+func TestDiffTest(t *testing.T) {
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("There is no C compiler")
+	}
+
+	//the interface has initial values, which the generated C has to set up properly to compile
+	res, err := DiffTest(deadlineMonitor("5"), DiffTestOptions{Traces: 20, Guided: true})
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	if res.Mismatch != nil || res.Traces < 20 || res.Ticks < 20*100 {
+		t.Errorf("Result was %+v", res)
+	}
+
+	//a reference with a shorter deadline disagrees with the C, and the shortest trace that shows it waits until the deadline
+	dir, err := ioutil.TempDir("", "easy-rv-fuzz-test")
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	d, err := buildDiffTester(deadlineMonitor("5"), DiffTestOptions{Compiler: "cc", Dir: dir})
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	ref, err := deadlineMonitor("3").NewPolicySimulator(0)
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	d.mon, d.sims = deadlineMonitor("3"), []*rvdef.PolicySimulator{ref}
+	b := rvdef.Inputs{"B": rvdef.BoolValue(true)}
+	a := rvdef.Inputs{"A": rvdef.BoolValue(true), "h[1]": rvdef.IntValue(3)}
+	none := rvdef.Inputs{}
+	mismatch, err := d.shorten([]rvdef.Inputs{b, b, a, b, a, none, b, none, a, none, none, none})
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	if len(mismatch.Trace) != 4 || mismatch.Found != 12 || mismatch.CState != "s1" || mismatch.CVerdict != 2 || mismatch.RefState != "violation" || mismatch.RefVerdict != 3 {
+		t.Errorf("Mismatch was:\n%s", mismatch.String())
+	}
+}
+
+func TestDiffTestReport(t *testing.T) {
+	if _, err := exec.LookPath("cc"); err != nil {
+		t.Skip("There is no C compiler")
+	}
+
+	//there are too many inputs to generate tests for, and the reference can't divide by zero
+	mon := rvdef.Monitor{
+		Name:          "m",
+		InterfaceList: []rvdef.Variable{{Name: "bs", Type: "bool", ArraySize: "13"}, {Name: "x", Type: "int8_t"}},
+		Policies: []rvdef.Policy{{
+			Name:   "P",
+			States: []rvdef.PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "bad"}},
+			Transitions: []rvdef.PTransition{
+				{Source: "s0", Destination: "s0", Condition: "10 / x > 1 or bs[0] or bs[12]"},
+				{Source: "s0", Destination: "bad", Else: true},
+			},
+		}},
+	}
+	res, err := DiffTest(mon, DiffTestOptions{Traces: 20, Guided: true})
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	if res.Mismatch != nil || res.Traces != 20 || res.CutShort == 0 || !strings.Contains(res.CutShortErr, "Policy P: transition s0 -> s0: division by zero") {
+		t.Errorf("Result was %+v", res)
+	}
+	if len(res.Skipped) != 1 || res.Skipped[0] != "P" {
+		t.Errorf("Skipped policies were %v", res.Skipped)
+	}
+}
@@ -0,0 +1,29 @@
+package rvc
+
+import (
+	"strings"
+	"text/template"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//each policy of the monitor is an automaton of the HOA stream
+const rvcHOATemplate = `{{define "functionHOA"}}{{getHOA (index .Functions .FunctionIndex)}}{{end}}
+`
+
+//getHOA writes out every policy of a monitor in the Hanoi Omega-Automata format (see rvdef.Monitor.WriteHOA)
+func getHOA(f rvdef.Monitor) (string, error) {
+	var b strings.Builder
+	for i := range f.Policies {
+		if err := f.WriteHOA(&b, i); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+var hoaTemplateFuncMap = template.FuncMap{
+	"getHOA": getHOA,
+}
+
+var hoaTemplates = template.Must(template.New("").Funcs(hoaTemplateFuncMap).Parse(rvcHOATemplate))
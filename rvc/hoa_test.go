@@ -0,0 +1,58 @@
+package rvc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+func TestConvertHOA(t *testing.T) {
+	mon := rvdef.Monitor{
+		Name:          "m",
+		InterfaceList: []rvdef.Variable{{Name: "A", Type: "bool"}},
+		Policies: []rvdef.Policy{
+			{Name: "P", States: []rvdef.PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "bad"}}, Transitions: []rvdef.PTransition{{Source: "s0", Destination: "bad", Condition: "A"}}},
+			{Name: "Q", States: []rvdef.PState{{Name: "s0", Accepting: true, Initial: true}}},
+			{
+				Name:         "R",
+				InternalVars: []rvdef.Variable{{Name: "v", Type: "dtimer_t"}, {Name: "K", Type: "dtimer_t", Constant: true, InitialValue: "5"}},
+				States:       []rvdef.PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "bad"}},
+				Transitions:  []rvdef.PTransition{{Source: "s0", Destination: "bad", Condition: "A and v > K"}},
+			},
+		},
+	}
+
+	conv, err := New("hoa")
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	conv.Funcs = []rvdef.Monitor{mon}
+	outputs, err := conv.ConvertAll()
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	if len(outputs) != 1 || outputs[0].Name != "m" || outputs[0].Extension != "hoa" {
+		t.Fatalf("Outputs were %+v", outputs)
+	}
+
+	//each policy is an automaton of the stream
+	hoa := string(outputs[0].Contents)
+	for _, want := range []string{
+		"HOA: v1\nname: \"m.P\"\n",
+		"State: 0 \"s0\" {0}\n[0] 1\n[!0] 0\nState: 1 \"bad\"\n[t] 1\n--END--\n",
+		"HOA: v1\nname: \"m.Q\"\n",
+	} {
+		if !strings.Contains(hoa, want) {
+			t.Errorf("HOA doesn't have '%s':\n%s", want, hoa)
+		}
+	}
+	if n := strings.Count(hoa, "--END--"); n != 3 {
+		t.Errorf("HOA has %d automata, it should have 3", n)
+	}
+
+	//the guards of R read an internal, which the HOA can't keep track of
+	if len(conv.Warnings) != 1 || !strings.Contains(conv.Warnings[0], "policy R has guards that read the internals v,") {
+		t.Errorf("Warnings were %v", conv.Warnings)
+	}
+}
@@ -13,9 +13,38 @@ import (
 var (
 	inFileName  = flag.String("i", "", "Specifies the name of the source xml file to be compiled.")
 	outLocation = flag.String("o", "", "Specifies the name of the directory to put output files. If blank, uses current directory")
-	language    = flag.String("l", "c", "The output language")
+	language    = flag.String("l", "c", "The output language (c, smv for a NuSMV model, uppaal for an UPPAAL model, or hoa for a HOA automaton of each policy)")
+	minimise    = flag.Bool("minimise", false, "Set this to true to merge equivalent (bisimilar) states in each policy, which can make the output smaller")
+	coverage    = flag.Bool("coverage", false, "Set this to true to count how many times each transition is taken, and add a function to export the counts for easy-rv-coverage")
+	tests       = flag.Bool("tests", false, "Set this to true to also generate test traces that take every transition of each policy, as CSV files and a self-checking C test harness")
+	testDepth   = flag.Int("testdepth", 0, "The longest test trace to try, with -tests (0 for no limit).")
+	testInputs  = flag.Int("testinputs", 0, "The most different inputs to try on each tick, with -tests (0 for the default of 4096).")
+	cbmc        = flag.Bool("cbmc", false, "Set this to true to also generate a harness for the CBMC model checker (cbmc_main_<monitor>.c)")
+	acsl        = flag.Bool("acsl", false, "Set this to true to annotate the C with ACSL contracts, so that it can be checked with Frama-C")
+	cbmcTicks   = flag.Int("cbmcticks", 10, "How many ticks the CBMC harness runs the monitor for, with -cbmc.")
+	assumptions stringList
+	never       stringList
+	specs       stringList
 )
 
+//stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func init() {
+	flag.Var(&assumptions, "assume", "A guard over the interface that the CBMC harness assumes holds on every tick, with -cbmc (can be given more than once).")
+	flag.Var(&never, "never", "A state (written as policy.state) that the CBMC harness checks is never reached, with -cbmc (can be given more than once).")
+	flag.Var(&specs, "spec", "A CTLSPEC, LTLSPEC or INVARSPEC to append to the SMV module, with -l=smv (can be given more than once).")
+}
+
 func main() {
 	flag.Parse()
 
@@ -89,12 +118,45 @@ func main() {
 		return
 	}
 
+	conv.Minimise = *minimise
+	conv.Coverage = *coverage
+	conv.Tests = *tests
+	conv.TestOptions.MaxDepth = *testDepth
+	conv.TestOptions.MaxInputs = *testInputs
+	conv.CBMC = *cbmc
+	conv.ACSL = *acsl
+	conv.CBMCOptions = rvc.CBMCOptions{Ticks: *cbmcTicks, Assumptions: assumptions, Never: never}
+	conv.Specs = specs
 	outputs, err := conv.ConvertAll()
 	if err != nil {
 		fmt.Println("Error during conversion:", err.Error())
 		return
 	}
 
+	for _, warning := range conv.Warnings {
+		fmt.Println("Warning:", warning)
+	}
+
+	for i, removed := range conv.RemovedStates {
+		for j, n := range removed {
+			fun := conv.Funcs[i]
+			fmt.Printf("Minimised %s's policy %s: removed %d state(s), %d left\n", fun.Name, fun.Policies[j].Name, n, len(fun.Policies[j].States))
+		}
+	}
+
+	for i, suites := range conv.TestSuites {
+		for _, suite := range suites {
+			fmt.Printf("Generated %d test trace(s) for %s's policy %s, covering %d%% of its states and transitions\n", len(suite.Traces), conv.Funcs[i].Name, suite.Policy, suite.Coverage())
+			if len(suite.Uncovered) > 0 {
+				reason := "no trace was found for them, so they are probably unreachable"
+				if !suite.Complete {
+					reason = "the search was stopped early"
+				}
+				fmt.Printf("\tNot covered (%s): %s\n", reason, strings.Join(suite.Uncovered, "; "))
+			}
+		}
+	}
+
 	for _, output := range outputs {
 		fmt.Printf("Writing %s.%s\n", output.Name, output.Extension)
 
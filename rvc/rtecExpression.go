@@ -0,0 +1,216 @@
+package rvc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//cTypes are the types that can be used as casts in expressions, e.g. float(t)
+//(enum types of the monitor can be used too)
+var cTypes = []string{"bool", "char", "int8_t", "int16_t", "int32_t", "int64_t", "uint8_t", "uint16_t", "uint32_t", "uint64_t", "float", "double", "dtimer_t"}
+
+//cFunctions are the C library functions that can be called in expressions
+var cFunctions = []string{"abs", "labs", "fabs", "sqrt", "pow", "floor", "ceil", "round", "fmin", "fmax", "exp", "log", "sin", "cos", "tan"}
+
+//cOperators maps binary stcompilerlib operator tokens to their C equivalents
+var cOperators = map[string]string{
+	"*":   "*",
+	"/":   "/",
+	"MOD": "%",
+	"+":   "+",
+	"-":   "-",
+	"<":   "<",
+	">":   ">",
+	"<=":  "<=",
+	">=":  ">=",
+	"=":   "==",
+	"<>":  "!=",
+	"and": "&&",
+	"xor": "^",
+	"or":  "||",
+}
+
+//cSymbols is a symbol table of all names that can be used in the expressions of a policy, mapped to the C that they are emitted as
+type cSymbols map[string]string
+
+//newCSymbols makes the symbol table for the policy with index policyIndex of the monitor function
+//(I/O hides any internals of the same name, in the same way as rvdef.Monitor.GetVariable)
+func newCSymbols(function rvdef.Monitor, policyIndex int) cSymbols {
+	symbols := make(cSymbols)
+	for _, e := range function.Enums {
+		for _, member := range e.Members {
+			symbols[member] = member
+		}
+	}
+	if policyIndex >= 0 && policyIndex < len(function.Policies) {
+		pol := function.Policies[policyIndex]
+		for _, v := range pol.InternalVars {
+			if v.Constant {
+				symbols[v.Name] = "CONST_" + pol.Name + "_" + v.Name
+			} else {
+				symbols[v.Name] = "me->" + v.Name
+			}
+		}
+	}
+	for _, v := range function.InterfaceList {
+		symbols[v.Name] = "io->" + v.Name
+	}
+	return symbols
+}
+
+//cCompileExpression converts a stcompilerlib expression tree into fully parenthesised C,
+// using the symbols of the policy with index policyIndex of the monitor function
+//An error is returned if the expression contains something that can't be resolved
+func cCompileExpression(function rvdef.Monitor, policyIndex int, expr stcompilerlib.STExpression) (string, error) {
+	if expr == nil {
+		return "", fmt.Errorf("missing expression")
+	}
+	return newCSymbols(function, policyIndex).compile(function, expr)
+}
+
+//compile recursively converts expr into C
+func (symbols cSymbols) compile(function rvdef.Monitor, expr stcompilerlib.STExpression) (string, error) {
+	op := expr.HasOperator()
+	if op == nil {
+		return symbols.compileValue(function, expr.HasValue())
+	}
+
+	//arguments are in reverse order
+	var args []string
+	stArgs := expr.GetArguments()
+	for i := len(stArgs) - 1; i >= 0; i-- {
+		arg, err := symbols.compile(function, stArgs[i])
+		if err != nil {
+			return "", err
+		}
+		args = append(args, arg)
+	}
+
+	tok := op.GetToken()
+	switch {
+	case tok == "not":
+		return "(!" + args[0] + ")", nil
+	case tok == "`": //unary minus
+		return "(-" + args[0] + ")", nil
+	case tok == "**":
+		return "pow(" + args[0] + ", " + args[1] + ")", nil
+	case tok == ":=":
+		return args[0] + " = " + args[1], nil
+	case cOperators[tok] != "":
+		return "(" + args[0] + " " + cOperators[tok] + " " + args[1] + ")", nil
+	}
+
+	name := rvdef.FunctionName(tok)
+	if isCastType(function, name) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("cast to %s must have exactly one argument", name)
+		}
+		return "((" + name + ")" + args[0] + ")", nil
+	}
+	for _, fn := range cFunctions {
+		if fn == name {
+			return name + "(" + strings.Join(args, ", ") + ")", nil
+		}
+	}
+	return "", fmt.Errorf("unknown function or operator '%s'", name)
+}
+
+//compileValue converts a single value (a variable with an optional access path, or a literal) into C
+//Names are looked up before literals, so that a variable can't be mistaken for a number (e.g. an internal called nan).
+func (symbols cSymbols) compileValue(function rvdef.Monitor, val string) (string, error) {
+	root, path := rvdef.SplitAccessPath(val)
+	c, ok := symbols[root]
+	if !ok {
+		if strings.EqualFold(val, "true") || strings.EqualFold(val, "false") {
+			return strings.ToLower(val), nil
+		}
+		if isCNumber(val) {
+			return val, nil
+		}
+		if len(val) > 2 && strings.HasPrefix(val, "'") && strings.HasSuffix(val, "'") {
+			return val, nil //a char
+		}
+		return "", fmt.Errorf("unknown identifier '%s'", root)
+	}
+
+	//struct members stay as they are, but array indices are expressions too
+	rest, err := mapAccessPath(val, path, func(index string) (string, error) {
+		indexExpr, perr := rvdef.ParseSTExpression(root, index)
+		if perr != nil {
+			return "", fmt.Errorf("bad index in '%s': %s", val, perr.Error())
+		}
+		return symbols.compile(function, indexExpr)
+	})
+	if err != nil {
+		return "", err
+	}
+	return c + rest, nil
+}
+
+//isCNumber returns true if val is a number that is written the same way in C: a decimal or hex integer, or a decimal float
+//(so not one with digit separators, such as 1_000, or one of Go's spellings of infinity and NaN)
+func isCNumber(val string) bool {
+	if len(val) > 2 && (strings.HasPrefix(val, "0x") || strings.HasPrefix(val, "0X")) {
+		_, err := strconv.ParseUint(val[2:], 16, 64)
+		return err == nil
+	}
+	if val == "" || strings.Trim(val, "0123456789.eE+-") != "" || strings.IndexAny(val[:1], "0123456789.") == -1 {
+		return false
+	}
+	_, err := strconv.ParseFloat(val, 64)
+	return err == nil
+}
+
+//mapAccessPath returns the access path (e.g. ".temp[i + 1]", the part of val after its root) with each array index
+//replaced by what index returns for it
+func mapAccessPath(val string, path string, index func(string) (string, error)) (string, error) {
+	out := ""
+	for path != "" {
+		if path[0] == '.' {
+			end := strings.IndexAny(path[1:], ".[")
+			if end == -1 {
+				end = len(path) - 1
+			}
+			out += path[:end+1]
+			path = path[end+1:]
+			continue
+		}
+		//find the matching close bracket
+		depth, end := 0, -1
+		for i, ch := range path {
+			if ch == '[' {
+				depth++
+			} else if ch == ']' {
+				depth--
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end == -1 {
+			return "", fmt.Errorf("unmatched '[' in '%s'", val)
+		}
+		i, err := index(path[1:end])
+		if err != nil {
+			return "", err
+		}
+		out += "[" + i + "]"
+		path = path[end+1:]
+	}
+	return out, nil
+}
+
+//isCastType returns true if name is a type that values can be cast to
+func isCastType(function rvdef.Monitor, name string) bool {
+	for _, typ := range cTypes {
+		if typ == name {
+			return true
+		}
+	}
+	return function.GetEnum(name) != nil
+}
@@ -0,0 +1,83 @@
+package rvc
+
+import (
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+func TestCCompileExpression(t *testing.T) {
+	m := rvdef.Monitor{
+		Name:  "m",
+		Enums: []rvdef.Enum{{Name: "Mode", Members: []string{"IDLE", "RUN"}}},
+		InterfaceList: []rvdef.Variable{
+			{Name: "t", Type: "uint8_t"},
+			{Name: "a", Type: "bool"},
+			{Name: "mode", Type: "Mode"},
+			{Name: "sensors", Type: "int16_t", ArraySize: "4"},
+			{Name: "pkt", Type: "packet_t", Fields: []rvdef.Variable{{Name: "temp", Type: "int16_t"}}},
+		},
+		Policies: []rvdef.Policy{
+			{
+				Name: "P1",
+				InternalVars: []rvdef.Variable{
+					{Name: "v", Type: "dtimer_t"},
+					{Name: "K", Type: "uint8_t", Constant: true, InitialValue: "3"},
+				},
+			},
+			{
+				Name: "P2",
+				InternalVars: []rvdef.Variable{
+					{Name: "K", Type: "uint8_t", Constant: true, InitialValue: "5"},
+				},
+			},
+			{
+				Name:         "P3",
+				InternalVars: []rvdef.Variable{{Name: "nan", Type: "double"}},
+			},
+		},
+	}
+	tests := []struct {
+		Policy int
+		Input  string
+		Output string //empty if it should fail
+	}{
+		{0, "t > 5 AND a", "((io->t > 5) && io->a)"},
+		{0, "!a || t == 1", "((!io->a) || (io->t == 1))"},
+		{0, "v >= K", "(me->v >= CONST_P1_K)"},
+		{1, "t >= K", "(io->t >= CONST_P2_K)"},
+		{0, "mode = RUN", "(io->mode == RUN)"},
+		{0, "sensors[K - 1] < pkt.temp", "(io->sensors[(CONST_P1_K - 1)] < io->pkt.temp)"},
+		{0, "-t < -5", "((-io->t) < (-5))"},
+		{0, "float(t) / 2 > 1.5", "((((float)io->t) / 2) > 1.5)"},
+		{0, "t MOD 2 = 0 and TRUE", "(((io->t % 2) == 0) && true)"},
+		{0, "v := v + 1", "me->v = (me->v + 1)"},
+		{0, "t > 0x1F and t < 1e2", "((io->t > 0x1F) && (io->t < 1e2))"},
+		{2, "t < nan", "(io->t < me->nan)"},
+		{0, "t < nan", ""},
+		{0, "t < Inf", ""},
+		{0, "t > 1_000", ""},
+		{0, "ANDROID > 1", ""},
+		{0, "nope(t) > 1", ""},
+		{1, "v > 1", ""},
+		{0, "sensors[i] > 1", ""},
+	}
+	for _, test := range tests {
+		expr, perr := rvdef.ParseSTExpression("test", test.Input)
+		if perr != nil {
+			t.Fatalf("%s: can't parse: %s", test.Input, perr.Error())
+		}
+		out, err := cCompileExpression(m, test.Policy, expr)
+		if test.Output == "" {
+			if err == nil {
+				t.Errorf("%s: Error didn't occur and it should have (output was '%s')", test.Input, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Error '%s' occurred when it shouldn't have", test.Input, err.Error())
+		} else if out != test.Output {
+			t.Errorf("%s: Outputs don't match (it was '%s', should have been '%s')", test.Input, out, test.Output)
+		}
+	}
+}
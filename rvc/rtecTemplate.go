@@ -2,33 +2,59 @@ package rvc
 
 import (
 	"text/template"
-
-	"github.com/PRETgroup/stcompilerlib"
 )
 
-const rvcCTemplate = `{{define "_policyUpd"}}{{$block := .}}
+const rvcCTemplate = `{{define "_policyUpd"}}{{$block := index .Functions .FunctionIndex}}{{$coverage := .Coverage}}{{$acsl := .ACSL}}
 //output policies
 {{range $polI, $pol := $block.Policies}}{{$pfbMon := getPolicyMonInfo $block $polI}}
 //POLICY {{$pol.Name}} BEGIN
-//This will run the monitor for {{$block.Name}}'s policy {{$pol.Name}}
+//This will run the monitor for {{$block.Name}}'s policy {{$pol.Name}}{{if $acsl}}
+//A dtimer that wrapped around to 0 would restart its timeouts, so the contract requires callers to show that this can't happen
+//(e.g. by bounding how long the monitor runs for)
+/*@ requires \valid(me) && \valid_read(io) && \separated(me, io);
+  @ requires {{$block.Name}}_{{$pol.Name}}_valid_state(me);{{range $var := $pol.InternalVars}}{{if and $var.IsDTimer (not $var.Constant) (not $var.ArraySize)}}
+  @ requires {{$pol.Name}}_{{$var.Name}}_no_overflow: me->{{$var.Name}} < 18446744073709551615;{{end}}{{end}}
+  @ assigns {{range $i, $loc := getACSLAssigns $block $polI $coverage}}{{if $i}}, {{end}}{{$loc}}{{end}};
+  @ ensures {{$block.Name}}_{{$pol.Name}}_valid_state(me);{{range $var := getACSLCountingTimers $block $polI}}
+  @ ensures me->{{$var.Name}} == \old(me->{{$var.Name}}) + 1;{{end}}
+  @*/{{end}}
 void {{$block.Name}}_run_monitor_{{$pol.Name}}(monitorvars_{{$block.Name}}_t* me, io_{{$block.Name}}_t* io) {
 	//advance timers
 	{{range $varI, $var := $pfbMon.Policy.GetDTimers}}
 	me->{{$var.Name}}++;{{end}}
-
+	{{with $pol.GetInitialState}}{{if .InitialCondition}}
+	//check the initial condition on the first tick
+	if(!me->_policy_{{$pol.Name}}_started) {
+		me->_policy_{{$pol.Name}}_started = 1;
+		if(!({{$cond := getCECCTransitionCondition $block $polI $pfbMon.Policy.InitialGuard}}{{$cond.IfCond}})) {
+			//initial condition {{.InitialCondition}} doesn't hold
+			me->_policy_{{$pol.Name}}_state = POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{.InitialElse}};
+		}
+	}
+	{{end}}{{end}}
 	//select transition to advance state
 	switch(me->_policy_{{$pol.Name}}_state) {
 		{{range $sti, $st := $pol.States}}case POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{$st.Name}}:
-			{{range $tri, $tr := $pfbMon.Policy.Transitions}}{{if eq $tr.Source $st.Name}}{{/*
+			{{range $tri, $tr := $pfbMon.Policy.Transitions}}{{if and (eq $tr.Source $st.Name) (not $tr.Else)}}{{/*
 			*/}}
-			if({{$cond := getCECCTransitionCondition $block (compileExpression $tr.STGuard)}}{{$cond.IfCond}}) {
-				//transition {{$tr.Source}} -> {{$tr.Destination}} on {{$tr.Condition}}
+			if({{$cond := getCECCTransitionCondition $block $polI $tr.STGuard}}{{$cond.IfCond}}) {
+				//transition {{$tr.Source}} -> {{$tr.Destination}} on {{$tr.Condition}}{{if $coverage}}
+				me->_coverage_{{$pol.Name}}[{{$tri}}]++;{{end}}
 				me->_policy_{{$pol.Name}}_state = POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{$tr.Destination}};
 				//set expressions
-				{{range $exi, $ex := $tr.Expressions}}
-				me->{{$ex.VarName}} = {{$ex.Value}};{{end}}
+				{{range $exi, $ex := $tr.STExpressions}}
+				{{$ass := getCECCTransitionCondition $block $polI $ex}}{{$ass.IfCond}};{{end}}
 				break;
 			} {{end}}{{end}}
+			{{range $tri, $tr := $pfbMon.Policy.Transitions}}{{if and (eq $tr.Source $st.Name) $tr.Else}}
+			//transition {{$tr.Source}} -> {{$tr.Destination}} else (no other transition was taken){{if $coverage}}
+			me->_coverage_{{$pol.Name}}[{{$tri}}]++;{{end}}
+			me->_policy_{{$pol.Name}}_state = POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{$tr.Destination}};
+			//set expressions
+			{{range $exi, $ex := $tr.STExpressions}}
+			{{$ass := getCECCTransitionCondition $block $polI $ex}}{{$ass.IfCond}};{{end}}
+			break;
+			{{end}}{{end}}
 			
 			//ensure a transition was taken in this state
 			//assert(false && "{{$block.Name}}_{{$pol.Name}}_{{$st.Name}} must take a transition"); //if we are still here, then no transition was taken and we are no longer satisfying liveness
@@ -42,12 +68,15 @@ void {{$block.Name}}_run_monitor_{{$pol.Name}}(monitorvars_{{$block.Name}}_t* me
 //OUTPUT POLICY {{/* $pol.Name */}} END
 {{end}}
 
-{{define "functionH"}}{{$block := index .Functions .FunctionIndex}}{{$blocks := .Functions}}
+{{define "functionH"}}{{$block := index .Functions .FunctionIndex}}{{$blocks := .Functions}}{{$coverage := .Coverage}}{{$acsl := .ACSL}}
 //This file should be called F_{{$block.Name}}.h
 //This is autogenerated code. Edit by hand at your peril!
-
+{{if $coverage}}
+#include <stdio.h>{{end}}
 #include <stdint.h>
 #include <stdbool.h>
+#include <stdlib.h>
+#include <math.h>
 #include <assert.h>
 
 //the dtimer_t type
@@ -60,6 +89,23 @@ enum {{$block.Name}}_policy_{{$pol.Name}}_states { {{if len $pol.States}}{{range
 };
 {{end}}
 
+{{range $enumI, $enum := $block.Enums}}
+//enumerated type {{$enum.Name}}
+#ifndef ENUM_{{$enum.Name}}_DEFINED
+#define ENUM_{{$enum.Name}}_DEFINED
+typedef enum { {{range $memberI, $member := $enum.Members}}{{if $memberI}}, {{end}}{{$member}}{{end}} } {{$enum.Name}};
+#endif
+{{end}}
+{{range $structI, $struct := $block.GetStructTypes}}
+//record type {{$struct.Type}}
+#ifndef STRUCT_{{$struct.Type}}_DEFINED
+#define STRUCT_{{$struct.Type}}_DEFINED
+typedef struct {
+	{{range $fieldI, $field := $struct.Fields}}{{$field.Type}} {{$field.Name}}{{if $field.ArraySize}}[{{$field.ArraySize}}]{{end}};
+	{{end}}
+} {{$struct.Type}};
+#endif
+{{end}}
 //IO to the function {{$block.Name}}
 typedef struct {
 	{{range $index, $var := $block.InterfaceList}}{{$var.Type}} {{$var.Name}}{{if $var.ArraySize}}[{{$var.ArraySize}}]{{end}};
@@ -69,12 +115,19 @@ typedef struct {
 //monitor state and vars:
 typedef struct {
 	{{range $polI, $pol := $block.Policies}}enum {{$block.Name}}_policy_{{$pol.Name}}_states _policy_{{$pol.Name}}_state;
-	//internal vars
+	{{if $coverage}}{{$pfbMon := getPolicyMonInfo $block $polI}}{{if $pfbMon.Policy.Transitions}}uint64_t _coverage_{{$pol.Name}}[{{len $pfbMon.Policy.Transitions}}]; //how many times each transition has been taken
+	{{end}}{{end}}{{with $pol.GetInitialState}}{{if .InitialCondition}}uint8_t _policy_{{$pol.Name}}_started; //set once the initial condition has been checked
+	{{end}}{{end}}	//internal vars
 	{{range $vari, $var := $pol.InternalVars}}{{if not $var.Constant}}{{$var.Type}} {{$var.Name}}{{if $var.ArraySize}}[{{$var.ArraySize}}]{{end}};
 	{{end}}{{end}}
 	{{end}}
 } monitorvars_{{$block.Name}}_t;
-
+{{if $acsl}}
+//ACSL predicates (for Frama-C), which hold when each policy is in one of its states
+{{range $polI, $pol := $block.Policies}}/*@ predicate {{$block.Name}}_{{$pol.Name}}_valid_state(monitorvars_{{$block.Name}}_t* me) =
+  @   {{if $pol.States}}POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{(index $pol.States 0).Name}} <= me->_policy_{{$pol.Name}}_state <= POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{(index $pol.States (sub (len $pol.States) 1)).Name}}{{else}}me->_policy_{{$pol.Name}}_state == POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_unknown{{end}};
+  @*/
+{{end}}{{end}}
 {{range $polI, $pol := $block.Policies -}}
 {{range $varI, $var := $pol.InternalVars -}}
 {{if $var.Constant}}#define CONST_{{$pol.Name}}_{{$var.Name}} {{$var.InitialValue}}{{end}}
@@ -108,26 +161,41 @@ void {{$block.Name}}_run_monitor_{{$pol.Name}}(monitorvars_{{$block.Name}}_t* me
 //3: always false (unsafe)
 uint8_t {{$block.Name}}_check_rv_status_{{$pol.Name}}(monitorvars_{{$block.Name}}_t* me);
 
+{{end}}{{if $coverage}}
+//This function is provided in "F_{{$block.Name}}.c"
+//It writes out how many times each transition of each policy has been taken, for easy-rv-coverage
+//Each line is: monitor, policy, transition number, count, source file, source line, and transition (separated by tabs)
+void {{$block.Name}}_coverage_export(monitorvars_{{$block.Name}}_t* me, FILE* out);
+
 {{end}}
 {{end}}
 
-{{define "functionC"}}{{$block := index .Functions .FunctionIndex}}{{$blocks := .Functions}}
+{{define "functionC"}}{{$block := index .Functions .FunctionIndex}}{{$blocks := .Functions}}{{$coverage := .Coverage}}{{$acsl := .ACSL}}
 //This file should be called F_{{$block.Name}}.c
 //This is autogenerated code. Edit by hand at your peril!
 #include "F_{{$block.Name}}.h"
 
 void {{$block.Name}}_init_all_vars(monitorvars_{{$block.Name}}_t* me, io_{{$block.Name}}_t* io) {
 	//set any IO vars with default values
-	{{range $index, $var := $block.InterfaceList}}{{if $var.InitialValue}}{{$initialArray := $var.GetInitialArray}}{{if $initialArray}}{{range $initialIndex, $initialValue := $initialArray}}inputs->{{$var.Name}}[{{$initialIndex}}] = {{$initialValue}};
-	{{end}}{{else}}inputs->{{$var.Name}} = {{$var.InitialValue}};
-	{{end}}{{end}}{{end}}
+	{{range $index, $var := $block.InterfaceList}}{{if $var.ArraySize}}{{range $initialIndex, $initialValue := $var.GetInitialArray}}io->{{$var.Name}}[{{$initialIndex}}] = {{$initialValue}};
+	{{end}}{{else if $var.InitialValue}}io->{{$var.Name}} = {{$var.InitialValue}};
+	{{end}}{{end}}
 
 	{{if $block.Policies}}{{range $polI, $pol := $block.Policies}}
-	me->_policy_{{$pol.Name}}_state = {{if $pol.States}}POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{(index $pol.States 0).Name}}{{else}}POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_unknown{{end}};
+	me->_policy_{{$pol.Name}}_state = {{with $pol.GetInitialState}}POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{.Name}}{{else}}POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_unknown{{end}};
+	{{with $pol.GetInitialState}}{{if .InitialCondition}}me->_policy_{{$pol.Name}}_started = 0;
+	{{end}}{{end}}{{if $coverage}}{{$pfbMon := getPolicyMonInfo $block $polI}}{{if $pfbMon.Policy.Transitions}}for(int i = 0; i < {{len $pfbMon.Policy.Transitions}}; i++) {
+		me->_coverage_{{$pol.Name}}[i] = 0;
+	}
+	{{end}}{{end}}
 	//input policy internal vars
 	{{range $vari, $var := $pol.InternalVars}}{{if not $var.Constant}}
-	{{$initialArray := $var.GetInitialArray}}{{if $initialArray}}{{range $initialIndex, $initialValue := $initialArray}}me->{{$var.Name}}[{{$initialIndex}}] = {{$initialValue}};
-	{{end}}{{else}}me->{{$var.Name}} = {{if $var.InitialValue}}{{$var.InitialValue}}{{else}}0{{end}};
+	{{if $var.ArraySize}}for(int i = 0; i < {{$var.ArraySize}}; i++) {
+		me->{{$var.Name}}[i] = {{if $var.IsStruct}}({{$var.Type}}){0}{{else}}0{{end}};
+	}
+	{{range $initialIndex, $initialValue := $var.GetInitialArray}}me->{{$var.Name}}[{{$initialIndex}}] = {{$initialValue}};
+	{{end}}{{else if $var.IsStruct}}me->{{$var.Name}} = ({{$var.Type}}){0};
+	{{else}}me->{{$var.Name}} = {{if $var.InitialValue}}{{$var.InitialValue}}{{else}}0{{end}};
 	{{end}}{{end}}{{end}}
 	{{end}}{{end}}
 }
@@ -141,7 +209,7 @@ void {{$block.Name}}_run_via_monitor(monitorvars_{{$block.Name}}_t* me, io_{{$bl
 }
 
 
-{{if $block.Policies}}{{template "_policyUpd" $block}}{{end}}
+{{if $block.Policies}}{{template "_policyUpd" .}}{{end}}
 
 {{range $polI, $pol := $block.Policies}} {{$pfbMon := getPolicyMonInfo $block $polI}}
 //This function is provided in "F_{{$block.Name}}.c"
@@ -150,7 +218,17 @@ void {{$block.Name}}_run_via_monitor(monitorvars_{{$block.Name}}_t* me, io_{{$bl
 //0: always true (safe)
 //1: currently true (safe)
 //2: currently false (unsafe)
-//3: always false (unsafe)
+//3: always false (unsafe){{if $acsl}}
+/*@ requires \valid_read(me);
+  @ requires {{$block.Name}}_{{$pol.Name}}_valid_state(me);
+  @ assigns \nothing;
+  @ ensures 0 <= \result <= 3;{{range $sti, $st := $pol.States}}
+  @ behavior state_{{$st.Name}}:
+  @   assumes me->_policy_{{$pol.Name}}_state == POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{$st.Name}};
+  @   ensures \result == {{$st.Verdict}}; //{{if $st.Accepting}}accepting{{else}}rejecting{{end}}{{if $st.FinalStatusType}} and final{{end}}{{end}}{{if $pol.States}}
+  @ complete behaviors;
+  @ disjoint behaviors;{{end}}
+  @*/{{end}}
 uint8_t {{$block.Name}}_check_rv_status_{{$pol.Name}}(monitorvars_{{$block.Name}}_t* me) { 
 	switch(me->_policy_{{$pol.Name}}_state) { 
 		{{range $sti, $st := $pol.States}}case POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{$st.Name}}:
@@ -160,62 +238,181 @@ uint8_t {{$block.Name}}_check_rv_status_{{$pol.Name}}(monitorvars_{{$block.Name}
 		{{end}}
 	}
 }
+{{end}}{{if $coverage}}
+void {{$block.Name}}_coverage_export(monitorvars_{{$block.Name}}_t* me, FILE* out) {
+	{{range $polI, $pol := $block.Policies}}{{$pfbMon := getPolicyMonInfo $block $polI}}{{if $pfbMon.Policy.Transitions}}
+	//policy {{$pol.Name}}: where each transition was defined, and what it is
+	static const char* {{$pol.Name}}_transitions[] = { {{range $tri, $tr := $pfbMon.Policy.Transitions}}{{if $tri}},{{end}}
+		{{getCoverageLabel $tr}}{{end}}
+	};
+	for(int i = 0; i < {{len $pfbMon.Policy.Transitions}}; i++) {
+		fprintf(out, "%s\t%s\t%d\t%llu\t%s\n", "{{$block.Name}}", "{{$pol.Name}}", i, (unsigned long long)me->_coverage_{{$pol.Name}}[i], {{$pol.Name}}_transitions[i]);
+	}
+	{{end}}{{end}}
+}
 {{end}}
 {{end}}
-{{define "mainCBMCC"}}{{$block := index .Functions .FunctionIndex}}{{$blocks := .Functions}}
-//This file should be called cbmc_main_{{$block.Name}}.c
+{{define "testC"}}{{$block := index .Functions .FunctionIndex}}
+//This file should be called test_{{$block.Name}}.c
 //This is autogenerated code. Edit by hand at your peril!
 
-//It can be used with the cbmc model checker
-//Call it using the following command: 
-//$ cbmc cbmc_main_{{$block.Name}}.c{{range $blockI, $block := $blocks}} F_{{$block.Name}}.c{{end}}
+//It runs the test traces that were generated for each policy of {{$block.Name}}, and checks that each policy gives the expected statuses
+//Compile and run it using the following commands:
+//$ gcc test_{{$block.Name}}.c F_{{$block.Name}}.c -o test_{{$block.Name}}
+//$ ./test_{{$block.Name}}{{if .Coverage}}
+//The coverage of each trace is written to coverage_{{$block.Name}}.txt, which can be read by easy-rv-coverage{{end}}
 
-{{range $blockI, $block := $blocks}}
-#include "F_{{$block.Name}}.h"{{end}}
+#include "F_{{$block.Name}}.h"
 #include <stdio.h>
-#include <stdint.h>
-
-int main() {
+#include <string.h>
 
-{{range $blockI, $block := $blocks}}
-	//I/O and state for {{$block.Name}}
-	monitorvars_{{$block.Name}}_t enf_{{$block.Name}};
-    inputs_{{$block.Name}}_t inputs_{{$block.Name}};
-    outputs_{{$block.Name}}_t outputs_{{$block.Name}};
+//The controller does nothing, so the policies only see the test inputs
+void {{$block.Name}}_run(io_{{$block.Name}}_t* io) {
+}
 
-	//set values to known state
-    {{$block.Name}}_init_all_vars(&enf_{{$block.Name}}, &inputs_{{$block.Name}}, &outputs_{{$block.Name}});
+//check_status compares the status that a policy gave with the one it should have given, and counts the failures
+static int failures = 0;
+static void check_status(const char* policy, int trace, int tick, uint8_t status, uint8_t expected) {
+	if(status != expected) {
+		printf("FAILED: policy %s, trace %d, tick %d: status was %d, it should have been %d\n", policy, trace, tick, status, expected);
+		failures++;
+	}
+}
 
-	//introduce nondeterminism
-    //a nondet_xxxxx function name tells cbmc that it could be anything, but must be unique
-    //randomise inputs
-	{{range $inputI, $input := $block.InputVars -}}
-	inputs_{{$block.Name}}.{{$input.Name}} = nondet_{{$block.Name}}_input_{{$inputI}}();
+int main() {
+	monitorvars_{{$block.Name}}_t me;
+	io_{{$block.Name}}_t io;{{$coverage := .Coverage}}{{if $coverage}}
+	FILE* coverage = fopen("coverage_{{$block.Name}}.txt", "w");{{end}}
+	{{range $suiteI, $suite := .TestSuites}}
+	//policy {{$suite.Policy}}: {{len $suite.Traces}} trace(s), which cover {{$suite.Coverage}}% of its states and transitions
+	{{range $trI, $tr := $suite.Traces}}
+	//trace {{add $trI 1}}, which covers: {{range $i, $c := $tr.Covers}}{{if $i}}, {{end}}{{$c}}{{end}}
+	memset(&io, 0, sizeof(io));
+	{{$block.Name}}_init_all_vars(&me, &io);
+	{{range $run := getTestRuns $tr}}{{if eq $run.First $run.Last}}
+	memset(&io, 0, sizeof(io));
+	{{range $name := $run.Inputs.Names}}io.{{$name}} = {{index $run.Inputs $name}};
+	{{end}}{{$block.Name}}_run_via_monitor(&me, &io);
+	check_status("{{$suite.Policy}}", {{add $trI 1}}, {{$run.First}}, {{$block.Name}}_check_rv_status_{{$suite.Policy}}(&me), {{$run.Verdict}});
+	{{else}}
+	for(int tick = {{$run.First}}; tick <= {{$run.Last}}; tick++) {
+		memset(&io, 0, sizeof(io));
+		{{range $name := $run.Inputs.Names}}io.{{$name}} = {{index $run.Inputs $name}};
+		{{end}}{{$block.Name}}_run_via_monitor(&me, &io);
+		check_status("{{$suite.Policy}}", {{add $trI 1}}, tick, {{$block.Name}}_check_rv_status_{{$suite.Policy}}(&me), {{$run.Verdict}});
+	}
+	{{end}}{{end}}{{if $coverage}}{{$block.Name}}_coverage_export(&me, coverage);
+	{{end}}{{end}}{{end}}{{if $coverage}}
+	fclose(coverage);
 	{{end}}
+	if(failures > 0) {
+		printf("%d check(s) failed\n", failures);
+		return 1;
+	}
+	printf("All checks passed\n");
+	return 0;
+}
+{{end}}
+{{define "fuzzC"}}{{$block := index .Functions .FunctionIndex}}
+//This file should be called fuzz_{{$block.Name}}.c
+//This is autogenerated code. Edit by hand at your peril!
 
-	//randomise monitor state, i.e. clock values and position (excepting violation state)
-	{{range $polI, $pol := $block.Policies -}}
-	{{range $varI, $var := $pol.InternalVars -}}
-	{{if not $var.Constant}}enf_{{$block.Name}}.{{$var.Name}} = nondet_{{$block.Name}}_enf_{{$pol.Name}}_{{$varI}}();{{end}}
-	{{end}}
-	enf_{{$block.Name}}._policy_{{$pol.Name}}_state = nondet_{{$block.Name}}_enf_{{$pol.Name}}_state() % {{len $pol.States}};
-	{{end}}
+//It is used by DiffTest to run input traces through {{$block.Name}}
+//Each line of its input is either "r", which resets the monitor, or "t" followed by the value of each input for a tick
+//(in the order of rvdef.Monitor.InterfaceLeaves, with enums as numbers), and after each tick it prints the state and the
+//status of each policy
+
+#include "F_{{$block.Name}}.h"
+#include <stdio.h>
+#include <string.h>
+
+//The controller does nothing, so the policies only see the inputs that they are given
+void {{$block.Name}}_run(io_{{$block.Name}}_t* io) {
+}
 
-    //run the monitor (i.e. tell CBMC to check this out)
-	{{$block.Name}}_run_via_monitor(&enf_{{$block.Name}}, &inputs_{{$block.Name}}, &outputs_{{$block.Name}});
+int main() {
+	monitorvars_{{$block.Name}}_t me;
+	io_{{$block.Name}}_t io;
+	char command[2];
+	long long i;
+	double d;
+
+	memset(&io, 0, sizeof(io));
+	{{$block.Name}}_init_all_vars(&me, &io);
+	while(scanf("%1s", command) == 1) {
+		if(command[0] == 'r') {
+			memset(&io, 0, sizeof(io));
+			{{$block.Name}}_init_all_vars(&me, &io);
+			continue;
+		}
+		{{range $leaf := $block.InterfaceLeaves}}{{if $leaf.IsFloatType}}if(scanf("%lf", &d) != 1) return 1;
+		io.{{$leaf.Name}} = d;
+		{{else}}if(scanf("%lld", &i) != 1) return 1;
+		io.{{$leaf.Name}} = ({{$leaf.Type}})i;
+		{{end}}{{end}}{{$block.Name}}_run_via_monitor(&me, &io);
+		printf("{{range $polI, $pol := $block.Policies}}{{if $polI}} {{end}}%d %d{{end}}\n"{{range $pol := $block.Policies}}, (int)me._policy_{{$pol.Name}}_state, {{$block.Name}}_check_rv_status_{{$pol.Name}}(&me){{end}});
+	}
+	return 0;
+}
 {{end}}
+{{define "mainCBMCC"}}{{$block := index .Functions .FunctionIndex}}{{$harness := .CBMC}}
+//This file should be called cbmc_main_{{$block.Name}}.c
+//This is autogenerated code. Edit by hand at your peril!
+
+//It can be used with the cbmc model checker, to check {{$block.Name}} with any values on its interface
+//Call it using the following command:
+//$ cbmc cbmc_main_{{$block.Name}}.c F_{{$block.Name}}.c --unwind {{add $harness.Ticks 1}} --unwinding-assertions
+//The monitor is run for CBMC_TICKS ticks, which is {{$harness.Ticks}} unless it is set with -D CBMC_TICKS=n (--unwind must then be at least n+1).
+//It starts in its initial state, unless CBMC_ANY_STATE is defined (with -D CBMC_ANY_STATE), in which case each policy starts in
+//any state that can be reached from its initial state, with any values in its internal variables. This covers more than can
+//really happen, so what cbmc finds might not be possible from the initial state, but it isn't limited to the first CBMC_TICKS ticks.
+//On every tick, cbmc checks that each policy is in one of its states{{if $harness.Never}}, and that:{{range $n := $harness.Never}}
+//  policy {{$n.Policy}} is never in state {{$n.State}}{{end}}{{end}}{{if $harness.Assumptions}}
+//It assumes that these hold on every tick:{{range $a := $harness.Assumptions}}
+//  {{$a.Guard}}{{end}}{{end}}
+
+#include "F_{{$block.Name}}.h"
+
+#ifndef CBMC_TICKS
+#define CBMC_TICKS {{$harness.Ticks}}
+#endif
+
+//nondet_ functions have no body, which tells cbmc that they can return any value
+io_{{$block.Name}}_t nondet_io_{{$block.Name}}();
+monitorvars_{{$block.Name}}_t nondet_monitorvars_{{$block.Name}}();
+
+//The controller does nothing, so the policies see the interface values that cbmc picks
+void {{$block.Name}}_run(io_{{$block.Name}}_t* io) {
 }
 
-{{range $blockI, $block := $blocks}}
-void {{$block.Name}}_run(inputs_{{$block.Name}}_t *inputs, outputs_{{$block.Name}}_t *outputs) {
-    //randomise controller
+int main() {
+	monitorvars_{{$block.Name}}_t monitor;
+	io_{{$block.Name}}_t interface;
+	monitorvars_{{$block.Name}}_t* me = &monitor;
+	io_{{$block.Name}}_t* io = &interface;
+
+	{{$block.Name}}_init_all_vars(me, io);
+#ifdef CBMC_ANY_STATE
+	*me = nondet_monitorvars_{{$block.Name}}();
+	{{range $polI, $pol := $block.Policies}}{{if $pol.States}}__CPROVER_assume({{range $i, $st := index $harness.Reachable $polI}}{{if $i}} || {{end}}me->_policy_{{$pol.Name}}_state == POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{$st}}{{end}});
+	{{end}}{{end}}
+#endif
+
+	for(int tick = 0; tick < CBMC_TICKS; tick++) {
+		//any values on the interface (except that enums must be one of their members)
+		*io = nondet_io_{{$block.Name}}();
+		{{range $leaf := $block.InterfaceLeaves}}{{with $block.GetEnum $leaf.Type}}__CPROVER_assume((int)io->{{$leaf.Name}} >= 0 && (int)io->{{$leaf.Name}} < {{len .Members}});
+		{{end}}{{end}}{{range $a := $harness.Assumptions}}__CPROVER_assume({{$a.C}}); //{{$a.Guard}}
+		{{end}}
+		{{$block.Name}}_run_via_monitor(me, io);
 
-    {{range $outputI, $output := $block.OutputVars -}}
-	outputs->{{$output.Name}} = nondet_{{$block.Name}}_output_{{$outputI}}();
-	{{end}} 
+		{{range $polI, $pol := $block.Policies}}{{if $pol.States}}__CPROVER_assert((int)me->_policy_{{$pol.Name}}_state >= 0 && (int)me->_policy_{{$pol.Name}}_state < {{len $pol.States}}, "policy {{$pol.Name}} is in one of its states");
+		{{range $n := $harness.Never}}{{if eq $n.Policy $pol.Name}}__CPROVER_assert(me->_policy_{{$pol.Name}}_state != POLICY_STATE_{{$block.Name}}_{{$pol.Name}}_{{$n.State}}, "policy {{$pol.Name}} is never in state {{$n.State}}");
+		{{end}}{{end}}{{end}}{{end}}
+	}
+	return 0;
 }
 {{end}}
-{{end}}
 `
 
 var cTemplateFuncMap = template.FuncMap{
@@ -223,9 +420,17 @@ var cTemplateFuncMap = template.FuncMap{
 
 	"getPolicyMonInfo": getPolicyMonInfo,
 
-	"compileExpression": stcompilerlib.CCompileExpression,
+	"getCoverageLabel": getCoverageLabel,
+
+	"getACSLAssigns": getACSLAssigns,
+
+	"getACSLCountingTimers": getACSLCountingTimers,
 
 	"sub": sub,
+
+	"add": add,
+
+	"getTestRuns": getTestRuns,
 }
 
 var cTemplates = template.Must(template.New("").Funcs(cTemplateFuncMap).Parse(rvcCTemplate))
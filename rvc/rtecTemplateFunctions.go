@@ -2,10 +2,10 @@ package rvc
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 
 	"github.com/PRETgroup/easy-rv/rvdef"
+	"github.com/PRETgroup/stcompilerlib"
 )
 
 //CECCTransition is used with getCECCTransitionCondition to return results to the template
@@ -14,85 +14,115 @@ type CECCTransition struct {
 	AssEvents []string
 }
 
-//getCECCTransitionCondition returns the C "if" condition to use in state machine next state logic and associated events
-// returns "full condition", "associated events"
-func getCECCTransitionCondition(function rvdef.Monitor, trans string) CECCTransition {
-	var events []string
-
-	re1 := regexp.MustCompile("([<>=!]+)")          //for capturing operators
-	re2 := regexp.MustCompile("([a-zA-Z0-9_<>=]+)") //for capturing variable and event names and operators
-	isNum := regexp.MustCompile("^[0-9.]+$")
+//getCECCTransitionCondition returns the C for an expression (e.g. a guard or an assignment) of the policy with index policyIndex
+func getCECCTransitionCondition(function rvdef.Monitor, policyIndex int, expr stcompilerlib.STExpression) (CECCTransition, error) {
+	c, err := cCompileExpression(function, policyIndex, expr)
+	if err != nil {
+		return CECCTransition{}, fmt.Errorf("Policy %s: can't compile '%s': %s", function.Policies[policyIndex].Name, stcompilerlib.CCompileExpression(expr), err.Error())
+	}
+	return CECCTransition{IfCond: c}, nil
+}
 
-	retVal := trans
+//getPolicyMonInfo will get a PEnforcer for a given policy
+func getPolicyMonInfo(function rvdef.Monitor, policyIndex int) *rvdef.PMonitor {
+	pmon, err := rvdef.MakePMonitor(function.InterfaceList, function.Policies[policyIndex])
+	if err != nil {
+		fmt.Printf("ERROR: The policy %s is broken! Message: %s", function.Policies[policyIndex].Name, err.Error())
+		panic(err)
+	}
+	//Uncomment these two lines to generate the intermediate enforcer JSON file
+	//bytes, _ := json.MarshalIndent(enfPol, "", "\t")
+	//ioutil.WriteFile(function.Name+".json", bytes, 0644)
+	return pmon
+}
 
-	//rename AND and OR
-	retVal = strings.Replace(retVal, "AND", "&&", -1)
-	retVal = strings.Replace(retVal, "OR", "||", -1)
+//getCoverageLabel returns a C string literal saying where a transition was defined, and what it is,
+//in the format that the coverage export function writes out (i.e. source file, source line, and transition, separated by tabs)
+func getCoverageLabel(tr rvdef.PSTTransition) string {
+	return cStringLiteral(fmt.Sprintf("%s\t%d\t%s", tr.SourceFile, tr.SourceLine, CoverageLabel(tr.PTransition)))
+}
 
-	//re1: add whitespace around operators
-	retVal = re1.ReplaceAllStringFunc(retVal, func(in string) string {
-		if in != "!" {
-			return " " + in + " "
-		}
-		return " !"
-	})
+//cStringLiteral quotes s as a C string literal
+func cStringLiteral(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\t", `\t`, "\n", " ", "\r", " ").Replace(s) + `"`
+}
 
-	//re2: add "me->" where appropriate
-	retVal = re2.ReplaceAllStringFunc(retVal, func(in string) string {
-		if strings.ToLower(in) == "and" || strings.ToLower(in) == "or" || strings.ContainsAny(in, "!><=") || strings.ToLower(in) == "true" || strings.ToLower(in) == "false" {
-			//no need to make changes, these aren't variables or events
-			return in
+//getACSLAssigns returns the locations that the run_monitor function of the policy with index policyIndex can change, for its
+//ACSL assigns clause: the state, the dtimers, and the internal variables that transitions assign to (whole arrays and structs)
+func getACSLAssigns(function rvdef.Monitor, policyIndex int, coverage bool) []string {
+	pol := function.Policies[policyIndex]
+	assigns := []string{"me->_policy_" + pol.Name + "_state"}
+	if st := pol.GetInitialState(); st != nil && st.InitialCondition != "" {
+		assigns = append(assigns, "me->_policy_"+pol.Name+"_started")
+	}
+	assigned := acslAssignedVars(pol)
+	for _, v := range pol.InternalVars {
+		if v.Constant || !(v.IsDTimer() || assigned[v.Name]) {
+			continue
 		}
-
-		if isNum.MatchString(in) {
-			//no need to make changes, it is a numerical value of some sort
-			return in
+		if v.ArraySize != "" {
+			assigns = append(assigns, "me->"+v.Name+"[..]")
+		} else {
+			assigns = append(assigns, "me->"+v.Name)
 		}
+	}
+	if coverage && len(getPolicyMonInfo(function, policyIndex).Policy.Transitions) > 0 {
+		assigns = append(assigns, "me->_coverage_"+pol.Name+"[..]")
+	}
+	return assigns
+}
 
-		//check to see if it is io data
-		if function.InterfaceList != nil {
-			for _, Var := range function.InterfaceList {
-				if Var.Name == in {
-					return "io->" + in
-				}
-			}
+//getACSLCountingTimers returns the dtimers of the policy with index policyIndex that no transition assigns to, which go up by
+//exactly one on each tick
+func getACSLCountingTimers(function rvdef.Monitor, policyIndex int) []rvdef.Variable {
+	pol := function.Policies[policyIndex]
+	assigned := acslAssignedVars(pol)
+	var timers []rvdef.Variable
+	for _, v := range pol.InternalVars {
+		if v.IsDTimer() && !v.Constant && !assigned[v.Name] && v.ArraySize == "" {
+			timers = append(timers, v)
 		}
+	}
+	return timers
+}
 
-		//check to see if it is a policy internal var
-		for i := 0; i < len(function.Policies); i++ {
-			for _, Var := range function.Policies[i].InternalVars {
-				if Var.Name == in || Var.Name+"_i" == in {
-					if Var.Constant {
-						return "CONST_" + function.Policies[i].Name + "_" + in
-					}
-					return "me->" + in
-				}
-			}
+//acslAssignedVars returns the names of the variables of a policy that its transitions assign to
+func acslAssignedVars(pol rvdef.Policy) map[string]bool {
+	assigned := make(map[string]bool)
+	for _, tr := range pol.Transitions {
+		for _, ex := range tr.Expressions {
+			root, _ := rvdef.SplitAccessPath(ex.VarName)
+			assigned[root] = true
 		}
+	}
+	return assigned
+}
 
-		//else, return it (no idea what else to do!) - it might be a function call or strange text constant
-		return in
-	})
-
-	//tidy the whitespace
-	retVal = strings.Replace(retVal, "  ", " ", -1)
-
-	return CECCTransition{IfCond: retVal, AssEvents: events}
+//testRun is a run of ticks of a test trace which all have the same inputs and the same expected status
+type testRun struct {
+	Inputs  rvdef.Inputs
+	Verdict int
+	First   int //the first tick of the run (counting from 1)
+	Last    int //the last tick of the run
 }
 
-//getPolicyMonInfo will get a PEnforcer for a given policy
-func getPolicyMonInfo(function rvdef.Monitor, policyIndex int) *rvdef.PMonitor {
-	pmon, err := rvdef.MakePMonitor(function.InterfaceList, function.Policies[policyIndex])
-	if err != nil {
-		fmt.Printf("ERROR: The policy %s is broken! Message: %s", function.Policies[policyIndex].Name, err.Error())
-		panic(err)
+//getTestRuns splits a test trace up into runs of ticks which all have the same inputs and expected status
+func getTestRuns(tr rvdef.TestTrace) []testRun {
+	var runs []testRun
+	for i, in := range tr.Inputs {
+		if n := len(runs); n > 0 && runs[n-1].Verdict == tr.Verdicts[i] && runs[n-1].Inputs.String() == in.String() {
+			runs[n-1].Last = i + 1
+			continue
+		}
+		runs = append(runs, testRun{Inputs: in, Verdict: tr.Verdicts[i], First: i + 1, Last: i + 1})
 	}
-	//Uncomment these two lines to generate the intermediate enforcer JSON file
-	//bytes, _ := json.MarshalIndent(enfPol, "", "\t")
-	//ioutil.WriteFile(function.Name+".json", bytes, 0644)
-	return pmon
+	return runs
 }
 
 func sub(a, b int) int {
 	return a - b
 }
+
+func add(a, b int) int {
+	return a + b
+}
@@ -0,0 +1,494 @@
+package rvc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//smvSpecKinds are the kinds of specification that can be appended to an SMV module
+var smvSpecKinds = []string{"CTLSPEC", "LTLSPEC", "INVARSPEC", "SPEC", "PSLSPEC"}
+
+//smvKeywords are the reserved words of NuSMV and nuXmv, which can't be used as identifiers
+var smvKeywords = map[string]bool{
+	"MODULE": true, "DEFINE": true, "MDEFINE": true, "CONSTANTS": true, "VAR": true, "IVAR": true, "FROZENVAR": true,
+	"INIT": true, "TRANS": true, "INVAR": true, "SPEC": true, "CTLSPEC": true, "LTLSPEC": true, "PSLSPEC": true,
+	"COMPUTE": true, "NAME": true, "INVARSPEC": true, "FAIRNESS": true, "JUSTICE": true, "COMPASSION": true,
+	"ISA": true, "ASSIGN": true, "CONSTRAINT": true, "SIMPWFF": true, "CTLWFF": true, "LTLWFF": true, "PSLWFF": true,
+	"COMPWFF": true, "IN": true, "MIN": true, "MAX": true, "MIRROR": true, "PRED": true, "PREDICATES": true,
+	"process": true, "array": true, "of": true, "boolean": true, "integer": true, "real": true, "clock": true,
+	"word": true, "word1": true, "bool": true, "signed": true, "unsigned": true, "extend": true, "resize": true,
+	"sizeof": true, "uwconst": true, "swconst": true, "toint": true, "count": true, "abs": true, "max": true, "min": true,
+	"floor": true, "EX": true, "AX": true, "EF": true, "AF": true, "EG": true, "AG": true, "E": true, "F": true,
+	"O": true, "G": true, "H": true, "X": true, "Y": true, "Z": true, "A": true, "U": true, "S": true, "V": true,
+	"T": true, "BU": true, "EBF": true, "ABF": true, "EBG": true, "ABG": true, "case": true, "esac": true,
+	"mod": true, "next": true, "init": true, "union": true, "in": true, "xor": true, "xnor": true, "self": true,
+	"TRUE": true, "FALSE": true, "main": true,
+}
+
+//smvOperators maps binary stcompilerlib operator tokens to their SMV equivalents
+var smvOperators = map[string]string{
+	"*":   "*",
+	"/":   "/",
+	"MOD": "mod",
+	"+":   "+",
+	"-":   "-",
+	"<":   "<",
+	">":   ">",
+	"<=":  "<=",
+	">=":  ">=",
+	"=":   "=",
+	"<>":  "!=",
+	"and": "&",
+	"xor": "xor",
+	"or":  "|",
+}
+
+//smvModule is a monitor function as a NuSMV module, which the "functionSMV" template writes out
+type smvModule struct {
+	Vars    []smvDecl
+	Defines []smvDecl
+	Assigns []smvAssign
+	Specs   []string
+}
+
+//smvDecl is a variable (where Value is its type) or a DEFINE (where Value is its expression)
+type smvDecl struct {
+	Name    string
+	Value   string
+	Comment string
+}
+
+//smvAssign is the init and next assignments of a variable
+//If Next has a single case, it is written out without the case statement.
+type smvAssign struct {
+	Name string
+	Init string
+	Next []smvCase
+}
+
+//smvCase is one branch of a case expression
+type smvCase struct {
+	Cond  string
+	Value string
+}
+
+//validateSMVSpecs makes sure that each spec starts with the kind of specification that it is (e.g. CTLSPEC)
+func validateSMVSpecs(specs []string) error {
+	for _, spec := range specs {
+		fields := strings.Fields(spec)
+		if len(fields) == 0 || !stringSliceContains(smvSpecKinds, fields[0]) {
+			return fmt.Errorf("spec '%s' should start with one of %s", spec, strings.Join(smvSpecKinds, ", "))
+		}
+	}
+	return nil
+}
+
+//smvName turns an access path (e.g. pkt.temp or h[2]) into an SMV identifier (pkt_temp or h_2)
+func smvName(path string) string {
+	return strings.NewReplacer(".", "_", "[", "_", "]", "").Replace(path)
+}
+
+//smvIntegerRange returns the range of an integer type, narrowed to the 32 bit integers that NuSMV (and UPPAAL) support
+//(narrowed is set if it had to be)
+func smvIntegerRange(typ string) (lo int64, hi int64, narrowed bool) {
+	flo, fhi := rvdef.IntegerTypeRange(typ)
+	lo, hi = math.MinInt32, math.MaxInt32
+	if flo >= math.MinInt32 {
+		lo = int64(flo)
+	}
+	if fhi <= math.MaxInt32 {
+		hi = int64(fhi)
+	}
+	return lo, hi, flo < math.MinInt32 || fhi > math.MaxInt32
+}
+
+//smvType returns the SMV type of a scalar variable (and a comment if the type isn't exact)
+func smvType(function rvdef.Monitor, v rvdef.Variable) (string, string) {
+	if e := function.GetEnum(v.Type); e != nil {
+		return "{" + strings.Join(e.Members, ", ") + "}", ""
+	}
+	switch {
+	case strings.ToLower(v.Type) == "bool":
+		return "boolean", ""
+	case v.IsFloatType():
+		return "real", v.Type + " (reals are only supported by nuXmv)"
+	}
+	lo, hi, narrowed := smvIntegerRange(v.Type)
+	if narrowed {
+		return fmt.Sprintf("%d..%d", lo, hi), v.Type + " (narrowed to fit NuSMV's integers)"
+	}
+	return fmt.Sprintf("%d..%d", lo, hi), v.Type
+}
+
+//smvZero returns the SMV value that a scalar variable starts as if it has no initial value (i.e. what it is zeroed to in C)
+func smvZero(function rvdef.Monitor, v rvdef.Variable) string {
+	if e := function.GetEnum(v.Type); e != nil && len(e.Members) > 0 {
+		return e.Members[0]
+	}
+	switch {
+	case strings.ToLower(v.Type) == "bool":
+		return "FALSE"
+	case v.IsFloatType():
+		return "0.0"
+	}
+	return "0"
+}
+
+//smvSymbols is a symbol table for the expressions of a policy in SMV
+type smvSymbols struct {
+	function rvdef.Monitor
+	policy   rvdef.Policy
+	leaves   map[string]string //each scalar part of the variables (by access path), mapped to what it is emitted as
+	members  map[string]bool   //the enum members
+}
+
+//getSMVModule converts the (finalised) monitor function into a NuSMV module
+//Each tick of the C is a step of the module: the interface variables are left unassigned (so that they can take any value
+//in each step), and the states and internal variables of each policy are assigned from the values of the interface in the same
+//step, like the C does. Timers count up to just past the largest constant of their policy, and then stay there.
+func getSMVModule(function rvdef.Monitor, specs []string) (*smvModule, error) {
+	if smvKeywords[function.Name] {
+		return nil, fmt.Errorf("monitor name %s is a NuSMV keyword", function.Name)
+	}
+	m := &smvModule{Specs: specs}
+
+	symbols := smvSymbols{function: function, leaves: make(map[string]string), members: make(map[string]bool)}
+	for _, e := range function.Enums {
+		for _, member := range e.Members {
+			if smvKeywords[member] {
+				return nil, fmt.Errorf("enum member %s is a NuSMV keyword", member)
+			}
+			symbols.members[member] = true
+		}
+	}
+	for _, v := range function.InterfaceList {
+		for _, leaf := range function.VariableLeaves(rvdef.Policy{}, v) {
+			name := "io_" + smvName(leaf.Name)
+			typ, comment := smvType(function, leaf)
+			m.Vars = append(m.Vars, smvDecl{Name: name, Value: typ, Comment: comment})
+			symbols.leaves[leaf.Name] = name
+		}
+	}
+
+	for polI := range function.Policies {
+		if err := m.addPolicy(symbols, polI); err != nil {
+			return nil, fmt.Errorf("Policy %s: %s", function.Policies[polI].Name, err.Error())
+		}
+	}
+	return m, nil
+}
+
+//addPolicy adds the state, internal variables and transitions of the policy with index polI to the module
+func (m *smvModule) addPolicy(interfaceSymbols smvSymbols, polI int) error {
+	function := interfaceSymbols.function
+	pol := function.Policies[polI]
+	pmon, err := rvdef.MakePMonitor(function.InterfaceList, pol)
+	if err != nil {
+		return err
+	}
+	limit := pol.TimerLimit()
+	prefix := pol.Name + "_"
+
+	//the internals are only visible to this policy, and the interface hides any of the same name
+	symbols := smvSymbols{function: function, policy: pol, leaves: make(map[string]string), members: interfaceSymbols.members}
+	for path, name := range interfaceSymbols.leaves {
+		symbols.leaves[path] = name
+	}
+	type internal struct {
+		v    rvdef.Variable
+		name string
+		init string
+	}
+	var internals []internal
+	variables := make(map[string]rvdef.Variable) //the non-constant internals, by access path
+	for _, v := range pol.InternalVars {
+		if function.InterfaceList.HasIONamed(true, v.Name) {
+			continue
+		}
+		initial := v.GetInitialArray()
+		for i, leaf := range function.VariableLeaves(pol, v) {
+			init := leaf.InitialValue
+			if initial != nil {
+				init = ""
+				if i < len(initial) {
+					init = initial[i]
+				}
+			}
+			name := prefix + smvName(leaf.Name)
+			symbols.leaves[leaf.Name] = name
+			internals = append(internals, internal{v: leaf, name: name, init: init})
+			if !v.Constant {
+				variables[leaf.Name] = leaf
+			}
+		}
+	}
+
+	//constants and initial values can only use constants and enum members
+	constSymbols := smvSymbols{function: function, policy: pol, leaves: make(map[string]string), members: symbols.members}
+	for _, in := range internals {
+		if in.v.Constant {
+			constSymbols.leaves[in.v.Name] = in.name
+		}
+	}
+	//a dtimer stops counting up at the policy's TimerLimit if it is only compared with constants,
+	//otherwise it counts up to the largest value that NuSMV can hold
+	limitDefine := prefix + "TIMER_LIMIT"
+	_, timerMax, _ := smvIntegerRange("dtimer_t")
+	limits := make(map[string]int64)      //the value where each dtimer stops counting up, by access path
+	limitNames := make(map[string]string) //how that value is written in the model
+	for _, in := range internals {
+		if !in.v.IsDTimer() || in.v.Constant {
+			continue
+		}
+		if root, _ := rvdef.SplitAccessPath(in.v.Name); pol.TimerBounded(root) {
+			limits[in.v.Name], limitNames[in.v.Name] = limit, limitDefine
+		} else {
+			limits[in.v.Name], limitNames[in.v.Name] = timerMax, strconv.FormatInt(timerMax, 10)
+		}
+	}
+	for i, in := range internals {
+		if in.init == "" {
+			internals[i].init = smvZero(function, in.v)
+			continue
+		}
+		val, err := constSymbols.compileString(in.v.Name, in.init)
+		if err != nil {
+			return fmt.Errorf("initial value of %s: %s", in.v.Name, err.Error())
+		}
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil && in.v.IsDTimer() && !in.v.Constant && n > limits[in.v.Name] {
+			val = strconv.FormatInt(limits[in.v.Name], 10)
+		}
+		internals[i].init = val
+	}
+
+	//timers are read (in guards and assignments) after they have counted up for this tick
+	hasTimers := false
+	for _, in := range internals {
+		if in.v.Constant {
+			m.Defines = append(m.Defines, smvDecl{Name: in.name, Value: in.init, Comment: "constant " + in.v.Type})
+			continue
+		}
+		if in.v.IsDTimer() {
+			comment := "dtimer_t (narrowed to fit NuSMV's integers, as it isn't only compared with constants)"
+			if limitNames[in.v.Name] == limitDefine {
+				comment = "dtimer_t"
+				if !hasTimers {
+					m.Defines = append(m.Defines, smvDecl{Name: limitDefine, Value: strconv.FormatInt(limit, 10), Comment: "where the dtimers of " + pol.Name + " stop counting up"})
+					hasTimers = true
+				}
+			}
+			m.Vars = append(m.Vars, smvDecl{Name: in.name, Value: "0.." + strconv.FormatInt(limits[in.v.Name], 10), Comment: comment})
+			m.Defines = append(m.Defines, smvDecl{Name: in.name + "_now", Value: "min(" + in.name + " + 1, " + limitNames[in.v.Name] + ")", Comment: in.v.Name + " during this tick"})
+			symbols.leaves[in.v.Name] = in.name + "_now"
+			continue
+		}
+		typ, comment := smvType(function, in.v)
+		m.Vars = append(m.Vars, smvDecl{Name: in.name, Value: typ, Comment: comment})
+	}
+
+	//the state, and the state that the transitions are taken from (which is different on the first tick, if the initial condition doesn't hold)
+	if len(pol.States) == 0 {
+		return errors.New("it has no states")
+	}
+	var stateNames []string
+	for _, st := range pol.States {
+		if smvKeywords[st.Name] {
+			return fmt.Errorf("state name %s is a NuSMV keyword", st.Name)
+		}
+		stateNames = append(stateNames, st.Name)
+	}
+	stateVar, from := prefix+"state", prefix+"from"
+	m.Vars = append(m.Vars, smvDecl{Name: stateVar, Value: "{" + strings.Join(stateNames, ", ") + "}"})
+	st := pol.GetInitialState()
+	if st == nil {
+		return errors.New("there is no initial state (one state must be marked as initial)")
+	}
+	initialState := st.Name
+	stateAssign := len(m.Assigns)
+	m.Assigns = append(m.Assigns, smvAssign{Name: stateVar, Init: initialState})
+	fromValue := stateVar
+	if pmon.Policy.InitialGuard != nil {
+		started := prefix + "started"
+		guard, err := symbols.compile(pmon.Policy.InitialGuard)
+		if err != nil {
+			return fmt.Errorf("initial condition %s: %s", st.InitialCondition, err.Error())
+		}
+		m.Vars = append(m.Vars, smvDecl{Name: started, Value: "boolean", Comment: "set once the initial condition has been checked"})
+		m.Assigns = append(m.Assigns, smvAssign{Name: started, Init: "FALSE", Next: []smvCase{{Value: "TRUE"}}})
+		fromValue = "case !" + started + " & !" + guard + " : " + st.InitialElse + "; TRUE : " + stateVar + "; esac"
+	}
+	m.Defines = append(m.Defines, smvDecl{Name: from, Value: fromValue, Comment: "the state that " + pol.Name + "'s transitions are taken from in this tick"})
+
+	//each transition is taken when it is the first from its source whose guard holds (or, for the else transition, when none do)
+	assigned := make(map[string][]smvCase)
+	for tri, tr := range pmon.Policy.Transitions {
+		name := fmt.Sprintf("%st%d", prefix, tri)
+		taken := []string{from + " = " + tr.Source}
+		for _, other := range pmon.Policy.Transitions[:tri] {
+			if other.Source == tr.Source && !other.Else && !tr.Else {
+				guard, err := symbols.compile(other.STGuard)
+				if err != nil {
+					return fmt.Errorf("transition %s -> %s: %s", other.Source, other.Destination, err.Error())
+				}
+				taken = append(taken, "!"+guard)
+			}
+		}
+		guard, err := symbols.compile(tr.STGuard)
+		if err != nil {
+			return fmt.Errorf("transition %s -> %s: %s", tr.Source, tr.Destination, err.Error())
+		}
+		taken = append(taken, guard)
+		comment := "transition " + tr.Source + " -> " + tr.Destination + " on " + tr.Condition
+		if tr.Else {
+			comment = "transition " + tr.Source + " -> " + tr.Destination + " else"
+		}
+		m.Defines = append(m.Defines, smvDecl{Name: name, Value: strings.Join(taken, " & "), Comment: comment})
+		m.Assigns[stateAssign].Next = append(m.Assigns[stateAssign].Next, smvCase{Cond: name, Value: tr.Destination})
+
+		//assignments are made in order, so each sees the ones made before it
+		trSymbols := symbols
+		trSymbols.leaves = make(map[string]string, len(symbols.leaves))
+		for path, sym := range symbols.leaves {
+			trSymbols.leaves[path] = sym
+		}
+		for _, ex := range tr.Expressions {
+			target, err := symbols.leafPath(ex.VarName)
+			if err != nil {
+				return fmt.Errorf("assignment to %s: %s", ex.VarName, err.Error())
+			}
+			v, ok := variables[target]
+			if !ok {
+				return fmt.Errorf("can't assign to %s (only the scalar parts of internal variables can be assigned to)", ex.VarName)
+			}
+			value, err := trSymbols.compileString(pol.Name, ex.Value)
+			if err != nil {
+				return fmt.Errorf("assignment to %s: %s", ex.VarName, err.Error())
+			}
+			if n, err := strconv.ParseInt(value, 10, 64); err == nil && v.IsDTimer() && n > limits[target] {
+				value = limitNames[target]
+			} else if err != nil && v.IsDTimer() {
+				value = "min(" + value + ", " + limitNames[target] + ")"
+			}
+			assigned[target] = append(assigned[target], smvCase{Cond: name, Value: value})
+			trSymbols.leaves[target] = value
+		}
+	}
+	m.Assigns[stateAssign].Next = append(m.Assigns[stateAssign].Next, smvCase{Cond: "TRUE", Value: from})
+
+	//otherwise, timers count up and everything else stays the same
+	for _, in := range internals {
+		if in.v.Constant {
+			continue
+		}
+		unchanged := in.name
+		if in.v.IsDTimer() {
+			unchanged = in.name + "_now"
+		}
+		next := append(assigned[in.v.Name], smvCase{Cond: "TRUE", Value: unchanged})
+		m.Assigns = append(m.Assigns, smvAssign{Name: in.name, Init: in.init, Next: next})
+	}
+	return nil
+}
+
+//compileString parses and compiles a single expression
+func (symbols smvSymbols) compileString(name string, s string) (string, error) {
+	expr, perr := rvdef.ParseSTExpression(name, s)
+	if perr != nil {
+		return "", errors.New(perr.Error())
+	}
+	return symbols.compile(expr)
+}
+
+//compile recursively converts expr into fully parenthesised SMV
+func (symbols smvSymbols) compile(expr stcompilerlib.STExpression) (string, error) {
+	if expr == nil {
+		return "", errors.New("missing expression")
+	}
+	op := expr.HasOperator()
+	if op == nil {
+		return symbols.compileValue(expr.HasValue())
+	}
+
+	//arguments are in reverse order
+	var args []string
+	stArgs := expr.GetArguments()
+	for i := len(stArgs) - 1; i >= 0; i-- {
+		arg, err := symbols.compile(stArgs[i])
+		if err != nil {
+			return "", err
+		}
+		args = append(args, arg)
+	}
+
+	tok := op.GetToken()
+	switch {
+	case tok == "not":
+		return "(!" + args[0] + ")", nil
+	case tok == "`": //unary minus
+		return "(-" + args[0] + ")", nil
+	case smvOperators[tok] != "":
+		return "(" + args[0] + " " + smvOperators[tok] + " " + args[1] + ")", nil
+	}
+
+	name := rvdef.FunctionName(tok)
+	if len(args) == 1 && symbols.function.GetEnum(name) != nil {
+		return args[0], nil
+	}
+	if len(args) == 1 && (rvdef.Variable{Type: name}).IsIntegerType() {
+		return args[0], nil //NuSMV's integers have no width, so integer casts do nothing (beyond the range of what they are assigned to)
+	}
+	return "", fmt.Errorf("'%s' can't be converted to SMV", name)
+}
+
+//compileValue converts a single value (a literal, or a variable with an optional access path) into SMV
+func (symbols smvSymbols) compileValue(val string) (string, error) {
+	switch {
+	case strings.EqualFold(val, "true"):
+		return "TRUE", nil
+	case strings.EqualFold(val, "false"):
+		return "FALSE", nil
+	}
+	if i, err := strconv.ParseInt(val, 0, 64); err == nil {
+		return strconv.FormatInt(i, 10), nil
+	}
+	if _, err := strconv.ParseFloat(val, 64); err == nil {
+		return val, nil
+	}
+	if symbols.members[val] {
+		return val, nil
+	}
+	path, err := symbols.leafPath(val)
+	if err != nil {
+		return "", err
+	}
+	sym, ok := symbols.leaves[path]
+	if !ok {
+		if symbols.function.GetVariable(symbols.policy, path) != nil {
+			return "", fmt.Errorf("'%s' is not a scalar", val)
+		}
+		return "", fmt.Errorf("unknown identifier '%s'", val)
+	}
+	return sym, nil
+}
+
+//leafPath returns an access path with its array indices (which must be constant) replaced by their values, e.g. h[MAX - 1] is h[2]
+func (symbols smvSymbols) leafPath(val string) (string, error) {
+	root, path := rvdef.SplitAccessPath(val)
+	rest, err := mapAccessPath(val, path, func(index string) (string, error) {
+		i, ok := symbols.function.GetIntegerConstant(symbols.policy, strings.TrimSpace(index))
+		if !ok {
+			return "", fmt.Errorf("the index of '%s' must be a number or a constant", val)
+		}
+		return strconv.Itoa(i), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return root + rest, nil
+}
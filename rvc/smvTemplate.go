@@ -0,0 +1,38 @@
+package rvc
+
+import (
+	"text/template"
+)
+
+const rvcSMVTemplate = `{{define "functionSMV"}}{{$block := index .Functions .FunctionIndex}}{{$m := getSMVModule $block .Specs}}-- This file should be called {{$block.Name}}.smv
+-- This is autogenerated code. Edit by hand at your peril!
+-- Each step of the module {{$block.Name}} is a tick of the monitor: the io_ variables are its inputs, and can take any value
+-- in each step (or those allowed by INVAR constraints added to main), and <policy>_state is the state of each policy after the tick
+
+MODULE {{$block.Name}}
+{{if $m.Vars}}VAR{{range $m.Vars}}
+	{{.Name}} : {{.Value}};{{if .Comment}} -- {{.Comment}}{{end}}{{end}}
+{{end}}{{if $m.Defines}}
+DEFINE{{range $m.Defines}}
+	{{.Name}} := {{.Value}};{{if .Comment}} -- {{.Comment}}{{end}}{{end}}
+{{end}}{{if $m.Assigns}}
+ASSIGN{{range $m.Assigns}}
+	init({{.Name}}) := {{.Init}};
+	next({{.Name}}) :={{if eq (len .Next) 1}} {{(index .Next 0).Value}}{{else}}
+		case{{range .Next}}
+			{{.Cond}} : {{.Value}};{{end}}
+		esac{{end}};{{end}}
+{{end}}{{if $m.Specs}}
+{{range $m.Specs}}{{.}}
+{{end}}{{end}}
+MODULE main
+VAR
+	monitor : {{$block.Name}};
+{{end}}
+`
+
+var smvTemplateFuncMap = template.FuncMap{
+	"getSMVModule": getSMVModule,
+}
+
+var smvTemplates = template.Must(template.New("").Funcs(smvTemplateFuncMap).Parse(rvcSMVTemplate))
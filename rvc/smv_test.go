@@ -0,0 +1,107 @@
+package rvc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+func TestConvertSMV(t *testing.T) {
+	//a uint64_t is too wide for NuSMV
+	smvMonitor := func(transitions ...rvdef.PTransition) rvdef.Monitor {
+		mon := modelMonitor("bad", transitions...)
+		mon.Policies[0].InternalVars = append(mon.Policies[0].InternalVars, rvdef.Variable{Name: "c", Type: "uint64_t"})
+		return mon
+	}
+
+	ok := []rvdef.PTransition{
+		{Source: "s0", Destination: "s1", Condition: "A and h[LAST] > 2", Expressions: []rvdef.PExpression{{VarName: "v", Value: "0"}, {VarName: "n", Value: "v - 1"}}},
+		{Source: "s0", Destination: "s0", Else: true},
+		{Source: "s1", Destination: "s0", Condition: "!A or mode <> ON"},
+		{Source: "s1", Destination: "bad", Condition: "v >= 5", Expressions: []rvdef.PExpression{{VarName: "c", Value: "int8_t(n) MOD 2"}}},
+		{Source: "s1", Destination: "s1", Else: true},
+	}
+
+	tests := []struct {
+		mon   rvdef.Monitor
+		specs []string
+		err   string
+		want  []string
+	}{
+		{
+			mon:   smvMonitor(ok...),
+			specs: []string{"CTLSPEC AG(P_state = s1 -> AX P_state != s1 | !io_A)", "INVARSPEC P_v <= 7"},
+			want: []string{
+				"MODULE m\n",
+				"\tio_A : boolean;",
+				"\tio_mode : {IDLE, ON};",
+				"\tio_h_0 : 0..255; -- uint8_t",
+				"\tio_h_1 : 0..255; -- uint8_t",
+				"\tP_v : 0..7; -- dtimer_t",
+				"\tP_n : -128..127; -- int8_t",
+				"\tP_c : 0..2147483647; -- uint64_t (narrowed to fit NuSMV's integers)",
+				"\tP_state : {s0, s1, bad};",
+				"\tP_started : boolean;",
+				"\tP_TIMER_LIMIT := 7;",
+				"\tP_v_now := min(P_v + 1, P_TIMER_LIMIT);",
+				"\tP_LAST := 1; -- constant uint8_t",
+				"\tP_from := case !P_started & !(io_mode = IDLE) : bad; TRUE : P_state; esac;",
+				"\tP_t0 := P_from = s0 & (io_A & (io_h_1 > 2));",
+				"\tP_t1 := P_from = s0 & (!(io_A & (io_h_1 > 2)));",
+				"\tP_t2 := P_from = s1 & ((!io_A) | (io_mode != ON));",
+				"\tP_t3 := P_from = s1 & !((!io_A) | (io_mode != ON)) & (P_v_now >= 5);",
+				"\tP_t4 := P_from = s1 & (!(((!io_A) | (io_mode != ON)) | (P_v_now >= 5))); -- transition s1 -> s1 else",
+				"\tinit(P_started) := FALSE;\n\tnext(P_started) := TRUE;",
+				"\tinit(P_state) := s0;\n\tnext(P_state) :=\n\t\tcase\n\t\t\tP_t0 : s1;\n\t\t\tP_t1 : s0;",
+				"\t\t\tTRUE : P_from;\n\t\tesac;",
+				"\tinit(P_v) := 0;\n\tnext(P_v) :=\n\t\tcase\n\t\t\tP_t0 : 0;\n\t\t\tTRUE : P_v_now;\n\t\tesac;",
+				"\tinit(P_n) := 3;\n\tnext(P_n) :=\n\t\tcase\n\t\t\tP_t0 : (0 - 1);\n\t\t\tTRUE : P_n;\n\t\tesac;", //sees v after it is reset
+				"\t\t\tP_t3 : (P_n mod 2);",
+				"CTLSPEC AG(P_state = s1 -> AX P_state != s1 | !io_A)\nINVARSPEC P_v <= 7\n",
+				"MODULE main\nVAR\n\tmonitor : m;",
+			},
+		},
+		{
+			//a timer compared with an input can't stop counting up early
+			mon: smvMonitor(rvdef.PTransition{Source: "s0", Destination: "bad", Condition: "v > h[0]"}),
+			want: []string{
+				"\tP_v : 0..2147483647; -- dtimer_t (narrowed to fit NuSMV's integers, as it isn't only compared with constants)",
+				"\tP_v_now := min(P_v + 1, 2147483647);",
+			},
+		},
+		{mon: smvMonitor(ok...), specs: []string{"AG P_state != bad"}, err: "spec 'AG P_state != bad' should start with one of CTLSPEC"},
+		{mon: smvMonitor(rvdef.PTransition{Source: "s0", Destination: "s1", Condition: "h[n] > 2"}), err: "the index of 'h[n]' must be a number or a constant"},
+		{mon: smvMonitor(rvdef.PTransition{Source: "s0", Destination: "s1", Condition: "sqrt(n) > 2"}), err: "'sqrt' can't be converted to SMV"},
+		{mon: smvMonitor(rvdef.PTransition{Source: "s0", Destination: "s1", Condition: "h > 2"}), err: "'h' is not a scalar"},
+		{mon: smvMonitor(rvdef.PTransition{Source: "s0", Destination: "s1", Condition: "A", Expressions: []rvdef.PExpression{{VarName: "A", Value: "false"}}}), err: "can't assign to A"},
+	}
+
+	for i, test := range tests {
+		conv, err := New("smv")
+		if err != nil {
+			t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+		}
+		conv.Funcs = []rvdef.Monitor{test.mon}
+		conv.Specs = test.specs
+		outputs, err := conv.ConvertAll()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Test %d: error was %v, it should have been '%s'", i, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: error '%s' occurred when it shouldn't have", i, err.Error())
+		}
+		if len(outputs) != 1 || outputs[0].Name != "m" || outputs[0].Extension != "smv" {
+			t.Fatalf("Test %d: outputs were %+v", i, outputs)
+		}
+		smv := string(outputs[0].Contents)
+		for _, want := range test.want {
+			if !strings.Contains(smv, want) {
+				t.Errorf("Test %d: module doesn't have '%s':\n%s", i, want, smv)
+			}
+		}
+	}
+}
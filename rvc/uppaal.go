@@ -0,0 +1,758 @@
+package rvc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//uppaalEnvironment is the name of the template that picks the inputs and runs the ticks
+const uppaalEnvironment = "Environment"
+
+//uppaalKeywords are the reserved words of UPPAAL, which can't be used as identifiers
+var uppaalKeywords = map[string]bool{
+	"chan": true, "clock": true, "bool": true, "int": true, "double": true, "string": true, "void": true, "const": true,
+	"urgent": true, "broadcast": true, "commit": true, "committed": true, "init": true, "process": true, "state": true,
+	"guard": true, "sync": true, "assign": true, "select": true, "system": true, "trans": true, "deadlock": true,
+	"and": true, "or": true, "not": true, "imply": true, "true": true, "false": true, "for": true, "forall": true,
+	"exists": true, "sum": true, "while": true, "do": true, "if": true, "else": true, "return": true, "typedef": true,
+	"struct": true, "meta": true, "priority": true, "progress": true, "scalar": true, "rate": true, "switch": true,
+	"case": true, "default": true, "break": true, "continue": true, "before_update": true, "after_update": true,
+	"hybrid": true, "inf": true, "A": true, "E": true, "Environment": true,
+}
+
+//uppaalOperators maps binary stcompilerlib operator tokens to their UPPAAL equivalents
+var uppaalOperators = map[string]string{
+	"*":   "*",
+	"/":   "/",
+	"MOD": "%",
+	"+":   "+",
+	"-":   "-",
+	"<":   "<",
+	">":   ">",
+	"<=":  "<=",
+	">=":  ">=",
+	"=":   "==",
+	"<>":  "!=",
+	"and": "&&",
+	"xor": "!=",
+	"or":  "||",
+}
+
+//uppaalSwapped maps each comparison to the one that holds with its operands swapped
+var uppaalSwapped = map[string]string{"<": ">", ">": "<", "<=": ">=", ">=": "<=", "=": "=", "<>": "<>"}
+
+//uppaalNegated maps each comparison to the one that holds when it doesn't
+var uppaalNegated = map[string]string{"<": ">=", ">=": "<", ">": "<=", "<=": ">", "=": "<>", "<>": "="}
+
+//uppaalSystem is a monitor function as an UPPAAL network of timed automata, which the "functionUPPAAL" template writes out
+type uppaalSystem struct {
+	Declaration string
+	Templates   []uppaalTemplate
+	System      string
+	Queries     []uppaalQuery
+}
+
+//uppaalTemplate is a timed automaton
+type uppaalTemplate struct {
+	Name        string
+	Declaration string
+	Locations   []uppaalLocation
+	Init        string //the ID of the initial location
+	Transitions []uppaalTransition
+}
+
+//uppaalLocation is a location of a timed automaton, at position X, Y
+type uppaalLocation struct {
+	ID        string
+	Name      string
+	X, Y      int
+	Invariant string
+	Comment   string
+	Color     string
+	Committed bool
+}
+
+//uppaalTransition is an edge of a timed automaton, with its labels at position X, Y
+type uppaalTransition struct {
+	Source, Target string
+	Select         string
+	Guard          string
+	Sync           string
+	Assign         string
+	X, Y           int
+	Nails          []uppaalPoint
+}
+
+//uppaalPoint is a position in a template
+type uppaalPoint struct {
+	X, Y int
+}
+
+//uppaalQuery is a query that is saved with the system
+type uppaalQuery struct {
+	Formula string
+	Comment string
+}
+
+//uppaalDNF is a guard as a disjunction of conjunctions, which is how it has to be split up into edges when it compares
+//clocks (UPPAAL guards can only be conjunctions of clock constraints)
+//An empty conjunction is true, and an empty disjunction is false.
+type uppaalDNF [][]uppaalAtom
+
+//uppaalAtom is a part of a conjunction, and what it is when it is negated
+type uppaalAtom struct {
+	Text string
+	Not  string
+}
+
+//and returns the conjunction of two guards
+//Conjunctions that have both an atom and its negation are left out, as are repeated atoms.
+func (d uppaalDNF) and(o uppaalDNF) uppaalDNF {
+	var res uppaalDNF
+next:
+	for _, a := range d {
+		conj := append([]uppaalAtom(nil), a...)
+		for _, b := range o {
+			conj = conj[:len(a)]
+			for _, atom := range b {
+				for _, have := range conj {
+					if have.Text == atom.Not {
+						continue next
+					}
+				}
+				if !uppaalHasAtom(conj, atom) {
+					conj = append(conj, atom)
+				}
+			}
+			res = append(res, append([]uppaalAtom(nil), conj...))
+		}
+	}
+	return res
+}
+
+//uppaalHasAtom returns true if conj has atom
+func uppaalHasAtom(conj []uppaalAtom, atom uppaalAtom) bool {
+	for _, have := range conj {
+		if have.Text == atom.Text {
+			return true
+		}
+	}
+	return false
+}
+
+//uppaalConjunction returns a conjunction as an UPPAAL guard
+func uppaalConjunction(conj []uppaalAtom) string {
+	var texts []string
+	for _, atom := range conj {
+		texts = append(texts, atom.Text)
+	}
+	return strings.Join(texts, " && ")
+}
+
+//uppaalEdge is a way out of a state on a tick
+type uppaalEdge struct {
+	tr   *rvdef.PSTTransition //the transition (or nil, when the state stays the same because no transition is taken)
+	dest string
+	when uppaalDNF
+}
+
+//uppaalSymbols is a symbol table for the expressions of a policy in UPPAAL
+//Variables keep their names, as each policy's internals are declared in its own template.
+type uppaalSymbols struct {
+	function rvdef.Monitor
+	policy   rvdef.Policy
+}
+
+//getUPPAALSystem converts the (finalised) monitor function into an UPPAAL system
+//Each tick takes one time unit. On each tick, the Environment picks any values for the interface, and then has each policy
+//take a transition (over a channel tick_<policy>, one after the other through committed locations, so that no time passes and
+//the interface stays the same). Dtimers are clocks, and so they count the ticks since they were last reset.
+func getUPPAALSystem(function rvdef.Monitor) (*uppaalSystem, error) {
+	sys := &uppaalSystem{}
+	global := uppaalSymbols{function: function}
+
+	decls := []string{"// monitor " + function.Name + ": on each tick (every time unit), the " + uppaalEnvironment + " picks the values of the interface, and then each policy takes a transition"}
+	var processes []string
+	for _, p := range function.Policies {
+		if err := uppaalCheckName(p.Name); err != nil {
+			return nil, err
+		}
+		decls = append(decls, "chan tick_"+p.Name+";")
+		processes = append(processes, p.Name)
+	}
+	for _, e := range function.Enums {
+		if err := uppaalCheckName(e.Name); err != nil {
+			return nil, err
+		}
+		decls = append(decls, fmt.Sprintf("typedef int[0,%d] %s;", len(e.Members)-1, e.Name))
+		for i, member := range e.Members {
+			if err := uppaalCheckName(member); err != nil {
+				return nil, err
+			}
+			decls = append(decls, fmt.Sprintf("const int %s = %d;", member, i))
+		}
+	}
+	for _, v := range function.InterfaceList {
+		decl, err := global.declare(v)
+		if err != nil {
+			return nil, fmt.Errorf("interface variable %s: %s", v.Name, err.Error())
+		}
+		decls = append(decls, decl+";")
+	}
+	sys.Declaration = strings.Join(decls, "\n")
+
+	env, err := uppaalEnvironmentTemplate(function)
+	if err != nil {
+		return nil, err
+	}
+	sys.Templates = append(sys.Templates, *env)
+	sys.Queries = append(sys.Queries, uppaalQuery{Formula: "A[] not deadlock", Comment: "every policy can always take a transition on each tick"})
+
+	for polI := range function.Policies {
+		t, queries, err := uppaalPolicyTemplate(function, polI)
+		if err != nil {
+			return nil, fmt.Errorf("Policy %s: %s", function.Policies[polI].Name, err.Error())
+		}
+		sys.Templates = append(sys.Templates, *t)
+		sys.Queries = append(sys.Queries, queries...)
+	}
+	sys.System = "system " + strings.Join(append([]string{uppaalEnvironment}, processes...), ", ") + ";"
+	return sys, nil
+}
+
+//uppaalCheckName makes sure that a name can be used as an UPPAAL identifier
+func uppaalCheckName(name string) error {
+	if uppaalKeywords[name] {
+		return fmt.Errorf("%s is a reserved word in UPPAAL", name)
+	}
+	return nil
+}
+
+//uppaalEnvironmentTemplate makes the template that runs the ticks, picking the values of the interface for each one
+func uppaalEnvironmentTemplate(function rvdef.Monitor) (*uppaalTemplate, error) {
+	t := &uppaalTemplate{Name: uppaalEnvironment, Declaration: "clock t; // the time since the last tick", Init: "id0"}
+	t.Locations = append(t.Locations, uppaalLocation{ID: "id0", Name: "waiting", Invariant: "t <= 1"})
+
+	var selects, assigns []string
+	for i, leaf := range function.InterfaceLeaves() {
+		sel := fmt.Sprintf("i%d", i)
+		switch {
+		case function.GetEnum(leaf.Type) != nil:
+			selects = append(selects, sel+" : "+leaf.Type)
+			assigns = append(assigns, leaf.Name+" = "+sel)
+		case strings.ToLower(leaf.Type) == "bool":
+			selects = append(selects, sel+" : int[0,1]")
+			assigns = append(assigns, leaf.Name+" = ("+sel+" == 1)")
+		case leaf.IsIntegerType():
+			lo, hi, _ := smvIntegerRange(leaf.Type)
+			selects = append(selects, fmt.Sprintf("%s : int[%d,%d]", sel, lo, hi))
+			assigns = append(assigns, leaf.Name+" = "+sel)
+		default:
+			return nil, fmt.Errorf("UPPAAL can't pick values for %s (of type %s) on each tick", leaf.Name, leaf.Type)
+		}
+	}
+	assigns = append(assigns, "t = 0")
+
+	//one committed location before each policy's tick, and then back to waiting
+	prev := "id0"
+	for i, p := range function.Policies {
+		id := fmt.Sprintf("id%d", i+1)
+		t.Locations = append(t.Locations, uppaalLocation{ID: id, Name: "ticking_" + p.Name, X: 200 * (i + 1), Y: 150, Committed: true})
+		tr := uppaalTransition{Source: prev, Target: id}
+		if i > 0 {
+			tr.Sync = "tick_" + function.Policies[i-1].Name + "!"
+		}
+		t.Transitions = append(t.Transitions, tr)
+		prev = id
+	}
+	last := uppaalTransition{Source: prev, Target: "id0"}
+	if len(function.Policies) > 0 {
+		last.Sync = "tick_" + function.Policies[len(function.Policies)-1].Name + "!"
+	}
+	t.Transitions = append(t.Transitions, last)
+
+	//the first transition is the tick itself
+	t.Transitions[0].Select = strings.Join(selects, ",\n")
+	t.Transitions[0].Guard = "t == 1"
+	t.Transitions[0].Assign = strings.Join(assigns, ",\n")
+	for i := range t.Transitions {
+		tr := &t.Transitions[i]
+		src, dst := t.location(tr.Source), t.location(tr.Target)
+		tr.X, tr.Y = (src.X+dst.X)/2, (src.Y+dst.Y)/2
+		if tr.Source == tr.Target {
+			tr.Nails = []uppaalPoint{{src.X - 50, src.Y - 80}, {src.X + 50, src.Y - 80}}
+			tr.X, tr.Y = src.X+60, src.Y-120
+		}
+	}
+	if len(function.Policies) > 0 {
+		t.Transitions[0].X, t.Transitions[0].Y = -200, 0
+	}
+	return t, nil
+}
+
+//location returns the location with the given ID
+func (t *uppaalTemplate) location(id string) *uppaalLocation {
+	for i := range t.Locations {
+		if t.Locations[i].ID == id {
+			return &t.Locations[i]
+		}
+	}
+	return nil
+}
+
+//uppaalPolicyTemplate makes the template of the policy with index polI, and the queries about it
+func uppaalPolicyTemplate(function rvdef.Monitor, polI int) (*uppaalTemplate, []uppaalQuery, error) {
+	pol := function.Policies[polI]
+	pmon, err := rvdef.MakePMonitor(function.InterfaceList, pol)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pol.States) == 0 {
+		return nil, nil, errors.New("it has no states")
+	}
+	symbols := uppaalSymbols{function: function, policy: pol}
+	t := &uppaalTemplate{Name: pol.Name}
+
+	//the internals, where any dtimers that don't start at 0 are set in an initial committed location (as clocks always start at 0)
+	var decls, clockInits, traps []string
+	for _, v := range pol.InternalVars {
+		if function.InterfaceList.HasIONamed(true, v.Name) {
+			continue
+		}
+		if err := uppaalCheckName(v.Name); err != nil {
+			return nil, nil, err
+		}
+		decl, err := symbols.declare(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("internal variable %s: %s", v.Name, err.Error())
+		}
+		if v.IsDTimer() && v.InitialValue != "" && v.ArraySize == "" {
+			init, err := symbols.expression(v.InitialValue)
+			if err != nil {
+				return nil, nil, fmt.Errorf("initial value of %s: %s", v.Name, err.Error())
+			}
+			if init != "0" {
+				clockInits = append(clockInits, v.Name+" = "+init)
+			}
+		}
+		decls = append(decls, decl+";")
+	}
+
+	//the states, laid out in a circle (starting at the top)
+	r := math.Max(150, float64(60*len(pol.States))/math.Pi)
+	ids := make(map[string]string)
+	for i, st := range pol.States {
+		if err := uppaalCheckName(st.Name); err != nil {
+			return nil, nil, err
+		}
+		angle := 2*math.Pi*float64(i)/float64(len(pol.States)) - math.Pi/2
+		loc := uppaalLocation{ID: fmt.Sprintf("id%d", i), Name: st.Name, X: int(r * math.Cos(angle)), Y: int(r * math.Sin(angle))}
+		if st.Verdict() == 3 {
+			loc.Comment, loc.Color = "rejecting trap: the policy has been violated", "#ff0000"
+			traps = append(traps, st.Name)
+		}
+		ids[st.Name] = loc.ID
+		t.Locations = append(t.Locations, loc)
+	}
+	initial := pol.GetInitialState()
+	if initial == nil {
+		return nil, nil, errors.New("there is no initial state (one state must be marked as initial)")
+	}
+	t.Init = ids[initial.Name]
+	if len(traps) > 0 {
+		if symbols.function.GetVariable(pol, "rejected") != nil {
+			return nil, nil, errors.New("it can't have a variable called rejected, as that is the flag that is set when it enters a rejecting trap")
+		}
+		decls = append(decls, fmt.Sprintf("bool rejected = %t; // set when the policy enters a rejecting trap (%s)", stringSliceContains(traps, initial.Name), strings.Join(traps, ", ")))
+	}
+	t.Declaration = strings.Join(decls, "\n")
+
+	//the transitions of each state
+	for _, st := range pol.States {
+		edges, err := symbols.stateEdges(pmon, st.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := t.addEdges(symbols, ids[st.Name], edges, nil, traps, ids); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	//on the first tick, the transitions are taken from the initial state if its initial condition holds, and InitialElse if it doesn't
+	top := t.location(t.Init)
+	if pmon.Policy.InitialGuard != nil {
+		start := uppaalLocation{ID: "id_start", Name: "_start", X: top.X, Y: top.Y - 200, Comment: "before the first tick, which checks the initial condition " + initial.InitialCondition}
+		t.Locations = append(t.Locations, start)
+		for _, first := range []struct {
+			state  string
+			negate bool
+		}{{initial.Name, false}, {initial.InitialElse, true}} {
+			cond, err := symbols.guard(pmon.Policy.InitialGuard, first.negate)
+			if err != nil {
+				return nil, nil, fmt.Errorf("initial condition %s: %s", initial.InitialCondition, err.Error())
+			}
+			edges, err := symbols.stateEdges(pmon, first.state)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err := t.addEdges(symbols, start.ID, edges, cond, traps, ids); err != nil {
+				return nil, nil, err
+			}
+		}
+		t.Init = start.ID
+	}
+	if len(clockInits) > 0 {
+		first := t.location(t.Init)
+		set := uppaalLocation{ID: "id_init", Name: "_init_timers", X: first.X, Y: first.Y - 200, Committed: true}
+		t.Locations = append(t.Locations, set)
+		t.Transitions = append(t.Transitions, uppaalTransition{Source: set.ID, Target: t.Init, Assign: strings.Join(clockInits, ",\n"), X: set.X + 10, Y: set.Y + 80})
+		t.Init = set.ID
+	}
+
+	var queries []uppaalQuery
+	if len(traps) > 0 {
+		queries = append(queries, uppaalQuery{Formula: "A[] !" + pol.Name + ".rejected", Comment: "policy " + pol.Name + " never enters a rejecting trap"})
+	}
+	return t, queries, nil
+}
+
+//addEdges adds an UPPAAL edge from location source for each part of each edge (when pre also holds, if it isn't nil)
+func (t *uppaalTemplate) addEdges(symbols uppaalSymbols, source string, edges []uppaalEdge, pre uppaalDNF, traps []string, ids map[string]string) error {
+	src := t.location(source)
+	for _, e := range edges {
+		var assigns []string
+		if e.tr != nil {
+			for _, ex := range e.tr.Expressions {
+				assign, err := symbols.assignment(ex)
+				if err != nil {
+					return fmt.Errorf("transition %s -> %s: %s", e.tr.Source, e.tr.Destination, err.Error())
+				}
+				assigns = append(assigns, assign)
+			}
+		}
+		if stringSliceContains(traps, e.dest) && e.dest != src.Name {
+			assigns = append(assigns, "rejected = true")
+		}
+		when := e.when
+		if pre != nil {
+			when = pre.and(when)
+		}
+		dst := t.location(ids[e.dest])
+		for _, conj := range when {
+			tr := uppaalTransition{Source: source, Target: dst.ID, Guard: uppaalConjunction(conj), Sync: "tick_" + symbols.policy.Name + "?", Assign: strings.Join(assigns, ",\n")}
+			//labels go halfway along the edge, below any others between the same locations
+			n := 0
+			for _, other := range t.Transitions {
+				if other.Source == tr.Source && other.Target == tr.Target {
+					n++
+				}
+			}
+			if tr.Source == tr.Target {
+				//self loops go outwards from the middle of the circle
+				dx, dy := float64(src.X), float64(src.Y)
+				if l := math.Hypot(dx, dy); l > 0 {
+					dx, dy = dx/l, dy/l
+				} else {
+					dx, dy = 0, -1
+				}
+				for _, a := range []float64{-0.4, 0.4} {
+					tr.Nails = append(tr.Nails, uppaalPoint{src.X + int(90*(dx*math.Cos(a)-dy*math.Sin(a))), src.Y + int(90*(dx*math.Sin(a)+dy*math.Cos(a)))})
+				}
+				tr.X, tr.Y = src.X+int(110*dx), src.Y+int(110*dy)+60*n
+			} else {
+				tr.X, tr.Y = (src.X+dst.X)/2, (src.Y+dst.Y)/2+60*n
+			}
+			t.Transitions = append(t.Transitions, tr)
+		}
+	}
+	return nil
+}
+
+//stateEdges returns the ways out of state st on a tick: each transition from st, when it is the first whose guard holds
+//(or, for the else transition, when none do), and, if st has no else transition, staying in st when no guard holds
+func (symbols uppaalSymbols) stateEdges(pmon *rvdef.PMonitor, st string) ([]uppaalEdge, error) {
+	var edges []uppaalEdge
+	noneYet, hasElse := uppaalDNF{{}}, false
+	for i := range pmon.Policy.Transitions {
+		tr := &pmon.Policy.Transitions[i]
+		if tr.Source != st {
+			continue
+		}
+		guard, err := symbols.guard(tr.STGuard, false)
+		if err != nil {
+			return nil, fmt.Errorf("transition %s -> %s: %s", tr.Source, tr.Destination, err.Error())
+		}
+		if tr.Else {
+			edges, hasElse = append(edges, uppaalEdge{tr: tr, dest: tr.Destination, when: guard}), true
+			continue
+		}
+		edges = append(edges, uppaalEdge{tr: tr, dest: tr.Destination, when: noneYet.and(guard)})
+		notGuard, err := symbols.guard(tr.STGuard, true)
+		if err != nil {
+			return nil, fmt.Errorf("transition %s -> %s: %s", tr.Source, tr.Destination, err.Error())
+		}
+		noneYet = noneYet.and(notGuard)
+	}
+	if !hasElse {
+		edges = append(edges, uppaalEdge{dest: st, when: noneYet})
+	}
+	return edges, nil
+}
+
+//declare returns the UPPAAL declaration of a variable (without the semicolon)
+func (symbols uppaalSymbols) declare(v rvdef.Variable) (string, error) {
+	var typ string
+	switch {
+	case v.IsStruct():
+		var fields []string
+		for _, field := range v.Fields {
+			f := field
+			f.InitialValue = ""
+			decl, err := symbols.declare(f)
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, decl+";")
+		}
+		typ = "struct { " + strings.Join(fields, " ") + " }"
+	case symbols.function.GetEnum(v.Type) != nil:
+		typ = v.Type
+	case strings.ToLower(v.Type) == "bool":
+		typ = "bool"
+	case v.IsDTimer():
+		typ = "clock"
+	case isFloatVariable(v):
+		typ = "double"
+	default:
+		lo, hi, _ := smvIntegerRange(v.Type)
+		typ = fmt.Sprintf("int[%d,%d]", lo, hi)
+	}
+	if v.Constant {
+		typ = "const " + typ
+	}
+
+	decl := typ + " " + v.Name
+	if v.ArraySize != "" {
+		size, ok := symbols.function.GetIntegerConstant(symbols.policy, v.ArraySize)
+		if !ok {
+			return "", fmt.Errorf("can't work out the array size %s", v.ArraySize)
+		}
+		decl += "[" + strconv.Itoa(size) + "]"
+	}
+	if v.InitialValue == "" || v.IsDTimer() || v.IsStruct() {
+		return decl, nil
+	}
+	if initial := v.GetInitialArray(); initial != nil {
+		var vals []string
+		for _, val := range initial {
+			c, err := symbols.expression(val)
+			if err != nil {
+				return "", fmt.Errorf("initial value %s: %s", val, err.Error())
+			}
+			vals = append(vals, c)
+		}
+		return decl + " = {" + strings.Join(vals, ", ") + "}", nil
+	}
+	c, err := symbols.expression(v.InitialValue)
+	if err != nil {
+		return "", fmt.Errorf("initial value %s: %s", v.InitialValue, err.Error())
+	}
+	return decl + " = " + c, nil
+}
+
+//isFloatVariable returns true if v is a float or a double (or an array of them)
+func isFloatVariable(v rvdef.Variable) bool {
+	v.ArraySize = ""
+	return v.IsFloatType()
+}
+
+//assignment converts an assignment into an UPPAAL update
+func (symbols uppaalSymbols) assignment(ex rvdef.PExpression) (string, error) {
+	root, _ := rvdef.SplitAccessPath(ex.VarName)
+	v := symbols.function.GetVariable(symbols.policy, root)
+	if v == nil || v.Constant || symbols.function.InterfaceList.HasIONamed(true, root) {
+		return "", fmt.Errorf("can't assign to %s", ex.VarName)
+	}
+	targetExpr, perr := rvdef.ParseSTExpression(symbols.policy.Name, ex.VarName)
+	if perr != nil {
+		return "", fmt.Errorf("assignment to %s: %s", ex.VarName, perr.Error())
+	}
+	target, _, err := symbols.value(targetExpr) //clocks can be reset, just not read
+	if err != nil {
+		return "", fmt.Errorf("assignment to %s: %s", ex.VarName, err.Error())
+	}
+	value, err := symbols.expression(ex.Value)
+	if err != nil {
+		return "", fmt.Errorf("assignment to %s: %s", ex.VarName, err.Error())
+	}
+	return target + " = " + value, nil
+}
+
+//expression parses and converts an expression that doesn't read any clocks
+func (symbols uppaalSymbols) expression(s string) (string, error) {
+	expr, perr := rvdef.ParseSTExpression(symbols.policy.Name, s)
+	if perr != nil {
+		return "", errors.New(perr.Error())
+	}
+	c, clock, err := symbols.value(expr)
+	if err != nil {
+		return "", err
+	}
+	if clock {
+		return "", fmt.Errorf("dtimers can only be read in guards, by comparing them (in '%s')", s)
+	}
+	return c, nil
+}
+
+//guard converts expr (or its negation) into a disjunction of conjunctions, where each clock is only compared directly
+//with something that doesn't read a clock (e.g. v >= 5), so that each conjunction can be an UPPAAL guard
+//The parts that don't read a clock are left as they are.
+func (symbols uppaalSymbols) guard(expr stcompilerlib.STExpression, negate bool) (uppaalDNF, error) {
+	c, clock, err := symbols.value(expr)
+	if err != nil {
+		return nil, err
+	}
+	if !clock {
+		if negate {
+			return uppaalDNF{{{Text: "!" + c, Not: c}}}, nil
+		}
+		return uppaalDNF{{{Text: c, Not: "!" + c}}}, nil
+	}
+
+	op := expr.HasOperator()
+	if op == nil {
+		return nil, fmt.Errorf("dtimer %s can only be compared, not used as a bool", c)
+	}
+	//arguments are in reverse order
+	var args []stcompilerlib.STExpression
+	stArgs := expr.GetArguments()
+	for i := len(stArgs) - 1; i >= 0; i-- {
+		args = append(args, stArgs[i])
+	}
+
+	tok := op.GetToken()
+	switch tok {
+	case "not":
+		return symbols.guard(args[0], !negate)
+	case "and", "or":
+		a, err := symbols.guard(args[0], negate)
+		if err != nil {
+			return nil, err
+		}
+		b, err := symbols.guard(args[1], negate)
+		if err != nil {
+			return nil, err
+		}
+		if (tok == "and") != negate {
+			return a.and(b), nil
+		}
+		return append(a, b...), nil
+	}
+	if _, ok := uppaalSwapped[tok]; !ok {
+		return nil, fmt.Errorf("dtimers can only be compared (in '%s')", c)
+	}
+
+	left, leftClock, _ := symbols.value(args[0])
+	right, rightClock, _ := symbols.value(args[1])
+	clockExpr, other := args[0], right
+	if leftClock && rightClock {
+		return nil, fmt.Errorf("dtimers can't be compared with each other (in '%s')", c)
+	}
+	if rightClock {
+		tok, clockExpr, other, left = uppaalSwapped[tok], args[1], left, right
+	}
+	if clockExpr.HasOperator() != nil {
+		return nil, fmt.Errorf("dtimers can only be compared directly, not after arithmetic (in '%s')", c)
+	}
+	if negate {
+		tok = uppaalNegated[tok]
+	}
+	atom := func(tok string) uppaalAtom {
+		return uppaalAtom{Text: left + " " + uppaalOperators[tok] + " " + other, Not: left + " " + uppaalOperators[uppaalNegated[tok]] + " " + other}
+	}
+	if tok == "<>" {
+		return uppaalDNF{{atom("<")}, {atom(">")}}, nil
+	}
+	return uppaalDNF{{atom(tok)}}, nil
+}
+
+//value recursively converts expr into fully parenthesised UPPAAL, and says whether it reads a clock
+func (symbols uppaalSymbols) value(expr stcompilerlib.STExpression) (string, bool, error) {
+	if expr == nil {
+		return "", false, errors.New("missing expression")
+	}
+	op := expr.HasOperator()
+	if op == nil {
+		return symbols.compileValue(expr.HasValue())
+	}
+
+	//arguments are in reverse order
+	var args []string
+	clock := false
+	stArgs := expr.GetArguments()
+	for i := len(stArgs) - 1; i >= 0; i-- {
+		arg, argClock, err := symbols.value(stArgs[i])
+		if err != nil {
+			return "", false, err
+		}
+		args = append(args, arg)
+		clock = clock || argClock
+	}
+
+	tok := op.GetToken()
+	switch {
+	case tok == "not":
+		return "(!" + args[0] + ")", clock, nil
+	case tok == "`": //unary minus
+		return "(-" + args[0] + ")", clock, nil
+	case uppaalOperators[tok] != "":
+		return "(" + args[0] + " " + uppaalOperators[tok] + " " + args[1] + ")", clock, nil
+	}
+
+	name := rvdef.FunctionName(tok)
+	if len(args) == 1 && (symbols.function.GetEnum(name) != nil || (rvdef.Variable{Type: name}).IsIntegerType()) {
+		return args[0], clock, nil //UPPAAL's integers have no width, so integer casts do nothing (beyond the range of what they are assigned to)
+	}
+	return "", false, fmt.Errorf("'%s' can't be converted to UPPAAL", name)
+}
+
+//compileValue converts a single value (a literal, or a variable with an optional access path) into UPPAAL
+func (symbols uppaalSymbols) compileValue(val string) (string, bool, error) {
+	if strings.EqualFold(val, "true") || strings.EqualFold(val, "false") {
+		return strings.ToLower(val), false, nil
+	}
+	if i, err := strconv.ParseInt(val, 0, 64); err == nil {
+		return strconv.FormatInt(i, 10), false, nil
+	}
+	if _, err := strconv.ParseFloat(val, 64); err == nil {
+		return val, false, nil
+	}
+	for _, e := range symbols.function.Enums {
+		if e.HasMember(val) {
+			return val, false, nil
+		}
+	}
+
+	root, path := rvdef.SplitAccessPath(val)
+	v := symbols.function.GetVariable(symbols.policy, root)
+	if v == nil {
+		return "", false, fmt.Errorf("unknown identifier '%s'", root)
+	}
+	rest, err := mapAccessPath(val, path, func(index string) (string, error) {
+		i, err := symbols.expression(index)
+		if err != nil {
+			return "", fmt.Errorf("bad index in '%s': %s", val, err.Error())
+		}
+		return i, nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return root + rest, v.IsDTimer() && !symbols.function.InterfaceList.HasIONamed(true, root), nil
+}
@@ -0,0 +1,50 @@
+package rvc
+
+import (
+	"text/template"
+)
+
+const rvcUPPAALTemplate = `{{define "functionUPPAAL"}}{{$sys := getUPPAALSystem (index .Functions .FunctionIndex)}}<?xml version="1.0" encoding="utf-8"?>
+<!DOCTYPE nta PUBLIC '-//Uppaal Team//DTD Flat System 1.1//EN' 'http://www.it.uu.se/research/group/darts/uppaal/flat-1_2.dtd'>
+<nta>
+	<declaration>{{html $sys.Declaration}}</declaration>{{range $t := $sys.Templates}}
+	<template>
+		<name>{{$t.Name}}</name>
+		<declaration>{{html $t.Declaration}}</declaration>{{range $t.Locations}}
+		<location id="{{.ID}}" x="{{.X}}" y="{{.Y}}"{{if .Color}} color="{{.Color}}"{{end}}>
+			<name x="{{sub .X 20}}" y="{{sub .Y 34}}">{{.Name}}</name>{{if .Invariant}}
+			<label kind="invariant" x="{{sub .X 20}}" y="{{add .Y 17}}">{{html .Invariant}}</label>{{end}}{{if .Comment}}
+			<label kind="comments" x="{{sub .X 20}}" y="{{add .Y 34}}">{{html .Comment}}</label>{{end}}{{if .Committed}}
+			<committed/>{{end}}
+		</location>{{end}}
+		<init ref="{{$t.Init}}"/>{{range $t.Transitions}}
+		<transition>
+			<source ref="{{.Source}}"/>
+			<target ref="{{.Target}}"/>{{if .Select}}
+			<label kind="select" x="{{.X}}" y="{{sub .Y 17}}">{{html .Select}}</label>{{end}}{{if .Guard}}
+			<label kind="guard" x="{{.X}}" y="{{.Y}}">{{html .Guard}}</label>{{end}}{{if .Sync}}
+			<label kind="synchronisation" x="{{.X}}" y="{{add .Y 17}}">{{html .Sync}}</label>{{end}}{{if .Assign}}
+			<label kind="assignment" x="{{.X}}" y="{{add .Y 34}}">{{html .Assign}}</label>{{end}}{{range .Nails}}
+			<nail x="{{.X}}" y="{{.Y}}"/>{{end}}
+		</transition>{{end}}
+	</template>{{end}}
+	<system>{{html $sys.System}}</system>
+	<queries>{{range $sys.Queries}}
+		<query>
+			<formula>{{html .Formula}}</formula>
+			<comment>{{html .Comment}}</comment>
+		</query>{{end}}
+	</queries>
+</nta>
+{{end}}
+`
+
+var uppaalTemplateFuncMap = template.FuncMap{
+	"getUPPAALSystem": getUPPAALSystem,
+
+	"sub": sub,
+
+	"add": add,
+}
+
+var uppaalTemplates = template.Must(template.New("").Funcs(uppaalTemplateFuncMap).Parse(rvcUPPAALTemplate))
@@ -0,0 +1,106 @@
+package rvc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+func TestConvertUPPAAL(t *testing.T) {
+	ok := []rvdef.PTransition{
+		{Source: "s0", Destination: "s1", Condition: "A and h[LAST] > 2", Expressions: []rvdef.PExpression{{VarName: "v", Value: "0"}, {VarName: "n", Value: "n - 1"}}},
+		{Source: "s1", Destination: "bad", Condition: "(v >= 5 and n <> 0) or (3 < v and !A)"},
+		{Source: "s1", Destination: "s0", Else: true},
+	}
+
+	//a dtimer that doesn't start at 0 has to be set before the first tick
+	timed := modelMonitor("s1", ok...)
+	timed.Policies[0].InternalVars[0].InitialValue = "2"
+
+	tests := []struct {
+		mon  rvdef.Monitor
+		err  string
+		want []string
+	}{
+		{
+			mon: modelMonitor("s1", ok...),
+			want: []string{
+				"chan tick_P;\ntypedef int[0,1] Mode;\nconst int IDLE = 0;\nconst int ON = 1;\nbool A;\nMode mode;\nint[0,255] h[2];",
+				"<name>Environment</name>",
+				`<label kind="invariant" x="-20" y="17">t &lt;= 1</label>`,
+				"i0 : int[0,1],\ni1 : int[0,255],\ni2 : int[0,255],\ni3 : Mode",
+				"A = (i0 == 1),\nh[0] = i1,\nh[1] = i2,\nmode = i3,\nt = 0",
+				"<name>P</name>",
+				"clock v;\nint[-128,127] n = 3;\nconst int[0,255] LAST = 1;\nbool rejected = false;",
+				`color="#ff0000">`,
+				//on the first tick, the initial condition picks the state that the transitions are taken from
+				"(mode == IDLE) &amp;&amp; (A &amp;&amp; (h[LAST] &gt; 2))",
+				"(mode == IDLE) &amp;&amp; !(A &amp;&amp; (h[LAST] &gt; 2))",
+				"!(mode == IDLE) &amp;&amp; v &gt;= 5 &amp;&amp; (n != 0)",
+				//the guard is split into one edge for each way it can hold, with the clock constraints at the top level
+				">v &gt;= 5 &amp;&amp; (n != 0)</label>",
+				">v &gt; 3 &amp;&amp; (!A)</label>",
+				">v &lt; 5 &amp;&amp; v &lt;= 3</label>",
+				">v &lt; 5 &amp;&amp; !(!A)</label>",
+				">!(n != 0) &amp;&amp; v &lt;= 3</label>",
+				">!(n != 0) &amp;&amp; !(!A)</label>",
+				"v = 0,\nn = (n - 1)",
+				"rejected = true",
+				"<formula>A[] !P.rejected</formula>",
+				"<system>system Environment, P;</system>",
+			},
+		},
+		{
+			mon:  timed,
+			want: []string{"<name x=\"-20\" y=\"-584\">_init_timers</name>", "<committed/>", "v = 2</label>"},
+		},
+		{mon: modelMonitor("s1", rvdef.PTransition{Source: "s0", Destination: "s1", Condition: "v + 1 > 2"}), err: "dtimers can only be compared directly"},
+		{mon: modelMonitor("s1", rvdef.PTransition{Source: "s0", Destination: "s1", Condition: "A", Expressions: []rvdef.PExpression{{VarName: "n", Value: "v"}}}), err: "dtimers can only be read in guards"},
+		{mon: modelMonitor("s1", rvdef.PTransition{Source: "s0", Destination: "s1", Condition: "sqrt(n) > 2"}), err: "'sqrt' can't be converted to UPPAAL"},
+		{mon: modelMonitor("s1", rvdef.PTransition{Source: "s0", Destination: "s1", Condition: "A", Expressions: []rvdef.PExpression{{VarName: "A", Value: "false"}}}), err: "can't assign to A"},
+	}
+
+	for i, test := range tests {
+		conv, err := New("uppaal")
+		if err != nil {
+			t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+		}
+		conv.Funcs = []rvdef.Monitor{test.mon}
+		outputs, err := conv.ConvertAll()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Test %d: error was %v, it should have been '%s'", i, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: error '%s' occurred when it shouldn't have", i, err.Error())
+		}
+		if len(outputs) != 1 || outputs[0].Name != "uppaal_m" || outputs[0].Extension != "xml" {
+			t.Fatalf("Test %d: outputs were %+v", i, outputs)
+		}
+
+		//it has to be well formed to load
+		dec := xml.NewDecoder(bytes.NewReader(outputs[0].Contents))
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("Test %d: the XML isn't well formed: %s", i, err.Error())
+			}
+		}
+
+		model := string(outputs[0].Contents)
+		for _, want := range test.want {
+			if !strings.Contains(model, want) {
+				t.Errorf("Test %d: model doesn't have '%s':\n%s", i, want, model)
+			}
+		}
+	}
+}
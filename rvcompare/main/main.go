@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+	"github.com/PRETgroup/easy-rv/rvparser"
+)
+
+var (
+	aFileName   = flag.String("a", "", "Specifies the first (e.g. the new) version of the monitor, as an .erv or .xml file.")
+	bFileName   = flag.String("b", "", "Specifies the second (e.g. the old) version of the monitor, as an .erv or .xml file.")
+	monitorName = flag.String("monitor", "", "The name of the monitor to compare (only needed if the files have more than one).")
+	policyName  = flag.String("policy", "", "The name of the policy to compare. If blank, every policy is compared with the policy of the same name in the other file.")
+	refine      = flag.Bool("refine", false, "Set this to true to check that the first version flags every violation that the second does, rather than that they are equivalent.")
+	maxDepth    = flag.Int("depth", 1000, "The longest input trace to try (0 for no limit).")
+	maxStates   = flag.Int("states", 1000000, "The most pairs of states to explore (0 for no limit).")
+)
+
+func main() {
+	flag.Parse()
+
+	if *aFileName == "" || *bFileName == "" {
+		fmt.Println("You need to specify two files to compare! Check out -help for options")
+		return
+	}
+
+	a, err := rvparser.LoadMonitor(*aFileName, *monitorName)
+	if err != nil {
+		fmt.Printf("Error loading '%s': %s\n", *aFileName, err.Error())
+		os.Exit(2)
+	}
+	b, err := rvparser.LoadMonitor(*bFileName, *monitorName)
+	if err != nil {
+		fmt.Printf("Error loading '%s': %s\n", *bFileName, err.Error())
+		os.Exit(2)
+	}
+
+	opts := rvdef.CompareOptions{MaxDepth: *maxDepth, MaxStates: *maxStates}
+	relation := "equivalent"
+	if *refine {
+		opts.Mode = rvdef.CompareRefinement
+		relation = "a refinement"
+	}
+
+	different := false
+	for i, pol := range a.Policies {
+		if *policyName != "" && pol.Name != *policyName {
+			continue
+		}
+		j := -1
+		for k := range b.Policies {
+			if b.Policies[k].Name == pol.Name {
+				j = k
+			}
+		}
+		if j == -1 {
+			fmt.Printf("Policy %s: it isn't in '%s'\n", pol.Name, *bFileName)
+			different = true
+			continue
+		}
+
+		res, err := rvdef.ComparePolicies(a, i, b, j, opts)
+		if err != nil {
+			fmt.Printf("Error comparing policy %s: %s\n", pol.Name, err.Error())
+			os.Exit(2)
+		}
+		switch {
+		case res.Same && res.Complete:
+			fmt.Printf("Policy %s: is %s (explored %d pairs of states)\n", pol.Name, relation, res.Explored)
+		case res.Same:
+			fmt.Printf("Policy %s: no difference found, but this isn't a proof, as the search was stopped early or the inputs tried can't cover every guard (explored %d pairs of states)\n", pol.Name, res.Explored)
+		default:
+			different = true
+			fmt.Printf("Policy %s: is NOT %s, as shown by this trace of %d tick(s):\n", pol.Name, relation, len(res.Trace))
+			for t, in := range res.Trace {
+				fmt.Printf("\ttick %d: %s => a: %s, b: %s\n", t+1, in.String(), rvdef.VerdictName(res.VerdictsA[t]), rvdef.VerdictName(res.VerdictsB[t]))
+			}
+		}
+	}
+	if different {
+		os.Exit(1)
+	}
+}
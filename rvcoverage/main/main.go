@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvc"
+)
+
+var (
+	htmlFileName = flag.String("html", "", "If set, a HTML coverage report is also written to this file.")
+	srcLocation  = flag.String("src", "", "The directory to look for the .erv source files in. If blank, they are looked for where they were when they were parsed.")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] dump1.txt [dump2.txt ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Println("You need to specify at least one coverage dump (as written by the _coverage_export function)! Check out -help for options")
+		return
+	}
+
+	var runs [][]rvc.CoverageCount
+	for _, name := range flag.Args() {
+		dump, err := ioutil.ReadFile(name)
+		if err != nil {
+			fmt.Printf("Error reading coverage dump '%s': %s\n", name, err.Error())
+			os.Exit(2)
+		}
+		counts, err := rvc.ParseCoverage(name, bytes.NewReader(dump))
+		if err != nil {
+			fmt.Println("Error reading coverage dump:", err.Error())
+			os.Exit(2)
+		}
+		runs = append(runs, counts)
+	}
+	counts, err := rvc.MergeCoverage(runs...)
+	if err != nil {
+		fmt.Println("Error merging coverage dumps:", err.Error())
+		os.Exit(2)
+	}
+
+	//read in whichever source files can be found
+	sources := make(map[string][]string)
+	for _, c := range counts {
+		if _, ok := sources[c.SourceFile]; ok || c.SourceFile == "" {
+			continue
+		}
+		if text, err := readSource(c.SourceFile); err == nil {
+			sources[c.SourceFile] = strings.Split(strings.Replace(string(text), "\r\n", "\n", -1), "\n")
+		} else {
+			fmt.Printf("Warning: can't read source file '%s', so its lines won't be shown\n", c.SourceFile)
+		}
+	}
+
+	rvc.WriteCoverageText(os.Stdout, counts, sources)
+
+	if *htmlFileName != "" {
+		output := &bytes.Buffer{}
+		if err := rvc.WriteCoverageHTML(output, counts, sources); err != nil {
+			fmt.Println("Error making HTML report:", err.Error())
+			os.Exit(2)
+		}
+		fmt.Printf("Writing %s\n", *htmlFileName)
+		if err := ioutil.WriteFile(*htmlFileName, output.Bytes(), 0644); err != nil {
+			fmt.Println("Error during file write:", err.Error())
+			os.Exit(2)
+		}
+	}
+
+	for _, c := range counts {
+		if c.Count == 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+//readSource reads a source file, looking for it in the -src directory (if one was given)
+func readSource(name string) ([]byte, error) {
+	if *srcLocation == "" {
+		return ioutil.ReadFile(name)
+	}
+	text, err := ioutil.ReadFile(filepath.Join(*srcLocation, name))
+	if err != nil {
+		text, err = ioutil.ReadFile(filepath.Join(*srcLocation, filepath.Base(name)))
+	}
+	return text, err
+}
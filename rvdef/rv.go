@@ -2,6 +2,7 @@ package rvdef
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 )
@@ -10,8 +11,12 @@ import (
 type Monitor struct {
 	Name string `xml:"Name,attr"`
 
+	Enums []Enum `xml:"Enum,omitempty"`
+
 	InterfaceList
 
+	Predicates []Predicate `xml:"Predicate,omitempty"`
+
 	Policies []Policy `xml:"Policy"`
 }
 
@@ -28,6 +33,26 @@ func (il InterfaceList) HasIONamed(input bool, s string) bool {
 	return false
 }
 
+//An Enum is an enumerated type, which can be used as the type of I/O or internal vars
+type Enum struct {
+	Name    string   `xml:"Name,attr"`
+	Members []string `xml:"Member"`
+}
+
+//HasMember returns true if s is one of the members of the Enum
+func (e Enum) HasMember(s string) bool {
+	return stringSliceContains(e.Members, s)
+}
+
+//A Predicate is a named (and optionally parameterised) guard, which is expanded wherever it is referenced
+type Predicate struct {
+	Name   string   `xml:"Name,attr"`
+	Params []string `xml:"Param,omitempty"`
+	Body   string   `xml:"Body"`
+
+	DebugInfo `xml:"-"` //where the predicate was defined
+}
+
 //A Variable is used to store I/O or internal var data
 type Variable struct {
 	Name         string `xml:"Name,attr"`
@@ -36,13 +61,16 @@ type Variable struct {
 	ArraySize    string `xml:"ArraySize,attr,omitempty"`
 	InitialValue string `xml:"InitialValue,attr,omitempty"`
 	Comment      string `xml:"Comment,attr"`
+
+	Fields []Variable `xml:"Field,omitempty"` //if this is a struct, the members of it
 }
 
 //GetInitialArray returns a formatted initial array if there is one to do so
+//(it returns nil if v isn't an array, or has no initial value)
 func (v Variable) GetInitialArray() []string {
 	//if cannot parse an array size then give up
 	_, err := strconv.Atoi(v.ArraySize)
-	if err != nil {
+	if err != nil || v.InitialValue == "" {
 		return nil
 	}
 
@@ -58,6 +86,92 @@ func (v Variable) GetInitialArray() []string {
 	return raws
 }
 
+//errAccessPath is returned when an access path can't be resolved
+var errAccessPath = errors.New("can't resolve access path")
+
+//IsStruct returns true if the Variable is a struct (i.e. it has fields)
+func (v Variable) IsStruct() bool {
+	return len(v.Fields) > 0
+}
+
+//GetMember follows an access path (e.g. ".temp" or "[2].b") from Variable v,
+//and returns the struct member or array element that it refers to
+func (v Variable) GetMember(path string) (*Variable, error) {
+	return v.getMember(path, nil)
+}
+
+//getMember follows an access path from Variable v (see GetMember),
+//calling onIndex (if it isn't nil) for every array index in the path
+func (v Variable) getMember(path string, onIndex func(array Variable, index string) error) (*Variable, error) {
+	cur := v
+	for path != "" {
+		if path[0] == '[' {
+			if cur.ArraySize == "" {
+				return nil, fmt.Errorf("%s: %s is not an array", errAccessPath.Error(), cur.Name)
+			}
+			depth := 0
+			end := -1
+			for i, c := range path {
+				if c == '[' {
+					depth++
+				} else if c == ']' {
+					depth--
+					if depth == 0 {
+						end = i
+						break
+					}
+				}
+			}
+			if end == -1 {
+				return nil, fmt.Errorf("%s: missing ']'", errAccessPath.Error())
+			}
+			if onIndex != nil {
+				if err := onIndex(cur, path[1:end]); err != nil {
+					return nil, err
+				}
+			}
+			cur.ArraySize = "" //the element of an array has the same type, but isn't an array
+			cur.InitialValue = ""
+			path = path[end+1:]
+			continue
+		}
+		if path[0] != '.' {
+			return nil, fmt.Errorf("%s: unexpected '%s'", errAccessPath.Error(), path)
+		}
+		if cur.ArraySize != "" {
+			return nil, fmt.Errorf("%s: %s is an array and must be indexed", errAccessPath.Error(), cur.Name)
+		}
+		name, rest := SplitAccessPath(path[1:])
+		found := false
+		for _, field := range cur.Fields {
+			if field.Name == name {
+				cur = field
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: %s has no member named %s", errAccessPath.Error(), cur.Name, name)
+		}
+		path = rest
+	}
+	return &cur, nil
+}
+
+//IsIntegerType returns true if the Variable is of an integer type (including chars and dtimers)
+func (v Variable) IsIntegerType() bool {
+	switch strings.ToLower(v.Type) {
+	case "char", "uint8_t", "uint16_t", "uint32_t", "uint64_t", "int8_t", "int16_t", "int32_t", "int64_t", "dtimer_t":
+		return v.ArraySize == "" && !v.IsStruct()
+	}
+	return false
+}
+
+//IsFloatType returns true if the Variable is a float or a double
+func (v Variable) IsFloatType() bool {
+	return v.ArraySize == "" && getTypeClass(v) == typeFloat
+}
+
 //IsDTimer returns true if DTimer
 func (v Variable) IsDTimer() bool {
 	return strings.ToLower(v.Type) == "dtimer_t"
@@ -67,6 +181,7 @@ func (v Variable) IsDTimer() bool {
 type Policy struct {
 	Name         string        `xml:"Name,attr"`
 	InternalVars []Variable    `xml:"InternalVars>VarDeclaration,omitempty"`
+	Predicates   []Predicate   `xml:"Predicate,omitempty"`
 	States       []PState      `xml:"Machine>PState"`
 	Transitions  []PTransition `xml:"Machine>PTransition,omitempty"`
 }
@@ -76,6 +191,10 @@ type PState struct {
 	Name            string
 	FinalStatusType bool //if set to true, this stops being "currently xxx" and becomes just "xxx" when checking state
 	Accepting       bool //if set to true, this returns "true" when checking state, if set to false, it returns "false"
+
+	Initial          bool   `xml:",omitempty"` //if set to true, this is the state that the policy starts in
+	InitialCondition string `xml:",omitempty"` //for the initial state, a guard that must hold on the first tick (if there is one)
+	InitialElse      string `xml:",omitempty"` //the state to start in instead if InitialCondition doesn't hold on the first tick
 }
 
 //PTransition is a transition between PState in a Policy (mealy machine transitions)
@@ -84,6 +203,9 @@ type PTransition struct {
 	Destination string
 	Condition   string
 	Expressions []PExpression //output expressions associated with this transition
+	Else        bool          `xml:",omitempty"` //if set to true, this is only taken when no other transition from Source is (and Condition is unused)
+
+	DebugInfo //where the transition was defined
 }
 
 //PExpression is used to assign a var a value based on a PTransitions
@@ -122,6 +244,75 @@ func (f *Monitor) AddIO(intNames []string, typ string, size string, initialValue
 	return nil
 }
 
+//AddEnum adds an Enum to a given Monitor (if an Enum of that name isn't already present)
+func (f *Monitor) AddEnum(e Enum) {
+	if f.GetEnum(e.Name) != nil {
+		return
+	}
+	f.Enums = append(f.Enums, e)
+}
+
+//GetEnum returns the Enum with the given type name, or nil if there isn't one
+func (f Monitor) GetEnum(typ string) *Enum {
+	for i := 0; i < len(f.Enums); i++ {
+		if f.Enums[i].Name == typ {
+			return &f.Enums[i]
+		}
+	}
+	return nil
+}
+
+//GetEnumValues returns all possible values of a given variable if it is of an Enum type, or nil if it isn't
+func (f Monitor) GetEnumValues(v Variable) []string {
+	if e := f.GetEnum(v.Type); e != nil {
+		return e.Members
+	}
+	return nil
+}
+
+//GetStructTypes returns one example Variable for each struct type used in the Monitor (by the I/O or by policy internals)
+//Types which are used as members of other types are returned before the types which use them
+func (f Monitor) GetStructTypes() []Variable {
+	var types []Variable
+	seen := make(map[string]bool)
+	var add func(v Variable)
+	add = func(v Variable) {
+		if !v.IsStruct() || seen[v.Type] {
+			return
+		}
+		seen[v.Type] = true
+		for _, field := range v.Fields {
+			add(field)
+		}
+		types = append(types, Variable{Type: v.Type, Fields: v.Fields})
+	}
+	for _, v := range f.InterfaceList {
+		add(v)
+	}
+	for _, p := range f.Policies {
+		for _, v := range p.InternalVars {
+			add(v)
+		}
+	}
+	return types
+}
+
+//GetPredicate returns the Predicate with the given name that can be used in the Policy p,
+// searching the predicates of the Policy before those of the Monitor, or nil if there isn't one
+func (f Monitor) GetPredicate(p Policy, name string) *Predicate {
+	for i := 0; i < len(p.Predicates); i++ {
+		if p.Predicates[i].Name == name {
+			return &p.Predicates[i]
+		}
+	}
+	for i := 0; i < len(f.Predicates); i++ {
+		if f.Predicates[i].Name == name {
+			return &f.Predicates[i]
+		}
+	}
+	return nil
+}
+
 //AddPolicy adds a Policy to an Monitor
 func (f *Monitor) AddPolicy(name string) {
 	f.Policies = append(f.Policies, Policy{Name: name})
@@ -141,6 +332,36 @@ func (efb *Policy) AddState(name string, accepting bool) error {
 	return nil //TODO: add check (make sure name is unique)
 }
 
+//GetInitialState returns the state that the policy starts in, or nil if no state has been marked as initial
+func (efb Policy) GetInitialState() *PState {
+	for i := 0; i < len(efb.States); i++ {
+		if efb.States[i].Initial {
+			return &efb.States[i]
+		}
+	}
+	return nil
+}
+
+//HasState returns true if the policy has a state with the given name
+func (efb Policy) HasState(name string) bool {
+	for _, st := range efb.States {
+		if st.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+//GetState returns the state with the given name, or nil if the policy has no such state
+func (efb Policy) GetState(name string) *PState {
+	for i := 0; i < len(efb.States); i++ {
+		if efb.States[i].Name == name {
+			return &efb.States[i]
+		}
+	}
+	return nil
+}
+
 //AddTransition adds a state transition to a bfb
 func (efb *Policy) AddTransition(source string, dest string, cond string, expressions []PExpression) error {
 	efb.Transitions = append(efb.Transitions, PTransition{
@@ -152,7 +373,42 @@ func (efb *Policy) AddTransition(source string, dest string, cond string, expres
 	return nil //TODO: make sure [source] and [dest] can be found, make sure [cond] is valid, make sure [expressions] is valid
 }
 
+//AddElseTransition adds a state transition to a bfb that is only taken when no other transition from source is
+func (efb *Policy) AddElseTransition(source string, dest string, expressions []PExpression) error {
+	efb.Transitions = append(efb.Transitions, PTransition{
+		Source:      source,
+		Destination: dest,
+		Expressions: expressions,
+		Else:        true,
+	})
+	return nil
+}
+
+//GetTransitionCondition returns the guard of a transition
+//For else transitions, this is the complement of the guards of all the other transitions from the same source
+// (e.g. if s0 has transitions on a and b, the else transition from s0 is on !( ( a ) or ( b ) ))
+func (efb Policy) GetTransitionCondition(tr PTransition) string {
+	if !tr.Else {
+		return tr.Condition
+	}
+	var others []string
+	for _, other := range efb.Transitions {
+		if other.Source == tr.Source && !other.Else {
+			cond := other.Condition
+			if cond == "" {
+				cond = "true"
+			}
+			others = append(others, "( "+cond+" )")
+		}
+	}
+	if len(others) == 0 {
+		return "true"
+	}
+	return "!( " + strings.Join(others, " or ") + " )"
+}
+
+//DebugInfo stores where something was defined in the source files
 type DebugInfo struct {
-	SourceLine int
-	SourceFile string
+	SourceLine int    `xml:",omitempty"`
+	SourceFile string `xml:",omitempty"`
 }
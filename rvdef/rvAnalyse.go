@@ -0,0 +1,146 @@
+package rvdef
+
+import (
+	"fmt"
+	"strings"
+)
+
+//PolicyReport describes what verdicts a Policy can give, and which parts of it can never be used
+type PolicyReport struct {
+	Policy string
+
+	TrueReachable  bool //set if the definitive "true" verdict (0) can be reached from the initial state
+	FalseReachable bool //set if the definitive "false" verdict (3) can be reached from the initial state
+
+	CurrentlyOnly            []string      //reachable states which can only ever lead to "currently" verdicts (the non-monitorable parts of the policy)
+	Unreachable              []string      //states which can't be reached from the initial state
+	UnsatisfiableTransitions []PTransition //transitions whose guards can never hold
+}
+
+//Analyse returns a PolicyReport for each of the Monitor's policies
+//Its states are finalised (see FinaliseStates) to find which verdicts each state gives,
+// and transitions whose guards can never hold (see IsGuardSatisfiable) are left out when working out what can be reached.
+func (f Monitor) Analyse() []PolicyReport {
+	reports := make([]PolicyReport, 0, len(f.Policies))
+	for _, p := range f.Policies {
+		//FinaliseStates changes the states, so work on a copy of them
+		p.States = append([]PState(nil), p.States...)
+		p.FinaliseStates()
+		reports = append(reports, f.analysePolicy(p))
+	}
+	return reports
+}
+
+//analysePolicy makes the PolicyReport for p, which should already have been finalised
+func (f Monitor) analysePolicy(p Policy) PolicyReport {
+	report := PolicyReport{Policy: p.Name}
+
+	var transitions []PTransition
+	for _, tr := range p.Transitions {
+		if f.IsGuardSatisfiable(p, tr) {
+			transitions = append(transitions, tr)
+		} else {
+			report.UnsatisfiableTransitions = append(report.UnsatisfiableTransitions, tr)
+		}
+	}
+
+	var starts []string
+	if st := p.GetInitialState(); st != nil {
+		starts = append(starts, st.Name)
+		if st.InitialElse != "" {
+			starts = append(starts, st.InitialElse)
+		}
+	}
+	reachable := reachableStates(transitions, starts)
+
+	for _, st := range p.States {
+		if !reachable[st.Name] {
+			report.Unreachable = append(report.Unreachable, st.Name)
+			continue
+		}
+		if st.FinalStatusType && st.Accepting {
+			report.TrueReachable = true
+		}
+		if st.FinalStatusType && !st.Accepting {
+			report.FalseReachable = true
+		}
+
+		canDecide := false
+		for name := range reachableStates(transitions, []string{st.Name}) {
+			for _, other := range p.States {
+				if other.Name == name && other.FinalStatusType {
+					canDecide = true
+				}
+			}
+		}
+		if !canDecide {
+			report.CurrentlyOnly = append(report.CurrentlyOnly, st.Name)
+		}
+	}
+	return report
+}
+
+//reachableStates returns the names of all states that can be reached from the start states using the transitions
+// (including the start states themselves)
+func reachableStates(transitions []PTransition, starts []string) map[string]bool {
+	reached := make(map[string]bool)
+	S := append([]string(nil), starts...)
+	for len(S) > 0 {
+		v := S[len(S)-1]
+		S = S[:len(S)-1]
+		if reached[v] {
+			continue
+		}
+		reached[v] = true
+		for _, t := range transitions {
+			if t.Source == v && !reached[t.Destination] {
+				S = append(S, t.Destination)
+			}
+		}
+	}
+	return reached
+}
+
+//CanDetectViolation returns true if the policy can ever give the definitive "false" verdict
+func (r PolicyReport) CanDetectViolation() bool {
+	return r.FalseReachable
+}
+
+//String returns the PolicyReport as a human-readable summary
+func (r PolicyReport) String() string {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "NO"
+	}
+	lines := []string{
+		"Policy " + r.Policy + ":",
+		"\tdefinitive true (0) reachable: " + yesNo(r.TrueReachable),
+		"\tdefinitive false (3) reachable: " + yesNo(r.FalseReachable),
+	}
+	if !r.FalseReachable {
+		lines = append(lines, "\t(this policy can never detect a violation)")
+	}
+	if len(r.CurrentlyOnly) > 0 {
+		lines = append(lines, "\tstates that only give \"currently\" verdicts: "+strings.Join(r.CurrentlyOnly, ", "))
+	}
+	if len(r.Unreachable) > 0 {
+		lines = append(lines, "\tunreachable states: "+strings.Join(r.Unreachable, ", "))
+	}
+	for _, tr := range r.UnsatisfiableTransitions {
+		guard := tr.Condition
+		if tr.Else {
+			guard = "else"
+		}
+		loc := ""
+		if tr.SourceLine != 0 {
+			loc = fmt.Sprintf(" (Line %v)", tr.SourceLine)
+			if tr.SourceFile != "" {
+				loc = fmt.Sprintf(" (%s, Line %v)", tr.SourceFile, tr.SourceLine)
+			}
+		}
+		lines = append(lines, fmt.Sprintf("\ttransition %s -> %s can never be taken%s: %s", tr.Source, tr.Destination, loc, guard))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,115 @@
+package rvdef
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsGuardSatisfiable(t *testing.T) {
+	m := heaterMonitor("true", "IDLE", "")
+	m.InterfaceList = append(m.InterfaceList, Variable{Name: "ok", Type: "bool"}, Variable{Name: "f", Type: "float"})
+	m.Policies[0].InternalVars = append(m.Policies[0].InternalVars,
+		Variable{Name: "v", Type: "dtimer_t"},
+		Variable{Name: "MAX", Type: "int16_t", Constant: true, InitialValue: "10"},
+	)
+	tests := []struct {
+		Guard       string
+		Satisfiable bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"ok", true},
+		{"ok and !ok", false},
+		{"ok xor ok", false},
+		{"(ok or !ok) and temp > 3", true},
+		{"temp > 5 and temp < 10", true},
+		{"temp > 5 and temp < 6", false},
+		{"temp >= 5 and temp <= 5", true},
+		{"temp > MAX and temp < 5", false},
+		{"5 < temp and temp < MAX - 4", false},
+		{"temp > 40000", false},
+		{"count < 0", false},
+		{"v < 0", false},
+		{"temp = 1.5", false},
+		{"temp = 3 and temp <> 3", false},
+		{"f > 1.0 and f < 1.5", true},
+		{"f > 1.0 and f < 1.0", false},
+		{"f >= 1.0 and f <= 1.0 and f <> 1.0", false},
+		{"mode = IDLE and mode = HEATING", false},
+		{"mode <> IDLE and mode <> HEATING", true},
+		{"mode <> IDLE and mode <> HEATING and mode <> COOLING", false},
+		{"!(mode = last) and mode = last", false},
+		{"temp < count and temp > count", true}, //comparisons between variables aren't checked
+		{"3 > 5", false},
+		{"!(temp < 0 or temp >= 0)", false},
+	}
+	for _, test := range tests {
+		tr := PTransition{Source: "s0", Destination: "s0", Condition: test.Guard}
+		if sat := m.IsGuardSatisfiable(m.Policies[0], tr); sat != test.Satisfiable {
+			t.Errorf("%s: satisfiable was %v, it should have been %v", test.Guard, sat, test.Satisfiable)
+		}
+	}
+}
+
+func TestAnalyse(t *testing.T) {
+	m := Monitor{
+		Name:          "m",
+		InterfaceList: []Variable{{Name: "a", Type: "bool"}, {Name: "t", Type: "int16_t"}},
+		Policies: []Policy{
+			{
+				Name: "Vacuous",
+				States: []PState{
+					{Name: "s0", Accepting: true, Initial: true},
+					{Name: "s1", Accepting: false},
+					{Name: "s2", Accepting: true},
+				},
+				Transitions: []PTransition{
+					{Source: "s0", Destination: "s1", Condition: "a and !a"},
+					{Source: "s0", Destination: "s0", Condition: "a"},
+					{Source: "s0", Destination: "s0", Else: true},
+					{Source: "s1", Destination: "s1", Condition: "true"},
+				},
+			},
+			{
+				Name: "Live",
+				States: []PState{
+					{Name: "s0", Accepting: true, Initial: true},
+					{Name: "s1", Accepting: false},
+					{Name: "bad", Accepting: false},
+				},
+				Transitions: []PTransition{
+					{Source: "s0", Destination: "s1", Condition: "a"},
+					{Source: "s0", Destination: "s0", Condition: "!a"},
+					{Source: "s1", Destination: "s0", Condition: "t > 5"},
+					{Source: "s1", Destination: "bad", Condition: "t <= 5"},
+				},
+			},
+		},
+	}
+	reports := m.Analyse()
+	want := []PolicyReport{
+		{
+			Policy:                   "Vacuous",
+			CurrentlyOnly:            []string{"s0"},
+			Unreachable:              []string{"s1", "s2"},
+			UnsatisfiableTransitions: []PTransition{m.Policies[0].Transitions[0]},
+		},
+		{
+			Policy:         "Live",
+			FalseReachable: true,
+		},
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("Analyse gave\n%+v\nbut it should have given\n%+v", reports, want)
+	}
+	if reports[0].CanDetectViolation() || !reports[1].CanDetectViolation() {
+		t.Errorf("CanDetectViolation is wrong")
+	}
+
+	//the monitor's own states must not have been finalised
+	for _, st := range m.Policies[1].States {
+		if st.FinalStatusType {
+			t.Errorf("Analyse changed state %s of the monitor", st.Name)
+		}
+	}
+}
@@ -0,0 +1,433 @@
+package rvdef
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//CompareMode sets what ComparePolicies checks
+type CompareMode int
+
+const (
+	//CompareEquivalence checks that both policies always give the same verdict
+	CompareEquivalence CompareMode = iota
+	//CompareRefinement checks that the first policy flags every violation that the second does,
+	// i.e. whenever the second gives an unsafe verdict (currently false, or false), so does the first
+	CompareRefinement
+)
+
+//CompareOptions control how far ComparePolicies searches
+type CompareOptions struct {
+	Mode      CompareMode
+	MaxDepth  int //the longest input trace to try (0 for no limit)
+	MaxStates int //the most pairs of states to explore (0 for no limit)
+	MaxInputs int //the most different inputs to try on each tick (0 for the default of 4096)
+}
+
+//CompareResult is the result of ComparePolicies
+type CompareResult struct {
+	Same     bool //set if no distinguishing trace was found
+	Complete bool //set if every reachable pair of states was explored, and the inputs tried cover every guard (so Same is a proof, not just a lack of counterexamples)
+	Explored int  //how many pairs of states were explored
+
+	Trace     []Inputs //if Same isn't set, the shortest input trace that distinguishes the policies
+	VerdictsA []int    //the verdicts of the first policy after each tick of Trace
+	VerdictsB []int    //the verdicts of the second policy after each tick of Trace
+}
+
+//VerdictName returns the name of a verdict (as returned by PolicySimulator.Verdict)
+func VerdictName(verdict int) string {
+	switch verdict {
+	case 0:
+		return "true"
+	case 1:
+		return "currently true"
+	case 2:
+		return "currently false"
+	}
+	return "false"
+}
+
+//Verdict returns the verdict that a (finalised) state gives, as it is numbered by the generated C (see VerdictName)
+func (st PState) Verdict() int {
+	switch {
+	case st.Accepting && st.FinalStatusType:
+		return 0
+	case st.Accepting:
+		return 1
+	case st.FinalStatusType:
+		return 3
+	}
+	return 2
+}
+
+//ComparePolicies explores the product of policy policyA of Monitor a and policy policyB of Monitor b (which must have the same interface),
+// looking for the shortest input trace after which their verdicts differ (or, for CompareRefinement,
+// after which b's verdict is unsafe but a's isn't)
+// and 0, 1 and the values around every constant that a number is compared with (and, for floats, a value between each pair of them).
+// and 0, 1 and the values around every constant that a number is compared with.
+//dtimers stop counting a little after the largest constant in either policy, so that the search can finish.
+//These are only enough to prove that the policies are the same if each guard compares variables with constants,
+// otherwise the result isn't Complete.
+func ComparePolicies(a Monitor, policyA int, b Monitor, policyB int, opts CompareOptions) (*CompareResult, error) {
+	if err := sameInterface(a.InterfaceList, b.InterfaceList); err != nil {
+		return nil, err
+	}
+	simA, err := a.NewPolicySimulator(policyA)
+	if err != nil {
+		return nil, err
+	}
+	simB, err := b.NewPolicySimulator(policyB)
+	if err != nil {
+		return nil, err
+	}
+	limit := int64(math.Max(largestConstant(simA.p), largestConstant(simB.p))) + 2
+	simA.TimerLimit, simB.TimerLimit = limit, limit
+
+	alphabet, err := inputAlphabet(a, []Policy{simA.p, simB.p}, opts.MaxInputs)
+	if err != nil {
+		return nil, err
+	}
+
+	type node struct {
+		a, b   *PolicySimulator
+		parent int
+		input  int
+		depth  int
+	}
+	nodes := []node{{a: simA, b: simB, parent: -1}}
+	seen := map[string]bool{simA.Key() + "|" + simB.Key(): true}
+	result := &CompareResult{Same: true, Complete: alphabetComplete(a, simA.p) && alphabetComplete(b, simB.p)}
+
+	for i := 0; i < len(nodes); i++ {
+		n := nodes[i]
+		result.Explored++
+		if opts.MaxDepth > 0 && n.depth >= opts.MaxDepth {
+			result.Complete = false
+			continue
+		}
+		for j, in := range alphabet {
+			na, nb := n.a.Copy(), n.b.Copy()
+			if _, err := na.Step(in); err != nil {
+				return nil, fmt.Errorf("Policy %s: %s", na.p.Name, err.Error())
+			}
+			if _, err := nb.Step(in); err != nil {
+				return nil, fmt.Errorf("Policy %s: %s", nb.p.Name, err.Error())
+			}
+			va, vb := na.Verdict(), nb.Verdict()
+			if (opts.Mode == CompareEquivalence && va != vb) || (opts.Mode == CompareRefinement && vb >= 2 && va < 2) {
+				result.Same = false
+				//walk back up the tree to find the trace, and replay it to get the verdicts
+				trace := []Inputs{in}
+				for k := i; nodes[k].parent != -1; k = nodes[k].parent {
+					trace = append([]Inputs{alphabet[nodes[k].input]}, trace...)
+				}
+				result.Trace = trace
+				ra, rb := simA.Copy(), simB.Copy()
+				for _, tin := range trace {
+					ra.Step(tin)
+					rb.Step(tin)
+					result.VerdictsA = append(result.VerdictsA, ra.Verdict())
+					result.VerdictsB = append(result.VerdictsB, rb.Verdict())
+				}
+				return result, nil
+			}
+			key := na.Key() + "|" + nb.Key()
+			if seen[key] {
+				continue
+			}
+			if opts.MaxStates > 0 && len(nodes) >= opts.MaxStates {
+				result.Complete = false
+				continue
+			}
+			seen[key] = true
+			nodes = append(nodes, node{a: na, b: nb, parent: i, input: j, depth: n.depth + 1})
+		}
+	}
+	return result, nil
+}
+
+//sameInterface makes sure that two interfaces have the same variables (in any order)
+func sameInterface(a InterfaceList, b InterfaceList) error {
+	if len(a) != len(b) {
+		return fmt.Errorf("the interfaces are different (one has %d variables and the other has %d)", len(a), len(b))
+	}
+	for _, va := range a {
+		found := false
+		for _, vb := range b {
+			if va.Name == vb.Name {
+				found = true
+				if va.Type != vb.Type || va.ArraySize != vb.ArraySize {
+					return fmt.Errorf("the interfaces are different (%s is a %s in one and a %s in the other)", va.Name, typeWithSize(va), typeWithSize(vb))
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("the interfaces are different (%s is only in one of them)", va.Name)
+		}
+	}
+	return nil
+}
+
+//typeWithSize returns the type of v, with its array size if it has one
+func typeWithSize(v Variable) string {
+	if v.ArraySize != "" {
+		return v.Type + "[" + v.ArraySize + "]"
+	}
+	return v.Type
+}
+
+//policyExpressions returns all of the guards, initial conditions, and assignments of p, as ST trees
+func policyExpressions(p Policy) []stcompilerlib.STExpression {
+	var exprs []stcompilerlib.STExpression
+	if st := p.GetInitialState(); st != nil && st.InitialCondition != "" {
+		if expr, err := p.getSTGuard(PTransition{Condition: st.InitialCondition}); err == nil {
+			exprs = append(exprs, expr)
+		}
+	}
+	for _, tr := range p.Transitions {
+		if expr, err := p.getSTGuard(tr); err == nil {
+			exprs = append(exprs, expr)
+		}
+		for _, ex := range tr.Expressions {
+			if expr, err := p.getSTAssignment(ex); err == nil {
+				exprs = append(exprs, expr)
+			}
+		}
+	}
+	return exprs
+}
+
+//forEachValue calls fn for every value in expr, including those inside array indices
+func forEachValue(pName string, expr stcompilerlib.STExpression, fn func(val string)) {
+	if expr.HasOperator() != nil {
+		for _, arg := range expr.GetArguments() {
+			forEachValue(pName, arg, fn)
+		}
+		return
+	}
+	val := expr.HasValue()
+	fn(val)
+	parts := strings.FieldsFunc(val, func(r rune) bool { return r == '[' || r == ']' })
+	for i := 1; i < len(parts); i++ {
+		if index, err := ParseSTExpression(pName, parts[i]); err == nil {
+			forEachValue(pName, index, fn)
+		}
+	}
+}
+
+//largestConstant returns the largest number (ignoring its sign) that is used in p
+func largestConstant(p Policy) float64 {
+	largest := 0.0
+	for _, c := range policyConstants(p) {
+		largest = math.Max(largest, c)
+	}
+	return largest
+}
+
+//TimerLimit returns a value (just past the largest constant that the policy uses) where its dtimers can stop counting up
+//without changing any of its verdicts, as long as they are only compared with constants
+func (p Policy) TimerLimit() int64 {
+	return int64(largestConstant(p)) + 2
+}
+
+//policyConstants returns the numbers (ignoring their signs) that are used in p, including the values of its constants
+func policyConstants(p Policy) []float64 {
+	var constants []float64
+	check := func(val string) {
+		if fl, err := strconv.ParseFloat(val, 64); err == nil && !math.IsInf(fl, 0) {
+			constants = append(constants, math.Abs(fl))
+		} else if i, err := strconv.ParseInt(val, 0, 64); err == nil {
+			constants = append(constants, math.Abs(float64(i)))
+		}
+	}
+	for _, expr := range policyExpressions(p) {
+		forEachValue(p.Name, expr, check)
+	}
+	for _, v := range p.InternalVars {
+		if v.Constant {
+			check(v.InitialValue)
+		}
+	}
+	return constants
+}
+
+//inputAlphabet returns the inputs to try on each tick when exploring the policies
+func inputAlphabet(f Monitor, policies []Policy, maxInputs int) ([]Inputs, error) {
+	if maxInputs <= 0 {
+		maxInputs = 4096
+	}
+
+	used, constants := interfaceConstants(f, policies)
+
+	type choice struct {
+		path   string
+		values []Value
+	}
+	var choices []choice
+	for _, v := range f.InterfaceList {
+		if !used[v.Name] {
+			continue
+		}
+		for _, leaf := range f.leaves(Policy{}, v, v.Name) {
+			choices = append(choices, choice{leaf.path, candidateValues(f, leaf.v, constants[v.Name])})
+		}
+	}
+	sort.Slice(choices, func(i, j int) bool { return choices[i].path < choices[j].path })
+
+	total := 1
+	for _, c := range choices {
+		total *= len(c.values)
+		if total > maxInputs {
+			return nil, fmt.Errorf("there are too many different inputs to try on each tick (more than %d)", maxInputs)
+		}
+	}
+	alphabet := []Inputs{{}}
+	for _, c := range choices {
+		var next []Inputs
+		for _, in := range alphabet {
+			for _, val := range c.values {
+				n := make(Inputs, len(in)+1)
+				for k, v := range in {
+					n[k] = v
+				}
+				n[c.path] = val
+				next = append(next, n)
+			}
+		}
+		alphabet = next
+	}
+	return alphabet, nil
+}
+
+//alphabetComplete returns true if the inputs from inputAlphabet (and the limit on dtimers) are enough to see everything that p can do,
+//i.e. its guards only compare variables with constants, its dtimers are only compared with constants,
+//and its internal variables are never set from numbers in the interface
+func alphabetComplete(f Monitor, p Policy) bool {
+	s := satChecker{f: f, p: p, index: make(map[string]int)}
+	for _, expr := range policyExpressions(p) {
+		if op := expr.HasOperator(); op == nil || op.GetToken() != ":=" {
+			s.collectAtoms(expr)
+			continue
+		}
+		//arguments are in reverse order
+		complete := true
+		forEachValue(p.Name, expr.GetArguments()[0], func(val string) {
+			root, _ := SplitAccessPath(val)
+			if v := f.GetVariable(p, val); v != nil && f.InterfaceList.HasIONamed(true, root) && (v.IsIntegerType() || v.IsFloatType()) {
+				complete = false
+			}
+		})
+		if !complete {
+			return false
+		}
+	}
+	for _, atom := range s.atoms {
+		if !atom.fixed && atom.name == "" && atom.expr.HasOperator() != nil {
+			return false
+		}
+	}
+	for _, v := range p.InternalVars {
+		if v.IsDTimer() && !p.TimerBounded(v.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+//interfaceConstants finds the interface variables that are used by the policies, and the constants that they are compared with
+//(both are keyed by the root of the variable)
+func interfaceConstants(f Monitor, policies []Policy) (map[string]bool, map[string][]float64) {
+	used := make(map[string]bool)
+	constants := make(map[string][]float64)
+	for _, p := range policies {
+		for _, expr := range policyExpressions(p) {
+			forEachValue(p.Name, expr, func(val string) {
+				root, _ := SplitAccessPath(val)
+				if f.InterfaceList.HasIONamed(true, root) {
+					used[root] = true
+				}
+			})
+			s := satChecker{f: f, p: p, index: make(map[string]int)}
+			s.collectAtoms(expr)
+			for _, atom := range s.atoms {
+				if atom.name != "" && atom.value.member == "" {
+					root, _ := SplitAccessPath(atom.name)
+					constants[root] = append(constants[root], atom.value.num)
+				}
+			}
+		}
+	}
+	return used, constants
+}
+
+//candidateValues returns the values to try for a scalar interface variable v, which is compared with the given constants
+func candidateValues(f Monitor, v Variable, constants []float64) []Value {
+	if e := f.GetEnum(v.Type); e != nil {
+		var values []Value
+		for i, m := range e.Members {
+			values = append(values, Value{Kind: ValueEnum, Int: int64(i), Member: m})
+		}
+		return values
+	}
+	switch getTypeClass(v) {
+	case typeBool:
+		return []Value{BoolValue(false), BoolValue(true)}
+	case typeFloat:
+		//every constant, the values just past it, and a value in each interval between (and beyond) them,
+		//so that every combination of comparisons with the constants that can hold is tried
+		nums := []float64{0}
+		sorted := append([]float64(nil), constants...)
+		sort.Float64s(sorted)
+		for i, c := range sorted {
+			if i > 0 && c == sorted[i-1] {
+				continue
+			}
+			nums = append(nums, c, nextFloat(v, c, math.Inf(-1)), nextFloat(v, c, math.Inf(1)))
+			if i > 0 {
+				nums = append(nums, sorted[i-1]+(c-sorted[i-1])/2)
+			}
+		}
+		if len(sorted) > 0 {
+			nums = append(nums, sorted[0]-1, sorted[len(sorted)-1]+1)
+		}
+		return uniqueValues(f, v, nums)
+	}
+	lo, hi := IntegerTypeRange(v.Type)
+	nums := []float64{0, 1}
+	for _, c := range constants {
+		for _, n := range []float64{math.Floor(c) - 1, math.Floor(c), math.Ceil(c), math.Ceil(c) + 1} {
+			if n >= lo && n <= hi {
+				nums = append(nums, n)
+			}
+		}
+	}
+	return uniqueValues(f, v, nums)
+}
+
+//nextFloat returns the next value after c (towards dir) that a float variable v can hold
+func nextFloat(v Variable, c float64, dir float64) float64 {
+	if strings.ToLower(v.Type) == "float" {
+		return float64(math.Nextafter32(float32(c), float32(dir)))
+	}
+	return math.Nextafter(c, dir)
+}
+
+//uniqueValues converts nums into sorted Values of the type of v, without any repeats
+func uniqueValues(f Monitor, v Variable, nums []float64) []Value {
+	sort.Float64s(nums)
+	var values []Value
+	for _, n := range nums {
+		val := convertValue(f, v, FloatValue(n))
+		if len(values) > 0 && values[len(values)-1] == val {
+			continue
+		}
+		values = append(values, val)
+	}
+	return values
+}
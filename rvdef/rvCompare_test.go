@@ -0,0 +1,115 @@
+package rvdef
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComparePolicies(t *testing.T) {
+	//the same policy, but written with an explicit guard instead of else, and with an extra (equivalent) state
+	rewritten := ab5Monitor("5")
+	pol := &rewritten.Policies[0]
+	pol.States = append(pol.States, PState{Name: "violation2", Accepting: false})
+	pol.Transitions[4] = PTransition{Source: "s1", Destination: "violation2", Condition: "v >= 5 and !B"}
+
+	tests := []struct {
+		Name  string
+		A, B  Monitor
+		Mode  CompareMode
+		Same  bool
+		Trace int //the length of the distinguishing trace
+	}{
+		{"same", ab5Monitor("5"), ab5Monitor("5"), CompareEquivalence, true, 0},
+		{"rewritten", rewritten, ab5Monitor("5"), CompareEquivalence, true, 0},
+		{"different deadline", ab5Monitor("4"), ab5Monitor("5"), CompareEquivalence, false, 5},
+		{"tighter refines", ab5Monitor("4"), ab5Monitor("5"), CompareRefinement, true, 0},
+		{"looser doesn't refine", ab5Monitor("5"), ab5Monitor("4"), CompareRefinement, false, 6}, //both are unsafe until B comes too late for one of them
+	}
+	for _, test := range tests {
+		res, err := ComparePolicies(test.A, 0, test.B, 0, CompareOptions{Mode: test.Mode})
+		if err != nil {
+			t.Errorf("%s: Error '%s' occurred when it shouldn't have", test.Name, err.Error())
+			continue
+		}
+		if res.Same != test.Same || !res.Complete || len(res.Trace) != test.Trace {
+			t.Errorf("%s: got same=%v complete=%v with a trace of %d ticks, wanted same=%v with a trace of %d ticks", test.Name, res.Same, res.Complete, len(res.Trace), test.Same, test.Trace)
+		}
+	}
+
+	//the trace is the shortest one
+	res, _ := ComparePolicies(ab5Monitor("4"), 0, ab5Monitor("5"), 0, CompareOptions{})
+	wantTrace := []Inputs{{"A": BoolValue(true), "B": BoolValue(false)}}
+	for i := 0; i < 4; i++ {
+		wantTrace = append(wantTrace, Inputs{"A": BoolValue(false), "B": BoolValue(false)})
+	}
+	if !reflect.DeepEqual(res.Trace, wantTrace) {
+		t.Errorf("Trace was %v, it should have been %v", res.Trace, wantTrace)
+	}
+	if res.VerdictsA[4] != 3 || res.VerdictsB[4] != 2 {
+		t.Errorf("Verdicts were %v and %v", res.VerdictsA, res.VerdictsB)
+	}
+
+	//the search can be cut short
+	res, _ = ComparePolicies(ab5Monitor("4"), 0, ab5Monitor("5"), 0, CompareOptions{MaxDepth: 3})
+	if !res.Same || res.Complete {
+		t.Errorf("Search wasn't stopped at the maximum depth")
+	}
+
+	//the inputs tried can't tell these apart (x = 0, y = 7 does), so the search can't be a proof
+	sum := func(total string) Monitor {
+		return Monitor{
+			Name:          "m",
+			InterfaceList: []Variable{{Name: "x", Type: "uint8_t"}, {Name: "y", Type: "uint8_t"}},
+			Policies: []Policy{{
+				Name:        "P",
+				States:      []PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "bad"}},
+				Transitions: []PTransition{{Source: "s0", Destination: "bad", Condition: "x + y = " + total}, {Source: "s0", Destination: "s0", Else: true}},
+			}},
+		}
+	}
+	res, err := ComparePolicies(sum("7"), 0, sum("8"), 0, CompareOptions{})
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	if res.Same && res.Complete {
+		t.Errorf("x + y = 7 and x + y = 8 were proven to be the same")
+	}
+
+	//a float that is only bad in a narrow interval must not be proven the same as one that is never bad
+	interval := func(transitions ...PTransition) Monitor {
+		return Monitor{
+			Name:          "m",
+			InterfaceList: []Variable{{Name: "f", Type: "double"}},
+			Policies: []Policy{{
+				Name:        "P",
+				States:      []PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "bad"}},
+				Transitions: transitions,
+			}},
+		}
+	}
+	narrow := interval(PTransition{Source: "s0", Destination: "bad", Condition: "f > 1.0 and f < 1.2"}, PTransition{Source: "s0", Destination: "s0", Else: true})
+	never := interval(PTransition{Source: "s0", Destination: "bad", Condition: "f > 7.0 and f < 3.0"}, PTransition{Source: "s0", Destination: "s0", Else: true})
+	res, err = ComparePolicies(narrow, 0, never, 0, CompareOptions{})
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	if res.Same {
+		t.Errorf("f > 1.0 and f < 1.2 was found to be the same as f > 7.0 and f < 3.0")
+	}
+
+	//the policies must have initial states (e.g. one built with AddState doesn't)
+	var noInitial Policy
+	noInitial.AddState("s0", true)
+	other := ab5Monitor("5")
+	other.Policies[0] = noInitial
+	if _, err := ComparePolicies(ab5Monitor("5"), 0, other, 0, CompareOptions{}); err == nil {
+		t.Errorf("A policy with no initial state was compared")
+	}
+
+	//the interfaces must match
+	other = ab5Monitor("5")
+	other.InterfaceList[1].Type = "int8_t"
+	if _, err := ComparePolicies(ab5Monitor("5"), 0, other, 0, CompareOptions{}); err == nil {
+		t.Errorf("Different interfaces were compared")
+	}
+}
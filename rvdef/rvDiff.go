@@ -0,0 +1,354 @@
+package rvdef
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//PolicyDiff lists the changes made to one policy (or to the interface, if Policy is empty) between two versions of a Monitor
+type PolicyDiff struct {
+	Policy  string
+	Changes []string
+}
+
+//String returns the PolicyDiff as a human-readable list of changes
+func (d PolicyDiff) String() string {
+	name := "Interface"
+	if d.Policy != "" {
+		name = "Policy " + d.Policy
+	}
+	return name + ":\n\t" + strings.Join(d.Changes, "\n\t")
+}
+
+//DiffMonitors compares two versions of a Monitor structurally, and returns what changed in each policy (and in the interface)
+//Guards and assignments are compared (and shown) as normalised expressions, so changes to their spacing or brackets are ignored.
+//A state that was removed is reported as renamed if a state that was added has the same status and the same transitions.
+//Policies (and things in them) that didn't change are left out.
+func DiffMonitors(a Monitor, b Monitor) []PolicyDiff {
+	var diffs []PolicyDiff
+	if changes := diffVariables("interface variable", a.InterfaceList, b.InterfaceList); len(changes) > 0 {
+		diffs = append(diffs, PolicyDiff{Changes: changes})
+	}
+
+	for _, pa := range a.Policies {
+		found := false
+		for _, pb := range b.Policies {
+			if pa.Name == pb.Name {
+				found = true
+				if changes := diffPolicies(pa, pb); len(changes) > 0 {
+					diffs = append(diffs, PolicyDiff{Policy: pa.Name, Changes: changes})
+				}
+			}
+		}
+		if !found {
+			diffs = append(diffs, PolicyDiff{Policy: pa.Name, Changes: []string{"policy removed"}})
+		}
+	}
+	for _, pb := range b.Policies {
+		found := false
+		for _, pa := range a.Policies {
+			found = found || pa.Name == pb.Name
+		}
+		if !found {
+			diffs = append(diffs, PolicyDiff{Policy: pb.Name, Changes: []string{fmt.Sprintf("policy added (with %d state(s))", len(pb.States))}})
+		}
+	}
+	return diffs
+}
+
+//diffVariables compares two lists of variables (what is used for each change is described by kind, e.g. "internal")
+func diffVariables(kind string, a []Variable, b []Variable) []string {
+	var changes []string
+	describe := func(v Variable) string {
+		s := typeWithSize(v)
+		if v.InitialValue != "" {
+			s += " := " + v.InitialValue
+		}
+		return s
+	}
+	kindOf := func(v Variable) string {
+		if v.Constant {
+			return "constant"
+		}
+		return kind
+	}
+	for _, va := range a {
+		found := false
+		for _, vb := range b {
+			if va.Name != vb.Name {
+				continue
+			}
+			found = true
+			switch {
+			case va.Constant != vb.Constant:
+				changes = append(changes, fmt.Sprintf("%s %s is now a %s (%s)", kindOf(va), va.Name, kindOf(vb), describe(vb)))
+			case describe(va) != describe(vb) || !sameFields(va, vb):
+				changes = append(changes, fmt.Sprintf("%s %s changed from %s to %s", kindOf(va), va.Name, describe(va), describe(vb)))
+			}
+		}
+		if !found {
+			changes = append(changes, fmt.Sprintf("%s %s removed (was %s)", kindOf(va), va.Name, describe(va)))
+		}
+	}
+	for _, vb := range b {
+		found := false
+		for _, va := range a {
+			found = found || va.Name == vb.Name
+		}
+		if !found {
+			changes = append(changes, fmt.Sprintf("%s %s added (%s)", kindOf(vb), vb.Name, describe(vb)))
+		}
+	}
+	return changes
+}
+
+//sameFields returns true if two struct variables have the same fields
+func sameFields(a Variable, b Variable) bool {
+	return len(diffVariables("field", a.Fields, b.Fields)) == 0
+}
+
+//diffStatus describes the status of a state, e.g. "accepting trap"
+func diffStatus(p Policy, st PState) string {
+	s := "rejecting"
+	if st.Accepting {
+		s = "accepting"
+	}
+	if isTrap(p, st.Name) {
+		s += " trap"
+	}
+	return s
+}
+
+//isTrap returns true if the state has no transitions out of it
+func isTrap(p Policy, name string) bool {
+	for _, tr := range p.Transitions {
+		if tr.Source == name {
+			return false
+		}
+	}
+	return true
+}
+
+//describeTransition describes a transition, with its guard and assignments normalised, e.g. "s0 -> s1 on A and !B: v := 0"
+func describeTransition(pName string, tr PTransition) string {
+	s := tr.Source + " -> " + tr.Destination
+	if tr.Else {
+		s += " else"
+	} else {
+		s += " on " + normaliseExpression(pName, tr.Condition)
+	}
+	if len(tr.Expressions) > 0 {
+		s += ": " + normaliseAssignments(pName, tr.Expressions)
+	}
+	return s
+}
+
+//findRenames pairs up the states that were removed from a with the states that were added to b, if they look the same
+//(i.e. they have the same status and the same transitions out of them, and if that is ambiguous, come from the same states)
+func findRenames(a Policy, b Policy) map[string]string {
+	var removed, added []string
+	for _, st := range a.States {
+		if !b.HasState(st.Name) {
+			removed = append(removed, st.Name)
+		}
+	}
+	for _, st := range b.States {
+		if !a.HasState(st.Name) {
+			added = append(added, st.Name)
+		}
+	}
+
+	//a state's signature is its status and its transitions, with states that might have been renamed written as "?"
+	signature := func(p Policy, name string, candidates []string, incoming bool) string {
+		rename := func(s string) string {
+			if s == name {
+				return "<self>"
+			}
+			if stringSliceContains(candidates, s) {
+				return "?"
+			}
+			return s
+		}
+		var sig []string
+		if st := p.GetState(name); st != nil {
+			sig = append(sig, fmt.Sprintf("%v,%v,%s", st.Accepting, st.Initial, normaliseExpression(p.Name, st.InitialCondition)))
+		}
+		for _, tr := range p.Transitions {
+			if tr.Source == name {
+				tr.Source, tr.Destination = rename(tr.Source), rename(tr.Destination)
+				sig = append(sig, describeTransition(p.Name, tr))
+			}
+		}
+		if incoming {
+			var sources []string
+			for _, tr := range p.Transitions {
+				if tr.Destination == name && tr.Source != name && !stringSliceContains(sources, rename(tr.Source)) {
+					sources = append(sources, rename(tr.Source))
+				}
+			}
+			sort.Strings(sources)
+			sig = append(sig, "from "+strings.Join(sources, ","))
+		}
+		return strings.Join(sig, "\n")
+	}
+
+	//a pair is only used if no other removed or added state has the same signature
+	renames := make(map[string]string)
+	for _, incoming := range []bool{false, true} {
+		for _, ra := range removed {
+			if _, ok := renames[ra]; ok {
+				continue
+			}
+			sig := signature(a, ra, removed, incoming)
+			match, count := "", 0
+			for _, ab := range added {
+				if signature(b, ab, added, incoming) == sig {
+					match = ab
+					count++
+				}
+			}
+			others := 0
+			for _, other := range removed {
+				if signature(a, other, removed, incoming) == sig {
+					others++
+				}
+			}
+			if count == 1 && others == 1 && !renamedTo(renames, match) {
+				renames[ra] = match
+			}
+		}
+	}
+	return renames
+}
+
+//renamedTo returns true if some state has already been renamed to name
+func renamedTo(renames map[string]string, name string) bool {
+	for _, to := range renames {
+		if to == name {
+			return true
+		}
+	}
+	return false
+}
+
+//diffPolicies compares two versions of a policy
+func diffPolicies(a Policy, b Policy) []string {
+	changes := diffVariables("internal", a.InternalVars, b.InternalVars)
+
+	//rename the states of a, so that they can be compared with b
+	renames := findRenames(a, b)
+	rename := func(s string) string {
+		if r, ok := renames[s]; ok {
+			return r
+		}
+		return s
+	}
+	var renamed []string
+	for from, to := range renames {
+		renamed = append(renamed, fmt.Sprintf("state %s renamed to %s", from, to))
+	}
+	sort.Strings(renamed)
+	changes = append(changes, renamed...)
+
+	ra := a
+	ra.States = make([]PState, len(a.States))
+	for i, st := range a.States {
+		st.Name, st.InitialElse = rename(st.Name), rename(st.InitialElse)
+		ra.States[i] = st
+	}
+	ra.Transitions = make([]PTransition, len(a.Transitions))
+	for i, tr := range a.Transitions {
+		tr.Source, tr.Destination = rename(tr.Source), rename(tr.Destination)
+		ra.Transitions[i] = tr
+	}
+	fa, fb := ra, b
+	fa.States = append([]PState(nil), ra.States...)
+	fb.States = append([]PState(nil), b.States...)
+	fa.FinaliseStates()
+	fb.FinaliseStates()
+
+	//states
+	for _, sa := range fa.States {
+		sb := fb.GetState(sa.Name)
+		if sb == nil {
+			changes = append(changes, fmt.Sprintf("state %s removed (was %s)", sa.Name, diffStatus(fa, sa)))
+			continue
+		}
+		if diffStatus(fa, sa) != diffStatus(fb, *sb) {
+			changes = append(changes, fmt.Sprintf("state %s is now %s (was %s)", sa.Name, diffStatus(fb, *sb), diffStatus(fa, sa)))
+		}
+		if sa.Initial != sb.Initial {
+			if sb.Initial {
+				changes = append(changes, fmt.Sprintf("state %s is now the initial state", sa.Name))
+			} else {
+				changes = append(changes, fmt.Sprintf("state %s is no longer the initial state", sa.Name))
+			}
+		}
+		ca, cb := normaliseExpression(a.Name, sa.InitialCondition), normaliseExpression(b.Name, sb.InitialCondition)
+		if sa.Initial && sb.Initial && (ca != cb || sa.InitialElse != sb.InitialElse) {
+			changes = append(changes, fmt.Sprintf("state %s: initial condition changed from '%s' (else %s) to '%s' (else %s)", sa.Name, ca, sa.InitialElse, cb, sb.InitialElse))
+		}
+		if sa.Accepting == sb.Accepting && sa.FinalStatusType != sb.FinalStatusType {
+			changes = append(changes, fmt.Sprintf("state %s: verdict is now %s (was %s)", sa.Name, VerdictName(sb.Verdict()), VerdictName(sa.Verdict())))
+		}
+	}
+	for _, sb := range fb.States {
+		if fa.GetState(sb.Name) == nil {
+			changes = append(changes, fmt.Sprintf("state %s added (%s)", sb.Name, diffStatus(fb, sb)))
+		}
+	}
+
+	//transitions are paired up by their source and destination (in order), and then by their guards
+	matched := make([]bool, len(b.Transitions))
+	var removedTr []PTransition
+	var sources []string
+	order := make(map[string][]int) //the indices in b of the transitions of each state that were matched, in the order they are in a
+	for _, ta := range ra.Transitions {
+		best := -1
+		for j, tb := range b.Transitions {
+			if matched[j] || ta.Source != tb.Source || ta.Destination != tb.Destination || ta.Else != tb.Else {
+				continue
+			}
+			if describeTransition(a.Name, ta) == describeTransition(b.Name, tb) {
+				best = j
+				break
+			}
+			if best == -1 {
+				best = j
+			}
+		}
+		if best == -1 {
+			removedTr = append(removedTr, ta)
+			continue
+		}
+		matched[best] = true
+		if _, ok := order[ta.Source]; !ok {
+			sources = append(sources, ta.Source)
+		}
+		order[ta.Source] = append(order[ta.Source], best)
+		tb := b.Transitions[best]
+		ga, gb := normaliseExpression(a.Name, ta.Condition), normaliseExpression(b.Name, tb.Condition)
+		if !ta.Else && ga != gb {
+			changes = append(changes, fmt.Sprintf("transition %s -> %s: guard changed from '%s' to '%s'", ta.Source, ta.Destination, ga, gb))
+		}
+		if xa, xb := normaliseAssignments(a.Name, ta.Expressions), normaliseAssignments(b.Name, tb.Expressions); xa != xb {
+			changes = append(changes, fmt.Sprintf("transition %s -> %s: assignments changed from '%s' to '%s'", ta.Source, ta.Destination, xa, xb))
+		}
+	}
+	//transitions are tried in order, so moving one changes which is taken when more than one guard holds
+	for _, src := range sources {
+		if !sort.IntsAreSorted(order[src]) {
+			changes = append(changes, fmt.Sprintf("transitions of %s reordered", src))
+		}
+	}
+	for _, tr := range removedTr {
+		changes = append(changes, "transition removed: "+describeTransition(a.Name, tr))
+	}
+	for j, tb := range b.Transitions {
+		if !matched[j] {
+			changes = append(changes, "transition added: "+describeTransition(b.Name, tb))
+		}
+	}
+	return changes
+}
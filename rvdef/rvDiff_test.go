@@ -0,0 +1,89 @@
+package rvdef
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffMonitors(t *testing.T) {
+	//the same policy, but with its guards written differently
+	reformatted := ab5Monitor("5")
+	reformatted.Policies[0].Transitions[0].Condition = "(A) and (!B)"
+	reformatted.Policies[0].Transitions[1].Condition = "(!A or B)"
+
+	//the violation state renamed
+	renamed := ab5Monitor("5")
+	renamed.Policies[0].States[2].Name = "bad"
+	renamed.Policies[0].Transitions[4].Destination = "bad"
+
+	//renamed, and the guard into it changed too
+	retimed := ab5Monitor("6")
+	retimed.Policies[0].States[2].Name = "bad"
+	retimed.Policies[0].Transitions[4].Destination = "bad"
+
+	//a new deadline, kept as a constant
+	deadline := ab5Monitor("MAX")
+	deadline.Policies[0].InternalVars = append(deadline.Policies[0].InternalVars, Variable{Name: "MAX", Type: "uint8_t", Constant: true, InitialValue: "5"})
+	deadline.Policies[0].InternalVars[0].Type = "uint16_t"
+
+	//s1 made accepting, and the violation state given a way out
+	recoverable := ab5Monitor("5")
+	recoverable.Policies[0].States[1].Accepting = true
+	recoverable.Policies[0].Transitions = append(recoverable.Policies[0].Transitions, PTransition{Source: "violation", Destination: "s0", Condition: "B", Expressions: []PExpression{{VarName: "v", Value: "0"}}})
+
+	//s1 checks its deadline before B (so B no longer wins when both hold)
+	reordered := ab5Monitor("5")
+	trs := reordered.Policies[0].Transitions
+	trs[2], trs[3] = trs[3], trs[2]
+
+	//a new policy
+	extra := ab5Monitor("5")
+	extra.InterfaceList = extra.InterfaceList[:1]
+	extra.Policies = append(extra.Policies, Policy{Name: "Other", States: []PState{{Name: "s0", Accepting: true}}})
+
+	tests := []struct {
+		Name  string
+		New   Monitor
+		Diffs []PolicyDiff
+	}{
+		{"same", ab5Monitor("5"), nil},
+		{"reformatted", reformatted, nil},
+		{"renamed", renamed, []PolicyDiff{{Policy: "AB5", Changes: []string{
+			"state violation renamed to bad",
+		}}}},
+		{"renamed and retimed", retimed, []PolicyDiff{{Policy: "AB5", Changes: []string{
+			"state violation renamed to bad",
+			"transition s1 -> s1: guard changed from 'v < 5' to 'v < 6'",
+		}}}},
+		{"deadline", deadline, []PolicyDiff{{Policy: "AB5", Changes: []string{
+			"internal v changed from dtimer_t to uint16_t",
+			"constant MAX added (uint8_t := 5)",
+			"transition s1 -> s1: guard changed from 'v < 5' to 'v < MAX'",
+		}}}},
+		{"recoverable", recoverable, []PolicyDiff{{Policy: "AB5", Changes: []string{
+			"state s1 is now accepting (was rejecting)",
+			"state violation is now rejecting (was rejecting trap)",
+			"state violation: verdict is now currently false (was false)",
+			"transition added: violation -> s0 on B: v := 0",
+		}}}},
+		{"reordered", reordered, []PolicyDiff{{Policy: "AB5", Changes: []string{
+			"transitions of s1 reordered",
+		}}}},
+		{"extra", extra, []PolicyDiff{
+			{Changes: []string{"interface variable B removed (was bool)"}},
+			{Policy: "Other", Changes: []string{"policy added (with 1 state(s))"}},
+		}},
+	}
+	for _, test := range tests {
+		diffs := DiffMonitors(ab5Monitor("5"), test.New)
+		if !reflect.DeepEqual(diffs, test.Diffs) {
+			t.Errorf("%s: got %v, wanted %v", test.Name, diffs, test.Diffs)
+		}
+	}
+
+	//removing a transition is the reverse of adding it
+	diffs := DiffMonitors(recoverable, ab5Monitor("5"))
+	if len(diffs) != 1 || diffs[0].Changes[len(diffs[0].Changes)-1] != "transition removed: violation -> s0 on B: v := 0" {
+		t.Errorf("Removed transition wasn't found in %v", diffs)
+	}
+}
@@ -0,0 +1,265 @@
+package rvdef
+
+import (
+	"strings"
+	"text/scanner"
+
+	"github.com/PRETgroup/stcompilerlib"
+	"github.com/PRETgroup/stcompilerlib/postfixlib"
+)
+
+//stNegative is the token stcompilerlib uses for unary minus
+const stNegative = "`"
+
+//stOperators stores all operators that may be used in guards and expressions
+var stOperators = func() []postfixlib.Operator {
+	var ops []postfixlib.Operator
+	for _, tok := range []string{"not", stNegative, "**", "*", "/", "MOD", "+", "-", "<", ">", "<=", ">=", "=", "<>", "and", "xor", "or", ":="} {
+		ops = append(ops, stcompilerlib.FindOp(tok))
+	}
+	return ops
+}()
+
+//ParseSTExpression converts a single expression (e.g. a guard) into a STExpression parsetree.
+//It understands the same syntax as stcompilerlib, but it also keeps
+//struct member accesses (pkt.temp) and array element accesses (a[i]) together as single values
+func ParseSTExpression(name string, input string) (stcompilerlib.STExpression, *stcompilerlib.STParseError) {
+	tokens := scanSTExpression(name, input)
+	if len(tokens) == 0 {
+		return nil, &stcompilerlib.STParseError{LineNumber: 1, Err: stcompilerlib.ErrUnexpectedEOF}
+	}
+
+	postfixConverter := postfixlib.NewConverter(stOperators)
+	postfix := postfixConverter.ToPostfix(tokens)
+
+	//now go through the postfix expression and convert to function tree
+	var stack []stcompilerlib.STExpression
+	for _, token := range postfix {
+		op := stcompilerlib.FindOp(token)
+		if op == nil {
+			stack = append(stack, stcompilerlib.STExpressionValue{Value: token})
+			continue
+		}
+		if len(stack) < op.GetNumOperands() {
+			return nil, &stcompilerlib.STParseError{LineNumber: 1, Argument: op.GetToken(), Reason: "missing operand", Err: stcompilerlib.ErrBadExpression}
+		}
+		stEOp := stcompilerlib.STExpressionOperator{Operator: op}
+		for j := 0; j < op.GetNumOperands(); j++ {
+			stEOp.Arguments = append(stEOp.Arguments, stack[len(stack)-1])
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, stEOp)
+	}
+	if len(stack) != 1 {
+		return nil, &stcompilerlib.STParseError{LineNumber: 1, Argument: input, Err: stcompilerlib.ErrBadExpression}
+	}
+	return stack[0], nil
+}
+
+//scanSTExpression breaks up an expression into its tokens
+func scanSTExpression(name string, input string) []string {
+	var s scanner.Scanner
+
+	s.Filename = name
+	s.Init(strings.NewReader(input))
+	s.Mode = scanner.ScanIdents | scanner.ScanFloats | scanner.ScanStrings | scanner.ScanRawStrings | scanner.ScanComments | scanner.SkipComments
+	s.Error = func(*scanner.Scanner, string) {}
+
+	var items []string
+	for tok := s.Scan(); tok != scanner.EOF; tok = s.Scan() {
+		items = append(items, s.TokenText())
+	}
+
+	//combine multi-character operators, and convert aliases to their ST equivalents
+	var tokens []string
+	for i := 0; i < len(items); i++ {
+		item := items[i]
+		next := ""
+		if i+1 < len(items) {
+			next = items[i+1]
+		}
+		switch {
+		case item == ":" && next == "=":
+			item, i = ":=", i+1
+		case item == ">" && next == "=":
+			item, i = ">=", i+1
+		case item == "<" && next == "=":
+			item, i = "<=", i+1
+		case item == "<" && next == ">":
+			item, i = "<>", i+1
+		case item == "=" && next == "=":
+			item, i = "=", i+1
+		case item == "!" && next == "=":
+			item, i = "<>", i+1
+		case item == "&" && next == "&":
+			item, i = "and", i+1
+		case item == "|" && next == "|":
+			item, i = "or", i+1
+		case item == "*" && next == "*":
+			item, i = "**", i+1
+		case item == "!" || item == "NOT":
+			item = "not"
+		case item == "AND" || item == "OR" || item == "XOR":
+			item = strings.ToLower(item)
+		case item == "mod":
+			item = "MOD"
+		case item == "'" && i+2 < len(items) && items[i+2] == "'": //single-quoted constants become a single term
+			item, i = "'"+next+"'", i+2
+		}
+		tokens = append(tokens, item)
+	}
+
+	tokens = combineAccessPaths(tokens)
+
+	//convert minuses that should be negatives to negatives (i.e. "2 + -3" becomes "2 + `3")
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] != "-" {
+			continue
+		}
+		if i == 0 || tokens[i-1] == "(" || tokens[i-1] == "," || (tokens[i-1] != ")" && stcompilerlib.FindOp(tokens[i-1]) != nil) {
+			tokens[i] = stNegative
+		}
+	}
+
+	return tokens
+}
+
+//combineAccessPaths merges struct member accesses (a . b) and array element accesses (a [ i ]) into single tokens
+func combineAccessPaths(tokens []string) []string {
+	var out []string
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if !IsIdentifier(tok) || stcompilerlib.FindOp(tok) != nil {
+			out = append(out, tok)
+			continue
+		}
+		for i+1 < len(tokens) {
+			if tokens[i+1] == "." && i+2 < len(tokens) && IsIdentifier(tokens[i+2]) {
+				tok += "." + tokens[i+2]
+				i += 2
+				continue
+			}
+			if tokens[i+1] == "[" {
+				//find the matching close bracket
+				depth := 0
+				j := i + 1
+				for ; j < len(tokens); j++ {
+					if tokens[j] == "[" {
+						depth++
+					} else if tokens[j] == "]" {
+						depth--
+						if depth == 0 {
+							break
+						}
+					}
+				}
+				if j == len(tokens) {
+					break
+				}
+				tok += "[" + strings.Join(combineAccessPaths(tokens[i+2:j]), "") + "]"
+				i = j
+				continue
+			}
+			break
+		}
+		out = append(out, tok)
+	}
+	return out
+}
+
+//IsIdentifier returns true if s could be the name of a variable, member, or type
+func IsIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+//SplitAccessPath breaks up a struct member or array element access path into its root variable name and the remaining path,
+//e.g. "pkt.temp" becomes "pkt", ".temp" and "a[i].b" becomes "a", "[i].b"
+func SplitAccessPath(path string) (string, string) {
+	if i := strings.IndexAny(path, ".["); i != -1 {
+		return path[:i], path[i:]
+	}
+	return path, ""
+}
+
+//stPrecedence is how tightly each operator binds (a higher number binds more tightly)
+var stPrecedence = map[string]int{
+	"not": 9, stNegative: 9,
+	"**": 8,
+	"*":  7, "/": 7, "MOD": 7,
+	"+": 6, "-": 6,
+	"<": 5, ">": 5, "<=": 5, ">=": 5,
+	"=": 4, "<>": 4,
+	"and": 3,
+	"xor": 2,
+	"or":  1,
+	":=":  0,
+}
+
+//FunctionName returns the name of the function that an operator token calls
+//(function calls are stored as name<number of arguments>, e.g. sqrt<1>)
+func FunctionName(tok string) string {
+	if i := strings.Index(tok, "<"); i > 0 && strings.HasSuffix(tok, ">") {
+		return tok[:i]
+	}
+	return tok
+}
+
+//FormatSTExpression writes an expression tree back out as an Easy-rv expression, using only the brackets that are needed
+//Two expressions that differ only in their spacing or their unneeded brackets are written out the same way.
+func FormatSTExpression(expr stcompilerlib.STExpression) string {
+	op := expr.HasOperator()
+	if op == nil {
+		return expr.HasValue()
+	}
+
+	//arguments are in reverse order
+	stArgs := expr.GetArguments()
+	args := make([]string, len(stArgs))
+	for i := range stArgs {
+		args[i] = FormatSTExpression(stArgs[len(stArgs)-1-i])
+	}
+	tok := op.GetToken()
+	prec, ok := stPrecedence[tok]
+	if !ok {
+		return FunctionName(tok) + "(" + strings.Join(args, ", ") + ")"
+	}
+
+	//bracket any argument which binds less tightly than this operator (or as tightly, on the right)
+	bracket := func(i int, s string) string {
+		argOp := stArgs[len(stArgs)-1-i].HasOperator()
+		if argOp == nil {
+			return s
+		}
+		argPrec, ok := stPrecedence[argOp.GetToken()]
+		if ok && (argPrec < prec || (argPrec == prec && i > 0)) {
+			return "(" + s + ")"
+		}
+		return s
+	}
+	switch tok {
+	case "not":
+		return "!" + bracket(0, args[0])
+	case stNegative:
+		return "-" + bracket(0, args[0])
+	}
+	return bracket(0, args[0]) + " " + tok + " " + bracket(1, args[1])
+}
+
+//normaliseExpression returns expr written in a standard way, so that e.g. extra brackets and spaces don't matter
+//(if expr can't be parsed, it is returned as it is)
+func normaliseExpression(pName string, expr string) string {
+	stexpr, err := ParseSTExpression(pName, expr)
+	if err != nil {
+		return expr
+	}
+	return FormatSTExpression(stexpr)
+}
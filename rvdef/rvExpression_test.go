@@ -0,0 +1,105 @@
+package rvdef
+
+import (
+	"testing"
+
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+func TestParseSTExpression(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string //as compiled by stcompilerlib.STCompileExpression
+	}{
+		{"a and b", "a and b"},
+		{"( !A and !B )", "not (A) and not (B)"},
+		{"A && B || C", "A and B or C"},
+		{"x == 1 and y != 2", "x = 1 and y <> 2"},
+		{"pkt.temp > 80", "pkt.temp > 80"},
+		{"pkt . pos . x > 80", "pkt.pos.x > 80"},
+		{"sensors[i] < LIMIT", "sensors[i] < LIMIT"},
+		{"a[i + 1].b[2] = c", "a[i+1].b[2] = c"},
+		{"-x < 5", "`(x) < 5"},
+		{"(-x) < -5", "`(x) < `(5)"},
+		{"x - 1 > 0", "x - 1 > 0"},
+	}
+	for _, test := range tests {
+		expr, err := ParseSTExpression("test", test.Input)
+		if err != nil {
+			t.Errorf("%s: Error '%s' occurred when it shouldn't have", test.Input, err.Error())
+			continue
+		}
+		if out := stcompilerlib.STCompileExpression(expr); out != test.Output {
+			t.Errorf("%s: Outputs don't match (it was '%s', should have been '%s')", test.Input, out, test.Output)
+		}
+	}
+
+	for _, bad := range []string{"", "a and", "a b"} {
+		if _, err := ParseSTExpression("test", bad); err == nil {
+			t.Errorf("%s: Error didn't occur and it should have", bad)
+		}
+	}
+}
+
+func TestGetVariableAccessPath(t *testing.T) {
+	m := Monitor{
+		InterfaceList: []Variable{
+			{Name: "pkt", Type: "packet_t", Fields: []Variable{
+				{Name: "temp", Type: "int16_t"},
+				{Name: "flags", Type: "bool", ArraySize: "4"},
+				{Name: "pos", Type: "vec_t", Fields: []Variable{{Name: "x", Type: "float"}}},
+			}},
+		},
+	}
+	tests := []struct {
+		Path string
+		Type string //empty if the path can't be resolved
+	}{
+		{"pkt", "packet_t"},
+		{"pkt.temp", "int16_t"},
+		{"pkt.flags[2]", "bool"},
+		{"pkt.pos.x", "float"},
+		{"pkt.nope", ""},
+		{"pkt.flags", "bool"},
+		{"pkt.temp[2]", ""},
+		{"pkt.pos.x.y", ""},
+	}
+	for _, test := range tests {
+		v := m.GetVariable(Policy{}, test.Path)
+		if v == nil && test.Type != "" {
+			t.Errorf("%s: couldn't be resolved", test.Path)
+		} else if v != nil && v.Type != test.Type {
+			t.Errorf("%s: resolved to type '%s' (should have been '%s')", test.Path, v.Type, test.Type)
+		}
+	}
+}
+
+func TestFormatSTExpression(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output string
+	}{
+		{"( !A and !B )", "!A and !B"},
+		{"!(A and B)", "!(A and B)"},
+		{"(a or b) and c", "(a or b) and c"},
+		{"a or (b and c)", "a or b and c"},
+		{"(a + b) * c > 1", "(a + b) * c > 1"},
+		{"a + b * c > 1", "a + b * c > 1"},
+		{"a - (b - c)", "a - (b - c)"},
+		{"(a - b) - c", "a - b - c"},
+		{"-(x + 1) < -5", "-(x + 1) < -5"},
+		{"float(t) / 2 > fmax(a, b + 1)", "float(t) / 2 > fmax(a, b + 1)"},
+		{"a[i + 1].b[2] == c", "a[i+1].b[2] = c"},
+		{"x := y MOD 3", "x := y MOD 3"},
+	}
+	for _, test := range tests {
+		expr, err := ParseSTExpression("test", test.Input)
+		if err != nil {
+			t.Errorf("%s: Error '%s' occurred when it shouldn't have", test.Input, err.Error())
+			continue
+		}
+		if out := FormatSTExpression(expr); out != test.Output {
+			t.Errorf("%s: Outputs don't match (it was '%s', should have been '%s')", test.Input, out, test.Output)
+		}
+	}
+}
@@ -52,6 +52,7 @@ out:
 			if discoveredNames[v] == false {
 				discoveredNames[v] = true
 
+				//(this includes else transitions, which can be taken whenever none of the others are)
 				for _, t := range p.Transitions {
 					if t.Source == v {
 						if discoveredNames[t.Destination] == false {
@@ -59,6 +60,12 @@ out:
 						}
 					}
 				}
+				//the initial state can also be left on the first tick if its initial condition doesn't hold
+				for _, st := range p.States {
+					if st.Name == v && st.Initial && st.InitialElse != "" && discoveredNames[st.InitialElse] == false {
+						S = append(S, st.InitialElse)
+					}
+				}
 			}
 		}
 		//if we couldn't find an escape, this is a Final Status state
@@ -0,0 +1,111 @@
+package rvdef
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+//InterfaceLeaves returns the scalar parts of the Monitor's interface, each named by its access path (e.g. "a", "pkt.temp",
+//or "sensors[2]"), in sorted order
+func (f Monitor) InterfaceLeaves() []Variable {
+	var leaves []Variable
+	for _, v := range f.InterfaceList {
+		leaves = append(leaves, f.VariableLeaves(Policy{}, v)...)
+	}
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].Name < leaves[j].Name })
+	return leaves
+}
+
+//VariableLeaves returns the scalar parts of a variable (which can be an internal of p), each named by its access path,
+//in the order that they are laid out in
+func (f Monitor) VariableLeaves(p Policy, v Variable) []Variable {
+	var leaves []Variable
+	for _, leaf := range f.leaves(p, v, v.Name) {
+		l := leaf.v
+		l.Name = leaf.path
+		leaves = append(leaves, l)
+	}
+	return leaves
+}
+
+//A TraceGenerator makes random input traces for a Monitor
+//Most values are picked from the same candidates that ComparePolicies tries (i.e. the values around the constants that each
+//input is compared with), so that the guards are often near their edges, and the rest are picked from the whole of their type.
+//Floats are also picked from anywhere between two neighbouring candidates.
+type TraceGenerator struct {
+	f          Monitor
+	leaves     []Variable
+	candidates [][]Value
+	rng        *rand.Rand
+}
+
+//NewTraceGenerator returns a TraceGenerator for the Monitor, which makes the same traces each time it is given the same seed
+func (f Monitor) NewTraceGenerator(seed int64) *TraceGenerator {
+	_, constants := interfaceConstants(f, f.Policies)
+	g := &TraceGenerator{f: f, leaves: f.InterfaceLeaves(), rng: rand.New(rand.NewSource(seed))}
+	for _, leaf := range g.leaves {
+		root, _ := SplitAccessPath(leaf.Name)
+		g.candidates = append(g.candidates, candidateValues(f, leaf, constants[root]))
+	}
+	return g
+}
+
+//Inputs returns random values for every input of the Monitor
+func (g *TraceGenerator) Inputs() Inputs {
+	in := make(Inputs, len(g.leaves))
+	for i, leaf := range g.leaves {
+		c := g.candidates[i]
+		switch r := g.rng.Intn(4); {
+		case r == 0:
+			in[leaf.Name] = g.anyValue(leaf)
+		case r == 1 && getTypeClass(leaf) == typeFloat && len(c) > 1:
+			j := g.rng.Intn(len(c) - 1)
+			in[leaf.Name] = convertValue(g.f, leaf, FloatValue(c[j].Float+g.rng.Float64()*(c[j+1].Float-c[j].Float)))
+		default:
+			in[leaf.Name] = c[g.rng.Intn(len(c))]
+		}
+	}
+	return in
+}
+
+//Trace returns length random Inputs
+//Each is sometimes held for a few ticks, so that dtimers get the chance to count up while the inputs stay the same.
+func (g *TraceGenerator) Trace(length int) []Inputs {
+	var trace []Inputs
+	for len(trace) < length {
+		in := g.Inputs()
+		hold := 1
+		if g.rng.Intn(4) == 0 {
+			hold += g.rng.Intn(10)
+		}
+		for i := 0; i < hold && len(trace) < length; i++ {
+			trace = append(trace, in)
+		}
+	}
+	return trace
+}
+
+//anyValue returns a random value from the whole of the type of v
+func (g *TraceGenerator) anyValue(v Variable) Value {
+	if e := g.f.GetEnum(v.Type); e != nil {
+		i := g.rng.Intn(len(e.Members))
+		return Value{Kind: ValueEnum, Int: int64(i), Member: e.Members[i]}
+	}
+	switch getTypeClass(v) {
+	case typeBool:
+		return BoolValue(g.rng.Intn(2) == 1)
+	case typeFloat:
+		return convertValue(g.f, v, FloatValue(g.rng.NormFloat64()*1000))
+	}
+	lo, hi := IntegerTypeRange(v.Type)
+	if hi-lo > math.MaxUint32 {
+		//the 64 bit types get values near 0, which are the likeliest to matter
+		i := g.rng.Int63n(1 << 20)
+		if lo < 0 {
+			i -= 1 << 19
+		}
+		return IntValue(i)
+	}
+	return convertValue(g.f, v, IntValue(int64(lo)+g.rng.Int63n(int64(hi-lo)+1)))
+}
@@ -0,0 +1,78 @@
+package rvdef
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTraceGenerator(t *testing.T) {
+	m := Monitor{
+		Name:  "m",
+		Enums: []Enum{{Name: "Mode", Members: []string{"IDLE", "ON"}}},
+		InterfaceList: []Variable{
+			{Name: "t", Type: "int8_t"},
+			{Name: "mode", Type: "Mode"},
+			{Name: "h", Type: "uint8_t", ArraySize: "2"},
+		},
+		Policies: []Policy{{
+			Name:        "P",
+			States:      []PState{{Name: "s0", Accepting: true, Initial: true}},
+			Transitions: []PTransition{{Source: "s0", Destination: "s0", Condition: "t > 100 and h[1] < 3"}},
+		}},
+	}
+
+	var names []string
+	for _, leaf := range m.InterfaceLeaves() {
+		names = append(names, leaf.Name+":"+leaf.Type)
+	}
+	if want := []string{"h[0]:uint8_t", "h[1]:uint8_t", "mode:Mode", "t:int8_t"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Leaves were %v, they should have been %v", names, want)
+	}
+
+	//the same seed gives the same trace, and every value is of the right type
+	trace := m.NewTraceGenerator(7).Trace(200)
+	if len(trace) != 200 || !reflect.DeepEqual(trace, m.NewTraceGenerator(7).Trace(200)) {
+		t.Fatalf("Traces with the same seed were different")
+	}
+	nearConstant := false
+	for _, in := range trace {
+		if len(in) != 4 || in["t"].Int < -128 || in["t"].Int > 127 || in["h[0]"].Int < 0 || in["h[0]"].Int > 255 || in["mode"].Member == "" {
+			t.Fatalf("Inputs %s aren't all valid", in.String())
+		}
+		if in["t"].Int == 101 {
+			nearConstant = true
+		}
+	}
+	if !nearConstant {
+		t.Errorf("None of the inputs were just past the constant that t is compared with")
+	}
+}
+
+func TestTraceGeneratorFloats(t *testing.T) {
+	m := Monitor{
+		Name:          "m",
+		InterfaceList: []Variable{{Name: "f", Type: "double"}},
+		Policies: []Policy{{
+			Name:        "P",
+			States:      []PState{{Name: "s0", Accepting: true, Initial: true}},
+			Transitions: []PTransition{{Source: "s0", Destination: "s0", Condition: "f > 1.0 and f < 1.2"}},
+		}},
+	}
+
+	//floats are also picked from between the candidates
+	g := m.NewTraceGenerator(7)
+	between := false
+	for _, in := range g.Trace(200) {
+		f := in["f"].Float
+		isCandidate := false
+		for _, c := range g.candidates[0] {
+			isCandidate = isCandidate || c.Float == f
+		}
+		if !isCandidate && f > 1.0 && f < 1.2 {
+			between = true
+		}
+	}
+	if !between {
+		t.Errorf("None of the inputs were between the candidates for f")
+	}
+}
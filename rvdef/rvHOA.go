@@ -0,0 +1,984 @@
+package rvdef
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//WriteHOA writes the policy with index policyIndex out as an automaton in the Hanoi Omega-Automata format (http://adl.github.io/hoaf/),
+// for tools such as Spot to analyse
+//The atomic propositions are the parts of the guards that aren't and/or/not: a part that matches a predicate without parameters
+// is named after the predicate, and any other part (e.g. a bool in the interface, or "v < 5") is named by its text.
+// A comparison and its complement are the same proposition (e.g. "v >= 5" is written as the negation of "v < 5").
+//As the guards of a state are tried in order, each edge is labelled with its guard and the negation of the guards before it,
+// and states with no else transition have an edge back to themselves, so the automaton is deterministic and complete.
+//Accepting states are in the acceptance set of a (state-based) Büchi condition.
+//Assignments are left out, so comparisons of internals (such as dtimers) become free propositions
+// (GuardInternals says which internals a policy's guards read).
+func (f Monitor) WriteHOA(w io.Writer, policyIndex int) error {
+	if policyIndex < 0 || policyIndex >= len(f.Policies) {
+		return fmt.Errorf("There is no policy %d", policyIndex)
+	}
+	p := f.Policies[policyIndex]
+	h := hoaWriter{f: f, p: p, index: make(map[string]int)}
+
+	states := make(map[string]int)
+	for i, st := range p.States {
+		states[st.Name] = i
+	}
+	edges := make([][]hoaEdge, len(p.States))
+	for i, st := range p.States {
+		var err error
+		if edges[i], err = h.stateEdges(st, states); err != nil {
+			return err
+		}
+	}
+
+	init := p.GetInitialState()
+	if init == nil {
+		return errors.New("Policy " + p.Name + " has no initial state (one state must be marked as initial)")
+	}
+	start := states[init.Name]
+	names := make([]string, len(p.States))
+	accepting := make([]bool, len(p.States))
+	for i, st := range p.States {
+		names[i] = st.Name
+		accepting[i] = st.Accepting
+	}
+
+	//the initial condition is checked on the first tick, so it is taken from a start state with the edges of both possible states
+	if init.InitialCondition != "" {
+		cond, err := h.guard(init.InitialCondition)
+		if err != nil {
+			return errors.New("The initial condition of " + init.Name + " is invalid: " + err.Error())
+		}
+		var startEdges []hoaEdge
+		for _, e := range edges[start] {
+			startEdges = append(startEdges, hoaEdge{label: hoaAnd(cond, e.label), dest: e.dest})
+		}
+		for _, e := range edges[states[init.InitialElse]] {
+			startEdges = append(startEdges, hoaEdge{label: hoaAnd(hoaNot(cond), e.label), dest: e.dest})
+		}
+		edges = append(edges, startEdges)
+		names = append(names, "_start")
+		accepting = append(accepting, init.Accepting)
+		start = len(edges) - 1
+	}
+
+	var b strings.Builder
+	b.WriteString("HOA: v1\n")
+	fmt.Fprintf(&b, "name: %s\n", strconv.Quote(f.Name+"."+p.Name))
+	b.WriteString("tool: \"easy-rv\"\n")
+	fmt.Fprintf(&b, "States: %d\n", len(edges))
+	fmt.Fprintf(&b, "Start: %d\n", start)
+	fmt.Fprintf(&b, "AP: %d", len(h.aps))
+	for _, ap := range h.aps {
+		b.WriteString(" " + strconv.Quote(ap))
+	}
+	b.WriteString("\nacc-name: Buchi\nAcceptance: 1 Inf(0)\n")
+	b.WriteString("properties: trans-labels explicit-labels state-acc deterministic complete\n")
+	b.WriteString("--BODY--\n")
+	for i := range edges {
+		fmt.Fprintf(&b, "State: %d %s", i, strconv.Quote(names[i]))
+		if accepting[i] {
+			b.WriteString(" {0}")
+		}
+		b.WriteString("\n")
+		for _, e := range edges[i] {
+			fmt.Fprintf(&b, "[%s] %d\n", hoaUnbracket(e.label), e.dest)
+		}
+	}
+	b.WriteString("--END--\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+//hoaWriter stores the atomic propositions found while writing out a policy
+type hoaWriter struct {
+	f     Monitor
+	p     Policy
+	aps   []string
+	index map[string]int
+}
+
+//hoaEdge is an edge of a HOA automaton
+type hoaEdge struct {
+	label string
+	dest  int
+}
+
+//stateEdges returns the edges of a state of the policy, with labels that can't overlap
+func (h *hoaWriter) stateEdges(st PState, states map[string]int) ([]hoaEdge, error) {
+	var edges []hoaEdge
+	var earlier []string
+	dest := st.Name
+	for _, tr := range h.p.Transitions {
+		if tr.Source != st.Name {
+			continue
+		}
+		if tr.Else {
+			dest = tr.Destination
+			continue
+		}
+		if _, ok := states[tr.Destination]; !ok {
+			return nil, errors.New("Transition " + tr.Source + " -> " + tr.Destination + " goes to an unknown state")
+		}
+		label, err := h.guard(tr.Condition)
+		if err != nil {
+			return nil, errors.New("Transition " + tr.Source + " -> " + tr.Destination + " has an invalid guard: " + err.Error())
+		}
+		terms := []string{label}
+		for _, e := range earlier {
+			terms = append(terms, hoaNot(e))
+		}
+		if l := hoaAnd(terms...); l != "f" {
+			edges = append(edges, hoaEdge{label: l, dest: states[tr.Destination]})
+		}
+		earlier = append(earlier, label)
+	}
+	if _, ok := states[dest]; !ok {
+		return nil, errors.New("Transition " + st.Name + " -> " + dest + " goes to an unknown state")
+	}
+
+	//when no guard holds, the else transition is taken (or the policy stays where it is)
+	rest := "t"
+	if len(earlier) > 0 {
+		rest = hoaNot(hoaOr(earlier...))
+	}
+	if rest != "f" {
+		edges = append(edges, hoaEdge{label: rest, dest: states[dest]})
+	}
+	return edges, nil
+}
+
+//guard converts a guard into a HOA label
+func (h *hoaWriter) guard(cond string) (string, error) {
+	if cond == "" {
+		return "t", nil
+	}
+	expr, err := ParseSTExpression(h.p.Name, cond)
+	if err != nil {
+		return "", err
+	}
+	return h.label(expr), nil
+}
+
+//label converts an expression into a HOA label, adding atomic propositions for the parts that aren't and/or/not
+func (h *hoaWriter) label(expr stcompilerlib.STExpression) string {
+	text := FormatSTExpression(expr)
+	if name := h.predicateNamed(text); name != "" {
+		return h.ap(name)
+	}
+	op := expr.HasOperator()
+	if op == nil {
+		switch text {
+		case "true":
+			return "t"
+		case "false":
+			return "f"
+		}
+		return h.ap(text)
+	}
+	args := expr.GetArguments()
+	switch op.GetToken() {
+	case "not":
+		return hoaNot(h.label(args[0]))
+	case "and":
+		return hoaAnd(h.label(args[1]), h.label(args[0]))
+	case "or":
+		return hoaOr(h.label(args[1]), h.label(args[0]))
+	}
+	if comp, ok := hoaComplements[op.GetToken()]; ok {
+		return hoaNot(h.label(stcompilerlib.STExpressionOperator{Operator: stcompilerlib.FindOp(comp), Arguments: args}))
+	}
+	return h.ap(text)
+}
+
+//hoaComplements maps the comparisons that are written as the negation of another comparison to that comparison
+var hoaComplements = map[string]string{">=": "<", "<=": ">", "<>": "="}
+
+//GuardInternals returns the internals (other than constants) that the guards and initial conditions of the policy with index
+// policyIndex read, in the order that they are declared in
+//Assignments can't be written in HOA, so in the automaton that WriteHOA writes, their comparisons can hold at any time.
+func (f Monitor) GuardInternals(policyIndex int) []string {
+	if policyIndex < 0 || policyIndex >= len(f.Policies) {
+		return nil
+	}
+	p := f.Policies[policyIndex]
+	read := make(map[string]bool)
+	var visit func(expr stcompilerlib.STExpression)
+	visit = func(expr stcompilerlib.STExpression) {
+		if expr.HasOperator() == nil {
+			root, _ := SplitAccessPath(expr.HasValue())
+			read[root] = true
+			return
+		}
+		for _, arg := range expr.GetArguments() {
+			visit(arg)
+		}
+	}
+	var conds []string
+	for _, tr := range p.Transitions {
+		conds = append(conds, tr.Condition)
+	}
+	for _, st := range p.States {
+		conds = append(conds, st.InitialCondition)
+	}
+	for _, cond := range conds {
+		if expr, err := ParseSTExpression(p.Name, cond); err == nil {
+			visit(expr)
+		}
+	}
+
+	//I/O hides any internals of the same name
+	for _, v := range f.InterfaceList {
+		read[v.Name] = false
+	}
+	var names []string
+	for _, v := range p.InternalVars {
+		if !v.Constant && read[v.Name] {
+			names = append(names, v.Name)
+		}
+	}
+	return names
+}
+
+//predicateNamed returns the name of the predicate without parameters (of the policy, or else of the monitor) whose body is text
+func (h *hoaWriter) predicateNamed(text string) string {
+	for _, preds := range [][]Predicate{h.p.Predicates, h.f.Predicates} {
+		for _, pred := range preds {
+			if len(pred.Params) == 0 && normaliseExpression(h.p.Name, pred.Body) == text {
+				return pred.Name
+			}
+		}
+	}
+	return ""
+}
+
+//ap returns the index of the atomic proposition with the given name (as a label), adding it if it is new
+func (h *hoaWriter) ap(name string) string {
+	i, ok := h.index[name]
+	if !ok {
+		i = len(h.aps)
+		h.index[name] = i
+		h.aps = append(h.aps, name)
+	}
+	return strconv.Itoa(i)
+}
+
+//hoaNot negates a HOA label
+func hoaNot(l string) string {
+	switch {
+	case l == "t":
+		return "f"
+	case l == "f":
+		return "t"
+	case strings.HasPrefix(l, "!"):
+		return l[1:]
+	}
+	return "!" + l
+}
+
+//hoaAnd joins HOA labels with &, leaving out any that are t
+func hoaAnd(ls ...string) string {
+	return hoaJoin(ls, " & ", "t", "f")
+}
+
+//hoaOr joins HOA labels with |, leaving out any that are f
+func hoaOr(ls ...string) string {
+	return hoaJoin(ls, " | ", "f", "t")
+}
+
+//hoaJoin joins HOA labels with op, leaving out any that are unit, and giving zero if any of them are zero
+//The result is bracketed if it joins more than one label, so that it can be used in any other label.
+func hoaJoin(ls []string, op string, unit string, zero string) string {
+	var terms []string
+	for _, l := range ls {
+		switch {
+		case l == zero:
+			return zero
+		case l == unit:
+		case hoaTopOp(l) == op:
+			terms = append(terms, l[1:len(l)-1])
+		default:
+			terms = append(terms, l)
+		}
+	}
+	switch len(terms) {
+	case 0:
+		return unit
+	case 1:
+		return terms[0]
+	}
+	return "(" + strings.Join(terms, op) + ")"
+}
+
+//hoaTopOp returns the operator (" & " or " | ") that a bracketed label joins its terms with, or "" if l isn't bracketed
+func hoaTopOp(l string) string {
+	if !strings.HasPrefix(l, "(") || !strings.HasSuffix(l, ")") {
+		return ""
+	}
+	depth := 0
+	for i := 1; i < len(l)-1; i++ {
+		switch l[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '&', '|':
+			if depth == 0 {
+				return " " + string(l[i]) + " "
+			}
+		}
+	}
+	return ""
+}
+
+//hoaUnbracket removes the brackets around a whole label
+func hoaUnbracket(l string) string {
+	if hoaTopOp(l) != "" {
+		return l[1 : len(l)-1]
+	}
+	return l
+}
+
+//ReadHOA reads an automaton in the Hanoi Omega-Automata format (e.g. from an LTL-to-automata tool such as Spot's ltl2tgba) as a Policy named name
+//(which must be an identifier, as it is used in the names of the generated code)
+//Each atomic proposition must be a bool in the interface of the Monitor, a predicate without parameters (of the Monitor),
+// or a guard (such as "v < 5", as WriteHOA names them).
+//The automaton must be deterministic with one start state and state-based acceptance (e.g. from "ltl2tgba -D -S"),
+// and its acceptance condition must be t, f, Inf(n) or Fin(n). A state is Accepting if the acceptance condition holds
+// when the state is visited forever.
+//As an automaton rejects when no edge can be taken, a rejecting sink state is added for any input that a state has no edge for.
+func (f Monitor) ReadHOA(r io.Reader, name string) (*Policy, error) {
+	if !IsIdentifier(name) {
+		return nil, fmt.Errorf("The policy name '%s' isn't an identifier (it can only have letters, digits and underscores, and can't start with a digit)", name)
+	}
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	toks, err := scanHOA(string(src))
+	if err != nil {
+		return nil, err
+	}
+	a, err := (&hoaParser{toks: toks}).parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(a.aps) > 64 {
+		return nil, fmt.Errorf("The automaton has %d atomic propositions, but at most 64 are supported", len(a.aps))
+	}
+	guards := make([]string, len(a.aps))
+	for i, ap := range a.aps {
+		if guards[i], err = f.hoaAPGuard(ap); err != nil {
+			return nil, err
+		}
+	}
+
+	//name the states after their names in the automaton, if they can be
+	p := &Policy{Name: name}
+	used := make(map[string]bool)
+	stateNames := make([]string, len(a.states))
+	for i, st := range a.states {
+		if IsIdentifier(st.name) && !used[st.name] {
+			stateNames[i] = st.name
+			used[st.name] = true
+		}
+	}
+	unused := func(name string) string {
+		for used[name] {
+			name += "_"
+		}
+		used[name] = true
+		return name
+	}
+	for i := range a.states {
+		if stateNames[i] == "" {
+			stateNames[i] = unused("s" + strconv.Itoa(i))
+		}
+	}
+	for i, st := range a.states {
+		p.States = append(p.States, PState{Name: stateNames[i], Accepting: a.accepting(st.marks), Initial: i == a.start})
+	}
+
+	sink := ""
+	for i, st := range a.states {
+		for _, e := range st.edges {
+			p.Transitions = append(p.Transitions, PTransition{Source: stateNames[i], Destination: stateNames[e.dest], Condition: e.label.guard(guards)})
+		}
+
+		//check that at most one state can be gone to for each input, and whether there is an edge for every input
+		//(only the atomic propositions that the edges of the state use need to be tried)
+		used := make(map[int]bool)
+		for _, e := range st.edges {
+			e.label.collectAPs(used)
+		}
+		if len(used) > maxGuardAtoms {
+			return nil, fmt.Errorf("The edges of state %d use %d atomic propositions, but at most %d are supported", i, len(used), maxGuardAtoms)
+		}
+		var bits []uint64
+		for ap := range used {
+			bits = append(bits, 1<<uint(ap))
+		}
+		complete := true
+		for combo := 0; combo < 1<<uint(len(bits)); combo++ {
+			vals := uint64(0)
+			for j, bit := range bits {
+				if combo&(1<<uint(j)) != 0 {
+					vals |= bit
+				}
+			}
+			dest := -1
+			for _, e := range st.edges {
+				if !e.label.eval(vals) {
+					continue
+				}
+				if dest >= 0 && dest != e.dest {
+					return nil, fmt.Errorf("State %d isn't deterministic (it can go to both state %d and state %d), so it can't be a policy (try determinising it, e.g. with 'autfilt -D')", i, dest, e.dest)
+				}
+				dest = e.dest
+			}
+			complete = complete && dest >= 0
+		}
+		if !complete {
+			if sink == "" {
+				sink = unused("sink")
+			}
+			p.Transitions = append(p.Transitions, PTransition{Source: stateNames[i], Destination: sink, Else: true})
+		}
+	}
+	if sink != "" {
+		p.States = append(p.States, PState{Name: sink})
+	}
+	return p, nil
+}
+
+//hoaAPGuard returns the guard that an atomic proposition stands for
+func (f Monitor) hoaAPGuard(ap string) (string, error) {
+	if IsIdentifier(ap) {
+		if pred := f.GetPredicate(Policy{}, ap); pred != nil {
+			if len(pred.Params) != 0 {
+				return "", errors.New("The atomic proposition " + ap + " is a predicate with parameters")
+			}
+			return "( " + pred.Body + " )", nil
+		}
+		for _, v := range f.InterfaceList {
+			if v.Name == ap {
+				if v.Type != "bool" || v.ArraySize != "" {
+					return "", errors.New("The atomic proposition " + ap + " isn't a bool in the interface")
+				}
+				return ap, nil
+			}
+		}
+		return "", errors.New("The atomic proposition " + ap + " isn't a bool in the interface or a predicate")
+	}
+	if _, err := ParseSTExpression("", ap); err != nil {
+		return "", errors.New("The atomic proposition '" + ap + "' isn't a name or a guard: " + err.Error())
+	}
+	return "( " + ap + " )", nil
+}
+
+//hoaAutomaton is the part of a HOA automaton that can be made into a policy
+type hoaAutomaton struct {
+	aps        []string
+	start      int
+	acceptance string //t, f, Inf or Fin
+	accSet     int    //for Inf and Fin, the acceptance set
+	states     []hoaState
+}
+
+//hoaState is a state of a HOA automaton
+type hoaState struct {
+	name  string
+	marks []int
+	edges []hoaParsedEdge
+}
+
+//hoaParsedEdge is an edge of a HOA automaton
+type hoaParsedEdge struct {
+	label hoaLabel
+	dest  int
+}
+
+//accepting returns whether a state that is in the given acceptance sets is accepting
+func (a hoaAutomaton) accepting(marks []int) bool {
+	in := false
+	for _, m := range marks {
+		in = in || m == a.accSet
+	}
+	switch a.acceptance {
+	case "t":
+		return true
+	case "Inf":
+		return in
+	case "Fin":
+		return !in
+	}
+	return false
+}
+
+//hoaLabel is a parsed HOA label
+type hoaLabel struct {
+	op   string //t, f, ap, !, & or |
+	ap   int
+	args []hoaLabel
+}
+
+//eval returns whether the label holds when the atomic propositions whose bits are set in vals hold
+func (l hoaLabel) eval(vals uint64) bool {
+	switch l.op {
+	case "t":
+		return true
+	case "ap":
+		return vals&(1<<uint(l.ap)) != 0
+	case "!":
+		return !l.args[0].eval(vals)
+	case "&":
+		return l.args[0].eval(vals) && l.args[1].eval(vals)
+	case "|":
+		return l.args[0].eval(vals) || l.args[1].eval(vals)
+	}
+	return false
+}
+
+//collectAPs adds the atomic propositions that the label uses to used
+func (l hoaLabel) collectAPs(used map[int]bool) {
+	if l.op == "ap" {
+		used[l.ap] = true
+	}
+	for _, arg := range l.args {
+		arg.collectAPs(used)
+	}
+}
+
+//guard converts the label into a guard, given the guards of the atomic propositions
+func (l hoaLabel) guard(aps []string) string {
+	switch l.op {
+	case "t":
+		return "true"
+	case "f":
+		return "false"
+	case "ap":
+		return aps[l.ap]
+	case "!":
+		return "!" + l.args[0].guard(aps)
+	case "&":
+		return "( " + l.args[0].guard(aps) + " and " + l.args[1].guard(aps) + " )"
+	}
+	return "( " + l.args[0].guard(aps) + " or " + l.args[1].guard(aps) + " )"
+}
+
+//hoaToken is a token of the HOA format
+type hoaToken struct {
+	kind byte //h for a header name (without the colon), s for a string, i for an integer, w for an identifier, a for an alias, p for punctuation, or b/e/x for --BODY--/--END--/--ABORT--
+	text string
+}
+
+//scanHOA breaks up a HOA automaton into its tokens (leaving out comments)
+func scanHOA(src string) ([]hoaToken, error) {
+	var toks []hoaToken
+	isWord := func(c byte) bool {
+		return c == '_' || c == '-' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	}
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "/*"):
+			//comments can be nested
+			depth := 0
+			for ; i < len(src); i++ {
+				if strings.HasPrefix(src[i:], "/*") {
+					depth++
+					i++
+				} else if strings.HasPrefix(src[i:], "*/") {
+					depth--
+					i++
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+			if depth != 0 {
+				return nil, errors.New("Unterminated comment")
+			}
+		case c == '"':
+			var s strings.Builder
+			i++
+			for ; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				s.WriteByte(src[i])
+			}
+			if i == len(src) {
+				return nil, errors.New("Unterminated string")
+			}
+			i++
+			toks = append(toks, hoaToken{kind: 's', text: s.String()})
+		case strings.HasPrefix(src[i:], "--BODY--"), strings.HasPrefix(src[i:], "--END--"), strings.HasPrefix(src[i:], "--ABORT--"):
+			end := strings.Index(src[i+2:], "--") + i + 4
+			toks = append(toks, hoaToken{kind: map[string]byte{"BODY": 'b', "END": 'e', "ABORT": 'x'}[src[i+2:end-2]], text: src[i:end]})
+			i = end
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			toks = append(toks, hoaToken{kind: 'i', text: src[i:j]})
+			i = j
+		case c == '@' || isWord(c):
+			j := i + 1
+			for j < len(src) && isWord(src[j]) {
+				j++
+			}
+			switch {
+			case c == '@':
+				toks = append(toks, hoaToken{kind: 'a', text: src[i:j]})
+			case j < len(src) && src[j] == ':':
+				toks = append(toks, hoaToken{kind: 'h', text: src[i:j]})
+				j++
+			default:
+				toks = append(toks, hoaToken{kind: 'w', text: src[i:j]})
+			}
+			i = j
+		case strings.IndexByte("[]{}()!&|", c) >= 0:
+			toks = append(toks, hoaToken{kind: 'p', text: string(c)})
+			i++
+		default:
+			return nil, fmt.Errorf("Unexpected character '%c'", c)
+		}
+	}
+	return toks, nil
+}
+
+//hoaParser parses the tokens of a HOA automaton
+type hoaParser struct {
+	toks    []hoaToken
+	pos     int
+	aliases map[string]hoaLabel
+}
+
+//peek returns the next token (with kind 0 at the end)
+func (hp *hoaParser) peek() hoaToken {
+	if hp.pos < len(hp.toks) {
+		return hp.toks[hp.pos]
+	}
+	return hoaToken{}
+}
+
+//next returns the next token and moves past it
+func (hp *hoaParser) next() hoaToken {
+	tok := hp.peek()
+	if hp.pos < len(hp.toks) {
+		hp.pos++
+	}
+	return tok
+}
+
+//is returns whether the next token has the given kind and text
+func (hp *hoaParser) is(kind byte, text string) bool {
+	tok := hp.peek()
+	return tok.kind == kind && tok.text == text
+}
+
+//expect moves past the next token if it has the given kind and text, and returns an error if it doesn't
+func (hp *hoaParser) expect(kind byte, text string) error {
+	if tok := hp.next(); tok.kind != kind || tok.text != text {
+		return errors.New("Expected '" + text + "', got '" + tok.text + "'")
+	}
+	return nil
+}
+
+//integer reads an integer
+func (hp *hoaParser) integer() (int, error) {
+	tok := hp.next()
+	if tok.kind != 'i' {
+		return 0, errors.New("Expected a number, got '" + tok.text + "'")
+	}
+	return strconv.Atoi(tok.text)
+}
+
+//parse reads the header and body of the automaton
+func (hp *hoaParser) parse() (*hoaAutomaton, error) {
+	a := &hoaAutomaton{start: -1}
+	hp.aliases = make(map[string]hoaLabel)
+	if err := hp.expect('h', "HOA"); err != nil {
+		return nil, errors.New("This isn't a HOA automaton: " + err.Error())
+	}
+	if err := hp.expect('w', "v1"); err != nil {
+		return nil, errors.New("Only version v1 of the HOA format is supported: " + err.Error())
+	}
+
+	numStates := -1
+	for {
+		tok := hp.next()
+		if tok.kind == 'b' {
+			break
+		}
+		if tok.kind != 'h' {
+			return nil, errors.New("Expected a header item or --BODY--, got '" + tok.text + "'")
+		}
+		var err error
+		switch tok.text {
+		case "States":
+			numStates, err = hp.integer()
+		case "Start":
+			if a.start >= 0 {
+				return nil, errors.New("The automaton has more than one start state")
+			}
+			a.start, err = hp.integer()
+			if hp.is('p', "&") {
+				return nil, errors.New("Alternating automata aren't supported")
+			}
+		case "AP":
+			var n int
+			if n, err = hp.integer(); err == nil {
+				for hp.peek().kind == 's' {
+					a.aps = append(a.aps, hp.next().text)
+				}
+				if len(a.aps) != n {
+					err = fmt.Errorf("%d atomic propositions were declared, but %d were named", n, len(a.aps))
+				}
+			}
+		case "Alias":
+			alias := hp.next()
+			if alias.kind != 'a' {
+				return nil, errors.New("Expected an alias name, got '" + alias.text + "'")
+			}
+			hp.aliases[alias.text], err = hp.label(len(a.aps))
+		case "Acceptance":
+			if _, err = hp.integer(); err == nil {
+				err = hp.acceptance(a)
+			}
+		default:
+			//other header items (e.g. acc-name and properties) don't change the policy
+			for k := hp.peek().kind; k != 'h' && k != 'b' && k != 0; k = hp.peek().kind {
+				hp.next()
+			}
+		}
+		if err != nil {
+			return nil, errors.New("Header item " + tok.text + ": " + err.Error())
+		}
+	}
+	if a.acceptance == "" {
+		return nil, errors.New("The automaton has no Acceptance")
+	}
+
+	for !hp.is('e', "--END--") {
+		if err := hp.expect('h', "State"); err != nil {
+			if hp.peek().kind == 'x' {
+				return nil, errors.New("The automaton was aborted")
+			}
+			return nil, err
+		}
+		if hp.is('p', "[") {
+			return nil, errors.New("State-based labels aren't supported")
+		}
+		i, err := hp.integer()
+		if err != nil {
+			return nil, err
+		}
+		for len(a.states) <= i {
+			a.states = append(a.states, hoaState{})
+		}
+		st := &a.states[i]
+		if hp.peek().kind == 's' {
+			st.name = hp.next().text
+		}
+		if st.marks, err = hp.accSig(); err != nil {
+			return nil, err
+		}
+
+		var implicit []int
+		for hp.peek().kind == 'i' || hp.is('p', "[") {
+			e := hoaParsedEdge{}
+			labelled := hp.is('p', "[")
+			if labelled {
+				hp.next()
+				if e.label, err = hp.label(len(a.aps)); err != nil {
+					return nil, fmt.Errorf("State %d: %s", i, err.Error())
+				}
+				if err := hp.expect('p', "]"); err != nil {
+					return nil, fmt.Errorf("State %d: %s", i, err.Error())
+				}
+			}
+			if e.dest, err = hp.integer(); err != nil {
+				return nil, fmt.Errorf("State %d: %s", i, err.Error())
+			}
+			if hp.is('p', "&") {
+				return nil, errors.New("Alternating automata aren't supported")
+			}
+			marks, err := hp.accSig()
+			if err != nil {
+				return nil, err
+			}
+			if len(marks) > 0 {
+				return nil, errors.New("Transition-based acceptance isn't supported (try making it state-based, e.g. with 'autfilt -S')")
+			}
+			if !labelled {
+				implicit = append(implicit, len(st.edges))
+			}
+			st.edges = append(st.edges, e)
+		}
+
+		//edges without labels are taken for each input in turn (with the first atomic proposition as the lowest bit)
+		if len(implicit) > 0 {
+			if len(implicit) != len(st.edges) || len(st.edges) != 1<<uint(len(a.aps)) {
+				return nil, fmt.Errorf("State %d has edges without labels, but not one for each input", i)
+			}
+			for v := range st.edges {
+				st.edges[v].label = hoaValuation(v, len(a.aps))
+			}
+		}
+	}
+
+	if numStates < 0 {
+		numStates = len(a.states)
+	}
+	if len(a.states) > numStates {
+		return nil, fmt.Errorf("The automaton has %d states, but state %d is defined", numStates, len(a.states)-1)
+	}
+	for len(a.states) < numStates {
+		a.states = append(a.states, hoaState{})
+	}
+	if a.start < 0 {
+		return nil, errors.New("The automaton has no start state")
+	}
+	if a.start >= numStates {
+		return nil, fmt.Errorf("The start state %d doesn't exist", a.start)
+	}
+	for i, st := range a.states {
+		for _, e := range st.edges {
+			if e.dest >= numStates {
+				return nil, fmt.Errorf("State %d has an edge to state %d, which doesn't exist", i, e.dest)
+			}
+		}
+	}
+	return a, nil
+}
+
+//acceptance reads the acceptance condition
+func (hp *hoaParser) acceptance(a *hoaAutomaton) error {
+	tok := hp.next()
+	switch {
+	case tok.kind == 'w' && (tok.text == "t" || tok.text == "f"):
+		a.acceptance = tok.text
+	case tok.kind == 'w' && (tok.text == "Inf" || tok.text == "Fin"):
+		a.acceptance = tok.text
+		if err := hp.expect('p', "("); err != nil {
+			return err
+		}
+		var err error
+		if a.accSet, err = hp.integer(); err != nil {
+			return err
+		}
+		if err := hp.expect('p', ")"); err != nil {
+			return err
+		}
+	default:
+		return errors.New("Expected t, f, Inf or Fin, got '" + tok.text + "'")
+	}
+	if k := hp.peek().kind; k != 'h' && k != 'b' {
+		return errors.New("Only acceptance conditions with one set (e.g. Buchi or co-Buchi) are supported")
+	}
+	return nil
+}
+
+//accSig reads the acceptance sets of a state or an edge (if there are any)
+func (hp *hoaParser) accSig() ([]int, error) {
+	if !hp.is('p', "{") {
+		return nil, nil
+	}
+	hp.next()
+	var marks []int
+	for !hp.is('p', "}") {
+		m, err := hp.integer()
+		if err != nil {
+			return nil, err
+		}
+		marks = append(marks, m)
+	}
+	hp.next()
+	return marks, nil
+}
+
+//label reads a label, where | binds less tightly than &, which binds less tightly than !
+func (hp *hoaParser) label(numAPs int) (hoaLabel, error) {
+	return hp.labelOp(numAPs, 0)
+}
+
+//labelOp reads a label made of the operators from ops[level] on (| then &)
+func (hp *hoaParser) labelOp(numAPs int, level int) (hoaLabel, error) {
+	ops := []string{"|", "&"}
+	if level == len(ops) {
+		return hp.labelAtom(numAPs)
+	}
+	l, err := hp.labelOp(numAPs, level+1)
+	for err == nil && hp.is('p', ops[level]) {
+		hp.next()
+		var r hoaLabel
+		r, err = hp.labelOp(numAPs, level+1)
+		l = hoaLabel{op: ops[level], args: []hoaLabel{l, r}}
+	}
+	return l, err
+}
+
+//labelAtom reads t, f, an atomic proposition, an alias, a negation or a bracketed label
+func (hp *hoaParser) labelAtom(numAPs int) (hoaLabel, error) {
+	tok := hp.next()
+	switch {
+	case tok.kind == 'w' && (tok.text == "t" || tok.text == "f"):
+		return hoaLabel{op: tok.text}, nil
+	case tok.kind == 'i':
+		i, _ := strconv.Atoi(tok.text)
+		if i >= numAPs {
+			return hoaLabel{}, fmt.Errorf("There is no atomic proposition %d", i)
+		}
+		return hoaLabel{op: "ap", ap: i}, nil
+	case tok.kind == 'a':
+		l, ok := hp.aliases[tok.text]
+		if !ok {
+			return hoaLabel{}, errors.New("Unknown alias " + tok.text)
+		}
+		return l, nil
+	case tok.kind == 'p' && tok.text == "!":
+		l, err := hp.labelAtom(numAPs)
+		return hoaLabel{op: "!", args: []hoaLabel{l}}, err
+	case tok.kind == 'p' && tok.text == "(":
+		l, err := hp.label(numAPs)
+		if err != nil {
+			return l, err
+		}
+		return l, hp.expect('p', ")")
+	}
+	return hoaLabel{}, errors.New("Unexpected '" + tok.text + "' in label")
+}
+
+//hoaValuation returns the label that only holds for the input v (with the first atomic proposition as the lowest bit)
+func hoaValuation(v int, numAPs int) hoaLabel {
+	l := hoaLabel{op: "t"}
+	for i := 0; i < numAPs; i++ {
+		ap := hoaLabel{op: "ap", ap: i}
+		if v&(1<<uint(i)) == 0 {
+			ap = hoaLabel{op: "!", args: []hoaLabel{ap}}
+		}
+		if i == 0 {
+			l = ap
+		} else {
+			l = hoaLabel{op: "&", args: []hoaLabel{l, ap}}
+		}
+	}
+	return l
+}
@@ -0,0 +1,226 @@
+package rvdef
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func hoaMonitor() Monitor {
+	return Monitor{
+		Name:          "m",
+		InterfaceList: []Variable{{Name: "A", Type: "bool"}, {Name: "B", Type: "bool"}, {Name: "x", Type: "uint8_t"}, {Name: "h", Type: "bool", ArraySize: "2"}},
+		Predicates:    []Predicate{{Name: "busy", Body: "A and x > 3"}, {Name: "above", Params: []string{"n"}, Body: "x > n"}},
+	}
+}
+
+func TestWriteHOA(t *testing.T) {
+	m := hoaMonitor()
+	m.Policies = []Policy{{
+		Name:       "P",
+		Predicates: []Predicate{{Name: "quiet", Body: "!A and !B"}},
+		States:     []PState{{Name: "s0", Accepting: true, Initial: true, InitialCondition: "!B", InitialElse: "s1"}, {Name: "s1", Accepting: true}, {Name: "bad"}},
+		Transitions: []PTransition{
+			{Source: "s0", Destination: "s1", Condition: "( A and x > 3 ) or B"},
+			{Source: "s0", Destination: "bad", Condition: "x = 0"},
+			{Source: "s1", Destination: "s0", Condition: "( !A and !B )"},
+			{Source: "s1", Destination: "bad", Else: true},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := m.WriteHOA(&buf, 0); err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	hoa := buf.String()
+	for _, want := range []string{
+		"HOA: v1\nname: \"m.P\"\n",
+		"States: 4\nStart: 3\n",
+		"AP: 4 \"busy\" \"B\" \"x = 0\" \"quiet\"\n",
+		"Acceptance: 1 Inf(0)\n",
+		//guards are tried in order, so each edge is only taken when the ones before it aren't
+		"State: 0 \"s0\" {0}\n[0 | 1] 1\n[2 & !(0 | 1)] 2\n[!(0 | 1 | 2)] 0\n",
+		"State: 1 \"s1\" {0}\n[3] 0\n[!3] 2\n",
+		"State: 2 \"bad\"\n[t] 2\n",
+		//the initial condition is checked from a start state
+		"State: 3 \"_start\" {0}\n[!1 & (0 | 1)] 1\n[!1 & 2 & !(0 | 1)] 2\n[!1 & !(0 | 1 | 2)] 0\n[1 & 3] 0\n[1 & !3] 2\n",
+	} {
+		if !strings.Contains(hoa, want) {
+			t.Errorf("HOA doesn't have '%s':\n%s", want, hoa)
+		}
+	}
+
+	//reading it back gives a policy that behaves the same way (the predicates of policies can't be used, so quiet is moved to the monitor)
+	back := hoaMonitor()
+	back.Predicates = append(back.Predicates, m.Policies[0].Predicates...)
+	p, err := back.ReadHOA(strings.NewReader(hoa), "R")
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when reading the HOA back", err.Error())
+	}
+	back.Policies = []Policy{*p}
+	res, err := ComparePolicies(m, 0, back, 0, CompareOptions{})
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when comparing", err.Error())
+	}
+	if !res.Same || !res.Complete {
+		t.Errorf("The policy read back was different: %+v\n%+v", res, *p)
+	}
+
+	if err := m.WriteHOA(&buf, 1); err == nil {
+		t.Errorf("Writing a policy that doesn't exist should have failed")
+	}
+}
+
+func TestWriteHOAComparisons(t *testing.T) {
+	//the AB5 policy of example/ab5
+	m := Monitor{
+		Name:          "ab5",
+		InterfaceList: []Variable{{Name: "A", Type: "bool"}, {Name: "B", Type: "bool"}},
+		Policies: []Policy{{
+			Name:         "AB5",
+			InternalVars: []Variable{{Name: "v", Type: "dtimer_t"}},
+			States:       []PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "s1"}, {Name: "done", Accepting: true}, {Name: "violation"}},
+			Transitions: []PTransition{
+				{Source: "s0", Destination: "s0", Condition: "( !A and !B )", Expressions: []PExpression{{VarName: "v", Value: "0"}}},
+				{Source: "s0", Destination: "s1", Condition: "( A and !B )", Expressions: []PExpression{{VarName: "v", Value: "0"}}},
+				{Source: "s0", Destination: "violation", Condition: "( !A and B )"},
+				{Source: "s0", Destination: "done", Condition: "( A and B )"},
+				{Source: "s1", Destination: "s1", Condition: "( !A and !B and v < 5 )"},
+				{Source: "s1", Destination: "s0", Condition: "( !A and B )"},
+				{Source: "s1", Destination: "violation", Condition: "( ( v >= 5 ) or ( A and B ) or ( A and !B ) )"},
+				{Source: "done", Destination: "done", Else: true},
+				{Source: "violation", Destination: "violation", Else: true},
+			},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := m.WriteHOA(&buf, 0); err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	hoa := buf.String()
+
+	//v >= 5 is the negation of v < 5, rather than a proposition of its own
+	if want := "AP: 3 \"A\" \"B\" \"v < 5\"\n"; !strings.Contains(hoa, want) {
+		t.Errorf("HOA doesn't have '%s':\n%s", want, hoa)
+	}
+	if want := "[(!2 | (0 & 1) | (0 & !1)) & !(!0 & !1 & 2) & !(!0 & 1)] 3\n"; !strings.Contains(hoa, want) {
+		t.Errorf("HOA doesn't have '%s':\n%s", want, hoa)
+	}
+
+	//reading it back gives a deterministic automaton with the same states, which needs no sink
+	p, err := m.ReadHOA(strings.NewReader(hoa), "R")
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when reading the HOA back", err.Error())
+	}
+	var states []string
+	for _, st := range p.States {
+		states = append(states, st.Name)
+	}
+	if strings.Join(states, " ") != "s0 s1 done violation" {
+		t.Errorf("The states read back were %v", states)
+	}
+
+	//v is left free, which the guards that read it are warned about
+	if internals := m.GuardInternals(0); len(internals) != 1 || internals[0] != "v" {
+		t.Errorf("The guards read the internals %v", internals)
+	}
+	if internals := hoaMonitor().GuardInternals(0); internals != nil {
+		t.Errorf("A policy that doesn't exist reads the internals %v", internals)
+	}
+}
+
+func TestReadHOA(t *testing.T) {
+	const header = "HOA: v1\nStates: 2\nStart: 0\nAP: 2 \"A\" \"busy\"\n"
+	tests := []struct {
+		hoa         string
+		err         string
+		states      string
+		transitions []string
+	}{
+		{
+			//a deterministic Büchi automaton for G(A -> F busy), as Spot writes it
+			hoa: "HOA: v1\nname: \"G(A -> Fbusy)\"\nStates: 2\nStart: 0\nAP: 2 \"A\" \"busy\"\n" +
+				"acc-name: Buchi\nAcceptance: 1 Inf(0)\nproperties: trans-labels explicit-labels state-acc complete\nproperties: deterministic\n" +
+				"--BODY--\nState: 0 {0}\n[!0 | 1] 0\n[0&!1] 1\nState: 1 /* waiting */\n[1] 0\n[!1] 1\n--END--\n",
+			states:      "s0+ s1-",
+			transitions: []string{"s0 -> s0 on ( !A or ( A and x > 3 ) )", "s0 -> s1 on ( A and !( A and x > 3 ) )", "s1 -> s0 on ( A and x > 3 )", "s1 -> s1 on !( A and x > 3 )"},
+		},
+		{
+			//a safety automaton: the inputs that a state has no edge for go to a rejecting sink
+			hoa:         header + "Acceptance: 0 t\n--BODY--\nState: 0 \"ok\"\n[!0] 0\n[0 & 1] 1\nState: 1 \"sink\"\n[t] 1\n--END--\n",
+			states:      "ok+ sink+ sink_-",
+			transitions: []string{"ok -> ok on !A", "ok -> sink on ( A and ( A and x > 3 ) )", "ok -> sink_ else", "sink -> sink on true"},
+		},
+		{
+			//implicit labels, aliases, and co-Büchi acceptance
+			hoa:         "HOA: v1\nStates: 2\nStart: 1\nAP: 1 \"x < 10\"\nAlias: @low 0\nAcceptance: 1 Fin(0)\n--BODY--\nState: 0 {0}\n0 1\nState: 1\n[!@low] 1\n[@low] 0\n--END--\n",
+			states:      "s0- s1+",
+			transitions: []string{"s0 -> s0 on !( x < 10 )", "s0 -> s1 on ( x < 10 )", "s1 -> s1 on !( x < 10 )", "s1 -> s0 on ( x < 10 )"},
+		},
+		{hoa: header + "Acceptance: 1 Inf(0)\n--BODY--\nState: 0\n[0] 0\n[0 | 1] 1\nState: 1\n--END--\n", err: "State 0 isn't deterministic"},
+		{hoa: header + "Acceptance: 1 Inf(0)\n--BODY--\nState: 0\n[0] 0 {0}\nState: 1\n--END--\n", err: "Transition-based acceptance isn't supported"},
+		{hoa: header + "Acceptance: 2 Inf(0) & Inf(1)\n--BODY--\nState: 0\nState: 1\n--END--\n", err: "Only acceptance conditions with one set"},
+		{hoa: header + "Start: 1\nAcceptance: 0 t\n--BODY--\nState: 0\nState: 1\n--END--\n", err: "more than one start state"},
+		{hoa: header + "Acceptance: 0 t\n--BODY--\nState: 0\n[2] 1\nState: 1\n--END--\n", err: "There is no atomic proposition 2"},
+		{hoa: header + "Acceptance: 0 t\n--BODY--\nState: 0\n[0] 2\nState: 1\n--END--\n", err: "State 0 has an edge to state 2"},
+		{hoa: "HOA: v1\nStart: 0\nAP: 1 \"C\"\nAcceptance: 0 t\n--BODY--\nState: 0\n--END--\n", err: "The atomic proposition C isn't a bool in the interface or a predicate"},
+		{hoa: "HOA: v1\nStart: 0\nAP: 1 \"x\"\nAcceptance: 0 t\n--BODY--\nState: 0\n--END--\n", err: "The atomic proposition x isn't a bool in the interface"},
+		{hoa: "HOA: v1\nStart: 0\nAP: 1 \"above\"\nAcceptance: 0 t\n--BODY--\nState: 0\n--END--\n", err: "is a predicate with parameters"},
+		{hoa: "HOA: v1\nStart: 0\nAP: 0\n--BODY--\nState: 0\n--END--\n", err: "The automaton has no Acceptance"},
+	}
+
+	m := hoaMonitor()
+
+	//the policy is named after the file it came from, which might not be an identifier
+	for _, name := range []string{"my-aut", "2nd", ""} {
+		if _, err := m.ReadHOA(strings.NewReader(tests[0].hoa), name); err == nil || !strings.Contains(err.Error(), "isn't an identifier") {
+			t.Errorf("Reading a policy named '%s' gave error %v", name, err)
+		}
+	}
+
+	for i, test := range tests {
+		p, err := m.ReadHOA(strings.NewReader(test.hoa), "P")
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Test %d: error was %v, it should have been '%s'", i, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Test %d: error '%s' occurred when it shouldn't have", i, err.Error())
+		}
+
+		var states []string
+		for _, st := range p.States {
+			acc := "-"
+			if st.Accepting {
+				acc = "+"
+			}
+			states = append(states, st.Name+acc)
+		}
+		if got := strings.Join(states, " "); got != test.states {
+			t.Errorf("Test %d: states were '%s', they should have been '%s'", i, got, test.states)
+		}
+		if init := p.GetInitialState(); init == nil || !init.Initial {
+			t.Errorf("Test %d: the start state wasn't made initial", i)
+		}
+		var transitions []string
+		for _, tr := range p.Transitions {
+			if tr.Else {
+				transitions = append(transitions, tr.Source+" -> "+tr.Destination+" else")
+			} else {
+				transitions = append(transitions, tr.Source+" -> "+tr.Destination+" on "+tr.Condition)
+			}
+		}
+		if got, want := strings.Join(transitions, "\n"), strings.Join(test.transitions, "\n"); got != want {
+			t.Errorf("Test %d: transitions were\n%s\nthey should have been\n%s", i, got, want)
+		}
+
+		mon := m
+		mon.Policies = []Policy{*p}
+		if err := mon.Validate(); err != nil {
+			t.Errorf("Test %d: the policy isn't valid: %s", i, err.Error())
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package rvdef
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//MinimiseStates merges the bisimilar states of each of the Monitor's policies, and returns how many states were removed from each policy
+//The states of each policy are finalised (see FinaliseStates) both before and after they are merged.
+func (f *Monitor) MinimiseStates() []int {
+	removed := make([]int, len(f.Policies))
+	for i := range f.Policies {
+		removed[i] = f.minimisePolicy(&f.Policies[i])
+	}
+	return removed
+}
+
+//minimisePolicy merges the bisimilar states of p, and returns how many states were removed
+//Two states are bisimilar if they give the same verdict, and their transitions (in order) have equivalent guards,
+// the same assignments, and go to bisimilar states. Guards are equivalent if they are written the same way
+// or, when they only read the interface and constants, if IsGuardSatisfiable shows that they can never differ.
+//(Guards that read internal variables are only compared as they are written, to be conservative.)
+func (f Monitor) minimisePolicy(p *Policy) int {
+	p.FinaliseStates()
+
+	guards := guardClasses{f: f, p: *p}
+
+	//start with the states split by their verdicts, then keep splitting them by their transitions until nothing changes
+	class := make(map[string]int)
+	numClasses := 0
+	for {
+		ids := make(map[string]int)
+		next := make(map[string]int)
+		for _, st := range p.States {
+			sig := fmt.Sprintf("%v,%v", st.Accepting, st.FinalStatusType)
+			if numClasses > 0 {
+				sig = fmt.Sprintf("%d", class[st.Name])
+				for _, tr := range p.Transitions {
+					if tr.Source == st.Name {
+						sig += fmt.Sprintf(";%d,%v,%s,%d", guards.get(tr), tr.Else, normaliseAssignments(p.Name, tr.Expressions), class[tr.Destination])
+					}
+				}
+			}
+			if _, ok := ids[sig]; !ok {
+				ids[sig] = len(ids)
+			}
+			next[st.Name] = ids[sig]
+		}
+		done := len(ids) == numClasses
+		class, numClasses = next, len(ids)
+		if done {
+			break
+		}
+	}
+
+	//each class is kept as one of its states (the initial state if it is in the class, otherwise the first)
+	reps := make(map[int]string)
+	if st := p.GetInitialState(); st != nil {
+		reps[class[st.Name]] = st.Name
+	}
+	for _, st := range p.States {
+		if _, ok := reps[class[st.Name]]; !ok {
+			reps[class[st.Name]] = st.Name
+		}
+	}
+	rep := func(name string) string {
+		if c, ok := class[name]; ok {
+			return reps[c]
+		}
+		return name
+	}
+
+	var states []PState
+	for _, st := range p.States {
+		if rep(st.Name) != st.Name {
+			continue
+		}
+		if st.InitialElse != "" {
+			st.InitialElse = rep(st.InitialElse)
+		}
+		states = append(states, st)
+	}
+	var transitions []PTransition
+	for _, tr := range p.Transitions {
+		if rep(tr.Source) != tr.Source {
+			continue
+		}
+		tr.Destination = rep(tr.Destination)
+		transitions = append(transitions, tr)
+	}
+
+	removed := len(p.States) - len(states)
+	p.States, p.Transitions = states, transitions
+	p.FinaliseStates()
+	return removed
+}
+
+//guardClasses numbers guards so that equivalent guards get the same number
+type guardClasses struct {
+	f      Monitor
+	p      Policy
+	guards []string //the first guard seen of each class
+}
+
+//get returns the number of the class of the guard of tr
+func (g *guardClasses) get(tr PTransition) int {
+	if tr.Else {
+		return -1 //else transitions are compared by the other transitions of their state
+	}
+	guard := normaliseExpression(g.p.Name, tr.Condition)
+	for i, other := range g.guards {
+		if guard == other {
+			return i
+		}
+	}
+	if !g.readsInternals(guard) {
+		for i, other := range g.guards {
+			if g.readsInternals(other) {
+				continue
+			}
+			differ := PTransition{Condition: "( " + guard + " ) xor ( " + other + " )"}
+			if !g.f.IsGuardSatisfiable(g.p, differ) {
+				return i
+			}
+		}
+	}
+	g.guards = append(g.guards, guard)
+	return len(g.guards) - 1
+}
+
+//readsInternals returns true if guard uses any (non-constant) internal variables of the policy
+func (g *guardClasses) readsInternals(guard string) bool {
+	expr, err := ParseSTExpression(g.p.Name, guard)
+	if err != nil {
+		return true
+	}
+	return g.exprReadsInternals(expr)
+}
+
+//exprReadsInternals returns true if expr uses any (non-constant) internal variables of the policy
+func (g *guardClasses) exprReadsInternals(expr stcompilerlib.STExpression) bool {
+	if expr.HasOperator() == nil {
+		root, _ := SplitAccessPath(expr.HasValue())
+		if g.f.InterfaceList.HasIONamed(true, root) {
+			return false
+		}
+		for _, v := range g.p.InternalVars {
+			if v.Name == root && !v.Constant {
+				return true
+			}
+		}
+		return false
+	}
+	for _, arg := range expr.GetArguments() {
+		if g.exprReadsInternals(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+//normaliseAssignments returns the assignments written in a standard way
+func normaliseAssignments(pName string, exprs []PExpression) string {
+	var s []string
+	for _, ex := range exprs {
+		s = append(s, normaliseExpression(pName, ex.VarName+" := "+ex.Value))
+	}
+	return strings.Join(s, ", ")
+}
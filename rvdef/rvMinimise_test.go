@@ -0,0 +1,95 @@
+package rvdef
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMinimiseStates(t *testing.T) {
+	m := Monitor{
+		Name:          "m",
+		InterfaceList: []Variable{{Name: "a", Type: "bool"}, {Name: "b", Type: "bool"}},
+		Policies: []Policy{
+			{
+				//s0 and s1 just swap with each other, and s2 and s3 are the same (apart from how their guards are written)
+				Name: "Swap",
+				States: []PState{
+					{Name: "s0", Accepting: true, Initial: true},
+					{Name: "s1", Accepting: true},
+					{Name: "s2", Accepting: false},
+					{Name: "s3", Accepting: false},
+					{Name: "bad", Accepting: false},
+				},
+				Transitions: []PTransition{
+					{Source: "s0", Destination: "s1", Condition: "!a"},
+					{Source: "s0", Destination: "s2", Condition: "a"},
+					{Source: "s1", Destination: "s0", Condition: "!a"},
+					{Source: "s1", Destination: "s3", Condition: "a"},
+					{Source: "s2", Destination: "s0", Condition: "a and b"},
+					{Source: "s2", Destination: "bad", Else: true},
+					{Source: "s3", Destination: "s1", Condition: "!(!b or !a)"},
+					{Source: "s3", Destination: "bad", Else: true},
+				},
+			},
+			{
+				//s1 and s2 read an internal in different ways, so they must be kept apart even though the guards are equivalent
+				Name:         "Internal",
+				InternalVars: []Variable{{Name: "x", Type: "bool"}},
+				States: []PState{
+					{Name: "s0", Accepting: true, Initial: true},
+					{Name: "s1", Accepting: true},
+					{Name: "s2", Accepting: true},
+				},
+				Transitions: []PTransition{
+					{Source: "s0", Destination: "s1", Condition: "a", Expressions: []PExpression{{VarName: "x", Value: "b"}}},
+					{Source: "s0", Destination: "s2", Condition: "!a"},
+					{Source: "s1", Destination: "s0", Condition: "x and a"},
+					{Source: "s2", Destination: "s0", Condition: "a and x"},
+				},
+			},
+			{
+				//s1 and s2 only differ in what they assign
+				Name:         "Assignments",
+				InternalVars: []Variable{{Name: "x", Type: "bool"}},
+				States: []PState{
+					{Name: "s0", Accepting: true, Initial: true},
+					{Name: "s1", Accepting: true},
+					{Name: "s2", Accepting: true},
+				},
+				Transitions: []PTransition{
+					{Source: "s0", Destination: "s1", Condition: "a"},
+					{Source: "s0", Destination: "s2", Condition: "!a"},
+					{Source: "s1", Destination: "s0", Condition: "true", Expressions: []PExpression{{VarName: "x", Value: "true"}}},
+					{Source: "s2", Destination: "s0", Condition: "true", Expressions: []PExpression{{VarName: "x", Value: "false"}}},
+				},
+			},
+		},
+	}
+
+	removed := m.MinimiseStates()
+	if !reflect.DeepEqual(removed, []int{2, 0, 0}) {
+		t.Fatalf("Removed %v states, it should have been [2 0 0]", removed)
+	}
+
+	swap := m.Policies[0]
+	wantStates := []PState{
+		{Name: "s0", Accepting: true, Initial: true},
+		{Name: "s2", Accepting: false},
+		{Name: "bad", Accepting: false, FinalStatusType: true},
+	}
+	if !reflect.DeepEqual(swap.States, wantStates) {
+		t.Errorf("States were %+v, they should have been %+v", swap.States, wantStates)
+	}
+	wantTransitions := []PTransition{
+		{Source: "s0", Destination: "s0", Condition: "!a"},
+		{Source: "s0", Destination: "s2", Condition: "a"},
+		{Source: "s2", Destination: "s0", Condition: "a and b"},
+		{Source: "s2", Destination: "bad", Else: true},
+	}
+	if !reflect.DeepEqual(swap.Transitions, wantTransitions) {
+		t.Errorf("Transitions were %+v, they should have been %+v", swap.Transitions, wantTransitions)
+	}
+	if err := m.Validate(); err != nil {
+		t.Errorf("Minimised monitor is invalid: %s", err.Error())
+	}
+}
@@ -10,13 +10,18 @@ import (
 
 //FBECCGuardToSTExpression converts a given FB's guard into a STExpression parsetree
 func FBECCGuardToSTExpression(pName, guard string) ([]stcompilerlib.STInstruction, *stcompilerlib.STParseError) {
-	return stcompilerlib.ParseString(pName, guard)
+	expr, err := ParseSTExpression(pName, guard)
+	if err != nil {
+		return nil, err
+	}
+	return []stcompilerlib.STInstruction{expr}, nil
 }
 
 //PSTTransition is a container struct for a PTransition and its ST translated guard
 type PSTTransition struct {
 	PTransition
-	STGuard stcompilerlib.STExpression
+	STGuard       stcompilerlib.STExpression
+	STExpressions []stcompilerlib.STExpression //the ST translated output expressions (each is a := assignment)
 }
 
 //A PMonitorPolicy is what goes inside a PMonitor, it is derived from a Policy
@@ -24,6 +29,7 @@ type PMonitorPolicy struct {
 	InternalVars []Variable
 	States       []PState
 	Transitions  []PSTTransition
+	InitialGuard stcompilerlib.STExpression //the ST translated initial condition of the initial state (nil if there isn't one)
 }
 
 //GetDTimers returns all DTIMERS in a PMonitorPolicy
@@ -86,6 +92,13 @@ func MakePMonitor(il InterfaceList, p Policy) (*PMonitor, error) {
 		Transitions:  outpTr,
 	}
 
+	if st := p.GetInitialState(); st != nil && st.InitialCondition != "" {
+		enf.Policy.InitialGuard, err = p.getSTGuard(PTransition{Condition: st.InitialCondition})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	enf.Policy.RemoveNilTransitions()
 	enf.Policy.RemoveDuplicateTransitions()
 
@@ -93,10 +106,11 @@ func MakePMonitor(il InterfaceList, p Policy) (*PMonitor, error) {
 }
 
 //RemoveNilTransitions will do a search through a policies transitions and remove any that have nil guards
+//(else transitions have no Condition of their own, so they are only removed if their STGuard is nil)
 func (pol *PMonitorPolicy) RemoveNilTransitions() {
 	for i := 0; i < len(pol.Transitions); i++ {
 		for j := i; j < len(pol.Transitions); j++ {
-			if pol.Transitions[j].STGuard == nil || pol.Transitions[j].Condition == "" || stcompilerlib.STCompileExpression(pol.Transitions[j].STGuard) == "" {
+			if pol.Transitions[j].STGuard == nil || (pol.Transitions[j].Condition == "" && !pol.Transitions[j].Else) || stcompilerlib.STCompileExpression(pol.Transitions[j].STGuard) == "" {
 				pol.Transitions = append(pol.Transitions[:j], pol.Transitions[j+1:]...)
 				j--
 			}
@@ -153,25 +167,61 @@ func stringSliceContains(slice []string, str string) bool {
 func (p *Policy) GetPSTTransitions() ([]PSTTransition, error) {
 	stTrans := make([]PSTTransition, len(p.Transitions))
 	for i := 0; i < len(p.Transitions); i++ {
-		stguard, err := FBECCGuardToSTExpression(p.Name, p.Transitions[i].Condition)
+		expr, err := p.getSTGuard(p.Transitions[i])
 		if err != nil {
 			return nil, err
 		}
-		if len(stguard) != 1 {
-			return nil, fmt.Errorf("Incompatible policy guard (wrong number of expressions)")
-		}
-		expr, ok := stguard[0].(stcompilerlib.STExpression)
-		if !ok {
-			return nil, fmt.Errorf("Incompatible policy guard (not an expression)")
-		}
 		stTrans[i] = PSTTransition{
 			PTransition: p.Transitions[i],
 			STGuard:     expr,
 		}
+		for _, ex := range p.Transitions[i].Expressions {
+			stex, err := p.getSTAssignment(ex)
+			if err != nil {
+				return nil, err
+			}
+			stTrans[i].STExpressions = append(stTrans[i].STExpressions, stex)
+		}
 	}
 	return stTrans, nil
 }
 
+//getSTAssignment converts a single PExpression into a ST symbolic tree of the form [:= VarName Value]
+func (p Policy) getSTAssignment(ex PExpression) (stcompilerlib.STExpression, error) {
+	expr, err := ParseSTExpression(p.Name, ex.VarName+" := "+ex.Value)
+	if err != nil {
+		return nil, err
+	}
+	op := expr.HasOperator()
+	if op == nil || op.GetToken() != ":=" {
+		return nil, fmt.Errorf("Incompatible output expression (not an assignment)")
+	}
+	args := expr.GetArguments()
+	if args[1].HasValue() == "" {
+		return nil, fmt.Errorf("Incompatible output expression (can't assign to '%s')", ex.VarName)
+	}
+	if args[0].HasOperator() != nil && args[0].HasOperator().GetToken() == ":=" {
+		return nil, fmt.Errorf("Incompatible output expression (more than one assignment)")
+	}
+	return expr, nil
+}
+
+//getSTGuard converts the guard of a single PTransition into a ST symbolic tree
+func (p Policy) getSTGuard(tr PTransition) (stcompilerlib.STExpression, error) {
+	stguard, err := FBECCGuardToSTExpression(p.Name, p.GetTransitionCondition(tr))
+	if err != nil {
+		return nil, err
+	}
+	if len(stguard) != 1 {
+		return nil, fmt.Errorf("Incompatible policy guard (wrong number of expressions)")
+	}
+	expr, ok := stguard[0].(stcompilerlib.STExpression)
+	if !ok {
+		return nil, fmt.Errorf("Incompatible policy guard (not an expression)")
+	}
+	return expr, nil
+}
+
 //SplitExpressionsOnOr will take a given STExpression and return a slice of STExpressions which are
 //split over the "or" operators, e.g.
 //[a] should become [a]
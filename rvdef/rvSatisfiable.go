@@ -0,0 +1,421 @@
+package rvdef
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//maxGuardAtoms is the most atoms a guard can have before IsGuardSatisfiable gives up (and assumes it is satisfiable)
+const maxGuardAtoms = 16
+
+//IsGuardSatisfiable returns false if the guard of tr (in Policy p) can be shown to never hold, e.g. "a and !a" or "t < 5 and t > 10"
+//Every combination of the comparisons and booleans in the guard is tried, and a combination only counts
+// if its comparisons of a variable with constants can all hold at once (within the range of the variable's type)
+//This can't prove everything (e.g. comparisons between two variables are assumed to hold),
+// so a guard that it can't decide is assumed to be satisfiable.
+func (f Monitor) IsGuardSatisfiable(p Policy, tr PTransition) bool {
+	expr, err := p.getSTGuard(tr)
+	if err != nil {
+		return true
+	}
+	s := satChecker{f: f, p: p, index: make(map[string]int)}
+	s.collectAtoms(expr)
+	if len(s.atoms) > maxGuardAtoms {
+		return true
+	}
+
+	values := make([]bool, len(s.atoms))
+	for combo := 0; combo < 1<<uint(len(s.atoms)); combo++ {
+		for i := range values {
+			values[i] = combo&(1<<uint(i)) != 0
+		}
+		if s.eval(expr, values) && s.consistent(values) {
+			return true
+		}
+	}
+	return false
+}
+
+//satAtom is a part of a guard that is treated as a single boolean (e.g. a bool variable, or a comparison)
+type satAtom struct {
+	expr stcompilerlib.STExpression
+
+	//for comparisons of a variable with a constant (i.e. "name op value"), these are set
+	name  string
+	op    string
+	value satValue
+
+	//for comparisons of two constants, this is their result
+	fixed, fixedValue bool
+}
+
+//satValue is a constant, which is a number or an enum member
+type satValue struct {
+	num    float64
+	member string
+}
+
+//satChecker finds the atoms of a guard and decides whether the guard can hold
+type satChecker struct {
+	f     Monitor
+	p     Policy
+	atoms []satAtom
+	index map[string]int //from the formatted atom to its index in atoms
+}
+
+//isSatConnective returns true if tok is one of the boolean operators that join atoms
+func isSatConnective(tok string) bool {
+	return tok == "not" || tok == "and" || tok == "or" || tok == "xor"
+}
+
+//collectAtoms finds all of the atoms in expr
+func (s *satChecker) collectAtoms(expr stcompilerlib.STExpression) {
+	if op := expr.HasOperator(); op != nil && isSatConnective(op.GetToken()) {
+		for _, arg := range expr.GetArguments() {
+			s.collectAtoms(arg)
+		}
+		return
+	}
+	if val := expr.HasValue(); strings.EqualFold(val, "true") || strings.EqualFold(val, "false") {
+		return
+	}
+	key := FormatSTExpression(expr)
+	if _, ok := s.index[key]; ok {
+		return
+	}
+	s.index[key] = len(s.atoms)
+	s.atoms = append(s.atoms, s.makeAtom(expr))
+}
+
+//makeAtom works out what is known about an atom
+func (s *satChecker) makeAtom(expr stcompilerlib.STExpression) satAtom {
+	atom := satAtom{expr: expr}
+	op := expr.HasOperator()
+	if op == nil || !stcompilerlib.OpTokenIsComparison(op.GetToken()) {
+		return atom
+	}
+	//arguments are in reverse order
+	args := expr.GetArguments()
+	tok := op.GetToken()
+	left, lok := s.constValue(args[1])
+	right, rok := s.constValue(args[0])
+	switch {
+	case lok && rok:
+		atom.fixed = true
+		atom.fixedValue = compareSatValues(left, tok, right)
+	case rok && args[1].HasValue() != "":
+		atom.name, atom.op, atom.value = args[1].HasValue(), tok, right
+	case lok && args[0].HasValue() != "":
+		atom.name, atom.op, atom.value = args[0].HasValue(), flipComparison(tok), left
+	}
+	return atom
+}
+
+//constValue returns the value of expr, if it is made only from literals, numeric constants, and enum members
+func (s *satChecker) constValue(expr stcompilerlib.STExpression) (satValue, bool) {
+	if op := expr.HasOperator(); op != nil {
+		args := expr.GetArguments()
+		if op.GetToken() == stNegative {
+			v, ok := s.constValue(args[0])
+			return satValue{num: -v.num}, ok && v.member == ""
+		}
+		if len(args) != 2 {
+			return satValue{}, false
+		}
+		a, aok := s.constValue(args[1])
+		b, bok := s.constValue(args[0])
+		if !aok || !bok || a.member != "" || b.member != "" {
+			return satValue{}, false
+		}
+		switch op.GetToken() {
+		case "+":
+			return satValue{num: a.num + b.num}, true
+		case "-":
+			return satValue{num: a.num - b.num}, true
+		case "*":
+			return satValue{num: a.num * b.num}, true
+		}
+		return satValue{}, false
+	}
+
+	val := expr.HasValue()
+	for _, e := range s.f.Enums {
+		if e.HasMember(val) {
+			return satValue{member: val}, true
+		}
+	}
+	for _, v := range s.p.InternalVars {
+		if v.Name == val && v.Constant {
+			val = v.InitialValue
+		}
+	}
+	if i, err := strconv.ParseInt(val, 0, 64); err == nil {
+		return satValue{num: float64(i)}, true
+	}
+	if fl, err := strconv.ParseFloat(val, 64); err == nil {
+		return satValue{num: fl}, true
+	}
+	return satValue{}, false
+}
+
+//eval evaluates expr when its atoms have the given values
+func (s *satChecker) eval(expr stcompilerlib.STExpression, values []bool) bool {
+	op := expr.HasOperator()
+	if op == nil || !isSatConnective(op.GetToken()) {
+		if val := expr.HasValue(); strings.EqualFold(val, "true") || strings.EqualFold(val, "false") {
+			return strings.EqualFold(val, "true")
+		}
+		return values[s.index[FormatSTExpression(expr)]]
+	}
+	args := expr.GetArguments()
+	switch op.GetToken() {
+	case "not":
+		return !s.eval(args[0], values)
+	case "and":
+		return s.eval(args[0], values) && s.eval(args[1], values)
+	case "or":
+		return s.eval(args[0], values) || s.eval(args[1], values)
+	}
+	return s.eval(args[0], values) != s.eval(args[1], values) //xor
+}
+
+//consistent returns false if the atoms can't have the given values at the same time
+func (s *satChecker) consistent(values []bool) bool {
+	domains := make(map[string]*satDomain)
+	for i, atom := range s.atoms {
+		if atom.fixed {
+			if atom.fixedValue != values[i] {
+				return false
+			}
+			continue
+		}
+		if atom.name == "" {
+			continue
+		}
+		d := domains[atom.name]
+		if d == nil {
+			if d = s.newDomain(atom.name); d == nil {
+				continue
+			}
+			domains[atom.name] = d
+		}
+		op := atom.op
+		if !values[i] {
+			op = negateComparison(op)
+		}
+		d.restrict(op, atom.value)
+	}
+	for _, d := range domains {
+		if d.empty() {
+			return false
+		}
+	}
+	return true
+}
+
+//satDomain is the set of values that a variable can have, given the comparisons that have been made with it
+type satDomain struct {
+	integer, float bool //otherwise, it is an enum
+
+	lo, hi             float64
+	loStrict, hiStrict bool //for floats
+	excluded           []float64
+
+	members         []string //for enums
+	equal           string
+	excludedMembers []string
+	broken          bool //set if a restriction can never hold
+}
+
+//newDomain returns the domain of a variable, from the range of its type (or nil if its type isn't a number or an enum)
+func (s *satChecker) newDomain(name string) *satDomain {
+	v := s.f.GetVariable(s.p, name)
+	if v == nil || v.ArraySize != "" {
+		return nil
+	}
+	switch getTypeClass(*v) {
+	case typeInteger:
+		lo, hi := IntegerTypeRange(v.Type)
+		return &satDomain{integer: true, lo: lo, hi: hi}
+	case typeFloat:
+		return &satDomain{float: true, lo: math.Inf(-1), hi: math.Inf(1)}
+	}
+	if members := s.f.GetEnumValues(*v); len(members) > 0 {
+		return &satDomain{members: members}
+	}
+	return nil
+}
+
+//restrict narrows the domain to the values where "value op c" holds
+func (d *satDomain) restrict(op string, c satValue) {
+	if !d.integer && !d.float {
+		switch {
+		case c.member == "":
+			d.broken = true //an enum can't be compared with a number
+		case op == "=" && d.equal != "" && d.equal != c.member:
+			d.broken = true
+		case op == "=":
+			d.equal = c.member
+		case op == "<>":
+			d.excludedMembers = append(d.excludedMembers, c.member)
+		}
+		return
+	}
+	if c.member != "" {
+		d.broken = true
+		return
+	}
+	switch op {
+	case "<":
+		if d.integer {
+			d.setHi(math.Ceil(c.num)-1, false)
+		} else {
+			d.setHi(c.num, true)
+		}
+	case "<=":
+		if d.integer {
+			d.setHi(math.Floor(c.num), false)
+		} else {
+			d.setHi(c.num, false)
+		}
+	case ">":
+		if d.integer {
+			d.setLo(math.Floor(c.num)+1, false)
+		} else {
+			d.setLo(c.num, true)
+		}
+	case ">=":
+		if d.integer {
+			d.setLo(math.Ceil(c.num), false)
+		} else {
+			d.setLo(c.num, false)
+		}
+	case "=":
+		if d.integer && c.num != math.Trunc(c.num) {
+			d.broken = true
+		}
+		d.setLo(c.num, false)
+		d.setHi(c.num, false)
+	case "<>":
+		d.excluded = append(d.excluded, c.num)
+	}
+}
+
+//setLo raises the lower bound of the domain to lo (if it is higher)
+func (d *satDomain) setLo(lo float64, strict bool) {
+	if lo > d.lo || (lo == d.lo && strict) {
+		d.lo, d.loStrict = lo, strict
+	}
+}
+
+//setHi lowers the upper bound of the domain to hi (if it is lower)
+func (d *satDomain) setHi(hi float64, strict bool) {
+	if hi < d.hi || (hi == d.hi && strict) {
+		d.hi, d.hiStrict = hi, strict
+	}
+}
+
+//empty returns true if no value is left in the domain
+func (d *satDomain) empty() bool {
+	if d.broken {
+		return true
+	}
+	if !d.integer && !d.float {
+		if d.equal != "" {
+			return stringSliceContains(d.excludedMembers, d.equal)
+		}
+		for _, m := range d.members {
+			if !stringSliceContains(d.excludedMembers, m) {
+				return false
+			}
+		}
+		return true
+	}
+	if d.lo > d.hi || (d.lo == d.hi && (d.loStrict || d.hiStrict)) {
+		return true
+	}
+	if d.float {
+		//there are always more floats between two different bounds
+		return d.lo == d.hi && containsFloat(d.excluded, d.lo)
+	}
+	left := d.hi - d.lo + 1
+	seen := make(map[float64]bool)
+	for _, x := range d.excluded {
+		if x >= d.lo && x <= d.hi && !seen[x] {
+			seen[x] = true
+			left--
+		}
+	}
+	return left <= 0
+}
+
+//containsFloat returns true if x is in xs
+func containsFloat(xs []float64, x float64) bool {
+	for _, y := range xs {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
+
+//compareSatValues returns the result of "a op b"
+func compareSatValues(a satValue, op string, b satValue) bool {
+	if a.member != "" || b.member != "" {
+		switch op {
+		case "=":
+			return a.member == b.member
+		case "<>":
+			return a.member != b.member
+		}
+		return true //not something we can know
+	}
+	switch op {
+	case "<":
+		return a.num < b.num
+	case "<=":
+		return a.num <= b.num
+	case ">":
+		return a.num > b.num
+	case ">=":
+		return a.num >= b.num
+	case "=":
+		return a.num == b.num
+	}
+	return a.num != b.num
+}
+
+//flipComparison returns the comparison with its operands swapped (e.g. a < b is b > a)
+func flipComparison(op string) string {
+	switch op {
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	}
+	return op
+}
+
+//negateComparison returns the opposite comparison (e.g. !(a < b) is a >= b)
+func negateComparison(op string) string {
+	switch op {
+	case "<":
+		return ">="
+	case "<=":
+		return ">"
+	case ">":
+		return "<="
+	case ">=":
+		return "<"
+	case "=":
+		return "<>"
+	}
+	return "="
+}
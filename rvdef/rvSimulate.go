@@ -0,0 +1,620 @@
+package rvdef
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//ValueKind is the kind of a Value
+type ValueKind int
+
+//The kinds of Value
+const (
+	ValueBool ValueKind = iota
+	ValueInt
+	ValueFloat
+	ValueEnum
+)
+
+//A Value is the value of a variable (or of an expression) while simulating a policy
+type Value struct {
+	Kind   ValueKind
+	Int    int64   //for bools (0 or 1), integers, and enums (the index of the member)
+	Float  float64 //for floats
+	Member string  //for enums
+}
+
+//BoolValue returns b as a Value
+func BoolValue(b bool) Value {
+	if b {
+		return Value{Kind: ValueBool, Int: 1}
+	}
+	return Value{Kind: ValueBool}
+}
+
+//IntValue returns i as a Value
+func IntValue(i int64) Value {
+	return Value{Kind: ValueInt, Int: i}
+}
+
+//FloatValue returns f as a Value
+func FloatValue(f float64) Value {
+	return Value{Kind: ValueFloat, Float: f}
+}
+
+//String returns the Value as it would be written in a guard
+func (v Value) String() string {
+	switch v.Kind {
+	case ValueBool:
+		return strconv.FormatBool(v.Int != 0)
+	case ValueFloat:
+		s := strconv.FormatFloat(v.Float, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEI") {
+			s += ".0"
+		}
+		return s
+	case ValueEnum:
+		return v.Member
+	}
+	return strconv.FormatInt(v.Int, 10)
+}
+
+//num returns the Value as a float, for arithmetic that involves floats
+func (v Value) num() float64 {
+	if v.Kind == ValueFloat {
+		return v.Float
+	}
+	return float64(v.Int)
+}
+
+//truth returns the Value as a C condition would see it (i.e. true if non-zero)
+func (v Value) truth() bool {
+	if v.Kind == ValueFloat {
+		return v.Float != 0
+	}
+	return v.Int != 0
+}
+
+//Inputs are the values of a Monitor's interface for one tick, by access path (e.g. "a", "pkt.temp", or "sensors[2]")
+//Interface variables that aren't given are zero.
+type Inputs map[string]Value
+
+//String returns the Inputs sorted by name, e.g. "a=true, t=3"
+func (in Inputs) String() string {
+	var s []string
+	for _, name := range in.Names() {
+		s = append(s, name+"="+in[name].String())
+	}
+	return strings.Join(s, ", ")
+}
+
+//Names returns the names of the Inputs in sorted order
+func (in Inputs) Names() []string {
+	names := make([]string, 0, len(in))
+	for name := range in {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//A PolicySimulator runs a policy of a Monitor one tick at a time, in the same way as the generated C
+type PolicySimulator struct {
+	f Monitor
+	p Policy
+
+	State     string           //the current state
+	Started   bool             //set once the first tick has run (and the initial condition has been checked)
+	Internals map[string]Value //the values of the (non-constant) internals, by access path
+
+	//TimerLimit, if it isn't 0, is where dtimers stop counting up
+	//(this keeps the number of different simulator states finite, and doesn't change any verdicts if dtimers are only compared
+	//with constants smaller than it)
+	TimerLimit int64
+
+	transitions  []PSTTransition
+	initialGuard stcompilerlib.STExpression
+}
+
+//NewPolicySimulator returns a PolicySimulator for the policy with index policyIndex of the Monitor, in its initial state
+func (f Monitor) NewPolicySimulator(policyIndex int) (*PolicySimulator, error) {
+	if policyIndex < 0 || policyIndex >= len(f.Policies) {
+		return nil, fmt.Errorf("there is no policy with index %d", policyIndex)
+	}
+	p := f.Policies[policyIndex]
+	p.States = append([]PState(nil), p.States...)
+	p.FinaliseStates()
+
+	mon, err := MakePMonitor(f.InterfaceList, p)
+	if err != nil {
+		return nil, fmt.Errorf("Policy %s: %s", p.Name, err.Error())
+	}
+	s := &PolicySimulator{f: f, p: p, transitions: mon.Policy.Transitions, initialGuard: mon.Policy.InitialGuard, Internals: make(map[string]Value)}
+	st := p.GetInitialState()
+	if st == nil {
+		return nil, fmt.Errorf("Policy %s: there is no initial state (one state must be marked as initial)", p.Name)
+	}
+	s.State = st.Name
+	for _, v := range p.InternalVars {
+		if v.Constant {
+			continue
+		}
+		initial := v.GetInitialArray()
+		for i, leaf := range f.leaves(p, v, v.Name) {
+			val := zeroValue(f, leaf.v)
+			if leaf.v.InitialValue != "" && initial == nil {
+				val, err = f.parseValue(leaf.v, leaf.v.InitialValue)
+			} else if initial != nil && i < len(initial) && initial[i] != "" {
+				val, err = f.parseValue(leaf.v, initial[i])
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Policy %s: initial value of %s: %s", p.Name, v.Name, err.Error())
+			}
+			s.Internals[leaf.path] = val
+		}
+	}
+	return s, nil
+}
+
+//Policy returns the (finalised) policy being simulated
+func (s *PolicySimulator) Policy() Policy {
+	return s.p
+}
+
+//Copy returns a copy of the simulator, which can be stepped without changing s
+func (s *PolicySimulator) Copy() *PolicySimulator {
+	c := *s
+	c.Internals = make(map[string]Value, len(s.Internals))
+	for k, v := range s.Internals {
+		c.Internals[k] = v
+	}
+	return &c
+}
+
+//Key returns a string that is the same for two simulators of the same policy if (and only if) they are in the same state
+func (s *PolicySimulator) Key() string {
+	names := make([]string, 0, len(s.Internals))
+	for name := range s.Internals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	key := fmt.Sprintf("%s,%v", s.State, s.Started)
+	for _, name := range names {
+		key += ";" + name + "=" + s.Internals[name].String()
+	}
+	return key
+}
+
+//Verdict returns what the policy's check_rv_status function would return in the current state:
+//0 for true, 1 for currently true, 2 for currently false, and 3 for false
+func (s *PolicySimulator) Verdict() int {
+	if st := s.p.GetState(s.State); st != nil {
+		return st.Verdict()
+	}
+	return 2
+}
+
+//Step runs one tick of the policy with the given inputs, and returns the transition that was taken (or nil if none was)
+func (s *PolicySimulator) Step(in Inputs) (*PTransition, error) {
+	//advance timers
+	for _, v := range s.p.InternalVars {
+		if v.IsDTimer() && !v.Constant && v.ArraySize == "" {
+			t := s.Internals[v.Name]
+			if s.TimerLimit == 0 || t.Int < s.TimerLimit {
+				t.Int++
+			}
+			s.Internals[v.Name] = t
+		}
+	}
+
+	//check the initial condition on the first tick
+	if !s.Started {
+		s.Started = true
+		if st := s.p.GetInitialState(); st != nil && s.initialGuard != nil {
+			holds, err := s.eval(in, s.initialGuard)
+			if err != nil {
+				return nil, fmt.Errorf("initial condition of %s: %s", st.Name, err.Error())
+			}
+			if !holds.truth() {
+				s.State = st.InitialElse
+			}
+		}
+	}
+
+	//take the first transition whose guard holds (or else the else transition)
+	for pass := 0; pass < 2; pass++ {
+		for i := range s.transitions {
+			tr := &s.transitions[i]
+			if tr.Source != s.State || tr.Else != (pass == 1) {
+				continue
+			}
+			if !tr.Else {
+				holds, err := s.eval(in, tr.STGuard)
+				if err != nil {
+					return nil, fmt.Errorf("transition %s -> %s: %s", tr.Source, tr.Destination, err.Error())
+				}
+				if !holds.truth() {
+					continue
+				}
+			}
+			s.State = tr.Destination
+			for _, ex := range tr.STExpressions {
+				if err := s.assign(in, ex); err != nil {
+					return nil, fmt.Errorf("transition %s -> %s: %s", tr.Source, tr.Destination, err.Error())
+				}
+			}
+			return &tr.PTransition, nil
+		}
+	}
+	return nil, nil
+}
+
+//assign runs an assignment (an expression of the form [:= target value])
+func (s *PolicySimulator) assign(in Inputs, ex stcompilerlib.STExpression) error {
+	args := ex.GetArguments()
+	val, err := s.eval(in, args[0])
+	if err != nil {
+		return err
+	}
+	path, err := s.resolvePath(in, args[1].HasValue())
+	if err != nil {
+		return err
+	}
+	v := s.f.GetVariable(s.p, path)
+	if v == nil {
+		return fmt.Errorf("can't assign to %s", path)
+	}
+	s.Internals[path] = convertValue(s.f, *v, val)
+	return nil
+}
+
+//eval evaluates an expression with the given inputs (and the simulator's internals)
+func (s *PolicySimulator) eval(in Inputs, expr stcompilerlib.STExpression) (Value, error) {
+	op := expr.HasOperator()
+	if op == nil {
+		return s.evalValue(in, expr.HasValue())
+	}
+
+	//arguments are in reverse order
+	stArgs := expr.GetArguments()
+	args := make([]Value, len(stArgs))
+	for i := range stArgs {
+		v, err := s.eval(in, stArgs[len(stArgs)-1-i])
+		if err != nil {
+			return Value{}, err
+		}
+		args[i] = v
+	}
+
+	tok := op.GetToken()
+	switch tok {
+	case "not":
+		return BoolValue(!args[0].truth()), nil
+	case stNegative:
+		if args[0].Kind == ValueFloat {
+			return FloatValue(-args[0].Float), nil
+		}
+		return IntValue(-args[0].Int), nil
+	case "and":
+		return BoolValue(args[0].truth() && args[1].truth()), nil
+	case "or":
+		return BoolValue(args[0].truth() || args[1].truth()), nil
+	case "xor":
+		if args[0].Kind == ValueBool && args[1].Kind == ValueBool {
+			return BoolValue(args[0].truth() != args[1].truth()), nil
+		}
+		return IntValue(args[0].Int ^ args[1].Int), nil
+	case "**":
+		return FloatValue(math.Pow(args[0].num(), args[1].num())), nil
+	case "+", "-", "*", "/", "MOD":
+		return arithmetic(tok, args[0], args[1])
+	case "<", "<=", ">", ">=", "=", "<>":
+		return BoolValue(compareValues(tok, args[0], args[1])), nil
+	}
+
+	name := FunctionName(tok)
+	if cast := (Variable{Name: name, Type: name}); s.f.GetEnum(name) != nil || getTypeClass(cast) == typeBool || isNumericClass(getTypeClass(cast)) {
+		if len(args) != 1 {
+			return Value{}, fmt.Errorf("cast to %s must have exactly one argument", name)
+		}
+		return convertValue(s.f, cast, args[0]), nil
+	}
+	return callFunction(name, args)
+}
+
+//evalValue returns the value of a literal, variable, constant, or enum member
+func (s *PolicySimulator) evalValue(in Inputs, val string) (Value, error) {
+	if strings.EqualFold(val, "true") || strings.EqualFold(val, "false") {
+		return BoolValue(strings.EqualFold(val, "true")), nil
+	}
+	if i, err := strconv.ParseInt(val, 0, 64); err == nil {
+		return IntValue(i), nil
+	}
+	if f, err := strconv.ParseFloat(val, 64); err == nil {
+		return FloatValue(f), nil
+	}
+	if len(val) > 2 && strings.HasPrefix(val, "'") && strings.HasSuffix(val, "'") {
+		return IntValue(int64(val[1])), nil
+	}
+
+	root, _ := SplitAccessPath(val)
+	if s.f.InterfaceList.HasIONamed(true, root) {
+		path, err := s.resolvePath(in, val)
+		if err != nil {
+			return Value{}, err
+		}
+		if v, ok := in[path]; ok {
+			return v, nil
+		}
+		if v := s.f.GetVariable(s.p, path); v != nil {
+			return zeroValue(s.f, *v), nil
+		}
+		return Value{}, fmt.Errorf("can't resolve '%s'", path)
+	}
+	for _, v := range s.p.InternalVars {
+		if v.Name == root && v.Constant {
+			return s.f.parseValue(v, v.InitialValue)
+		}
+	}
+	if s.f.GetVariable(s.p, root) != nil {
+		path, err := s.resolvePath(in, val)
+		if err != nil {
+			return Value{}, err
+		}
+		if v, ok := s.Internals[path]; ok {
+			return v, nil
+		}
+		return Value{}, fmt.Errorf("can't resolve '%s'", path)
+	}
+	for _, e := range s.f.Enums {
+		for i, m := range e.Members {
+			if m == val {
+				return Value{Kind: ValueEnum, Int: int64(i), Member: m}, nil
+			}
+		}
+	}
+	return Value{}, fmt.Errorf("can't resolve '%s'", val)
+}
+
+//resolvePath evaluates the array indices in an access path, e.g. "a[i+1].b" might become "a[3].b"
+func (s *PolicySimulator) resolvePath(in Inputs, val string) (string, error) {
+	root, path := SplitAccessPath(val)
+	resolved := root
+	for path != "" {
+		if path[0] == '.' {
+			end := strings.IndexAny(path[1:], ".[")
+			if end == -1 {
+				end = len(path) - 1
+			}
+			resolved += path[:end+1]
+			path = path[end+1:]
+			continue
+		}
+		depth, end := 0, -1
+		for i, ch := range path {
+			if ch == '[' {
+				depth++
+			} else if ch == ']' {
+				depth--
+				if depth == 0 {
+					end = i
+					break
+				}
+			}
+		}
+		if end == -1 {
+			return "", fmt.Errorf("unmatched '[' in '%s'", val)
+		}
+		indexExpr, perr := ParseSTExpression(s.p.Name, path[1:end])
+		if perr != nil {
+			return "", fmt.Errorf("bad index in '%s': %s", val, perr.Error())
+		}
+		index, err := s.eval(in, indexExpr)
+		if err != nil {
+			return "", err
+		}
+		resolved += "[" + strconv.FormatInt(index.Int, 10) + "]"
+		path = path[end+1:]
+	}
+	if s.f.GetVariable(s.p, resolved) == nil {
+		return "", fmt.Errorf("'%s' is out of bounds", resolved)
+	}
+	return resolved, nil
+}
+
+//simLeaf is a scalar part of a variable (e.g. one element of an array, or one member of a struct)
+type simLeaf struct {
+	path string
+	v    Variable
+}
+
+//leaves returns all of the scalar parts of v (which is called path)
+func (f Monitor) leaves(p Policy, v Variable, path string) []simLeaf {
+	if v.ArraySize != "" {
+		size, ok := f.GetIntegerConstant(p, v.ArraySize)
+		if !ok {
+			return nil
+		}
+		elem := v
+		elem.ArraySize, elem.InitialValue = "", ""
+		var leaves []simLeaf
+		for i := 0; i < size; i++ {
+			leaves = append(leaves, f.leaves(p, elem, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return leaves
+	}
+	if v.IsStruct() {
+		var leaves []simLeaf
+		for _, field := range v.Fields {
+			leaves = append(leaves, f.leaves(p, field, path+"."+field.Name)...)
+		}
+		return leaves
+	}
+	return []simLeaf{{path: path, v: v}}
+}
+
+//zeroValue returns the zero value of a scalar variable
+func zeroValue(f Monitor, v Variable) Value {
+	return convertValue(f, v, IntValue(0))
+}
+
+//parseValue parses a literal (e.g. an initial value) as a value of the type of v
+func (f Monitor) parseValue(v Variable, s string) (Value, error) {
+	if e := f.GetEnum(v.Type); e != nil {
+		for i, m := range e.Members {
+			if m == s {
+				return Value{Kind: ValueEnum, Int: int64(i), Member: m}, nil
+			}
+		}
+		return Value{}, fmt.Errorf("%s is not a member of %s", s, e.Name)
+	}
+	expr, err := ParseSTExpression(v.Name, s)
+	if err != nil {
+		return Value{}, fmt.Errorf("can't parse '%s'", s)
+	}
+	sim := PolicySimulator{f: Monitor{Enums: f.Enums}}
+	val, verr := sim.eval(nil, expr)
+	if verr != nil {
+		return Value{}, verr
+	}
+	return convertValue(f, v, val), nil
+}
+
+//convertValue converts val to the type of v, in the same way as C would when assigning it
+func convertValue(f Monitor, v Variable, val Value) Value {
+	if e := f.GetEnum(v.Type); e != nil {
+		if val.Kind == ValueEnum {
+			return val
+		}
+		i := int64(val.num())
+		member := ""
+		if i >= 0 && i < int64(len(e.Members)) {
+			member = e.Members[i]
+		}
+		return Value{Kind: ValueEnum, Int: i, Member: member}
+	}
+	switch getTypeClass(v) {
+	case typeBool:
+		return BoolValue(val.truth())
+	case typeFloat:
+		f := val.num()
+		if strings.ToLower(v.Type) == "float" {
+			f = float64(float32(f))
+		}
+		return FloatValue(f)
+	}
+	i := val.Int
+	if val.Kind == ValueFloat {
+		i = int64(val.Float)
+	}
+	switch strings.ToLower(v.Type) {
+	case "int8_t", "char":
+		i = int64(int8(i))
+	case "uint8_t":
+		i = int64(uint8(i))
+	case "int16_t":
+		i = int64(int16(i))
+	case "uint16_t":
+		i = int64(uint16(i))
+	case "int32_t":
+		i = int64(int32(i))
+	case "uint32_t":
+		i = int64(uint32(i))
+	}
+	return IntValue(i)
+}
+
+//arithmetic returns "a tok b"
+func arithmetic(tok string, a Value, b Value) (Value, error) {
+	if a.Kind == ValueFloat || b.Kind == ValueFloat {
+		x, y := a.num(), b.num()
+		switch tok {
+		case "+":
+			return FloatValue(x + y), nil
+		case "-":
+			return FloatValue(x - y), nil
+		case "*":
+			return FloatValue(x * y), nil
+		case "/":
+			return FloatValue(x / y), nil
+		}
+		return Value{}, fmt.Errorf("'%s' can't be used with floats", tok)
+	}
+	x, y := a.Int, b.Int
+	switch tok {
+	case "+":
+		return IntValue(x + y), nil
+	case "-":
+		return IntValue(x - y), nil
+	case "*":
+		return IntValue(x * y), nil
+	}
+	if y == 0 {
+		return Value{}, fmt.Errorf("division by zero")
+	}
+	if tok == "/" {
+		return IntValue(x / y), nil
+	}
+	return IntValue(x % y), nil
+}
+
+//compareValues returns "a tok b"
+func compareValues(tok string, a Value, b Value) bool {
+	var c int
+	switch {
+	case a.Kind == ValueFloat || b.Kind == ValueFloat:
+		x, y := a.num(), b.num()
+		if x != y {
+			if x < y {
+				c = -1
+			} else if x > y {
+				c = 1
+			} else {
+				return tok == "<>" //NaN
+			}
+		}
+	case a.Int < b.Int:
+		c = -1
+	case a.Int > b.Int:
+		c = 1
+	}
+	switch tok {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "=":
+		return c == 0
+	}
+	return c != 0
+}
+
+//callFunction calls one of the C maths functions that can be used in expressions
+func callFunction(name string, args []Value) (Value, error) {
+	one := map[string]func(float64) float64{
+		"fabs": math.Abs, "sqrt": math.Sqrt, "floor": math.Floor, "ceil": math.Ceil, "round": math.Round,
+		"exp": math.Exp, "log": math.Log, "sin": math.Sin, "cos": math.Cos, "tan": math.Tan,
+	}
+	two := map[string]func(float64, float64) float64{"pow": math.Pow, "fmin": math.Min, "fmax": math.Max}
+	switch {
+	case (name == "abs" || name == "labs") && len(args) == 1:
+		if args[0].Int < 0 {
+			return IntValue(-args[0].Int), nil
+		}
+		return IntValue(args[0].Int), nil
+	case one[name] != nil && len(args) == 1:
+		return FloatValue(one[name](args[0].num())), nil
+	case two[name] != nil && len(args) == 2:
+		return FloatValue(two[name](args[0].num(), args[1].num())), nil
+	}
+	return Value{}, fmt.Errorf("unknown function '%s' (with %d arguments)", name, len(args))
+}
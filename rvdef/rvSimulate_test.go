@@ -0,0 +1,130 @@
+package rvdef
+
+import (
+	"strings"
+	"testing"
+)
+
+//ab5Monitor returns the AB5 example, where B must follow A within the given number of ticks
+func ab5Monitor(deadline string) Monitor {
+	return Monitor{
+		Name:          "ab5",
+		InterfaceList: []Variable{{Name: "A", Type: "bool"}, {Name: "B", Type: "bool"}},
+		Policies: []Policy{
+			{
+				Name:         "AB5",
+				InternalVars: []Variable{{Name: "v", Type: "dtimer_t"}},
+				States: []PState{
+					{Name: "s0", Accepting: true, Initial: true},
+					{Name: "s1", Accepting: false},
+					{Name: "violation", Accepting: false},
+				},
+				Transitions: []PTransition{
+					{Source: "s0", Destination: "s1", Condition: "A and !B", Expressions: []PExpression{{VarName: "v", Value: "0"}}},
+					{Source: "s0", Destination: "s0", Condition: "!A or B"},
+					{Source: "s1", Destination: "s0", Condition: "B"},
+					{Source: "s1", Destination: "s1", Condition: "v < " + deadline},
+					{Source: "s1", Destination: "violation", Else: true},
+				},
+			},
+		},
+	}
+}
+
+func TestPolicySimulator(t *testing.T) {
+	sim, err := ab5Monitor("5").NewPolicySimulator(0)
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	a := Inputs{"A": BoolValue(true), "B": BoolValue(false)}
+	none := Inputs{}
+	steps := []struct {
+		In      Inputs
+		State   string
+		Verdict int
+	}{
+		{none, "s0", 1},
+		{a, "s1", 2},
+		{none, "s1", 2},
+		{none, "s1", 2},
+		{none, "s1", 2},
+		{none, "s1", 2},
+		{none, "violation", 3},
+		{Inputs{"B": BoolValue(true)}, "violation", 3},
+	}
+	for i, step := range steps {
+		if _, err := sim.Step(step.In); err != nil {
+			t.Fatalf("Tick %d: error '%s' occurred when it shouldn't have", i+1, err.Error())
+		}
+		if sim.State != step.State || sim.Verdict() != step.Verdict {
+			t.Errorf("Tick %d: in state %s with verdict %d, it should have been %s with verdict %d", i+1, sim.State, sim.Verdict(), step.State, step.Verdict)
+		}
+	}
+
+	//a policy with no initial state (e.g. one built with AddState) can't be simulated
+	m := ab5Monitor("5")
+	m.Policies[0].States[0].Initial = false
+	if m.Policies[0].GetInitialState() != nil {
+		t.Errorf("A policy with no initial state has one")
+	}
+	if _, err := m.NewPolicySimulator(0); err == nil || !strings.Contains(err.Error(), "no initial state") {
+		t.Errorf("Simulating a policy with no initial state gave the error %v", err)
+	}
+}
+
+func TestSimulateExpressions(t *testing.T) {
+	m := heaterMonitor("true", "IDLE", "")
+	m.InterfaceList = append(m.InterfaceList,
+		Variable{Name: "pkt", Type: "packet_t", Fields: []Variable{{Name: "temp", Type: "int16_t"}}},
+		Variable{Name: "f", Type: "float"},
+	)
+	m.Policies[0].InternalVars = append(m.Policies[0].InternalVars,
+		Variable{Name: "hist", Type: "int16_t", ArraySize: "3", InitialValue: "[1, 2, 3]"},
+		Variable{Name: "MAX", Type: "uint8_t", Constant: true, InitialValue: "2"},
+	)
+	sim, err := m.NewPolicySimulator(0)
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	in := Inputs{"temp": IntValue(7), "mode": Value{Kind: ValueEnum, Int: 1, Member: "HEATING"}, "pkt.temp": IntValue(-3), "f": FloatValue(2.5)}
+	tests := []struct {
+		Expr  string
+		Value string
+	}{
+		{"temp / 2", "3"},
+		{"temp MOD 4", "3"},
+		{"-temp * 2 + 1", "-13"},
+		{"temp > 5 and !(mode = IDLE)", "true"},
+		{"mode = HEATING xor true", "false"},
+		{"pkt.temp < 0", "true"},
+		{"hist[MAX] + hist[MAX - 2]", "4"},
+		{"hist[temp - 6]", "2"},
+		{"f * 2", "5.0"},
+		{"float(temp) / 2", "3.5"},
+		{"int16_t(f)", "2"},
+		{"uint8_t(300)", "44"},
+		{"fmax(f, 3)", "3.0"},
+		{"abs(pkt.temp)", "3"},
+		{"count", "0"},
+		{"last", "IDLE"},
+	}
+	for _, test := range tests {
+		expr, perr := ParseSTExpression("P", test.Expr)
+		if perr != nil {
+			t.Fatalf("%s: can't parse: %s", test.Expr, perr.Error())
+		}
+		val, err := sim.eval(in, expr)
+		if err != nil {
+			t.Errorf("%s: Error '%s' occurred when it shouldn't have", test.Expr, err.Error())
+		} else if val.String() != test.Value {
+			t.Errorf("%s: got %s, it should have been %s", test.Expr, val.String(), test.Value)
+		}
+	}
+
+	for _, bad := range []string{"hist[5]", "temp / 0", "nope + 1", "foo(1)"} {
+		expr, _ := ParseSTExpression("P", bad)
+		if _, err := sim.eval(in, expr); err == nil {
+			t.Errorf("%s: Error didn't occur and it should have", bad)
+		}
+	}
+}
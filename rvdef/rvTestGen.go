@@ -0,0 +1,313 @@
+package rvdef
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+//TestGenOptions limits how hard GenerateTests looks for traces (0 means the default for each)
+type TestGenOptions struct {
+	MaxDepth  int //the longest trace to try (default no limit)
+	MaxStates int //the most simulator states to explore (default 1000000)
+	MaxInputs int //the most different inputs to try on each tick (default 4096)
+}
+
+//TestTrace is a sequence of inputs for a policy, and the verdict that the policy should give after each of them
+type TestTrace struct {
+	Inputs   []Inputs
+	Verdicts []int
+	Covers   []string //the states and transitions that this trace is the first in the suite to reach or take
+}
+
+//TestSuite is a set of traces that, between them, reach every reachable state of a policy and take every reachable transition
+type TestSuite struct {
+	Policy    string
+	Traces    []TestTrace
+	Targets   int      //how many states and transitions the policy has
+	Uncovered []string //the states and transitions that no trace reaches or takes
+	Complete  bool     //false if the search was stopped by one of the limits in TestGenOptions
+}
+
+//GenerateTests looks for input traces that drive the policy with index policyIndex of the Monitor through every state and transition
+//The inputs tried on each tick are the same as for ComparePolicies, and the search is breadth-first. When an input keeps the policy
+// on a self-loop (without assignments), it is held until a dtimer gets near a constant it could be compared with, rather than
+// for one tick, so that long timeouts are found quickly. This means that dtimers are only tried at values near these constants.
+//Traces that are prefixes of others are left out.
+func (f Monitor) GenerateTests(policyIndex int, opts TestGenOptions) (*TestSuite, error) {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = math.MaxInt32
+	}
+	if opts.MaxStates <= 0 {
+		opts.MaxStates = 1000000
+	}
+	sim, err := f.NewPolicySimulator(policyIndex)
+	if err != nil {
+		return nil, err
+	}
+	sim.TimerLimit = sim.p.TimerLimit()
+	boundaries := timerBoundaries(sim.p, sim.TimerLimit)
+	alphabet, err := inputAlphabet(f, []Policy{sim.p}, opts.MaxInputs)
+	if err != nil {
+		return nil, fmt.Errorf("Policy %s: %s", sim.p.Name, err.Error())
+	}
+
+	//the targets are the states and the transitions (as the generated C has them), and each is found by the first node that gets to it
+	var targets []string
+	for _, st := range sim.p.States {
+		targets = append(targets, "state "+st.Name)
+	}
+	for _, tr := range sim.transitions {
+		targets = append(targets, "transition "+describeTransition(sim.p.Name, tr.PTransition))
+	}
+	found := make(map[int]int)
+	for _, target := range sim.target(nil) {
+		found[target] = 0
+	}
+
+	type node struct {
+		sim    *PolicySimulator
+		parent int
+		input  int
+		repeat int //how many ticks input is held for
+		depth  int
+	}
+	nodes := []node{{sim: sim, parent: -1}}
+	seen := map[string]bool{sim.Key(): true}
+	suite := &TestSuite{Policy: sim.p.Name, Targets: len(targets), Complete: true}
+
+	for i := 0; i < len(nodes) && len(found) < len(targets); i++ {
+		n := nodes[i]
+		if n.depth >= opts.MaxDepth {
+			suite.Complete = false
+			continue
+		}
+		for j, in := range alphabet {
+			next := n.sim.Copy()
+			tr, err := next.Step(in)
+			if err != nil {
+				return nil, fmt.Errorf("Policy %s: %s", sim.p.Name, err.Error())
+			}
+
+			//the node is added even if its simulator state has been seen before, if it reaches something new
+			isNew := false
+			for _, target := range next.target(tr) {
+				if _, ok := found[target]; !ok {
+					found[target] = len(nodes)
+					isNew = true
+				}
+			}
+
+			//holding the input on a self-loop only moves the dtimers on, so instead of stopping after one tick,
+			//they skip to just before their next boundary
+			if tr != nil && tr.Source == tr.Destination && len(tr.Expressions) == 0 && !isNew {
+				if wait := next.ticksToBoundary(boundaries); wait > 0 {
+					if n.depth+1+int(wait) > opts.MaxDepth {
+						suite.Complete = false
+						continue
+					}
+					next.skipTime(wait)
+					if key := next.Key(); !seen[key] && len(nodes) < opts.MaxStates {
+						seen[key] = true
+						nodes = append(nodes, node{sim: next, parent: i, input: j, repeat: 1 + int(wait), depth: n.depth + 1 + int(wait)})
+					}
+					continue
+				}
+			}
+
+			key := next.Key()
+			if seen[key] && !isNew {
+				continue
+			}
+			if len(nodes) >= opts.MaxStates {
+				suite.Complete = false
+				continue
+			}
+			seen[key] = true
+			nodes = append(nodes, node{sim: next, parent: i, input: j, repeat: 1, depth: n.depth + 1})
+		}
+	}
+
+	//make a trace for each node that found something, longest first, leaving out those that are prefixes of traces already made
+	var ends []int
+	for target, name := range targets {
+		if end, ok := found[target]; ok {
+			if end > 0 && !intSliceContains(ends, end) {
+				ends = append(ends, end)
+			}
+		} else {
+			suite.Uncovered = append(suite.Uncovered, name)
+		}
+	}
+	sort.Slice(ends, func(i, j int) bool { return nodes[ends[i]].depth > nodes[ends[j]].depth })
+	onPath := make(map[int]bool)
+	var paths [][]int
+	for _, end := range ends {
+		if onPath[end] {
+			continue
+		}
+		var path []int
+		for k := end; nodes[k].parent != -1; k = nodes[k].parent {
+			for r := 0; r < nodes[k].repeat; r++ {
+				path = append([]int{nodes[k].input}, path...)
+			}
+			onPath[k] = true
+		}
+		paths = append(paths, path)
+	}
+
+	//replay the traces (shortest first) to get their verdicts, and what they cover
+	covered := make(map[int]bool)
+	for _, target := range sim.target(nil) {
+		covered[target] = true
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		replay := sim.Copy()
+		trace := TestTrace{}
+		for _, j := range paths[i] {
+			tr, _ := replay.Step(alphabet[j])
+			trace.Inputs = append(trace.Inputs, alphabet[j])
+			trace.Verdicts = append(trace.Verdicts, replay.Verdict())
+			for _, target := range replay.target(tr) {
+				if !covered[target] {
+					covered[target] = true
+					trace.Covers = append(trace.Covers, targets[target])
+				}
+			}
+		}
+		suite.Traces = append(suite.Traces, trace)
+	}
+	return suite, nil
+}
+
+//timerBoundaries returns the values around each constant used in p (up to limit), which are where guards that compare dtimers with
+//constants can change
+func timerBoundaries(p Policy, limit int64) []int64 {
+	set := map[int64]bool{limit: true}
+	for _, c := range policyConstants(p) {
+		for _, b := range []int64{int64(math.Floor(c)) - 1, int64(math.Floor(c)), int64(math.Ceil(c)), int64(math.Ceil(c)) + 1} {
+			if b >= 0 && b <= limit {
+				set[b] = true
+			}
+		}
+	}
+	var boundaries []int64
+	for b := range set {
+		boundaries = append(boundaries, b)
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i] < boundaries[j] })
+	return boundaries
+}
+
+//ticksToBoundary returns how many more ticks the dtimers can count for before one of them gets to a boundary (see timerBoundaries)
+//It returns 0 if there are no dtimers, or if one is already on a boundary.
+func (s *PolicySimulator) ticksToBoundary(boundaries []int64) int64 {
+	wait := int64(-1)
+	for _, v := range s.p.InternalVars {
+		if !v.IsDTimer() || v.Constant || v.ArraySize != "" {
+			continue
+		}
+		t := s.Internals[v.Name].Int
+		i := sort.Search(len(boundaries), func(i int) bool { return boundaries[i] >= t })
+		if i == len(boundaries) || boundaries[i] == t {
+			return 0
+		}
+		if d := boundaries[i] - 1 - t; wait == -1 || d < wait {
+			wait = d
+		}
+	}
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+//skipTime moves the dtimers on by the given number of ticks (as Step would, if no transition changed them)
+func (s *PolicySimulator) skipTime(ticks int64) {
+	for _, v := range s.p.InternalVars {
+		if v.IsDTimer() && !v.Constant && v.ArraySize == "" {
+			t := s.Internals[v.Name]
+			t.Int += ticks
+			if s.TimerLimit != 0 && t.Int > s.TimerLimit {
+				t.Int = s.TimerLimit
+			}
+			s.Internals[v.Name] = t
+		}
+	}
+}
+
+//target returns the numbers of the targets of GenerateTests that the simulator has just reached:
+//its current state (numbered as in the policy), and tr (the transition it just took, if any, numbered after the states)
+func (s *PolicySimulator) target(tr *PTransition) []int {
+	var reached []int
+	for i, st := range s.p.States {
+		if st.Name == s.State {
+			reached = append(reached, i)
+		}
+	}
+	for i := range s.transitions {
+		if tr == &s.transitions[i].PTransition {
+			reached = append(reached, len(s.p.States)+i)
+		}
+	}
+	return reached
+}
+
+//intSliceContains returns true if slice contains i
+func intSliceContains(slice []int, i int) bool {
+	for _, s := range slice {
+		if s == i {
+			return true
+		}
+	}
+	return false
+}
+
+//Coverage returns how many of the policy's states and transitions the suite reaches or takes, as a (rounded down) percentage
+func (s TestSuite) Coverage() int {
+	if s.Targets == 0 {
+		return 100
+	}
+	return 100 * (s.Targets - len(s.Uncovered)) / s.Targets
+}
+
+//InputNames returns the names of all of the inputs that the suite's traces set, in sorted order
+func (s TestSuite) InputNames() []string {
+	all := Inputs{}
+	for _, tr := range s.Traces {
+		for _, in := range tr.Inputs {
+			for name, val := range in {
+				all[name] = val
+			}
+		}
+	}
+	return all.Names()
+}
+
+//WriteCSV writes out the suite's traces as CSV, with a row for each tick, giving the trace number, tick number,
+//each input, and the status that the policy's check_rv_status function should then return (see VerdictName)
+func (s TestSuite) WriteCSV(w io.Writer) error {
+	names := s.InputNames()
+	out := csv.NewWriter(w)
+	header := append(append([]string{"trace", "tick"}, names...), "status")
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for i, tr := range s.Traces {
+		for t, in := range tr.Inputs {
+			row := []string{strconv.Itoa(i + 1), strconv.Itoa(t + 1)}
+			for _, name := range names {
+				row = append(row, in[name].String())
+			}
+			row = append(row, strconv.Itoa(tr.Verdicts[t]))
+			if err := out.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,73 @@
+package rvdef
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTests(t *testing.T) {
+	//long deadlines are found by holding the inputs
+	for _, deadline := range []string{"5", "5000"} {
+		m := ab5Monitor(deadline)
+		m.Policies[0].Transitions = append(m.Policies[0].Transitions, PTransition{Source: "violation", Destination: "s0", Condition: "A and !A"})
+		suite, err := m.GenerateTests(0, TestGenOptions{})
+		if err != nil {
+			t.Fatalf("Deadline %s: error '%s' occurred when it shouldn't have", deadline, err.Error())
+		}
+		if !suite.Complete || suite.Targets != 9 || len(suite.Uncovered) != 1 || suite.Uncovered[0] != "transition violation -> s0 on A and !A" {
+			t.Errorf("Deadline %s: complete=%v, %d targets, uncovered %v", deadline, suite.Complete, suite.Targets, suite.Uncovered)
+		}
+
+		//replaying the traces gives the same verdicts, and between them they cover everything else
+		covers := 0
+		for i, tr := range suite.Traces {
+			sim, _ := m.NewPolicySimulator(0)
+			for tick, in := range tr.Inputs {
+				sim.Step(in)
+				if sim.Verdict() != tr.Verdicts[tick] {
+					t.Errorf("Deadline %s: trace %d, tick %d: verdict was %d, it should have been %d", deadline, i+1, tick+1, sim.Verdict(), tr.Verdicts[tick])
+				}
+			}
+			covers += len(tr.Covers)
+		}
+		if covers != 7 { //all but the initial state (which is covered without a trace) and the unreachable transition
+			t.Errorf("Deadline %s: traces covered %d states and transitions", deadline, covers)
+		}
+	}
+
+	//the traces can be written as CSV
+	suite, _ := ab5Monitor("2").GenerateTests(0, TestGenOptions{})
+	out := &bytes.Buffer{}
+	if err := suite.WriteCSV(out); err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if lines[0] != "trace,tick,A,B,status" || !strings.HasSuffix(lines[len(lines)-1], ",3") {
+		t.Errorf("CSV was:\n%s", out.String())
+	}
+
+	//a transition on a narrow interval of a float is taken
+	narrow := Monitor{
+		Name:          "m",
+		InterfaceList: []Variable{{Name: "f", Type: "double"}},
+		Policies: []Policy{{
+			Name:        "P",
+			States:      []PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "bad"}},
+			Transitions: []PTransition{{Source: "s0", Destination: "bad", Condition: "f > 1.0 and f < 1.2"}, {Source: "s0", Destination: "s0", Else: true}},
+		}},
+	}
+	suite, err := narrow.GenerateTests(0, TestGenOptions{})
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	if !suite.Complete || len(suite.Uncovered) != 0 {
+		t.Errorf("Float interval: complete=%v, uncovered %v", suite.Complete, suite.Uncovered)
+	}
+
+	//the search can be cut short
+	suite, _ = ab5Monitor("5000").GenerateTests(0, TestGenOptions{MaxDepth: 100})
+	if suite.Complete || len(suite.Uncovered) == 0 {
+		t.Errorf("Search wasn't stopped at the maximum depth")
+	}
+}
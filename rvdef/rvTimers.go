@@ -0,0 +1,69 @@
+package rvdef
+
+import "github.com/PRETgroup/stcompilerlib"
+
+//TimerBounded returns true if the dtimer named name (or the array or struct of them with that root) is only ever read
+//by comparing it directly with a constant (or after a transition has set it to a constant), so that it can stop counting up at TimerLimit
+func (p Policy) TimerBounded(name string) bool {
+	s := satChecker{p: p}
+	bounded := true
+	isTimer := func(expr stcompilerlib.STExpression) bool {
+		root, _ := SplitAccessPath(expr.HasValue())
+		return root == name
+	}
+	var walk func(expr stcompilerlib.STExpression)
+	walk = func(expr stcompilerlib.STExpression) {
+		op := expr.HasOperator()
+		if op == nil {
+			forEachValue(p.Name, expr, func(val string) {
+				if root, _ := SplitAccessPath(val); root == name {
+					bounded = false
+				}
+			})
+			return
+		}
+		//arguments are in reverse order
+		args := expr.GetArguments()
+		if stcompilerlib.OpTokenIsComparison(op.GetToken()) && len(args) == 2 {
+			for i := 0; i < 2; i++ {
+				if isTimer(args[i]) {
+					if _, ok := s.constValue(args[1-i]); !ok {
+						bounded = false
+					}
+					return
+				}
+			}
+		}
+		for _, arg := range args {
+			walk(arg)
+		}
+	}
+	if st := p.GetInitialState(); st != nil && st.InitialCondition != "" {
+		if expr, err := p.getSTGuard(PTransition{Condition: st.InitialCondition}); err == nil {
+			walk(expr)
+		}
+	}
+	for _, tr := range p.Transitions {
+		if expr, err := p.getSTGuard(tr); err == nil {
+			walk(expr)
+		}
+		//assignments are made in order, so once the timer is set to a constant it can be read freely
+		reset := false
+		for _, ex := range tr.Expressions {
+			expr, err := p.getSTAssignment(ex)
+			if err != nil {
+				continue
+			}
+			args := expr.GetArguments()
+			if !reset {
+				walk(args[0])
+			}
+			if args[1].HasValue() == name {
+				_, reset = s.constValue(args[0])
+			} else if !isTimer(args[1]) {
+				walk(args[1])
+			}
+		}
+	}
+	return bounded
+}
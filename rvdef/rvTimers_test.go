@@ -0,0 +1,32 @@
+package rvdef
+
+import "testing"
+
+func TestTimerBounded(t *testing.T) {
+	tests := []struct {
+		tr      PTransition
+		bounded bool
+	}{
+		{PTransition{Condition: "v > 5 and 3 <= v"}, true},
+		{PTransition{Condition: "v > LIMIT"}, true},
+		{PTransition{Condition: "v > x"}, false},
+		{PTransition{Condition: "v + 1 > 5"}, false},
+		{PTransition{Condition: "h[v] > 5"}, false},
+		{PTransition{Condition: "true", Expressions: []PExpression{{VarName: "x", Value: "v"}}}, false},
+		//assignments are made in order
+		{PTransition{Condition: "true", Expressions: []PExpression{{VarName: "v", Value: "0"}, {VarName: "x", Value: "v"}}}, true},
+		{PTransition{Condition: "true", Expressions: []PExpression{{VarName: "v", Value: "x"}, {VarName: "x", Value: "v"}}}, false},
+	}
+	for i, test := range tests {
+		test.tr.Source, test.tr.Destination = "s0", "s0"
+		p := Policy{
+			Name:         "P",
+			InternalVars: []Variable{{Name: "v", Type: "dtimer_t"}, {Name: "x", Type: "uint8_t"}, {Name: "LIMIT", Type: "uint8_t", Constant: true, InitialValue: "7"}},
+			States:       []PState{{Name: "s0", Initial: true}},
+			Transitions:  []PTransition{test.tr},
+		}
+		if got := p.TimerBounded("v"); got != test.bounded {
+			t.Errorf("Test %d: bounded was %v, it should have been %v", i, got, test.bounded)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package rvdef
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//TypeCheckMode sets how TypeCheck reports hazards, which are expressions that are legal (and compile) but
+// probably don't do what was meant, e.g. comparing a signed value with an unsigned one
+type TypeCheckMode int
+
+const (
+	//TypeCheckPermissive reports hazards as warnings
+	TypeCheckPermissive TypeCheckMode = iota
+	//TypeCheckStrict reports hazards as errors
+	TypeCheckStrict
+)
+
+//TypeIssue is a problem found in a guard or assignment by TypeCheck
+type TypeIssue struct {
+	DebugInfo        //where the guard or assignment was defined (if known)
+	Policy    string //the policy it was found in
+	Where     string //what it was found in, e.g. "transition s0 -> s1"
+	Message   string
+	Error     bool //if false, this is only a warning
+}
+
+//String returns the TypeIssue in the same format as the parser's errors
+func (t TypeIssue) String() string {
+	kind := "Warning"
+	if t.Error {
+		kind = "Error"
+	}
+	loc := ""
+	if t.SourceLine != 0 {
+		loc = fmt.Sprintf("Line %v", t.SourceLine)
+		if t.SourceFile != "" {
+			loc = t.SourceFile + ", " + loc
+		}
+		loc = " (" + loc + ")"
+	}
+	return fmt.Sprintf("%s%s: Policy %s: %s: %s", kind, loc, t.Policy, t.Where, t.Message)
+}
+
+//TypeCheck infers the types of every guard, initial condition, and assignment in the Monitor from the declared types of its variables,
+// and returns all of the problems that it finds
+//Type mismatches (e.g. arithmetic on a bool, or a guard that compares a bool with a number) are always errors,
+// while hazards (signed/unsigned comparisons, float equality, dtimers mixed with floats, and non-boolean guards)
+// are warnings in TypeCheckPermissive mode and errors in TypeCheckStrict mode
+//The Monitor should already have passed Validate.
+func (f Monitor) TypeCheck(mode TypeCheckMode) []TypeIssue {
+	var issues []TypeIssue
+	report := func(c typeChecker, p Policy, where string, debug DebugInfo, text string) {
+		for _, msg := range c.errs {
+			issues = append(issues, TypeIssue{DebugInfo: debug, Policy: p.Name, Where: where, Message: fmt.Sprintf("'%s': %s", text, msg), Error: true})
+		}
+		for _, msg := range c.hazards {
+			issues = append(issues, TypeIssue{DebugInfo: debug, Policy: p.Name, Where: where, Message: fmt.Sprintf("'%s': %s", text, msg), Error: mode == TypeCheckStrict})
+		}
+	}
+
+	for _, p := range f.Policies {
+		if st := p.GetInitialState(); st != nil && st.InitialCondition != "" {
+			if stguard, err := p.getSTGuard(PTransition{Condition: st.InitialCondition}); err == nil {
+				c := typeChecker{f: f, p: p}
+				c.checkGuard(stguard)
+				report(c, p, "initial condition of state "+st.Name, DebugInfo{}, st.InitialCondition)
+			}
+		}
+
+		for _, tr := range p.Transitions {
+			where := "transition " + tr.Source + " -> " + tr.Destination
+			//the guard of an else transition is made from the guards of the others, which are checked by themselves
+			if !tr.Else {
+				if stguard, err := p.getSTGuard(tr); err == nil {
+					c := typeChecker{f: f, p: p}
+					c.checkGuard(stguard)
+					report(c, p, where, tr.DebugInfo, tr.Condition)
+				}
+			}
+			for _, ex := range tr.Expressions {
+				if stex, err := p.getSTAssignment(ex); err == nil {
+					c := typeChecker{f: f, p: p}
+					c.checkAssignment(stex)
+					report(c, p, where, tr.DebugInfo, ex.VarName+" := "+ex.Value)
+				}
+			}
+		}
+	}
+	return issues
+}
+
+//exprType is the inferred type of an expression
+type exprType struct {
+	class    string //one of the type classes (see getTypeClass), or "" if it can't be known
+	unsigned bool   //for integers, true if the value is unsigned
+	dtimer   bool   //true if the value is (or is computed from) a dtimer
+	constant bool   //true if the value is a literal
+	negative bool   //for constants, true if the value is negative
+}
+
+//typeChecker infers the types of expressions in a policy, collecting the problems that it finds along the way
+type typeChecker struct {
+	f       Monitor
+	p       Policy
+	errs    []string //type mismatches
+	hazards []string //things that are legal, but probably wrong
+}
+
+//mismatch records a type mismatch, and returns the unknown type so that it isn't reported again further up the tree
+func (c *typeChecker) mismatch(format string, a ...interface{}) exprType {
+	c.errs = append(c.errs, fmt.Sprintf(format, a...))
+	return exprType{}
+}
+
+//hazard records a hazard
+func (c *typeChecker) hazard(format string, a ...interface{}) {
+	c.hazards = append(c.hazards, fmt.Sprintf(format, a...))
+}
+
+//checkGuard checks the types in a guard, which should be a bool
+func (c *typeChecker) checkGuard(expr stcompilerlib.STExpression) {
+	t := c.typeOf(expr)
+	switch {
+	case t.class == "" || t.class == typeBool:
+	case isNumericClass(t.class):
+		c.hazard("guard is of type %s, not bool (it is true if non-zero)", t.class)
+	default:
+		c.mismatch("guard is of type %s, not bool", t.class)
+	}
+}
+
+//checkAssignment checks the types in an assignment (which should have already passed Validate)
+func (c *typeChecker) checkAssignment(expr stcompilerlib.STExpression) {
+	args := expr.GetArguments()
+	v := c.f.GetVariable(c.p, args[1].HasValue())
+	if v == nil {
+		c.mismatch("can't resolve '%s'", args[1].HasValue())
+		return
+	}
+	t := c.typeOf(args[0])
+	if err := checkAssignable(*v, t.class); err != nil {
+		c.mismatch("%s", err.Error())
+		return
+	}
+	target := c.variableType(*v)
+	if target.unsigned && t.negative {
+		c.hazard("a negative value is assigned to %s, which is unsigned", v.Name)
+	}
+	if (target.dtimer && t.class == typeFloat) || (t.dtimer && target.class == typeFloat) {
+		c.hazard("a dtimer is mixed with a float (dtimers count whole ticks)")
+	}
+}
+
+//typeOf infers the type of expr
+func (c *typeChecker) typeOf(expr stcompilerlib.STExpression) exprType {
+	op := expr.HasOperator()
+	if op == nil {
+		return c.valueType(expr.HasValue())
+	}
+
+	//arguments are in reverse order
+	args := expr.GetArguments()
+	types := make([]exprType, len(args))
+	for i := range args {
+		types[i] = c.typeOf(args[len(args)-1-i])
+	}
+	tok := op.GetToken()
+	for _, t := range types {
+		if t.class == "" {
+			return exprType{} //can't check any further
+		}
+	}
+
+	switch {
+	case tok == "not":
+		if types[0].class != typeBool {
+			return c.mismatch("'not' can't be used with a value of type %s", types[0].class)
+		}
+		return exprType{class: typeBool}
+	case tok == stNegative:
+		if !isNumericClass(types[0].class) {
+			return c.mismatch("'-' can't be used with a value of type %s", types[0].class)
+		}
+		t := types[0]
+		t.negative = t.constant && !t.negative
+		return t
+	case tok == "*" || tok == "/" || tok == "+" || tok == "-" || tok == "**" || tok == "MOD":
+		a, b := types[0], types[1]
+		if !isNumericClass(a.class) || !isNumericClass(b.class) {
+			return c.mismatch("'%s' can't be used with values of type %s and %s", tok, a.class, b.class)
+		}
+		if tok == "MOD" && (a.class != typeInteger || b.class != typeInteger) {
+			return c.mismatch("'%s' can only be used with integers", tok)
+		}
+		c.checkDTimerMix(tok, a, b)
+		t := exprType{
+			class:    typeInteger,
+			unsigned: (a.unsigned && !a.constant) || (b.unsigned && !b.constant),
+			dtimer:   a.dtimer || b.dtimer,
+			constant: a.constant && b.constant,
+		}
+		if a.class == typeFloat || b.class == typeFloat || tok == "**" {
+			t.class, t.unsigned = typeFloat, false
+		}
+		return t
+	case tok == "=" || tok == "<>":
+		a, b := types[0], types[1]
+		if a.class != b.class && !(isNumericClass(a.class) && isNumericClass(b.class)) {
+			return c.mismatch("values of type %s and %s can't be compared", a.class, b.class)
+		}
+		if a.class == typeFloat || b.class == typeFloat {
+			c.hazard("floats are compared with '%s', which is unreliable due to rounding", tok)
+		}
+		c.checkSignedness(tok, a, b)
+		c.checkDTimerMix(tok, a, b)
+		return exprType{class: typeBool}
+	case stcompilerlib.OpTokenIsComparison(tok):
+		a, b := types[0], types[1]
+		if !isNumericClass(a.class) || !isNumericClass(b.class) {
+			return c.mismatch("'%s' can't be used with values of type %s and %s", tok, a.class, b.class)
+		}
+		c.checkSignedness(tok, a, b)
+		c.checkDTimerMix(tok, a, b)
+		return exprType{class: typeBool}
+	case stcompilerlib.OpTokenIsCombinator(tok):
+		a, b := types[0], types[1]
+		if a.class == typeBool && b.class == typeBool {
+			return exprType{class: typeBool}
+		}
+		if a.class == typeInteger && b.class == typeInteger {
+			if tok == "xor" {
+				return exprType{class: typeInteger, unsigned: a.unsigned || b.unsigned} //bitwise
+			}
+			c.hazard("'%s' is used with integers (they are true if non-zero)", tok)
+			return exprType{class: typeBool}
+		}
+		return c.mismatch("'%s' can't be used with values of type %s and %s", tok, a.class, b.class)
+	}
+
+	//casts (e.g. float(v)) have the type that they cast to
+	if cast, ok := c.castType(FunctionName(tok)); ok {
+		return cast
+	}
+	return exprType{} //a function call, which we know nothing about
+}
+
+//checkSignedness records a hazard if a comparison mixes signed and unsigned integers
+//(C converts the signed value to unsigned, so e.g. -1 > 0u)
+func (c *typeChecker) checkSignedness(tok string, a exprType, b exprType) {
+	if a.class != typeInteger || b.class != typeInteger {
+		return
+	}
+	if (a.unsigned && b.negative) || (b.unsigned && a.negative) {
+		c.hazard("an unsigned value is compared with a negative constant using '%s'", tok)
+		return
+	}
+	if !a.constant && !b.constant && a.unsigned != b.unsigned {
+		c.hazard("a signed value is compared with an unsigned value using '%s'", tok)
+	}
+}
+
+//checkDTimerMix records a hazard if a dtimer is used with a float
+func (c *typeChecker) checkDTimerMix(tok string, a exprType, b exprType) {
+	if (a.dtimer && b.class == typeFloat) || (b.dtimer && a.class == typeFloat) {
+		c.hazard("a dtimer is mixed with a float using '%s' (dtimers count whole ticks)", tok)
+	}
+}
+
+//valueType infers the type of a single value (e.g. a literal, a variable, or an enum member)
+func (c *typeChecker) valueType(val string) exprType {
+	if strings.EqualFold(val, "true") || strings.EqualFold(val, "false") {
+		return exprType{class: typeBool, constant: true}
+	}
+	if i, err := strconv.ParseInt(val, 0, 64); err == nil {
+		return exprType{class: typeInteger, constant: true, negative: i < 0}
+	}
+	if fl, err := strconv.ParseFloat(val, 64); err == nil {
+		return exprType{class: typeFloat, constant: true, negative: fl < 0}
+	}
+	if len(val) > 2 && strings.HasPrefix(val, "'") && strings.HasSuffix(val, "'") {
+		return exprType{class: typeInteger, constant: true} //a char
+	}
+	if v := c.f.GetVariable(c.p, val); v != nil {
+		return c.variableType(*v)
+	}
+	for _, e := range c.f.Enums {
+		if e.HasMember(val) {
+			return exprType{class: e.Name}
+		}
+	}
+	return c.mismatch("can't resolve '%s' (it isn't a variable, constant, or enum member)", val)
+}
+
+//variableType returns the type of a variable
+func (c *typeChecker) variableType(v Variable) exprType {
+	typ := strings.ToLower(v.Type)
+	t := exprType{class: getTypeClass(v), dtimer: v.IsDTimer()}
+	t.unsigned = t.class == typeInteger && (strings.HasPrefix(typ, "uint") || t.dtimer)
+	return t
+}
+
+//castType returns the type of a cast to the type called name, if name is a type
+func (c *typeChecker) castType(name string) (exprType, bool) {
+	v := Variable{Type: name}
+	if c.f.GetEnum(name) == nil {
+		switch t := getTypeClass(v); t {
+		case typeBool, typeInteger, typeFloat:
+		default:
+			return exprType{}, false
+		}
+	}
+	return c.variableType(v), true
+}
@@ -0,0 +1,87 @@
+package rvdef
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTypeCheck(t *testing.T) {
+	typedMonitor := func(guard string, varName string, value string) Monitor {
+		m := heaterMonitor(guard, "IDLE", "")
+		m.InterfaceList = append(m.InterfaceList,
+			Variable{Name: "ok", Type: "bool"},
+			Variable{Name: "f", Type: "float"},
+			Variable{Name: "u", Type: "uint32_t"},
+			Variable{Name: "pkt", Type: "packet_t", Fields: []Variable{{Name: "temp", Type: "int16_t"}}},
+		)
+		m.Policies[0].InternalVars = append(m.Policies[0].InternalVars, Variable{Name: "v", Type: "dtimer_t"}, Variable{Name: "g", Type: "float"})
+		m.Policies[0].Transitions[0].DebugInfo = DebugInfo{SourceLine: 7, SourceFile: "heater.erv"}
+		if varName != "" {
+			m.Policies[0].Transitions[0].Expressions = []PExpression{{VarName: varName, Value: value}}
+		}
+		return m
+	}
+	tests := []struct {
+		Name     string
+		Monitor  Monitor
+		Errors   int //in permissive mode
+		Warnings int //in permissive mode (these are errors in strict mode)
+	}{
+		{"valid", typedMonitor("ok and temp > 10 and mode = HEATING", "", ""), 0, 0},
+		{"valid unsigned", typedMonitor("u > 5 and count < u and v >= 10", "", ""), 0, 0},
+		{"valid cast", typedMonitor("float(v) < 2.5 and f < 1.0", "g", "float(v) / 2.0"), 0, 0},
+		{"valid function", typedMonitor("fabs(f) < 1", "", ""), 0, 0},
+		{"bool compared to number", typedMonitor("ok = 1", "", ""), 1, 0},
+		{"bool arithmetic", typedMonitor("ok + 1 > 2", "", ""), 1, 0},
+		{"not on number", typedMonitor("!temp", "", ""), 1, 0},
+		{"struct as scalar", typedMonitor("pkt > 3", "", ""), 1, 0},
+		{"float mod", typedMonitor("f MOD 2 = 0", "", ""), 1, 0},
+		{"enum compared to number", typedMonitor("mode = 1", "", ""), 1, 0},
+		{"enum guard", typedMonitor("mode", "", ""), 1, 0},
+		{"unknown identifier", typedMonitor("nope > 3", "", ""), 1, 0},
+		{"signed unsigned", typedMonitor("temp < u", "", ""), 0, 1},
+		{"unsigned negative", typedMonitor("u > -1", "", ""), 0, 1},
+		{"float equality", typedMonitor("f = 1.5", "", ""), 0, 1},
+		{"float inequality", typedMonitor("f <> g", "", ""), 0, 1},
+		{"dtimer float", typedMonitor("v < f", "", ""), 0, 1},
+		{"dtimer float arithmetic", typedMonitor("v * 2.5 > 10", "", ""), 0, 1},
+		{"integer guard", typedMonitor("temp", "", ""), 0, 1},
+		{"integer and", typedMonitor("temp and count", "", ""), 0, 1},
+		{"several", typedMonitor("f = 0.0 or temp < u", "", ""), 0, 2},
+		{"assignment mismatch", typedMonitor("true", "count", "ok"), 1, 0},
+		{"assignment dtimer float", typedMonitor("true", "g", "v"), 0, 1},
+		{"assignment negative unsigned", typedMonitor("true", "count", "-1"), 0, 1},
+	}
+	for _, test := range tests {
+		if err := test.Monitor.Validate(); err != nil && test.Errors == 0 {
+			t.Errorf("%s: Validate failed with '%s'", test.Name, err.Error())
+			continue
+		}
+		for _, mode := range []TypeCheckMode{TypeCheckPermissive, TypeCheckStrict} {
+			errs, warnings := 0, 0
+			for _, issue := range test.Monitor.TypeCheck(mode) {
+				if issue.Error {
+					errs++
+				} else {
+					warnings++
+				}
+			}
+			wantErrs, wantWarnings := test.Errors, test.Warnings
+			if mode == TypeCheckStrict {
+				wantErrs, wantWarnings = wantErrs+wantWarnings, 0
+			}
+			if errs != wantErrs || warnings != wantWarnings {
+				t.Errorf("%s (mode %d): got %d errors and %d warnings, wanted %d and %d: %v", test.Name, mode, errs, warnings, wantErrs, wantWarnings, test.Monitor.TypeCheck(mode))
+			}
+		}
+	}
+
+	//issues say where they came from
+	issues := typedMonitor("temp < u", "", "").TypeCheck(TypeCheckPermissive)
+	if len(issues) != 1 {
+		t.Fatalf("Expected 1 issue, got %v", issues)
+	}
+	if s := issues[0].String(); !strings.HasPrefix(s, "Warning (heater.erv, Line 7): Policy P: transition s0 -> s0: 'temp < u'") {
+		t.Errorf("Issue was formatted badly: %s", s)
+	}
+}
@@ -0,0 +1,84 @@
+package rvdef
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//The type classes that expressions are checked with
+//(enums, structs, and arrays are instead classed by their type names, e.g. "Mode", "packet_t", or "int16_t[4]")
+const (
+	typeBool    = "bool"
+	typeInteger = "integer"
+	typeFloat   = "float"
+)
+
+//getTypeClass returns the type class of a variable
+func getTypeClass(v Variable) string {
+	if v.ArraySize != "" {
+		return v.Type + "[" + v.ArraySize + "]"
+	}
+	switch strings.ToLower(v.Type) {
+	case "bool":
+		return typeBool
+	case "float", "double":
+		return typeFloat
+	}
+	if v.IsIntegerType() {
+		return typeInteger
+	}
+	return v.Type
+}
+
+//IntegerTypeRange returns the smallest and largest values of an integer type
+//(types without a known width, e.g. int, are given the range of an int64_t)
+func IntegerTypeRange(typ string) (float64, float64) {
+	switch strings.ToLower(typ) {
+	case "int8_t", "char":
+		return math.MinInt8, math.MaxInt8
+	case "uint8_t":
+		return 0, math.MaxUint8
+	case "int16_t":
+		return math.MinInt16, math.MaxInt16
+	case "uint16_t":
+		return 0, math.MaxUint16
+	case "int32_t":
+		return math.MinInt32, math.MaxInt32
+	case "uint32_t":
+		return 0, math.MaxUint32
+	case "uint64_t", "dtimer_t":
+		return 0, math.MaxUint64
+	}
+	return math.MinInt64, math.MaxInt64
+}
+
+//isNumericClass returns true if values of type class t can be used in arithmetic
+func isNumericClass(t string) bool {
+	return t == typeInteger || t == typeFloat
+}
+
+//getExpressionType returns the type class of expr, after making sure that every identifier in it can be resolved
+// and that every operator is used with operands of a suitable type (hazards are ignored)
+//It uses the same rules as TypeCheck (see typeChecker.typeOf).
+//An empty type class is returned (with no error) if the type can't be known (e.g. for the result of a function call)
+func (f Monitor) getExpressionType(p Policy, expr stcompilerlib.STExpression) (string, error) {
+	c := typeChecker{f: f, p: p}
+	t := c.typeOf(expr)
+	if len(c.errs) > 0 {
+		return "", errors.New(c.errs[0])
+	}
+	return t.class, nil
+}
+
+//checkAssignable makes sure that a value of type class t can be assigned to variable v
+func checkAssignable(v Variable, t string) error {
+	target := getTypeClass(v)
+	if t == "" || t == target || (target == typeFloat && t == typeInteger) {
+		return nil
+	}
+	return fmt.Errorf("a value of type %s can't be assigned to %s (of type %s)", t, v.Name, target)
+}
@@ -0,0 +1,288 @@
+package rvdef
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//Validate checks a Monitor for the errors that can't be found while parsing, e.g.
+//enum variables that are compared with (or set to) values that aren't members of their enum.
+//It returns the first error found, or nil if the Monitor is valid.
+func (f Monitor) Validate() error {
+	for _, v := range f.InterfaceList {
+		if err := f.validateInitialValue(v); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range f.Policies {
+		for _, v := range p.InternalVars {
+			if err := f.validateInitialValue(v); err != nil {
+				return fmt.Errorf("Policy %s: %s", p.Name, err.Error())
+			}
+		}
+
+		if err := f.validateInitialState(p); err != nil {
+			return fmt.Errorf("Policy %s: %s", p.Name, err.Error())
+		}
+
+		elseSources := make(map[string]bool)
+		for _, tr := range p.Transitions {
+			if tr.Else {
+				if elseSources[tr.Source] {
+					return fmt.Errorf("Policy %s: state %s has more than one else transition", p.Name, tr.Source)
+				}
+				elseSources[tr.Source] = true
+			}
+			stguard, err := p.getSTGuard(tr)
+			if err != nil {
+				return fmt.Errorf("Policy %s: transition %s -> %s has a broken guard: %s", p.Name, tr.Source, tr.Destination, err.Error())
+			}
+			if err := f.validateAccessPaths(p, stguard); err != nil {
+				return fmt.Errorf("Policy %s: transition %s -> %s: %s", p.Name, tr.Source, tr.Destination, err.Error())
+			}
+			if err := f.validateEnumComparisons(p, stguard); err != nil {
+				return fmt.Errorf("Policy %s: transition %s -> %s: %s", p.Name, tr.Source, tr.Destination, err.Error())
+			}
+			for _, ex := range tr.Expressions {
+				if err := f.validateAssignment(p, ex); err != nil {
+					return fmt.Errorf("Policy %s: transition %s -> %s: %s", p.Name, tr.Source, tr.Destination, err.Error())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//validateInitialState makes sure that a policy with states has exactly one initial state,
+// and that its initial condition (if it has one) is valid
+func (f Monitor) validateInitialState(p Policy) error {
+	if len(p.States) == 0 {
+		return nil
+	}
+	var initial []string
+	for _, st := range p.States {
+		if st.Initial {
+			initial = append(initial, st.Name)
+		}
+	}
+	if len(initial) == 0 {
+		return fmt.Errorf("there is no initial state (one state must be marked as initial)")
+	}
+	if len(initial) > 1 {
+		return fmt.Errorf("there must be exactly one initial state, but %s are all marked as initial", strings.Join(initial, ", "))
+	}
+
+	st := p.GetInitialState()
+	if st.InitialCondition == "" && st.InitialElse == "" {
+		return nil
+	}
+	if st.InitialCondition == "" || st.InitialElse == "" {
+		return fmt.Errorf("initial state %s must have both an initial condition and a state to start in if it doesn't hold", st.Name)
+	}
+	if !p.HasState(st.InitialElse) {
+		return fmt.Errorf("initial state %s: can't find state %s", st.Name, st.InitialElse)
+	}
+	stguard, err := p.getSTGuard(PTransition{Condition: st.InitialCondition})
+	if err != nil {
+		return fmt.Errorf("initial state %s has a broken initial condition: %s", st.Name, err.Error())
+	}
+	if err := f.validateAccessPaths(p, stguard); err != nil {
+		return fmt.Errorf("initial state %s: %s", st.Name, err.Error())
+	}
+	return f.validateEnumComparisons(p, stguard)
+}
+
+//validateAssignment makes sure that an output expression assigns a value of the right type to an internal variable
+func (f Monitor) validateAssignment(p Policy, ex PExpression) error {
+	stex, err := p.getSTAssignment(ex)
+	if err != nil {
+		return fmt.Errorf("broken assignment to %s: %s", ex.VarName, err.Error())
+	}
+	if err := f.validateAccessPaths(p, stex); err != nil {
+		return err
+	}
+
+	args := stex.GetArguments()
+	v := f.GetVariable(p, args[1].HasValue())
+	if v == nil {
+		return fmt.Errorf("can't assign to %s, which is not a variable", ex.VarName)
+	}
+	root, _ := SplitAccessPath(args[1].HasValue())
+	if f.InterfaceList.HasIONamed(true, root) {
+		return fmt.Errorf("can't assign to %s, as interface variables can only be read by the monitor", ex.VarName)
+	}
+	for _, internal := range p.InternalVars {
+		if internal.Name == root && internal.Constant {
+			return fmt.Errorf("can't assign to %s, as it is a constant", ex.VarName)
+		}
+	}
+
+	if val := args[0].HasValue(); val != "" {
+		if err := f.validateEnumValue(p, *v, val); err != nil {
+			return err
+		}
+	}
+	t, err := f.getExpressionType(p, args[0])
+	if err != nil {
+		return fmt.Errorf("assignment to %s: %s", ex.VarName, err.Error())
+	}
+	return checkAssignable(*v, t)
+}
+
+//GetVariable returns the variable (either I/O, or an internal of Policy p) with a given name, or nil if there isn't one
+//If name is an access path (e.g. pkt.temp or a[2]), the struct member or array element that it refers to is returned
+func (f Monitor) GetVariable(p Policy, name string) *Variable {
+	root, path := SplitAccessPath(name)
+	var v *Variable
+	for i := 0; i < len(f.InterfaceList) && v == nil; i++ {
+		if f.InterfaceList[i].Name == root {
+			v = &f.InterfaceList[i]
+		}
+	}
+	for i := 0; i < len(p.InternalVars) && v == nil; i++ {
+		if p.InternalVars[i].Name == root {
+			v = &p.InternalVars[i]
+		}
+	}
+	if v == nil || path == "" {
+		return v
+	}
+	member, err := v.GetMember(path)
+	if err != nil {
+		return nil
+	}
+	return member
+}
+
+//validateInitialValue makes sure that the initial value of an enum variable is one of its members
+func (f Monitor) validateInitialValue(v Variable) error {
+	e := f.GetEnum(v.Type)
+	if e == nil || v.InitialValue == "" {
+		return nil
+	}
+	vals := []string{v.InitialValue}
+	if initialArray := v.GetInitialArray(); initialArray != nil {
+		vals = initialArray
+	}
+	for _, val := range vals {
+		if !e.HasMember(val) {
+			return fmt.Errorf("Variable %s has initial value '%s', which is not a member of enum %s", v.Name, val, e.Name)
+		}
+	}
+	return nil
+}
+
+//validateEnumValue makes sure that a value used with enum variable v is either one of its members or another variable of the same enum
+//values that aren't a single identifier can't be checked here and are ignored
+func (f Monitor) validateEnumValue(p Policy, v Variable, val string) error {
+	e := f.GetEnum(v.Type)
+	if e == nil || val == "" || e.HasMember(val) {
+		return nil
+	}
+	if other := f.GetVariable(p, val); other != nil {
+		if other.Type != v.Type {
+			return fmt.Errorf("enum variable %s (of type %s) is used with variable %s (of type %s)", v.Name, v.Type, other.Name, other.Type)
+		}
+		return nil
+	}
+	return fmt.Errorf("enum variable %s is used with '%s', which is not a member of enum %s", v.Name, val, e.Name)
+}
+
+//validateEnumComparisons recursively finds all comparisons in expr that involve an enum variable and validates the other side of them
+func (f Monitor) validateEnumComparisons(p Policy, expr stcompilerlib.STExpression) error {
+	op := expr.HasOperator()
+	if op == nil {
+		return nil
+	}
+	args := expr.GetArguments()
+	if stcompilerlib.OpTokenIsComparison(op.GetToken()) && len(args) == 2 {
+		for i := 0; i < 2; i++ {
+			v := f.GetVariable(p, args[i].HasValue())
+			if v == nil {
+				continue
+			}
+			if err := f.validateEnumValue(p, *v, args[1-i].HasValue()); err != nil {
+				return err
+			}
+		}
+	}
+	for _, arg := range args {
+		if err := f.validateEnumComparisons(p, arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//validateAccessPaths makes sure that all struct member and array element accesses in expr refer to something that exists
+func (f Monitor) validateAccessPaths(p Policy, expr stcompilerlib.STExpression) error {
+	for _, val := range DeepGetValues(expr) {
+		root, path := SplitAccessPath(val)
+		if path == "" || !IsIdentifier(root) {
+			continue
+		}
+		v := f.GetVariable(p, root)
+		if v == nil {
+			return fmt.Errorf("%s: %s is not a variable", errAccessPath.Error(), root)
+		}
+		if _, err := v.getMember(path, func(array Variable, index string) error {
+			return f.validateArrayIndex(p, array, index)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//validateArrayIndex makes sure that an index into an array only uses variables that exist,
+//and that constant indices are within the bounds of the array
+func (f Monitor) validateArrayIndex(p Policy, array Variable, index string) error {
+	expr, perr := ParseSTExpression(p.Name, index)
+	if perr != nil {
+		return fmt.Errorf("bad index '%s' for array %s: %s", index, array.Name, perr.Error())
+	}
+	if err := f.validateAccessPaths(p, expr); err != nil {
+		return err
+	}
+	for _, val := range DeepGetValues(expr) {
+		if _, isConst := f.GetIntegerConstant(p, val); isConst {
+			continue
+		}
+		v := f.GetVariable(p, val)
+		if v == nil {
+			return fmt.Errorf("unknown variable '%s' in index for array %s", val, array.Name)
+		}
+		if !v.IsIntegerType() {
+			return fmt.Errorf("variable %s (of type %s) can't be used in an index for array %s", val, v.Type, array.Name)
+		}
+	}
+
+	//if the index is a constant, make sure that it is in range
+	size, err := strconv.Atoi(array.ArraySize)
+	if err != nil {
+		return nil //can't check
+	}
+	if i, isConst := f.GetIntegerConstant(p, index); isConst && (i < 0 || i >= size) {
+		return fmt.Errorf("index %s is out of bounds for array %s (of size %d)", index, array.Name, size)
+	}
+	return nil
+}
+
+//GetIntegerConstant returns the value of s if it is an integer, or the name of an integer constant in Policy p
+func (f Monitor) GetIntegerConstant(p Policy, s string) (int, bool) {
+	if i, err := strconv.Atoi(s); err == nil {
+		return i, true
+	}
+	for _, v := range p.InternalVars {
+		if v.Name == s && v.Constant {
+			if i, err := strconv.Atoi(v.InitialValue); err == nil {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,254 @@
+package rvdef
+
+import (
+	"testing"
+
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+func heaterMonitor(guard string, value string, initialValue string) Monitor {
+	return Monitor{
+		Name:  "heater",
+		Enums: []Enum{{Name: "Mode", Members: []string{"IDLE", "HEATING", "COOLING"}}},
+		InterfaceList: []Variable{
+			{Name: "mode", Type: "Mode", InitialValue: initialValue},
+			{Name: "temp", Type: "int16_t"},
+		},
+		Policies: []Policy{
+			{
+				Name:         "P",
+				InternalVars: []Variable{{Name: "last", Type: "Mode"}, {Name: "count", Type: "uint8_t"}},
+				States:       []PState{{Name: "s0", Accepting: true, Initial: true}},
+				Transitions: []PTransition{
+					{Source: "s0", Destination: "s0", Condition: guard, Expressions: []PExpression{{VarName: "last", Value: value}}},
+				},
+			},
+		},
+	}
+}
+
+func TestValidateEnums(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Monitor Monitor
+		Valid   bool
+	}{
+		{"valid", heaterMonitor("mode = HEATING and temp > 100", "HEATING", "IDLE"), true},
+		{"valid variable", heaterMonitor("mode <> last", "mode", ""), true},
+		{"bad comparison", heaterMonitor("mode = WARM", "HEATING", ""), false},
+		{"bad reversed comparison", heaterMonitor("(WARM = mode) and temp > 100", "HEATING", ""), false},
+		{"bad assignment", heaterMonitor("true", "WARM", ""), false},
+		{"bad assignment type", heaterMonitor("true", "count", ""), false},
+		{"bad initial value", heaterMonitor("true", "IDLE", "WARM"), false},
+	}
+	for _, test := range tests {
+		err := test.Monitor.Validate()
+		if test.Valid && err != nil {
+			t.Errorf("%s: Error '%s' occurred when it shouldn't have", test.Name, err.Error())
+		} else if !test.Valid && err == nil {
+			t.Errorf("%s: Error didn't occur and it should have", test.Name)
+		}
+	}
+}
+
+func TestGetEnumValues(t *testing.T) {
+	m := heaterMonitor("true", "IDLE", "")
+	if vals := m.GetEnumValues(m.InterfaceList[0]); len(vals) != 3 || vals[2] != "COOLING" {
+		t.Errorf("Enum values of mode are wrong: %v", vals)
+	}
+	if vals := m.GetEnumValues(m.InterfaceList[1]); vals != nil {
+		t.Errorf("temp shouldn't have enum values: %v", vals)
+	}
+}
+
+func TestValidateArrayIndices(t *testing.T) {
+	arrayMonitor := func(guard string) Monitor {
+		return Monitor{
+			Name: "arr",
+			InterfaceList: []Variable{
+				{Name: "sensors", Type: "int16_t", ArraySize: "4"},
+				{Name: "idx", Type: "uint8_t"},
+				{Name: "f", Type: "float"},
+			},
+			Policies: []Policy{
+				{
+					Name:         "P",
+					InternalVars: []Variable{{Name: "N", Type: "uint8_t", Constant: true, InitialValue: "3"}},
+					States:       []PState{{Name: "s0", Accepting: true, Initial: true}},
+					Transitions:  []PTransition{{Source: "s0", Destination: "s0", Condition: guard}},
+				},
+			},
+		}
+	}
+	tests := []struct {
+		Guard string
+		Valid bool
+	}{
+		{"sensors[0] > 1 and sensors[3] < 2", true},
+		{"sensors[N] > 1", true},
+		{"sensors[idx] > 1.5", true},
+		{"sensors[idx + 1] > 1", true},
+		{"sensors[4] > 1", false},
+		{"sensors[nope] > 1", false},
+		{"sensors[f] > 1", false},
+		{"idx[0] > 1", false},
+	}
+	for _, test := range tests {
+		err := arrayMonitor(test.Guard).Validate()
+		if test.Valid && err != nil {
+			t.Errorf("%s: Error '%s' occurred when it shouldn't have", test.Guard, err.Error())
+		} else if !test.Valid && err == nil {
+			t.Errorf("%s: Error didn't occur and it should have", test.Guard)
+		}
+	}
+}
+
+func TestValidateInitialState(t *testing.T) {
+	initialMonitor := func(states ...PState) Monitor {
+		return Monitor{
+			Name:          "init",
+			InterfaceList: []Variable{{Name: "a", Type: "bool"}},
+			Policies:      []Policy{{Name: "P", States: states}},
+		}
+	}
+	tests := []struct {
+		Name    string
+		Monitor Monitor
+		Valid   bool
+	}{
+		{"one initial", initialMonitor(PState{Name: "s0"}, PState{Name: "s1", Initial: true}), true},
+		{"initial condition", initialMonitor(PState{Name: "s0", Initial: true, InitialCondition: "a", InitialElse: "s1"}, PState{Name: "s1"}), true},
+		{"no initial", initialMonitor(PState{Name: "s0"}, PState{Name: "s1"}), false},
+		{"two initial", initialMonitor(PState{Name: "s0", Initial: true}, PState{Name: "s1", Initial: true}), false},
+		{"missing else", initialMonitor(PState{Name: "s0", Initial: true, InitialCondition: "a"}), false},
+		{"undefined else", initialMonitor(PState{Name: "s0", Initial: true, InitialCondition: "a", InitialElse: "s2"}), false},
+		{"bad condition", initialMonitor(PState{Name: "s0", Initial: true, InitialCondition: "a[2]", InitialElse: "s0"}), false},
+	}
+	for _, test := range tests {
+		err := test.Monitor.Validate()
+		if test.Valid && err != nil {
+			t.Errorf("%s: Error '%s' occurred when it shouldn't have", test.Name, err.Error())
+		} else if !test.Valid && err == nil {
+			t.Errorf("%s: Error didn't occur and it should have", test.Name)
+		}
+	}
+}
+
+func TestElseTransitions(t *testing.T) {
+	elseMonitor := func(transitions ...PTransition) Monitor {
+		return Monitor{
+			Name:          "else",
+			InterfaceList: []Variable{{Name: "a", Type: "bool"}, {Name: "b", Type: "bool"}},
+			Policies: []Policy{
+				{
+					Name:        "P",
+					States:      []PState{{Name: "s0", Accepting: true, Initial: true}, {Name: "s1", Accepting: false}},
+					Transitions: transitions,
+				},
+			},
+		}
+	}
+
+	m := elseMonitor(
+		PTransition{Source: "s0", Destination: "s1", Else: true},
+		PTransition{Source: "s0", Destination: "s0", Condition: "a"},
+		PTransition{Source: "s0", Destination: "s0", Condition: "b and !a"},
+		PTransition{Source: "s1", Destination: "s1", Else: true},
+	)
+	if err := m.Validate(); err != nil {
+		t.Errorf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	pol := m.Policies[0]
+	if cond := pol.GetTransitionCondition(pol.Transitions[0]); cond != "!( ( a ) or ( b and !a ) )" {
+		t.Errorf("Else condition of s0 is wrong: %s", cond)
+	}
+	if cond := pol.GetTransitionCondition(pol.Transitions[3]); cond != "true" {
+		t.Errorf("Else condition of s1 is wrong: %s", cond)
+	}
+	if cond := pol.GetTransitionCondition(pol.Transitions[1]); cond != "a" {
+		t.Errorf("Condition of s0 -> s0 is wrong: %s", cond)
+	}
+
+	//else transitions are kept in the monitor, and can be used to escape a state
+	mon, err := MakePMonitor(m.InterfaceList, pol)
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	if len(mon.Policy.GetTransitionsForSource("s0")) != 3 {
+		t.Errorf("Else transition of s0 was removed from the monitor")
+	}
+	pol.FinaliseStates()
+	if pol.States[0].FinalStatusType || !pol.States[1].FinalStatusType {
+		t.Errorf("Final states are wrong: %v", pol.States)
+	}
+
+	m = elseMonitor(
+		PTransition{Source: "s0", Destination: "s1", Else: true},
+		PTransition{Source: "s0", Destination: "s0", Else: true},
+	)
+	if err := m.Validate(); err == nil {
+		t.Errorf("Error didn't occur for two else transitions and it should have")
+	}
+}
+
+func TestValidateAssignments(t *testing.T) {
+	assignMonitor := func(varName string, value string) Monitor {
+		m := heaterMonitor("true", "IDLE", "")
+		m.InterfaceList = append(m.InterfaceList, Variable{Name: "pkt", Type: "packet_t", Fields: []Variable{{Name: "temp", Type: "int16_t"}}})
+		m.Policies[0].InternalVars = append(m.Policies[0].InternalVars,
+			Variable{Name: "f", Type: "float"},
+			Variable{Name: "ok", Type: "bool"},
+			Variable{Name: "hist", Type: "int16_t", ArraySize: "4"},
+			Variable{Name: "v", Type: "dtimer_t"},
+			Variable{Name: "MAX", Type: "uint8_t", Constant: true, InitialValue: "3"},
+		)
+		m.Policies[0].Transitions[0].Expressions = []PExpression{{VarName: varName, Value: value}}
+		return m
+	}
+	tests := []struct {
+		VarName string
+		Value   string
+		Valid   bool
+	}{
+		{"count", "count + 1", true},
+		{"count", "temp * MAX - 2", true},
+		{"f", "temp / 2", true},
+		{"f", "1.5", true},
+		{"ok", "temp > 10 and !ok", true},
+		{"hist[2]", "pkt.temp", true},
+		{"hist[MAX]", "-hist[0]", true},
+		{"v", "0", true},
+		{"last", "mode", true},
+		{"last", "COOLING", true},
+		{"temp", "1", false},
+		{"pkt.temp", "1", false},
+		{"MAX", "1", false},
+		{"nope", "1", false},
+		{"hist[4]", "1", false},
+		{"count", "nope + 1", false},
+		{"count", "1.5", false},
+		{"count", "ok", false},
+		{"ok", "1", false},
+		{"ok", "temp + ok", false},
+		{"last", "IDLE + 1", false},
+		{"count", "", false},
+	}
+	for _, test := range tests {
+		err := assignMonitor(test.VarName, test.Value).Validate()
+		if test.Valid && err != nil {
+			t.Errorf("%s := %s: Error '%s' occurred when it shouldn't have", test.VarName, test.Value, err.Error())
+		} else if !test.Valid && err == nil {
+			t.Errorf("%s := %s: Error didn't occur and it should have", test.VarName, test.Value)
+		}
+	}
+
+	//assignments are kept as ST trees in the monitor
+	m := assignMonitor("count", "count + temp")
+	mon, err := MakePMonitor(m.InterfaceList, m.Policies[0])
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when it shouldn't have", err.Error())
+	}
+	if exprs := mon.Policy.Transitions[0].STExpressions; len(exprs) != 1 || stcompilerlib.STCompileExpression(exprs[0]) != "count := count + temp" {
+		t.Errorf("Assignment wasn't converted properly: %v", exprs)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+	"github.com/PRETgroup/easy-rv/rvparser"
+)
+
+var (
+	aFileName   = flag.String("a", "", "Specifies the new version of the monitor, as an .erv or .xml file.")
+	bFileName   = flag.String("b", "", "Specifies the old version of the monitor, as an .erv or .xml file.")
+	monitorName = flag.String("monitor", "", "The name of the monitor to diff (only needed if the files have more than one).")
+	policyName  = flag.String("policy", "", "The name of the policy to diff. If blank, every policy (and the interface) is diffed.")
+)
+
+func main() {
+	flag.Parse()
+
+	if *aFileName == "" || *bFileName == "" {
+		fmt.Println("You need to specify two files to diff! Check out -help for options")
+		return
+	}
+
+	a, err := rvparser.LoadMonitor(*aFileName, *monitorName)
+	if err != nil {
+		fmt.Printf("Error loading '%s': %s\n", *aFileName, err.Error())
+		os.Exit(2)
+	}
+	b, err := rvparser.LoadMonitor(*bFileName, *monitorName)
+	if err != nil {
+		fmt.Printf("Error loading '%s': %s\n", *bFileName, err.Error())
+		os.Exit(2)
+	}
+
+	different := false
+	for _, d := range rvdef.DiffMonitors(b, a) {
+		if *policyName != "" && d.Policy != *policyName {
+			continue
+		}
+		different = true
+		fmt.Println(d.String())
+	}
+	if !different {
+		fmt.Println("No changes")
+		return
+	}
+	os.Exit(1)
+}
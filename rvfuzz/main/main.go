@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvc"
+	"github.com/PRETgroup/easy-rv/rvparser"
+)
+
+var (
+	inFileName  = flag.String("i", "", "Specifies the monitor to test, as an .erv or .xml file.")
+	monitorName = flag.String("monitor", "", "The name of the monitor to test (only needed if the file has more than one).")
+	compiler    = flag.String("cc", "cc", "The C compiler to build the generated C with.")
+	keepDir     = flag.String("keep", "", "If set, the generated C and the test program are built in (and left in) this directory.")
+	traces      = flag.Int("traces", 100, "How many random input traces to run.")
+	length      = flag.Int("length", 100, "How many ticks each random input trace has.")
+	seed        = flag.Int64("seed", 1, "The seed for the random input traces.")
+	guided      = flag.Bool("guided", true, "Set this to false to only run random input traces, rather than also running the traces from test generation.")
+)
+
+func main() {
+	flag.Parse()
+
+	if *inFileName == "" {
+		fmt.Println("You need to specify a file name for the monitor to test! Check out -help for options")
+		return
+	}
+
+	mon, err := rvparser.LoadMonitor(*inFileName, *monitorName)
+	if err != nil {
+		fmt.Printf("Error loading '%s': %s\n", *inFileName, err.Error())
+		os.Exit(2)
+	}
+
+	opts := rvc.DiffTestOptions{Compiler: *compiler, Dir: *keepDir, Traces: *traces, Length: *length, Seed: *seed, Guided: *guided}
+	res, err := rvc.DiffTest(mon, opts)
+	if err != nil {
+		fmt.Println("Error testing monitor:", err.Error())
+		os.Exit(2)
+	}
+	if res.CutShort > 0 {
+		fmt.Printf("Warning: %d trace(s) were cut short, before a tick that the reference couldn't evaluate (the first at %s)\n", res.CutShort, res.CutShortErr)
+	}
+	if len(res.Skipped) > 0 {
+		fmt.Printf("Warning: no test traces could be made for %d policy(s) (%s), so they only had random traces\n", len(res.Skipped), strings.Join(res.Skipped, ", "))
+	}
+	if res.Mismatch != nil {
+		fmt.Printf("Monitor %s: the generated C and the reference disagree after %d trace(s) (seed %d)\n", mon.Name, res.Traces, *seed)
+		fmt.Println(res.Mismatch.String())
+		os.Exit(1)
+	}
+	fmt.Printf("Monitor %s: the generated C and the reference agree on all %d trace(s) (%d ticks, seed %d)\n", mon.Name, res.Traces, res.Ticks, *seed)
+}
@@ -5,7 +5,11 @@ import (
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/PRETgroup/easy-rv/rvdef"
 	"github.com/PRETgroup/easy-rv/rvparser"
 )
 
@@ -13,6 +17,9 @@ var (
 	inFileName    = flag.String("i", "", "Specifies the name of the source file (.erte) file to be compiled.")
 	outFileName   = flag.String("o", "out.xml", "Specifies the name of the output file (.erte.xml) files.")
 	policyProduct = flag.Bool("product", false, "(Experimental) Set this to true to take the product of all specified policies rather than executing them in sequence")
+	report        = flag.Bool("report", false, "Set this to true to print a report of each policy's reachable verdicts, unreachable states, and transitions that can never be taken")
+	strictTypes   = flag.Bool("strict", false, "Set this to true to treat type hazards in guards and assignments (e.g. signed/unsigned comparisons) as errors rather than warnings")
+	hoaFileName   = flag.String("hoa", "", "If set, the automaton in this HOA file (e.g. from an LTL-to-automata tool) is added to the monitor as a policy named after the file")
 )
 
 var (
@@ -90,6 +97,49 @@ func main() {
 		fmt.Printf("Error during parsing file '%s': %s\n", *inFileName, parseErr.Error())
 		return
 	}
+	if *hoaFileName != "" {
+		if len(mfbs) != 1 {
+			fmt.Printf("A HOA automaton can only be added when there is one monitor, but '%s' has %d\n", *inFileName, len(mfbs))
+			return
+		}
+		hoaFile, err := os.Open(*hoaFileName)
+		if err != nil {
+			fmt.Printf("Error reading file '%s': %s\n", *hoaFileName, err.Error())
+			return
+		}
+		name := strings.TrimSuffix(filepath.Base(*hoaFileName), filepath.Ext(*hoaFileName))
+		policy, err := mfbs[0].ReadHOA(hoaFile, name)
+		hoaFile.Close()
+		if err != nil {
+			fmt.Printf("Error during reading of HOA file '%s': %s\n", *hoaFileName, err.Error())
+			return
+		}
+		mfbs[0].Policies = append(mfbs[0].Policies, *policy)
+	}
+	for _, fun := range mfbs {
+		if err := fun.Validate(); err != nil {
+			fmt.Printf("Error during validation of '%s': %s\n", fun.Name, err.Error())
+			return
+		}
+		mode := rvdef.TypeCheckPermissive
+		if *strictTypes {
+			mode = rvdef.TypeCheckStrict
+		}
+		failed := false
+		for _, issue := range fun.TypeCheck(mode) {
+			fmt.Println(issue.String())
+			failed = failed || issue.Error
+		}
+		if failed {
+			fmt.Printf("Error during type checking of '%s'\n", fun.Name)
+			return
+		}
+		if *report {
+			for _, r := range fun.Analyse() {
+				fmt.Println(r.String())
+			}
+		}
+	}
 	for _, fun := range mfbs {
 
 		// name := fun.Name
@@ -2,6 +2,7 @@ package rvparser
 
 import (
 	"errors"
+	"path/filepath"
 	"strings"
 	"text/scanner"
 
@@ -12,6 +13,9 @@ const (
 	pNewline = "\n"
 
 	pMonitor      = "monitor"
+	pEnum         = "enum"
+	pStruct       = "struct"
+	pImport       = "import"
 	pInterface    = "interface"
 	pArchitecture = "architecture"
 
@@ -19,6 +23,8 @@ const (
 	pCloseBrace   = "}"
 	pOpenBracket  = "["
 	pCloseBracket = "]"
+	pOpenParen    = "("
+	pCloseParen   = ")"
 	pComma        = ","
 	pSemicolon    = ";"
 	pColon        = ":"
@@ -33,8 +39,18 @@ const (
 	pTrans = "->"
 	pOn    = "on"
 
+	pForall = "forall"
+	pExists = "exists"
+	pIn     = "in"
+	pRange  = ".."
+
 	pConstant = "constant"
 
+	pPredicate = "predicate"
+
+	pTemplate    = "template"
+	pInstantiate = "="
+
 	pInternal   = "internal"
 	pInternals  = "internals"
 	pState      = "state"
@@ -42,6 +58,8 @@ const (
 	pAlgorithm  = "algorithm"
 	pAlgorithms = "algorithms"
 
+	pInitial   = "initial"
+	pElse      = "else"
 	pAccepting = "accepting"
 	pRejecting = "rejecting"
 	pTrap      = "trap"
@@ -78,6 +96,20 @@ func scanString(name string, input string) []string {
 
 	//combine multi-character operators
 	for i := 0; i < len(items)-1; i++ {
+		//ranges (e.g. 0..N) get scanned as floats, so break them back up into lo .. hi
+		if len(items[i]) > 1 && strings.HasSuffix(items[i], ".") && strings.HasPrefix(items[i+1], ".") {
+			rng := []string{strings.TrimSuffix(items[i], "."), pRange}
+			if hi := strings.TrimPrefix(items[i+1], "."); hi != "" {
+				rng = append(rng, hi)
+			}
+			items = append(items[:i], append(rng, items[i+2:]...)...)
+		}
+
+		if items[i] == "." && items[i+1] == "." {
+			items[i] = pRange
+			items = append(items[:i+1], items[i+2:]...)
+		}
+
 		if items[i] == "<" && items[i+1] == "-" {
 			items[i] = "<-"
 			items = append(items[:i+1], items[i+2:]...)
@@ -122,6 +154,12 @@ func scanString(name string, input string) []string {
 			items[i] = "||"
 			items = append(items[:i+1], items[i+2:]...)
 		}
+
+		//struct member accesses (e.g. pkt.temp) become a single item
+		for i+2 < len(items) && items[i+1] == "." && rvdef.IsIdentifier(items[i][strings.LastIndexAny(items[i], "!.")+1:]) && rvdef.IsIdentifier(items[i+2]) {
+			items[i] = items[i] + "." + items[i+2]
+			items = append(items[:i+1], items[i+3:]...)
+		}
 	}
 
 	return items
@@ -129,17 +167,74 @@ func scanString(name string, input string) []string {
 
 //parseItems creates and runs a pparse struct
 func parseItems(name string, items []string) ([]rvdef.Monitor, *ParseError) {
-	t := pParse{items: items, currentLine: 1, currentFile: name}
+	t := pParse{items: items, currentLine: 1, currentFile: name, imported: make(map[string]bool)}
 
+	//the pattern library can be used by every file
+	templates, err := builtinTemplates()
+	if err != nil {
+		return nil, err
+	}
+	t.templates = templates
+
+	//the root file is on the import stack so that it can't be imported by its own imports
+	if abs, err := filepath.Abs(name); err == nil {
+		t.importStack = []string{abs}
+		t.imported[abs] = true
+	}
+
+	if err := t.parseAll(); err != nil {
+		return nil, err
+	}
+	if err := t.checkPredicates(); err != nil {
+		return nil, err
+	}
+
+	return t.funcs, nil
+}
+
+//parseAll runs through all items in a pParse
+func (t *pParse) parseAll() *ParseError {
 	for !t.done() {
 		s := t.pop()
 		if t.done() {
 			break
 		}
+		//are we importing another file
+		if s == pImport {
+			if err := t.parseImport(); err != nil {
+				return err
+			}
+			continue
+		}
+
 		//have we defined a monitor name
 		if s == pMonitor {
 			if err := t.parseMonitor(s); err != nil {
-				return nil, err
+				return err
+			}
+			continue
+		}
+
+		//is this defining an enumerated type
+		if s == pEnum {
+			if err := t.parseEnum(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		//is this defining a policy template
+		if s == pTemplate {
+			if err := t.parseTemplate(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		//is this defining a record type
+		if s == pStruct {
+			if err := t.parseStruct(); err != nil {
+				return err
 			}
 			continue
 		}
@@ -147,7 +242,7 @@ func parseItems(name string, items []string) ([]rvdef.Monitor, *ParseError) {
 		//is this defining an interface for a monitor
 		if s == pInterface {
 			if err := t.parseMonitorInterface(); err != nil {
-				return nil, err
+				return err
 			}
 			continue
 		}
@@ -155,14 +250,27 @@ func parseItems(name string, items []string) ([]rvdef.Monitor, *ParseError) {
 		//is this defining an architecture for a monitor
 		if s == pArchitecture || s == pFBpolicy {
 			if err := t.parseMonitorArchitecture(s); err != nil {
-				return nil, err
+				return err
 			}
 			continue
 		}
-		return nil, t.errorWithArg(ErrUnexpectedValue, s)
+		return t.errorWithArg(ErrUnexpectedValue, s)
 	}
 
-	return t.funcs, nil
+	return nil
+}
+
+//guardComponent returns s as it should be stored in a guard (i.e. with C-style boolean operators converted)
+func guardComponent(s string) string {
+	//if any component is "&&" then turn it into and
+	if s == "&&" {
+		return "and"
+	}
+	//if any component is "||" then turn it into or
+	if s == "||" {
+		return "or"
+	}
+	return s
 }
 
 //isValidType returns true if string s is one of the valid event/data types
@@ -243,6 +351,11 @@ func (t *pParse) parseMonitorArchitecture(archType string) *ParseError {
 	}
 
 	if archType == pFBpolicy {
+		//is this an instance of a template
+		if t.peek() == pInstantiate {
+			t.pop()
+			return t.parsePolicyInstance(fbIndex, pName)
+		}
 		t.funcs[fbIndex].AddPolicy(pName)
 		return t.parsePolicyArchitecture(fbIndex)
 	}
@@ -0,0 +1,57 @@
+package rvparser
+
+import (
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//parseEnum shall only be called once we have already parsed the "enum" keyword
+// so, we are up to the type name
+//the format is
+// enum <name> { <member>[, <member>...] };
+func (t *pParse) parseEnum() *ParseError {
+	name := t.pop()
+	if name == "" {
+		return t.error(ErrUnexpectedEOF)
+	}
+	if !t.isTypeNameUnused(name) {
+		return t.errorWithArg(ErrNameAlreadyInUse, name)
+	}
+
+	if s := t.pop(); s != pOpenBrace {
+		return t.errorUnexpectedWithExpected(s, pOpenBrace)
+	}
+
+	e := rvdef.Enum{Name: name}
+	for {
+		member := t.pop()
+		if member == "" {
+			return t.error(ErrUnexpectedEOF)
+		}
+		if !rvdef.IsIdentifier(member) || isExprKeyword(member) {
+			return t.errorUnexpectedWithExpected(member, "enum member name")
+		}
+
+		//enum members share a namespace in C, so they must be unique across all enums,
+		// and in guards they share one with the types and variables
+		if e.HasMember(member) || t.isEnumMember(member) || !t.isTypeNameUnused(member) || t.isVariableName(member) {
+			return t.errorWithArg(ErrNameAlreadyInUse, member)
+		}
+		e.Members = append(e.Members, member)
+
+		s := t.pop()
+		if s == pComma {
+			continue
+		}
+		if s == pCloseBrace {
+			break
+		}
+		return t.errorUnexpectedWithExpected(s, "Either '"+pComma+"' or '"+pCloseBrace+"'")
+	}
+
+	if s := t.pop(); s != pSemicolon {
+		return t.errorUnexpectedWithExpected(s, pSemicolon)
+	}
+
+	t.enums = append(t.enums, e)
+	return nil
+}
@@ -0,0 +1,184 @@
+package rvparser
+
+import (
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+var enumTests = []ParseTest{
+	{
+		Name: "enum interface and internal",
+		Input: `enum Mode { IDLE, HEATING, COOLING };
+				monitor heater;
+				interface of heater {
+					Mode mode := IDLE;
+				}
+				policy P of heater {
+					internals {
+						Mode last;
+					}
+					states {
+						s0 accepting {
+							-> s0 on mode = HEATING: last := HEATING;
+						}
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "heater",
+				Enums: []rvdef.Enum{
+					rvdef.Enum{Name: "Mode", Members: []string{"IDLE", "HEATING", "COOLING"}},
+				},
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "mode", Type: "Mode", InitialValue: "IDLE"},
+				},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name: "P",
+						InternalVars: []rvdef.Variable{
+							rvdef.Variable{Name: "last", Type: "Mode"},
+						},
+						States: []rvdef.PState{{Name: "s0", Accepting: true}},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "mode = HEATING", Expressions: []rvdef.PExpression{{VarName: "last", Value: "HEATING"}}, DebugInfo: rvdef.DebugInfo{SourceLine: 12, SourceFile: "Test[0]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "unused enum",
+		Input: `enum Mode { IDLE, HEATING };
+				monitor heater;
+				interface of heater {
+					bool on;
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "heater",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "on", Type: "bool"},
+				},
+			},
+		},
+	},
+	{
+		Name: "enum used before declaration",
+		Input: `monitor heater;
+				interface of heater {
+					Mode mode;
+				}
+				enum Mode { IDLE, HEATING };`,
+		Err: ErrInvalidType,
+	},
+	{
+		Name:  "enum name is a type",
+		Input: `enum bool { IDLE, HEATING };`,
+		Err:   ErrNameAlreadyInUse,
+	},
+	{
+		Name: "enum name reused",
+		Input: `enum Mode { IDLE, HEATING };
+				enum Mode { OFF, ON };`,
+		Err: ErrNameAlreadyInUse,
+	},
+	{
+		Name: "enum member reused",
+		Input: `enum Mode { IDLE, HEATING };
+				enum Status { OK, IDLE };`,
+		Err: ErrNameAlreadyInUse,
+	},
+	{
+		Name:  "enum member not an identifier",
+		Input: `enum M { 1, ; };`,
+		Err:   ErrUnexpectedValue,
+	},
+	{
+		Name:  "enum member trailing comma",
+		Input: `enum M { IDLE, };`,
+		Err:   ErrUnexpectedValue,
+	},
+	{
+		Name:  "enum member is a keyword",
+		Input: `enum M { IDLE, AND };`,
+		Err:   ErrUnexpectedValue,
+	},
+	{
+		Name:  "enum member is a type",
+		Input: `enum M { IDLE, bool };`,
+		Err:   ErrNameAlreadyInUse,
+	},
+	{
+		Name: "enum member is an interface variable",
+		Input: `monitor heater;
+				interface of heater {
+					bool IDLE;
+				}
+				enum Mode { IDLE, HEATING };`,
+		Err: ErrNameAlreadyInUse,
+	},
+	{
+		Name: "enum member is a constant",
+		Input: `monitor heater;
+				interface of heater {
+					bool on;
+				}
+				policy P of heater {
+					internals {
+						constant uint8_t HEATING := 1;
+					}
+					states {
+						s0 accepting trap;
+					}
+				}
+				enum Mode { IDLE, HEATING };`,
+		Err: ErrNameAlreadyInUse,
+	},
+	{
+		Name: "interface variable is an enum member",
+		Input: `enum Mode { IDLE, HEATING };
+				monitor heater;
+				interface of heater {
+					bool IDLE;
+				}`,
+		Err: ErrNameAlreadyInUse,
+	},
+	{
+		Name: "internal is an enum member",
+		Input: `enum Mode { IDLE, HEATING };
+				monitor heater;
+				interface of heater {
+					Mode mode;
+				}
+				policy P of heater {
+					internals {
+						dtimer_t HEATING;
+					}
+					states {
+						s0 accepting trap;
+					}
+				}`,
+		Err: ErrNameAlreadyInUse,
+	},
+	{
+		Name:  "enum empty",
+		Input: `enum Mode { };`,
+		Err:   ErrUnexpectedValue,
+	},
+	{
+		Name:  "enum missing comma",
+		Input: `enum Mode { IDLE HEATING };`,
+		Err:   ErrUnexpectedValue,
+	},
+	{
+		Name:  "enum missing semicolon",
+		Input: `enum Mode { IDLE, HEATING } monitor heater;`,
+		Err:   ErrUnexpectedValue,
+	},
+}
+
+func TestParseEnum(t *testing.T) {
+	runParseTests(t, enumTests)
+}
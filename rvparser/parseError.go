@@ -3,6 +3,8 @@ package rvparser
 import (
 	"errors"
 	"fmt"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
 )
 
 var (
@@ -26,11 +28,36 @@ var (
 
 	//ErrNameAlreadyInUse is returned whenever something is named but the name is already in use elsewhere
 	ErrNameAlreadyInUse = errors.New("This name is already defined elsewhere")
+
+	//ErrImportFailed is used when an imported file can't be found or read
+	ErrImportFailed = errors.New("Can't read imported file")
+
+	//ErrImportCycle is used when a file imports itself, either directly or via other imported files
+	ErrImportCycle = errors.New("Import cycle detected")
+
+	//ErrInvalidQuantifier is used when a forall/exists quantifier in a guard is malformed or can't be unrolled
+	ErrInvalidQuantifier = errors.New("Invalid quantifier")
+
+	//ErrInvalidState is used when a state in a policy is malformed
+	ErrInvalidState = errors.New("Invalid state")
+
+	//ErrUndefinedTemplate is used to indicate a policy template was referenced that can't be found
+	ErrUndefinedTemplate = errors.New("Can't find policy template with name")
+
+	//ErrInvalidTemplate is used when a policy template is malformed, or is instantiated with the wrong number of arguments
+	ErrInvalidTemplate = errors.New("Invalid policy template")
+
+	//ErrInvalidPredicate is used when a named predicate is malformed, or is referenced with the wrong number of arguments
+	ErrInvalidPredicate = errors.New("Invalid predicate")
+
+	//ErrRecursivePredicate is used when a named predicate refers to itself, either directly or via other predicates
+	ErrRecursivePredicate = errors.New("Recursive predicate definition")
 )
 
 //ParseError is used to contain a helpful error message when parsing fails
 type ParseError struct {
 	LineNumber int
+	SourceFile string //the file that LineNumber is in (if it is known)
 	Argument   string
 	Reason     string
 	Err        error
@@ -39,6 +66,9 @@ type ParseError struct {
 //Error makes ParseError fulfill error interface
 func (p ParseError) Error() string {
 	s := fmt.Sprintf("Error (Line %v): %s", p.LineNumber, p.Err.Error())
+	if p.SourceFile != "" {
+		s = fmt.Sprintf("Error (%s, Line %v): %s", p.SourceFile, p.LineNumber, p.Err.Error())
+	}
 	if p.Argument != "" {
 		s += " '" + p.Argument + "'"
 	}
@@ -50,26 +80,31 @@ func (p ParseError) Error() string {
 
 // helper functions to help construct helpful error messages
 
+//errorAt returns a ParseError for something defined at d
+func errorAt(d rvdef.DebugInfo, err error, arg string, reason string) *ParseError {
+	return &ParseError{LineNumber: d.SourceLine, SourceFile: d.SourceFile, Argument: arg, Reason: reason, Err: err}
+}
+
 func (t *pParse) errorWithArg(err error, arg string) *ParseError {
-	return &ParseError{LineNumber: t.currentLine, Argument: arg, Reason: "", Err: err}
+	return errorAt(t.getCurrentDebugInfo(), err, arg, "")
 }
 
 func (t *pParse) errorWithArgAndLineNumber(err error, arg string, line int) *ParseError {
-	return &ParseError{LineNumber: line, Argument: arg, Reason: "", Err: err}
+	return &ParseError{LineNumber: line, SourceFile: t.currentFile, Argument: arg, Reason: "", Err: err}
 }
 
 func (t *pParse) errorWithReason(err error, reason string) *ParseError {
-	return &ParseError{LineNumber: t.currentLine, Argument: "", Reason: reason, Err: err}
+	return errorAt(t.getCurrentDebugInfo(), err, "", reason)
 }
 
 func (t *pParse) error(err error) *ParseError {
-	return &ParseError{LineNumber: t.currentLine, Argument: "", Reason: "", Err: err}
+	return errorAt(t.getCurrentDebugInfo(), err, "", "")
 }
 
 func (t *pParse) errorWithArgAndReason(err error, arg string, reason string) *ParseError {
-	return &ParseError{LineNumber: t.currentLine, Argument: arg, Reason: reason, Err: err}
+	return errorAt(t.getCurrentDebugInfo(), err, arg, reason)
 }
 
 func (t *pParse) errorUnexpectedWithExpected(unexpected string, expected string) *ParseError {
-	return &ParseError{LineNumber: t.currentLine, Argument: unexpected, Reason: "Expected: " + expected, Err: ErrUnexpectedValue}
+	return errorAt(t.getCurrentDebugInfo(), ErrUnexpectedValue, unexpected, "Expected: "+expected)
 }
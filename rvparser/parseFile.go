@@ -0,0 +1,44 @@
+package rvparser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//LoadMonitor reads a Monitor from an .erv file (which is parsed) or an .xml file, and validates it
+//monitorName picks the monitor to return if the file has more than one (it can be blank if there is only one)
+func LoadMonitor(fileName string, monitorName string) (rvdef.Monitor, error) {
+	sourceFile, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return rvdef.Monitor{}, err
+	}
+
+	var mons []rvdef.Monitor
+	if strings.HasSuffix(strings.ToLower(fileName), ".xml") {
+		mon := rvdef.Monitor{}
+		if err := xml.Unmarshal(sourceFile, &mon); err != nil {
+			return rvdef.Monitor{}, err
+		}
+		mons = append(mons, mon)
+	} else {
+		var parseErr *ParseError
+		mons, parseErr = ParseString(fileName, string(sourceFile))
+		if parseErr != nil {
+			return rvdef.Monitor{}, parseErr
+		}
+	}
+
+	for _, mon := range mons {
+		if (monitorName == "" && len(mons) == 1) || mon.Name == monitorName {
+			return mon, mon.Validate()
+		}
+	}
+	if monitorName == "" {
+		return rvdef.Monitor{}, fmt.Errorf("there is more than one monitor, so one must be chosen by name")
+	}
+	return rvdef.Monitor{}, fmt.Errorf("there is no monitor named %s", monitorName)
+}
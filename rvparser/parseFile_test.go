@@ -0,0 +1,35 @@
+package rvparser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadMonitor(t *testing.T) {
+	tests := []struct {
+		file    string
+		monitor string
+		err     string
+	}{
+		{file: "testdata/load/two.erv", monitor: "a"},
+		{file: "testdata/load/two.erv", err: "there is more than one monitor"},
+		{file: "testdata/load/two.erv", monitor: "c", err: "there is no monitor named c"},
+		{file: "testdata/load/two.erv", monitor: "b", err: "index 2 is out of bounds"}, //monitors are validated
+		{file: "testdata/import/broken.erv", err: "testdata/import/broken.erv"},
+		{file: "testdata/load/missing.erv", err: "no such file"},
+	}
+	for i, test := range tests {
+		mon, err := LoadMonitor(test.file, test.monitor)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Test %d: error was %v, it should have been '%s'", i, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: error '%s' occurred when it shouldn't have", i, err.Error())
+		} else if mon.Name != test.monitor {
+			t.Errorf("Test %d: loaded monitor %s, it should have been %s", i, mon.Name, test.monitor)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package rvparser
+
+import (
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//compositeState is a state that contains sub-states
+//Composite states are flattened away once their policy is parsed, with each of their sub-states
+// (which are named <composite>_<sub-state>) taking on the transitions of the composite state
+type compositeState struct {
+	name     string   //the flattened name of the composite state
+	parent   string   //the flattened name of the composite state that this one is inside ("" if at the top level)
+	children []string //the flattened names of the sub-states, in order
+
+	defaultChild string //the flattened name of the sub-state marked as initial ("" if none are, in which case the first is used)
+}
+
+//initialState records the initial state of the current policy while it is being parsed
+type initialState struct {
+	rvdef.DebugInfo          //where the initial condition was defined
+	name            string   //the flattened name of the state (which may be a composite state)
+	components      []string //the components of the initial condition, if there is one
+	condition       string   //the initial condition, once it has been expanded
+	elseState       string   //the state to start in if the initial condition doesn't hold
+}
+
+//getDefault returns the flattened name of the sub-state that is entered when the composite state is entered
+func (c compositeState) getDefault() string {
+	if c.defaultChild != "" {
+		return c.defaultChild
+	}
+	return c.children[0]
+}
+
+//getComposite will search the composite states of the current policy for one that matches
+// the provided (flattened) name and return it if found
+func (t *pParse) getComposite(name string) *compositeState {
+	for i := 0; i < len(t.composites); i++ {
+		if t.composites[i].name == name {
+			return &t.composites[i]
+		}
+	}
+	return nil
+}
+
+//isStateDefined returns true if the (flattened) name is already in use by a state of the current policy
+func (t *pParse) isStateDefined(fbIndex int, name string) bool {
+	fb := &t.funcs[fbIndex]
+	for _, st := range fb.Policies[len(fb.Policies)-1].States {
+		if st.Name == name {
+			return true
+		}
+	}
+	return t.getComposite(name) != nil
+}
+
+//resolveState returns the flattened name of the state that dest refers to from inside the composite state scope
+//Names are looked for amongst the sub-states of scope first, and then in each enclosing scope in turn.
+//Sub-states can also be referred to with dotted names (e.g. parent.child).
+//If dest is a composite state, its default sub-state is returned.
+//If dest can't be found, it is returned unchanged.
+func (t *pParse) resolveState(fbIndex int, scope string, dest string) string {
+	dest = strings.Replace(dest, ".", "_", -1)
+	for {
+		name := dest
+		if scope != "" {
+			name = scope + "_" + dest
+		}
+		if t.isStateDefined(fbIndex, name) {
+			//enter composite states via their default sub-states
+			for c := t.getComposite(name); c != nil; c = t.getComposite(name) {
+				name = c.getDefault()
+			}
+			return name
+		}
+		if scope == "" {
+			return dest
+		}
+		scope = t.getComposite(scope).parent
+	}
+}
+
+//flattenStates resolves the destinations of all transitions of the current policy,
+// and replaces any transitions from composite states with transitions from each of their sub-states
+//The transitions of a sub-state take priority over those of the composite states it is inside
+func (t *pParse) flattenStates(fbIndex int) {
+	fb := &t.funcs[fbIndex]
+	pol := &fb.Policies[len(fb.Policies)-1]
+
+	for _, p := range t.pending {
+		tr := &pol.Transitions[p.transition]
+		tr.Destination = t.resolveState(fbIndex, p.scope, tr.Destination)
+	}
+	if t.initial != nil {
+		name := t.resolveState(fbIndex, "", t.initial.name)
+		for i := range pol.States {
+			if pol.States[i].Name == name {
+				pol.States[i].Initial = true
+				pol.States[i].InitialCondition = t.initial.condition
+				if t.initial.condition != "" {
+					pol.States[i].InitialElse = t.resolveState(fbIndex, "", t.initial.elseState)
+				}
+			}
+		}
+	}
+	if len(t.composites) == 0 {
+		return
+	}
+
+	//split the transitions into those from (leaf) states, and those from composite states
+	var transitions []rvdef.PTransition
+	inherited := make(map[string][]rvdef.PTransition)
+	for _, tr := range pol.Transitions {
+		if t.getComposite(tr.Source) != nil {
+			inherited[tr.Source] = append(inherited[tr.Source], tr)
+			continue
+		}
+		transitions = append(transitions, tr)
+	}
+
+	//each sub-state gets the transitions of its enclosing composite states (innermost first) after its own
+	parents := make(map[string]string)
+	for _, c := range t.composites {
+		for _, child := range c.children {
+			parents[child] = c.name
+		}
+	}
+	//(a state can only have one else transition, so the innermost one is used)
+	for _, st := range pol.States {
+		hasElse := false
+		for _, tr := range transitions {
+			if tr.Source == st.Name && tr.Else {
+				hasElse = true
+			}
+		}
+		for parent := parents[st.Name]; parent != ""; parent = parents[parent] {
+			for _, tr := range inherited[parent] {
+				if tr.Else {
+					if hasElse {
+						continue
+					}
+					hasElse = true
+				}
+				tr.Source = st.Name
+				tr.Expressions = append([]rvdef.PExpression(nil), tr.Expressions...)
+				transitions = append(transitions, tr)
+			}
+		}
+	}
+	pol.Transitions = transitions
+}
@@ -0,0 +1,245 @@
+package rvparser
+
+import (
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+var hierarchyTests = []ParseTest{
+	{
+		Name: "nested states",
+		Input: `monitor m;
+				interface of m {
+					bool a, b, c;
+				}
+				policy p of m {
+					states {
+						idle accepting {
+							-> busy on a;
+						}
+						busy {
+							-> idle on c;
+							states {
+								setup rejecting {
+									-> run on b;
+								}
+								run {
+									states {
+										working rejecting {
+											-> done on b;
+										}
+										done accepting {
+											-> busy.setup on a;
+										}
+									}
+								}
+							}
+						}
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "m",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "a", Type: "bool"},
+					rvdef.Variable{Name: "b", Type: "bool"},
+					rvdef.Variable{Name: "c", Type: "bool"},
+				},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name: "p",
+						States: []rvdef.PState{
+							{Name: "idle", Accepting: true},
+							{Name: "busy_setup", Accepting: false},
+							{Name: "busy_run_working", Accepting: false},
+							{Name: "busy_run_done", Accepting: true},
+						},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "idle", Destination: "busy_setup", Condition: "a", DebugInfo: rvdef.DebugInfo{SourceLine: 8, SourceFile: "Test[0]"}},
+							rvdef.PTransition{Source: "busy_setup", Destination: "busy_run_working", Condition: "b", DebugInfo: rvdef.DebugInfo{SourceLine: 14, SourceFile: "Test[0]"}},
+							rvdef.PTransition{Source: "busy_run_working", Destination: "busy_run_done", Condition: "b", DebugInfo: rvdef.DebugInfo{SourceLine: 19, SourceFile: "Test[0]"}},
+							rvdef.PTransition{Source: "busy_run_done", Destination: "busy_setup", Condition: "a", DebugInfo: rvdef.DebugInfo{SourceLine: 22, SourceFile: "Test[0]"}},
+							rvdef.PTransition{Source: "busy_setup", Destination: "idle", Condition: "c", DebugInfo: rvdef.DebugInfo{SourceLine: 11, SourceFile: "Test[0]"}},
+							rvdef.PTransition{Source: "busy_run_working", Destination: "idle", Condition: "c", DebugInfo: rvdef.DebugInfo{SourceLine: 11, SourceFile: "Test[0]"}},
+							rvdef.PTransition{Source: "busy_run_done", Destination: "idle", Condition: "c", DebugInfo: rvdef.DebugInfo{SourceLine: 11, SourceFile: "Test[0]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "composite state without sub-states",
+		Input: `monitor m;
+				policy p of m {
+					states {
+						busy {
+							-> busy on true;
+						}
+					}
+				}`,
+		Err: ErrInvalidState,
+	},
+	{
+		Name: "sub-states in a simple state",
+		Input: `monitor m;
+				policy p of m {
+					states {
+						busy accepting {
+							states {
+								s0 accepting trap;
+							}
+						}
+					}
+				}`,
+		Err: ErrInvalidState,
+	},
+	{
+		Name: "flattened name clash",
+		Input: `monitor m;
+				policy p of m {
+					states {
+						busy_s0 accepting trap;
+						busy {
+							states {
+								s0 accepting trap;
+							}
+						}
+					}
+				}`,
+		Err: ErrNameAlreadyInUse,
+	},
+}
+
+func TestParseHierarchy(t *testing.T) {
+	runParseTests(t, hierarchyTests)
+}
+
+var initialTests = []ParseTest{
+	{
+		Name: "initial state with condition",
+		Input: `monitor m;
+				interface of m {
+					bool a, b;
+				}
+				policy p of m {
+					predicate ready := a && !b;
+					states {
+						bad rejecting trap;
+						initial s0 accepting on ready else bad {
+							-> s0 on a;
+						}
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "m",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "a", Type: "bool"},
+					rvdef.Variable{Name: "b", Type: "bool"},
+				},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name:       "p",
+						Predicates: []rvdef.Predicate{{Name: "ready", Body: "a and !b", DebugInfo: rvdef.DebugInfo{SourceLine: 6, SourceFile: "Test[0]"}}},
+						States: []rvdef.PState{
+							{Name: "bad", Accepting: false},
+							{Name: "s0", Accepting: true, Initial: true, InitialCondition: "( a and !b )", InitialElse: "bad"},
+						},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "a", DebugInfo: rvdef.DebugInfo{SourceLine: 10, SourceFile: "Test[0]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "initial composite state and initial sub-state",
+		Input: `monitor m;
+				policy p of m {
+					states {
+						done accepting trap;
+						initial busy {
+							states {
+								setup rejecting trap;
+								initial run rejecting trap;
+							}
+						}
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "m",
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name: "p",
+						States: []rvdef.PState{
+							{Name: "done", Accepting: true},
+							{Name: "busy_setup", Accepting: false},
+							{Name: "busy_run", Accepting: false, Initial: true},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "two initial states",
+		Input: `monitor m;
+				policy p of m {
+					states {
+						initial s0 accepting trap;
+						initial s1 accepting trap;
+					}
+				}`,
+		Err: ErrInvalidState,
+	},
+	{
+		Name: "initial condition on a non-initial state",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+				}
+				policy p of m {
+					states {
+						initial s0 accepting trap;
+						s1 accepting on a else s0 trap;
+					}
+				}`,
+		Err: ErrInvalidState,
+	},
+	{
+		Name: "initial condition on a sub-state",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+				}
+				policy p of m {
+					states {
+						initial busy {
+							states {
+								initial s0 accepting on a else s1 trap;
+								s1 accepting trap;
+							}
+						}
+					}
+				}`,
+		Err: ErrInvalidState,
+	},
+	{
+		Name: "missing initial condition",
+		Input: `monitor m;
+				policy p of m {
+					states {
+						initial s0 accepting on else s0 trap;
+					}
+				}`,
+		Err: ErrInvalidState,
+	},
+}
+
+func TestParseInitial(t *testing.T) {
+	runParseTests(t, initialTests)
+}
@@ -0,0 +1,72 @@
+package rvparser
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+)
+
+//parseImport shall only be called once we have already parsed the "import" keyword
+// so, we are up to the file name.
+//Relative file names are resolved against the directory of the file doing the importing.
+//Files that have already been parsed are skipped, and files that (indirectly) import themselves are rejected.
+func (t *pParse) parseImport() *ParseError {
+	s := t.pop()
+	fileName, err := strconv.Unquote(s)
+	if err != nil || fileName == "" {
+		return t.errorUnexpectedWithExpected(s, "\"file name\"")
+	}
+
+	if s := t.pop(); s != pSemicolon {
+		return t.errorUnexpectedWithExpected(s, pSemicolon)
+	}
+
+	if !filepath.IsAbs(fileName) {
+		fileName = filepath.Join(filepath.Dir(t.currentFile), fileName)
+	}
+	absName, err := filepath.Abs(fileName)
+	if err != nil {
+		return t.errorWithArgAndReason(ErrImportFailed, fileName, err.Error())
+	}
+
+	for _, parent := range t.importStack {
+		if parent == absName {
+			return t.errorWithArg(ErrImportCycle, fileName)
+		}
+	}
+
+	if t.imported[absName] {
+		return nil //already included, nothing more to do
+	}
+	t.imported[absName] = true
+
+	contents, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return t.errorWithArgAndReason(ErrImportFailed, fileName, err.Error())
+	}
+
+	//the imported file gets its own pParse (so that its line numbers and file name are correct)
+	// but it shares its functions and import records with us
+	importStack := make([]string, len(t.importStack), len(t.importStack)+1)
+	copy(importStack, t.importStack)
+	it := pParse{
+		funcs:       t.funcs,
+		enums:       t.enums,
+		structs:     t.structs,
+		templates:   t.templates,
+		items:       scanString(fileName, string(contents)),
+		currentLine: 1,
+		currentFile: fileName,
+		importStack: append(importStack, absName),
+		imported:    t.imported,
+	}
+	if err := it.parseAll(); err != nil {
+		return err
+	}
+	t.funcs = it.funcs
+	t.enums = it.enums
+	t.structs = it.structs
+	t.templates = it.templates
+
+	return nil
+}
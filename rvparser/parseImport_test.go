@@ -0,0 +1,90 @@
+package rvparser
+
+import (
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+var importTests = []ParseTest{
+	{
+		Name: "import interface",
+		Input: `import "testdata/import/common/interfaces.erv";
+				policy AB of ab5 {
+					states {
+						s0 accepting {
+							-> s0 on A;
+						}
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "ab5",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "A", Type: "bool"},
+					rvdef.Variable{Name: "B", Type: "bool"},
+				},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name:   "AB",
+						States: []rvdef.PState{{Name: "s0", Accepting: true}},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "A", DebugInfo: rvdef.DebugInfo{SourceLine: 5, SourceFile: "Test[0]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "import de-duplication",
+		Input: `import "testdata/import/diamond.erv";
+				import "testdata/import/common/interfaces.erv";`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "ab5",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "A", Type: "bool"},
+					rvdef.Variable{Name: "B", Type: "bool"},
+				},
+			},
+		},
+	},
+	{
+		Name:  "import cycle",
+		Input: `import "testdata/import/cycle_a.erv";`,
+		Err:   ErrImportCycle,
+	},
+	{
+		Name:  "import missing file",
+		Input: `import "testdata/import/missing.erv";`,
+		Err:   ErrImportFailed,
+	},
+	{
+		Name:  "import missing quotes",
+		Input: `import testdata/import/common/interfaces.erv;`,
+		Err:   ErrUnexpectedValue,
+	},
+	{
+		Name:  "import missing semicolon",
+		Input: `import "testdata/import/common/interfaces.erv"`,
+		Err:   ErrUnexpectedValue,
+	},
+}
+
+func TestParseImport(t *testing.T) {
+	runParseTests(t, importTests)
+}
+
+func TestParseImportErrorSourceFile(t *testing.T) {
+	_, err := ParseString("root.erv", `import "testdata/import/broken.erv";`)
+	if err == nil {
+		t.Fatal("Error didn't occur and it should have")
+	}
+	if err.Err != ErrInvalidType {
+		t.Errorf("Error codes don't match (it was '%s', should have been '%s')", err.Error(), ErrInvalidType.Error())
+	}
+	if err.SourceFile != "testdata/import/broken.erv" || err.LineNumber != 4 {
+		t.Errorf("Error location is wrong (it was %s:%d, should have been testdata/import/broken.erv:4)", err.SourceFile, err.LineNumber)
+	}
+}
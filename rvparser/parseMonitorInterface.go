@@ -35,6 +35,22 @@ func (t *pParse) parseMonitorInterface() *ParseError {
 			t.pop()
 			return nil //we're done here
 		}
+		//record types can be declared inside an interface
+		if s == pStruct {
+			t.pop()
+			if err := t.parseStruct(); err != nil {
+				return err
+			}
+			continue
+		}
+		//predicates can be declared inside an interface, in which case they can be used by all policies of the monitor
+		if s == pPredicate {
+			t.pop()
+			if err := t.parseMonitorPredicate(fbIndex); err != nil {
+				return err
+			}
+			continue
+		}
 		//still here? attempt to add I/O
 		if err := t.addMonitorIO(fbIndex); err != nil {
 			return err
@@ -50,7 +66,8 @@ func (t *pParse) addMonitorIO(fbIndex int) *ParseError {
 
 	//next s is type
 	typ := t.pop()
-	if !isValidType(typ) {
+	fields, ok := t.useType(fbIndex, typ)
+	if !ok {
 		return t.errorWithArgAndReason(ErrInvalidType, typ, "Expected valid type")
 	}
 
@@ -70,6 +87,9 @@ func (t *pParse) addMonitorIO(fbIndex int) *ParseError {
 	//this could be an array of names, so we'll loop while we are finding commas
 	for {
 		name := t.pop()
+		if t.isEnumMember(name) {
+			return t.errorWithArg(ErrNameAlreadyInUse, name)
+		}
 
 		intNames = append(intNames, name)
 		if t.peek() == pComma {
@@ -117,11 +137,18 @@ func (t *pParse) addMonitorIO(fbIndex int) *ParseError {
 		return t.errorUnexpectedWithExpected(s, pSemicolon)
 	}
 
+	if fields != nil && initialValue != "" {
+		return t.errorWithArgAndReason(ErrInvalidIOMeta, initialValue, "struct variables can't have initial values")
+	}
+
 	//we now have everything we need to add the io to the interface
 
 	if err := fb.AddIO(intNames, typ, size, initialValue); err != nil {
 		return t.errorWithArg(ErrNameAlreadyInUse, err.Error())
 	}
+	for i := len(fb.InterfaceList) - len(intNames); i < len(fb.InterfaceList); i++ {
+		fb.InterfaceList[i].Fields = fields
+	}
 
 	return nil
 }
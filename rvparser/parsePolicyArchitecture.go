@@ -13,8 +13,13 @@ func (t *pParse) parsePolicyArchitecture(fbIndex int) *ParseError {
 	if s := t.pop(); s != pOpenBrace {
 		return t.errorUnexpectedWithExpected(s, pOpenBrace)
 	}
+	t.pending = nil
+	t.composites = nil
+	t.statePath = nil
+	t.initial = nil
+
 	//we now have several things that could be in here
-	//internal | internals | state | states | closeBrace
+	//internal | internals | predicate | state | states | closeBrace
 
 	//unlike in an interface, the various things that are in an architecture can be presented out of order
 	//this only has consequences with regards to states in the state machine
@@ -32,6 +37,10 @@ func (t *pParse) parsePolicyArchitecture(fbIndex int) *ParseError {
 			if err := t.parsePossibleArrayInto(fbIndex, (*pParse).parsePInternal); err != nil {
 				return err
 			}
+		} else if s == pPredicate {
+			if err := t.parsePPredicate(fbIndex); err != nil {
+				return err
+			}
 		} else if s == pState || s == pStates {
 			if err := t.parsePossibleArrayInto(fbIndex, (*pParse).parsePState); err != nil {
 				return err
@@ -39,6 +48,12 @@ func (t *pParse) parsePolicyArchitecture(fbIndex int) *ParseError {
 		}
 	}
 
+	//now that all predicates and states are known, the transitions can be finished
+	if err := t.expandGuards(fbIndex); err != nil {
+		return err
+	}
+	t.flattenStates(fbIndex)
+	t.pending = nil
 	return nil
 }
 
@@ -79,7 +94,8 @@ func (t *pParse) parsePInternal(fbIndex int) *ParseError {
 	//next s is type
 	typ := t.pop()
 
-	if !isValidType(typ) {
+	fields, ok := t.useType(fbIndex, typ)
+	if !ok {
 		return t.errorWithArgAndReason(ErrInvalidType, typ, "Expected valid type")
 	}
 
@@ -98,6 +114,9 @@ func (t *pParse) parsePInternal(fbIndex int) *ParseError {
 
 	for {
 		name := t.pop()
+		if t.isEnumMember(name) {
+			return t.errorWithArg(ErrNameAlreadyInUse, name)
+		}
 
 		intNames = append(intNames, name)
 		if t.peek() == pComma {
@@ -124,14 +143,12 @@ func (t *pParse) parsePInternal(fbIndex int) *ParseError {
 			//deal with brackets, if we have an open bracket we must have a close bracket, etc
 			if s == pOpenBracket && bracketOpen == 0 {
 				bracketOpen = 1
-			}
-			if s == pOpenBracket && bracketOpen != 0 {
+			} else if s == pOpenBracket && bracketOpen != 0 {
 				return t.errorUnexpectedWithExpected(s, "[Value]")
 			}
 			if s == pCloseBracket && bracketOpen == 1 {
 				bracketOpen = 2
-			}
-			if s == pCloseBracket && bracketOpen != 1 {
+			} else if s == pCloseBracket && bracketOpen != 1 {
 				return t.errorUnexpectedWithExpected(s, pSemicolon)
 			}
 			if s == pSemicolon && bracketOpen == 1 { //can't return if brackets are open
@@ -152,7 +169,15 @@ func (t *pParse) parsePInternal(fbIndex int) *ParseError {
 
 	//we now have everything we need to add the internal to the fb
 
-	fb.Policies[len(fb.Policies)-1].AddDataInternals(intNames, typ, isConstant, size, initialValue)
+	if fields != nil && initialValue != "" {
+		return t.errorWithArgAndReason(ErrInvalidIOMeta, initialValue, "struct variables can't have initial values")
+	}
+
+	pol := &fb.Policies[len(fb.Policies)-1]
+	pol.AddDataInternals(intNames, typ, isConstant, size, initialValue)
+	for i := len(pol.InternalVars) - len(intNames); i < len(pol.InternalVars); i++ {
+		pol.InternalVars[i].Fields = fields
+	}
 
 	return nil
 }
@@ -162,27 +187,93 @@ func (t *pParse) parsePInternal(fbIndex int) *ParseError {
 func (t *pParse) parsePState(fbIndex int) *ParseError {
 	fb := &t.funcs[fbIndex]
 
-	//next is name of state
+	//the state might be marked as the initial state (or, inside a composite state, as the default sub-state)
+	initial := false
+	if t.peek() == pInitial {
+		t.pop()
+		initial = true
+	}
+
+	//next is name of state (sub-states are named after the composite states they are inside)
 	name := t.pop()
+	scope := ""
+	if len(t.statePath) > 0 {
+		scope = t.statePath[len(t.statePath)-1]
+		name = scope + "_" + name
+	}
 
-	for _, st := range fb.Policies[len(fb.Policies)-1].States {
-		if st.Name == name {
-			return t.errorWithArg(ErrNameAlreadyInUse, name)
+	if t.isStateDefined(fbIndex, name) {
+		return t.errorWithArg(ErrNameAlreadyInUse, name)
+	}
+	if scope != "" {
+		parent := t.getComposite(scope)
+		parent.children = append(parent.children, name)
+	}
+
+	if initial {
+		if scope == "" {
+			if t.initial != nil {
+				return t.errorWithArgAndReason(ErrInvalidState, name, "Only one state can be initial, but "+t.initial.name+" already is")
+			}
+			t.initial = &initialState{name: name}
+		} else {
+			parent := t.getComposite(scope)
+			if parent.defaultChild != "" {
+				return t.errorWithArgAndReason(ErrInvalidState, name, "Only one sub-state can be initial, but "+parent.defaultChild+" already is")
+			}
+			parent.defaultChild = name
 		}
 	}
 
-	//next should be either "accepting" or "rejecting"
+	//next should be either "accepting" or "rejecting" (unless this is a composite state)
 	accepting := true
-	status := t.pop()
-	if status == pRejecting {
-		accepting = false
-	} else if status != pAccepting {
-		return t.errorUnexpectedWithExpected(status, "Either '"+pAccepting+"' or '"+pRejecting+"'")
+	composite := false
+	if s := t.peek(); s == pAccepting || s == pRejecting {
+		accepting = t.pop() == pAccepting
+	} else {
+		composite = true
+	}
+
+	//the initial state of a policy can have an initial condition, which must hold on the first tick
+	// on <guard> else <state>
+	if t.peek() == pOn {
+		t.pop() //clear the pOn
+		if !initial || scope != "" {
+			return t.errorWithArgAndReason(ErrInvalidState, name, "Only the initial state of a policy can have an initial condition")
+		}
+		t.initial.DebugInfo = t.getCurrentDebugInfo()
+		for {
+			s := t.pop()
+			if s == "" {
+				return t.error(ErrUnexpectedEOF)
+			}
+			if s == pElse {
+				break
+			}
+			t.initial.components = append(t.initial.components, guardComponent(s))
+		}
+		if len(t.initial.components) == 0 {
+			return t.errorWithArgAndReason(ErrInvalidState, name, "Missing initial condition")
+		}
+		t.initial.elseState = t.pop()
+		if !rvdef.IsIdentifier(strings.Replace(t.initial.elseState, ".", "_", -1)) {
+			return t.errorUnexpectedWithExpected(t.initial.elseState, "state name")
+		}
 	}
 
 	//next should either be "trap" or be an open brace
 	trap := false
 	s := t.pop()
+	if composite && s != pOpenBrace {
+		return t.errorUnexpectedWithExpected(s, "Either '"+pAccepting+"', '"+pRejecting+"', or '"+pOpenBrace+"'")
+	}
+	if composite {
+		t.composites = append(t.composites, compositeState{name: name, parent: scope})
+		t.statePath = append(t.statePath, name)
+		defer func() {
+			t.statePath = t.statePath[:len(t.statePath)-1]
+		}()
+	}
 	if s == pTrap {
 		trap = true
 		//clear the last semicolon
@@ -197,9 +288,13 @@ func (t *pParse) parsePState(fbIndex int) *ParseError {
 	//now we have an unknown number of ->s
 	// format is
 	// -> <destination> [on guard] [: output expression][, output expression...] ;
+	// -> <destination> else [: output expression][, output expression...] ;
 	// for transitions, or,
 	// enforce [expression][, expression...] on [guard]
+	// and composite states can also contain
+	// states { <sub-state> [<sub-state>...] }
 	if !trap {
+		hasElse := false
 		for {
 			var expressions []rvdef.PExpression
 			var expressionComponents []string
@@ -213,21 +308,44 @@ func (t *pParse) parsePState(fbIndex int) *ParseError {
 				break
 			}
 
+			if s == pState || s == pStates {
+				if !composite {
+					return t.errorWithArgAndReason(ErrInvalidState, name, "Only composite states (which have no '"+pAccepting+"' or '"+pRejecting+"') can contain sub-states")
+				}
+				if err := t.parsePossibleArrayInto(fbIndex, (*pParse).parsePState); err != nil {
+					return err
+				}
+				continue
+			}
+
 			if s == pTrans {
 
+				debug := t.getCurrentDebugInfo()
+
 				//next is dest state
 				destState := t.pop()
 
 				var condComponents []string
-				//next is on if we have a condition
-				if t.peek() == pOn {
+				openQuantifiers := 0
+				//next is else if this is only taken when no other transition is
+				isElse := false
+				if t.peek() == pElse {
+					t.pop() //clear the pElse
+					if hasElse {
+						return t.errorWithArgAndReason(ErrInvalidState, name, "Only one transition of a state can be '"+pElse+"'")
+					}
+					isElse = true
+					hasElse = true
+				} else if t.peek() == pOn {
+					//next is on if we have a condition
 					t.pop() //clear the pOn
 
 					//now we have an unknown number of condition components, terminated by a semicolon
 					for {
 						//pColon means that there are EXPRESSIONS that follow, but we're done here
+						//(unless it finishes the range of a quantifier)
 						//pSemicolon means that there is NOTHING that follows, and we're done here
-						if t.peek() == pColon || t.peek() == pSemicolon {
+						if (t.peek() == pColon && openQuantifiers == 0) || t.peek() == pSemicolon {
 							break
 						}
 
@@ -235,32 +353,30 @@ func (t *pParse) parsePState(fbIndex int) *ParseError {
 						if s == "" {
 							return t.error(ErrUnexpectedEOF)
 						}
-
-						//if any condComponent is "&&" then turn it into and
-						if s == "&&" {
-							s = "and"
+						if s == pForall || s == pExists {
+							openQuantifiers++
+						} else if s == pColon {
+							openQuantifiers--
 						}
-						//if any condComponint is "||" then turn it into or
-						if s == "||" {
-							s = "or"
-						}
-						condComponents = append(condComponents, s)
+
+						condComponents = append(condComponents, guardComponent(s))
 
 					}
 				}
-				if len(condComponents) == 0 { //put in a default condition if no condition exists
-					condComponents = append(condComponents, "true")
-				}
 
 				//if we broke on a colon then we now have EXPRESSIONS to parse
 				if t.peek() == pColon {
 					t.pop() //clear the pColon
 					//the format is
 					// VARIABLE := EXPRESSION [, VARIABLE := EXPRESSION]
+					//(the VARIABLE can also be a struct member or array element, e.g. pkt.temp or a[i])
 					expressionVar = ""
 					for {
 						if t.peek() == pSemicolon || t.peek() == pComma {
 							//finish the previous expression (if possible, indicated by expressionVar) and start the next one (if available, indicated by a comma)
+							if expressionVar != "" && len(expressionComponents) == 0 {
+								return t.errorUnexpectedWithExpected(t.peek(), "expression")
+							}
 							if expressionVar != "" {
 								expressions = append(expressions, rvdef.PExpression{
 									VarName: expressionVar,
@@ -282,15 +398,23 @@ func (t *pParse) parsePState(fbIndex int) *ParseError {
 						}
 						//we already dealt with case where it's a comma or a semicolon in the peek section above
 						if expressionVar == "" { //we've not yet started the expression, so here's the "VARIABLE :=" part
-							expressionVar = s
-							s = t.pop()
-							if s != pAssigment {
-								return t.errorUnexpectedWithExpected(s, pAssigment)
+							varComponents := []string{s}
+							for s = t.pop(); s != pAssigment; s = t.pop() {
+								if s == "" || s == pSemicolon || s == pComma || s == pCloseBrace {
+									return t.errorUnexpectedWithExpected(s, pAssigment)
+								}
+								varComponents = append(varComponents, s)
+							}
+							//the components must make up a single variable (possibly with an access path)
+							expr, err := rvdef.ParseSTExpression(t.currentFile, strings.Join(varComponents, " "))
+							if err != nil || expr.HasValue() == "" {
+								return t.errorUnexpectedWithExpected(strings.Join(varComponents, " "), "variable name")
 							}
+							expressionVar = expr.HasValue()
 							continue
 						} else {
-							//now here's the condition components
-							expressionComponents = append(expressionComponents, s)
+							//now here's the expression components
+							expressionComponents = append(expressionComponents, guardComponent(s))
 						}
 					}
 				}
@@ -299,12 +423,25 @@ func (t *pParse) parsePState(fbIndex int) *ParseError {
 					return t.errorUnexpectedWithExpected(t.peek(), pSemicolon)
 				}
 				t.pop() //pop the pSemicolon
-				//save the transition
-				fb.Policies[len(fb.Policies)-1].AddTransition(name, destState, strings.Join(condComponents, " "), expressions)
+				//save the transition (its condition is finished by expandGuards once the whole policy is parsed)
+				pol := &fb.Policies[len(fb.Policies)-1]
+				t.pending = append(t.pending, pendingTransition{DebugInfo: debug, transition: len(pol.Transitions), components: condComponents, scope: scope})
+				if isElse {
+					pol.AddElseTransition(name, destState, expressions)
+				} else {
+					pol.AddTransition(name, destState, "", expressions)
+				}
+				pol.Transitions[len(pol.Transitions)-1].DebugInfo = debug
 			}
 		}
 	}
-	//everything is parsed, add it to the state machine
+	//everything is parsed, add it to the state machine (composite states are flattened into their sub-states later)
+	if composite {
+		if len(t.getComposite(name).children) == 0 {
+			return t.errorWithArgAndReason(ErrInvalidState, name, "Composite states must contain sub-states")
+		}
+		return nil
+	}
 	fb.Policies[len(fb.Policies)-1].AddState(name, accepting)
 
 	return nil
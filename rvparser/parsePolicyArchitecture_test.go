@@ -68,9 +68,9 @@ var efbArchitectureTests = []ParseTest{
 						},
 						States: []rvdef.PState{{Name: "s1", Accepting: true}, {Name: "s2", Accepting: false}, {Name: "violation", Accepting: false}},
 						Transitions: []rvdef.PTransition{
-							rvdef.PTransition{Source: "s1", Destination: "s2", Condition: "( VS or VP )", Expressions: []rvdef.PExpression{rvdef.PExpression{VarName: "tAEI", Value: "0"}}},
-							rvdef.PTransition{Source: "s2", Destination: "s1", Condition: "( AS or AP )", Expressions: []rvdef.PExpression(nil)},
-							rvdef.PTransition{Source: "s2", Destination: "violation", Condition: "( tAEI > AEI_ns )", Expressions: []rvdef.PExpression(nil)},
+							rvdef.PTransition{Source: "s1", Destination: "s2", Condition: "( VS or VP )", Expressions: []rvdef.PExpression{rvdef.PExpression{VarName: "tAEI", Value: "0"}}, DebugInfo: rvdef.DebugInfo{SourceLine: 18, SourceFile: "Test[1]"}},
+							rvdef.PTransition{Source: "s2", Destination: "s1", Condition: "( AS or AP )", Expressions: []rvdef.PExpression(nil), DebugInfo: rvdef.DebugInfo{SourceLine: 22, SourceFile: "Test[1]"}},
+							rvdef.PTransition{Source: "s2", Destination: "violation", Condition: "( tAEI > AEI_ns )", Expressions: []rvdef.PExpression(nil), DebugInfo: rvdef.DebugInfo{SourceLine: 23, SourceFile: "Test[1]"}},
 						},
 					},
 				},
@@ -144,13 +144,13 @@ var efbArchitectureTests = []ParseTest{
 							rvdef.PState{Name: "violation", Accepting: false},
 						},
 						Transitions: []rvdef.PTransition{
-							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "( !A and !B )", Expressions: []rvdef.PExpression{rvdef.PExpression{VarName: "v", Value: "0"}}},
-							rvdef.PTransition{Source: "s0", Destination: "s1", Condition: "( A and !B )", Expressions: []rvdef.PExpression{rvdef.PExpression{VarName: "v", Value: "0"}}},
-							rvdef.PTransition{Source: "s0", Destination: "violation", Condition: "( !A and B )", Expressions: []rvdef.PExpression(nil)},
-							rvdef.PTransition{Source: "s0", Destination: "done", Condition: "( A and B )", Expressions: []rvdef.PExpression(nil)},
-							rvdef.PTransition{Source: "s1", Destination: "s1", Condition: "( !A and !B and v < 5 )", Expressions: []rvdef.PExpression(nil)},
-							rvdef.PTransition{Source: "s1", Destination: "s0", Condition: "( !A and B )", Expressions: []rvdef.PExpression(nil)},
-							rvdef.PTransition{Source: "s1", Destination: "violation", Condition: "( ( v >= 5 ) or ( A and B ) or ( A and !B ) )", Expressions: []rvdef.PExpression(nil)},
+							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "( !A and !B )", Expressions: []rvdef.PExpression{rvdef.PExpression{VarName: "v", Value: "0"}}, DebugInfo: rvdef.DebugInfo{SourceLine: 17, SourceFile: "Test[2]"}},
+							rvdef.PTransition{Source: "s0", Destination: "s1", Condition: "( A and !B )", Expressions: []rvdef.PExpression{rvdef.PExpression{VarName: "v", Value: "0"}}, DebugInfo: rvdef.DebugInfo{SourceLine: 20, SourceFile: "Test[2]"}},
+							rvdef.PTransition{Source: "s0", Destination: "violation", Condition: "( !A and B )", Expressions: []rvdef.PExpression(nil), DebugInfo: rvdef.DebugInfo{SourceLine: 23, SourceFile: "Test[2]"}},
+							rvdef.PTransition{Source: "s0", Destination: "done", Condition: "( A and B )", Expressions: []rvdef.PExpression(nil), DebugInfo: rvdef.DebugInfo{SourceLine: 26, SourceFile: "Test[2]"}},
+							rvdef.PTransition{Source: "s1", Destination: "s1", Condition: "( !A and !B and v < 5 )", Expressions: []rvdef.PExpression(nil), DebugInfo: rvdef.DebugInfo{SourceLine: 32, SourceFile: "Test[2]"}},
+							rvdef.PTransition{Source: "s1", Destination: "s0", Condition: "( !A and B )", Expressions: []rvdef.PExpression(nil), DebugInfo: rvdef.DebugInfo{SourceLine: 35, SourceFile: "Test[2]"}},
+							rvdef.PTransition{Source: "s1", Destination: "violation", Condition: "( ( v >= 5 ) or ( A and B ) or ( A and !B ) )", Expressions: []rvdef.PExpression(nil), DebugInfo: rvdef.DebugInfo{SourceLine: 38, SourceFile: "Test[2]"}},
 						},
 					},
 				},
@@ -162,3 +162,251 @@ var efbArchitectureTests = []ParseTest{
 func TestParsePFBArchitecture(t *testing.T) {
 	runParseTests(t, efbArchitectureTests)
 }
+
+var elseTests = []ParseTest{
+	{
+		Name: "else transitions",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+				}
+				policy p of m {
+					internals {
+						uint8_t n;
+					}
+					states {
+						initial s0 accepting {
+							-> s1 else: n := 1;
+							-> s0 on a;
+						}
+						s1 rejecting {
+							-> s0 else;
+						}
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "m",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "a", Type: "bool"},
+				},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name: "p",
+						InternalVars: []rvdef.Variable{
+							rvdef.Variable{Name: "n", Type: "uint8_t"},
+						},
+						States: []rvdef.PState{
+							{Name: "s0", Accepting: true, Initial: true},
+							{Name: "s1", Accepting: false},
+						},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "s0", Destination: "s1", Expressions: []rvdef.PExpression{{VarName: "n", Value: "1"}}, Else: true, DebugInfo: rvdef.DebugInfo{SourceLine: 11, SourceFile: "Test[0]"}},
+							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "a", DebugInfo: rvdef.DebugInfo{SourceLine: 12, SourceFile: "Test[0]"}},
+							rvdef.PTransition{Source: "s1", Destination: "s0", Else: true, DebugInfo: rvdef.DebugInfo{SourceLine: 15, SourceFile: "Test[0]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "else transitions of composite states",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+				}
+				policy p of m {
+					states {
+						initial idle accepting {
+							-> busy on a;
+						}
+						busy {
+							-> idle else;
+							states {
+								setup rejecting {
+									-> run on a;
+								}
+								run rejecting {
+									-> setup else;
+								}
+							}
+						}
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "m",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "a", Type: "bool"},
+				},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name: "p",
+						States: []rvdef.PState{
+							{Name: "idle", Accepting: true, Initial: true},
+							{Name: "busy_setup", Accepting: false},
+							{Name: "busy_run", Accepting: false},
+						},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "idle", Destination: "busy_setup", Condition: "a", DebugInfo: rvdef.DebugInfo{SourceLine: 8, SourceFile: "Test[1]"}},
+							rvdef.PTransition{Source: "busy_setup", Destination: "busy_run", Condition: "a", DebugInfo: rvdef.DebugInfo{SourceLine: 14, SourceFile: "Test[1]"}},
+							rvdef.PTransition{Source: "busy_run", Destination: "busy_setup", Else: true, DebugInfo: rvdef.DebugInfo{SourceLine: 17, SourceFile: "Test[1]"}},
+							rvdef.PTransition{Source: "busy_setup", Destination: "idle", Else: true, DebugInfo: rvdef.DebugInfo{SourceLine: 11, SourceFile: "Test[1]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "two else transitions",
+		Input: `monitor m;
+				policy p of m {
+					states {
+						initial s0 accepting {
+							-> s0 else;
+							-> s1 else;
+						}
+						s1 accepting trap;
+					}
+				}`,
+		Err: ErrInvalidState,
+	},
+	{
+		Name: "else transition with a guard",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+				}
+				policy p of m {
+					states {
+						initial s0 accepting {
+							-> s0 else on a;
+						}
+					}
+				}`,
+		Err: ErrUnexpectedValue,
+	},
+}
+
+func TestParseElse(t *testing.T) {
+	runParseTests(t, elseTests)
+}
+
+var assignmentTests = []ParseTest{
+	{
+		Name: "assignments to access paths",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+				}
+				policy p of m {
+					internals {
+						int16_t[4] hist;
+					}
+					states {
+						initial s0 accepting {
+							-> s0 on a: hist [ 1 ] := hist[0] + 1, hist[2] := a && true;
+						}
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "m",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "a", Type: "bool"},
+				},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name: "p",
+						InternalVars: []rvdef.Variable{
+							rvdef.Variable{Name: "hist", Type: "int16_t", ArraySize: "4"},
+						},
+						States: []rvdef.PState{
+							{Name: "s0", Accepting: true, Initial: true},
+						},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "a", Expressions: []rvdef.PExpression{
+								{VarName: "hist[1]", Value: "hist [ 0 ] + 1"},
+								{VarName: "hist[2]", Value: "a and true"},
+							}, DebugInfo: rvdef.DebugInfo{SourceLine: 11, SourceFile: "Test[0]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "internal array with an initial value",
+		Input: `monitor m;
+				policy p of m {
+					internals {
+						int16_t[2] hist := [0, 5];
+					}
+					states {
+						initial s0 accepting trap;
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "m",
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name: "p",
+						InternalVars: []rvdef.Variable{
+							rvdef.Variable{Name: "hist", Type: "int16_t", ArraySize: "2", InitialValue: "[0,5]"},
+						},
+						States: []rvdef.PState{
+							{Name: "s0", Accepting: true, Initial: true},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "internal array with nested brackets",
+		Input: `monitor m;
+				policy p of m {
+					internals {
+						int16_t[2] hist := [[0], 5];
+					}
+				}`,
+		Err: ErrUnexpectedValue,
+	},
+	{
+		Name: "assignment without a value",
+		Input: `monitor m;
+				policy p of m {
+					internals {
+						uint8_t n;
+					}
+					states {
+						initial s0 accepting {
+							-> s0: n := ;
+						}
+					}
+				}`,
+		Err: ErrUnexpectedValue,
+	},
+	{
+		Name: "assignment to more than one variable",
+		Input: `monitor m;
+				policy p of m {
+					internals {
+						uint8_t n, o;
+					}
+					states {
+						initial s0 accepting {
+							-> s0: n o := 1;
+						}
+					}
+				}`,
+		Err: ErrUnexpectedValue,
+	},
+}
+
+func TestParseAssignments(t *testing.T) {
+	runParseTests(t, assignmentTests)
+}
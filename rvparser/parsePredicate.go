@@ -0,0 +1,340 @@
+package rvparser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//pendingTransition is a transition that still needs its guard expanded (predicates and quantifiers) and its destination resolved
+// (this is done once the whole policy is parsed, so that predicates and states can be defined after they are used)
+type pendingTransition struct {
+	rvdef.DebugInfo //where the transition was defined
+	transition      int
+	components      []string
+	scope           string //the flattened name of the composite state that the source state is inside ("" if at the top level)
+}
+
+//parsePredicate shall only be called once we have already parsed the "predicate" keyword
+// so, we are up to the predicate name
+//the format is
+// predicate <name> [(<param>[, <param>...])] := <guard>;
+func (t *pParse) parsePredicate() (rvdef.Predicate, *ParseError) {
+	pred := rvdef.Predicate{DebugInfo: t.getCurrentDebugInfo()}
+
+	pred.Name = t.pop()
+	if pred.Name == "" {
+		return pred, t.error(ErrUnexpectedEOF)
+	}
+	if !rvdef.IsIdentifier(pred.Name) {
+		return pred, t.errorWithArgAndReason(ErrInvalidPredicate, pred.Name, "Expected predicate name")
+	}
+
+	//there might be parameters next
+	if t.peek() == pOpenParen {
+		t.pop() //get rid of the open paren
+		for {
+			param := t.pop()
+			if param == "" {
+				return pred, t.error(ErrUnexpectedEOF)
+			}
+			if !rvdef.IsIdentifier(param) {
+				return pred, t.errorWithArgAndReason(ErrInvalidPredicate, param, "Expected parameter name")
+			}
+			for _, other := range pred.Params {
+				if other == param {
+					return pred, t.errorWithArg(ErrNameAlreadyInUse, param)
+				}
+			}
+			pred.Params = append(pred.Params, param)
+
+			s := t.pop()
+			if s == pCloseParen {
+				break
+			}
+			if s != pComma {
+				return pred, t.errorUnexpectedWithExpected(s, "Either '"+pComma+"' or '"+pCloseParen+"'")
+			}
+		}
+	}
+
+	if s := t.pop(); s != pInitEq {
+		return pred, t.errorUnexpectedWithExpected(s, pInitEq)
+	}
+
+	//now we have an unknown number of guard components, terminated by a semicolon
+	var components []string
+	for {
+		s := t.pop()
+		if s == "" {
+			return pred, t.error(ErrUnexpectedEOF)
+		}
+		if s == pSemicolon {
+			break
+		}
+		components = append(components, guardComponent(s))
+	}
+	if len(components) == 0 {
+		return pred, t.errorWithArgAndReason(ErrInvalidPredicate, pred.Name, "Missing guard")
+	}
+	pred.Body = strings.Join(components, " ")
+
+	return pred, nil
+}
+
+//parseMonitorPredicate parses a single predicate and adds it to the fb identified by fbIndex
+func (t *pParse) parseMonitorPredicate(fbIndex int) *ParseError {
+	pred, err := t.parsePredicate()
+	if err != nil {
+		return err
+	}
+	fb := &t.funcs[fbIndex]
+	if fb.GetPredicate(rvdef.Policy{}, pred.Name) != nil {
+		return errorAt(pred.DebugInfo, ErrNameAlreadyInUse, pred.Name, "")
+	}
+	fb.Predicates = append(fb.Predicates, pred)
+	return nil
+}
+
+//parsePPredicate parses a single predicate and adds it to the latest policy of the fb identified by fbIndex
+func (t *pParse) parsePPredicate(fbIndex int) *ParseError {
+	pred, err := t.parsePredicate()
+	if err != nil {
+		return err
+	}
+	fb := &t.funcs[fbIndex]
+	pol := &fb.Policies[len(fb.Policies)-1]
+	if fb.GetPredicate(*pol, pred.Name) != nil {
+		return errorAt(pred.DebugInfo, ErrNameAlreadyInUse, pred.Name, "")
+	}
+	pol.Predicates = append(pol.Predicates, pred)
+	return nil
+}
+
+//expandGuards expands the predicates and quantifiers in all pending guards of the latest policy of the fb identified by fbIndex
+func (t *pParse) expandGuards(fbIndex int) *ParseError {
+	//errors should point to the transition, not to the end of the policy
+	line, file := t.currentLine, t.currentFile
+	defer func() {
+		t.currentLine, t.currentFile = line, file
+	}()
+
+	pol := &t.funcs[fbIndex].Policies[len(t.funcs[fbIndex].Policies)-1]
+	for _, guard := range t.pending {
+		if pol.Transitions[guard.transition].Else {
+			continue //else transitions have no guard of their own
+		}
+		cond, err := t.expandGuard(fbIndex, guard.DebugInfo, guard.components)
+		if err != nil {
+			return err
+		}
+		pol.Transitions[guard.transition].Condition = cond
+	}
+	if t.initial != nil && len(t.initial.components) > 0 {
+		cond, err := t.expandGuard(fbIndex, t.initial.DebugInfo, t.initial.components)
+		if err != nil {
+			return err
+		}
+		t.initial.condition = cond
+	}
+	return nil
+}
+
+//expandGuard expands the predicates and quantifiers in the components of a single guard (which was defined at debug)
+func (t *pParse) expandGuard(fbIndex int, debug rvdef.DebugInfo, components []string) (string, *ParseError) {
+	t.currentLine, t.currentFile = debug.SourceLine, debug.SourceFile
+
+	fb := &t.funcs[fbIndex]
+	components, err := t.expandPredicates(fb, fb.Policies[len(fb.Policies)-1], components, nil)
+	if err != nil {
+		return "", err
+	}
+	components, err = t.expandQuantifiers(fbIndex, components)
+	if err != nil {
+		return "", err
+	}
+	if len(components) == 0 { //put in a default condition if no condition exists
+		components = append(components, "true")
+	}
+	return strings.Join(components, " "), nil
+}
+
+//checkPredicates expands the body of every predicate of every monitor and policy once, whether or not it is used,
+// so that recursive (and otherwise invalid) definitions are reported where they are defined
+func (t *pParse) checkPredicates() *ParseError {
+	line, file := t.currentLine, t.currentFile
+	defer func() {
+		t.currentLine, t.currentFile = line, file
+	}()
+
+	check := func(fb *rvdef.Monitor, pol rvdef.Policy, pred rvdef.Predicate) *ParseError {
+		t.currentLine, t.currentFile = pred.SourceLine, pred.SourceFile
+		//the parameters could be anything, so stand in true for them
+		args := make([][]string, len(pred.Params))
+		for i := range args {
+			args[i] = []string{"true"}
+		}
+		var body []string
+		for _, s := range strings.Fields(pred.Body) {
+			body = append(body, substituteParam(s, pred.Params, args)...)
+		}
+		_, err := t.expandPredicates(fb, pol, body, []string{pred.Name})
+		return err
+	}
+	for i := range t.funcs {
+		fb := &t.funcs[i]
+		for _, pred := range fb.Predicates {
+			if err := check(fb, rvdef.Policy{}, pred); err != nil {
+				return err
+			}
+		}
+		for _, pol := range fb.Policies {
+			for _, pred := range pol.Predicates {
+				if err := check(fb, pol, pred); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+//expandPredicates replaces all references to predicates in the components of a guard with their (bracketed) definitions,
+// looking up predicates in the policy pol of fb
+//stack contains the names of the predicates currently being expanded, and is used to detect recursive definitions
+func (t *pParse) expandPredicates(fb *rvdef.Monitor, pol rvdef.Policy, components []string, stack []string) ([]string, *ParseError) {
+	var out []string
+	for i := 0; i < len(components); i++ {
+		name := components[i]
+		open := "("
+		if len(name) > 1 && strings.HasPrefix(name, "!") {
+			name = name[1:]
+			open = "!("
+		}
+		pred := fb.GetPredicate(pol, name)
+		if pred == nil {
+			out = append(out, components[i])
+			continue
+		}
+
+		for j, other := range stack {
+			if other == name {
+				cycle := strings.Join(append(stack[j:], name), " -> ")
+				return nil, t.errorWithArgAndReason(ErrRecursivePredicate, cycle, name+" "+definedAt(pred.DebugInfo))
+			}
+		}
+
+		//collect the arguments
+		var args [][]string
+		if i+1 < len(components) && components[i+1] == pOpenParen {
+			end, err := t.splitPredicateArgs(components, i+1, &args)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		}
+		if len(args) != len(pred.Params) {
+			return nil, t.errorWithArgAndReason(ErrInvalidPredicate, name, fmt.Sprintf("Expected %d argument(s) but got %d, %s", len(pred.Params), len(args), definedAt(pred.DebugInfo)))
+		}
+		for j := range args {
+			arg, err := t.expandPredicates(fb, pol, args[j], stack)
+			if err != nil {
+				return nil, err
+			}
+			args[j] = arg
+		}
+
+		//substitute the arguments into the body
+		var body []string
+		for _, s := range strings.Fields(pred.Body) {
+			body = append(body, substituteParam(s, pred.Params, args)...)
+		}
+		body, err := t.expandPredicates(fb, pol, body, append(stack, name))
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, open)
+		out = append(out, body...)
+		out = append(out, pCloseParen)
+	}
+	return out, nil
+}
+
+//splitPredicateArgs reads the bracketed, comma-separated arguments starting at components[start] into args,
+// and returns the index of the closing bracket
+func (t *pParse) splitPredicateArgs(components []string, start int, args *[][]string) (int, *ParseError) {
+	depth := 0
+	var arg []string
+	for i := start + 1; i < len(components); i++ {
+		s := components[i]
+		if strings.HasSuffix(s, pOpenParen) {
+			depth++
+		} else if s == pCloseParen {
+			if depth == 0 {
+				if len(arg) == 0 {
+					if len(*args) == 0 { //no arguments at all
+						return i, nil
+					}
+					return 0, t.errorUnexpectedWithExpected(s, "argument")
+				}
+				*args = append(*args, arg)
+				return i, nil
+			}
+			depth--
+		} else if s == pComma && depth == 0 {
+			if len(arg) == 0 {
+				return 0, t.errorUnexpectedWithExpected(s, "argument")
+			}
+			*args = append(*args, arg)
+			arg = nil
+			continue
+		}
+		arg = append(arg, s)
+	}
+	return 0, t.errorUnexpectedWithExpected("", pCloseParen)
+}
+
+//substituteParam returns the components that s should be replaced with if it refers to one of params
+func substituteParam(s string, params []string, args [][]string) []string {
+	neg := false
+	name := s
+	if len(s) > 1 && strings.HasPrefix(s, "!") {
+		neg = true
+		name = s[1:]
+	}
+	root, rest := rvdef.SplitAccessPath(name)
+	for i, param := range params {
+		if param != root {
+			continue
+		}
+		arg := args[i]
+		if rest != "" {
+			//a member of the argument is being accessed, which only makes sense for single-item arguments
+			if len(arg) != 1 {
+				break
+			}
+			arg = []string{arg[0] + rest}
+		}
+		if neg && len(arg) == 1 && !strings.HasPrefix(arg[0], "!") {
+			return []string{"!" + arg[0]}
+		}
+		if neg {
+			return append(append([]string{"!("}, arg...), pCloseParen)
+		}
+		if len(arg) == 1 {
+			return arg
+		}
+		return append(append([]string{pOpenParen}, arg...), pCloseParen)
+	}
+	return []string{s}
+}
+
+//definedAt describes where something (e.g. a predicate) was defined, for use in error messages
+func definedAt(d rvdef.DebugInfo) string {
+	if d.SourceFile != "" {
+		return fmt.Sprintf("defined at %s, Line %d", d.SourceFile, d.SourceLine)
+	}
+	return fmt.Sprintf("defined at Line %d", d.SourceLine)
+}
@@ -0,0 +1,195 @@
+package rvparser
+
+import (
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+var predicateTests = []ParseTest{
+	{
+		Name: "monitor and policy predicates",
+		Input: `monitor pizza;
+				interface of pizza {
+					uint32_t t;
+					predicate hot(lo) := t >= lo;
+				}
+				policy P of pizza {
+					states {
+						s0 accepting {
+							-> s0 on fresh && !hot(50);
+						}
+					}
+					predicate fresh := t < 10 || t > 20;
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "pizza",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "t", Type: "uint32_t"},
+				},
+				Predicates: []rvdef.Predicate{
+					rvdef.Predicate{Name: "hot", Params: []string{"lo"}, Body: "t >= lo", DebugInfo: rvdef.DebugInfo{SourceLine: 4, SourceFile: "Test[0]"}},
+				},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name: "P",
+						Predicates: []rvdef.Predicate{
+							rvdef.Predicate{Name: "fresh", Body: "t < 10 or t > 20", DebugInfo: rvdef.DebugInfo{SourceLine: 12, SourceFile: "Test[0]"}},
+						},
+						States: []rvdef.PState{{Name: "s0", Accepting: true}},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "( t < 10 or t > 20 ) and !( t >= 50 )", DebugInfo: rvdef.DebugInfo{SourceLine: 9, SourceFile: "Test[0]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "nested predicates with expression arguments",
+		Input: `monitor m;
+				interface of m {
+					int16_t a, b;
+				}
+				policy P of m {
+					predicate near(x, y) := x - y < 5 and y - x < 5;
+					predicate close := near(a + 1, b);
+					states {
+						s0 accepting {
+							-> s0 on close;
+						}
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "m",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "a", Type: "int16_t"},
+					rvdef.Variable{Name: "b", Type: "int16_t"},
+				},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name: "P",
+						Predicates: []rvdef.Predicate{
+							rvdef.Predicate{Name: "near", Params: []string{"x", "y"}, Body: "x - y < 5 and y - x < 5", DebugInfo: rvdef.DebugInfo{SourceLine: 6, SourceFile: "Test[1]"}},
+							rvdef.Predicate{Name: "close", Body: "near ( a + 1 , b )", DebugInfo: rvdef.DebugInfo{SourceLine: 7, SourceFile: "Test[1]"}},
+						},
+						States: []rvdef.PState{{Name: "s0", Accepting: true}},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "( ( ( a + 1 ) - b < 5 and b - ( a + 1 ) < 5 ) )", DebugInfo: rvdef.DebugInfo{SourceLine: 10, SourceFile: "Test[1]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "recursive predicate",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+					predicate p := a and q;
+				}
+				policy P of m {
+					predicate q := !p;
+					states {
+						s0 accepting {
+							-> s0 on q;
+						}
+					}
+				}`,
+		Err: ErrRecursivePredicate,
+	},
+	{
+		Name: "self recursive predicate",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+				}
+				policy P of m {
+					predicate p := a or p;
+					states {
+						s0 accepting {
+							-> s0 on p;
+						}
+					}
+				}`,
+		Err: ErrRecursivePredicate,
+	},
+	{
+		Name: "unused recursive predicate",
+		Input: `monitor m;
+				interface of m {
+					bool A;
+					predicate loop := A and loop;
+				}`,
+		Err: ErrRecursivePredicate,
+	},
+	{
+		Name: "unused recursive policy predicate",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+				}
+				policy P of m {
+					predicate p(x) := x and q(x);
+					predicate q(y) := !p(y);
+					states {
+						s0 accepting {
+							-> s0 on a;
+						}
+					}
+				}`,
+		Err: ErrRecursivePredicate,
+	},
+	{
+		Name: "wrong number of arguments",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+					predicate both(x, y) := x and y;
+				}
+				policy P of m {
+					states {
+						s0 accepting {
+							-> s0 on both(a);
+						}
+					}
+				}`,
+		Err: ErrInvalidPredicate,
+	},
+	{
+		Name: "duplicate predicate",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+					predicate p := a;
+				}
+				policy P of m {
+					predicate p := !a;
+				}`,
+		Err: ErrNameAlreadyInUse,
+	},
+	{
+		Name: "missing predicate body",
+		Input: `monitor m;
+				interface of m {
+					bool a;
+					predicate p := ;
+				}`,
+		Err: ErrInvalidPredicate,
+	},
+}
+
+func TestParsePredicate(t *testing.T) {
+	runParseTests(t, predicateTests)
+}
+
+func TestParseRecursivePredicateLine(t *testing.T) {
+	//the error is reported where the predicate is defined, even though it isn't used
+	_, err := ParseString("Test", "monitor m;\ninterface of m {\n\tbool A;\n\tpredicate loop := A and loop;\n}\n")
+	if err == nil || err.Err != ErrRecursivePredicate || err.LineNumber != 4 || err.SourceFile != "Test" {
+		t.Errorf("Error was %v, it should have been a recursive predicate at Test, Line 4", err)
+	}
+}
@@ -0,0 +1,120 @@
+package rvparser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//maxQuantifierRange is the largest number of values that a single quantifier may be unrolled over
+const maxQuantifierRange = 4096
+
+//expandQuantifiers unrolls all bounded quantifiers in the components of a guard, e.g.
+// forall i in 0..2: a[i] > 0
+//becomes
+// ( ( a [ 0 ] > 0 ) and ( a [ 1 ] > 0 ) and ( a [ 2 ] > 0 ) )
+//and "exists" does the same with "or".
+//Ranges are inclusive, and their bounds must be integers or integer constants of the policy.
+//The body of a quantifier extends as far right as possible (i.e. to the end of the enclosing brackets, or the end of the guard)
+func (t *pParse) expandQuantifiers(fbIndex int, components []string) ([]string, *ParseError) {
+	var out []string
+	for i := 0; i < len(components); i++ {
+		quant := components[i]
+		if quant != pForall && quant != pExists {
+			out = append(out, quant)
+			continue
+		}
+
+		//the format is <forall|exists> <name> in <lo>..<hi>: <body>
+		if i+7 >= len(components) {
+			return nil, t.errorWithArgAndReason(ErrInvalidQuantifier, quant, "Expected: "+quant+" <name> "+pIn+" <lo>"+pRange+"<hi>"+pColon+" <guard>")
+		}
+		name := components[i+1]
+		if !rvdef.IsIdentifier(name) {
+			return nil, t.errorWithArgAndReason(ErrInvalidQuantifier, name, "Expected variable name")
+		}
+		if components[i+2] != pIn {
+			return nil, t.errorUnexpectedWithExpected(components[i+2], pIn)
+		}
+		if components[i+4] != pRange {
+			return nil, t.errorUnexpectedWithExpected(components[i+4], pRange)
+		}
+		if components[i+6] != pColon {
+			return nil, t.errorUnexpectedWithExpected(components[i+6], pColon)
+		}
+		lo, ok := t.getIntegerConstant(fbIndex, components[i+3])
+		if !ok {
+			return nil, t.errorWithArgAndReason(ErrInvalidQuantifier, components[i+3], "Range bounds must be integers or integer constants")
+		}
+		hi, ok := t.getIntegerConstant(fbIndex, components[i+5])
+		if !ok {
+			return nil, t.errorWithArgAndReason(ErrInvalidQuantifier, components[i+5], "Range bounds must be integers or integer constants")
+		}
+		if hi-lo >= maxQuantifierRange {
+			return nil, t.errorWithArgAndReason(ErrInvalidQuantifier, components[i+3]+pRange+components[i+5], "Range is too large to unroll")
+		}
+
+		//find the end of the body
+		depth := 0
+		end := i + 7
+		for ; end < len(components); end++ {
+			if strings.HasSuffix(components[end], "(") {
+				depth++
+			} else if components[end] == ")" {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		body, err := t.expandQuantifiers(fbIndex, components[i+7:end])
+		if err != nil {
+			return nil, err
+		}
+		if len(body) == 0 {
+			return nil, t.errorWithArgAndReason(ErrInvalidQuantifier, quant, "Missing guard")
+		}
+
+		combinator := "and"
+		empty := "true"
+		if quant == pExists {
+			combinator = "or"
+			empty = "false"
+		}
+
+		out = append(out, "(")
+		if lo > hi {
+			out = append(out, empty)
+		}
+		for v := lo; v <= hi; v++ {
+			if v > lo {
+				out = append(out, combinator)
+			}
+			val := strconv.Itoa(v)
+			out = append(out, "(")
+			for _, s := range body {
+				if s == name {
+					s = val
+				} else if s == "!"+name {
+					s = "!" + val
+				}
+				out = append(out, s)
+			}
+			out = append(out, ")")
+		}
+		out = append(out, ")")
+
+		i = end - 1
+	}
+	return out, nil
+}
+
+//getIntegerConstant returns the value of s if it is an integer, or the name of an integer constant in the current policy
+func (t *pParse) getIntegerConstant(fbIndex int, s string) (int, bool) {
+	fb := &t.funcs[fbIndex]
+	if len(fb.Policies) == 0 {
+		return 0, false
+	}
+	return fb.GetIntegerConstant(fb.Policies[len(fb.Policies)-1], s)
+}
@@ -0,0 +1,90 @@
+package rvparser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//quantifierPolicy returns a policy with a single transition on the given guard
+func quantifierPolicy(guard string) string {
+	return `monitor arr;
+			interface of arr {
+				int16_t[4] sensors;
+				bool[4] ok;
+			}
+			policy P of arr {
+				internals {
+					constant uint8_t N := 2;
+				}
+				states {
+					s0 accepting {
+						-> s0 on ` + guard + `;
+					}
+				}
+			}`
+}
+
+//quantifierOutput returns the parsed version of quantifierPolicy with the given condition, as the test with the given index
+func quantifierOutput(test int, cond string) []rvdef.Monitor {
+	return []rvdef.Monitor{
+		rvdef.Monitor{
+			Name: "arr",
+			InterfaceList: []rvdef.Variable{
+				rvdef.Variable{Name: "sensors", Type: "int16_t", ArraySize: "4"},
+				rvdef.Variable{Name: "ok", Type: "bool", ArraySize: "4"},
+			},
+			Policies: []rvdef.Policy{
+				rvdef.Policy{
+					Name:         "P",
+					InternalVars: []rvdef.Variable{rvdef.Variable{Name: "N", Type: "uint8_t", Constant: true, InitialValue: "2"}},
+					States:       []rvdef.PState{{Name: "s0", Accepting: true}},
+					Transitions:  []rvdef.PTransition{{Source: "s0", Destination: "s0", Condition: cond, DebugInfo: rvdef.DebugInfo{SourceLine: 12, SourceFile: fmt.Sprintf("Test[%d]", test)}}},
+				},
+			},
+		},
+	}
+}
+
+var quantifierTests = []ParseTest{
+	{
+		Name:   "forall",
+		Input:  quantifierPolicy("forall i in 0..N: sensors[i] < 5"),
+		Output: quantifierOutput(0, "( ( sensors [ 0 ] < 5 ) and ( sensors [ 1 ] < 5 ) and ( sensors [ 2 ] < 5 ) )"),
+	},
+	{
+		Name:   "exists in brackets",
+		Input:  quantifierPolicy("(exists i in 1..2: !ok[i]) and ok[0]"),
+		Output: quantifierOutput(1, "( ( ( !ok [ 1 ] ) or ( !ok [ 2 ] ) ) ) and ok [ 0 ]"),
+	},
+	{
+		Name:   "nested",
+		Input:  quantifierPolicy("forall i in 0..1: exists j in 0..1: sensors[i] = sensors[j]"),
+		Output: quantifierOutput(2, "( ( ( ( sensors [ 0 ] = sensors [ 0 ] ) or ( sensors [ 0 ] = sensors [ 1 ] ) ) ) and ( ( ( sensors [ 1 ] = sensors [ 0 ] ) or ( sensors [ 1 ] = sensors [ 1 ] ) ) ) )"),
+	},
+	{
+		Name:   "empty range",
+		Input:  quantifierPolicy("exists i in 3..2: ok[i]"),
+		Output: quantifierOutput(3, "( false )"),
+	},
+	{
+		Name:  "unknown bound",
+		Input: quantifierPolicy("forall i in 0..M: ok[i]"),
+		Err:   ErrInvalidQuantifier,
+	},
+	{
+		Name:  "missing in",
+		Input: quantifierPolicy("forall i of 0..2: ok[i]"),
+		Err:   ErrUnexpectedValue,
+	},
+	{
+		Name:  "missing body",
+		Input: quantifierPolicy("(forall i in 0..2:) and ok[0]"),
+		Err:   ErrInvalidQuantifier,
+	},
+}
+
+func TestParseQuantifier(t *testing.T) {
+	runParseTests(t, quantifierTests)
+}
@@ -0,0 +1,102 @@
+package rvparser
+
+import (
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//structType is a record type that has been declared with "struct"
+type structType struct {
+	name   string
+	fields []rvdef.Variable
+}
+
+//parseStruct shall only be called once we have already parsed the "struct" keyword
+// so, we are up to the type name
+//the format is
+// struct <name> {
+//     <type>[[size]] <field>[, <field>...];
+//     ...
+// };
+//fields can be of any type (including other structs) that has already been declared
+func (t *pParse) parseStruct() *ParseError {
+	name := t.pop()
+	if name == "" {
+		return t.error(ErrUnexpectedEOF)
+	}
+	if !t.isTypeNameUnused(name) {
+		return t.errorWithArg(ErrNameAlreadyInUse, name)
+	}
+
+	if s := t.pop(); s != pOpenBrace {
+		return t.errorUnexpectedWithExpected(s, pOpenBrace)
+	}
+
+	st := structType{name: name}
+	for {
+		s := t.peek()
+		if s == "" {
+			return t.error(ErrUnexpectedEOF)
+		}
+		if s == pCloseBrace {
+			t.pop()
+			break
+		}
+		if err := t.parseStructField(&st); err != nil {
+			return err
+		}
+	}
+
+	if len(st.fields) == 0 {
+		return t.errorWithArgAndReason(ErrInvalidType, name, "structs must have at least one field")
+	}
+
+	if s := t.pop(); s != pSemicolon {
+		return t.errorUnexpectedWithExpected(s, pSemicolon)
+	}
+
+	t.structs = append(t.structs, st)
+	return nil
+}
+
+//parseStructField parses a single line of fields and adds them to st
+func (t *pParse) parseStructField(st *structType) *ParseError {
+	typ := t.pop()
+	var fields []rvdef.Variable
+	if other := t.getStruct(typ); other != nil {
+		fields = other.fields
+	} else if !isValidType(typ) && t.getEnum(typ) == nil {
+		return t.errorWithArgAndReason(ErrInvalidType, typ, "Expected valid type")
+	}
+
+	//there might be an array size next
+	size := ""
+	if t.peek() == pOpenBracket {
+		t.pop() // get rid of open bracket
+		size = t.pop()
+		if s := t.peek(); s != pCloseBracket {
+			return t.errorUnexpectedWithExpected(s, pCloseBracket)
+		}
+		t.pop() //get rid of close bracket
+	}
+
+	for {
+		name := t.pop()
+		for _, field := range st.fields {
+			if field.Name == name {
+				return t.errorWithArg(ErrNameAlreadyInUse, name)
+			}
+		}
+		st.fields = append(st.fields, rvdef.Variable{Name: name, Type: typ, ArraySize: size, Fields: fields})
+
+		if t.peek() == pComma {
+			t.pop() //get rid of the pComma
+			continue
+		}
+		break
+	}
+
+	if s := t.pop(); s != pSemicolon {
+		return t.errorUnexpectedWithExpected(s, pSemicolon)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package rvparser
+
+import (
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+var vecFields = []rvdef.Variable{
+	rvdef.Variable{Name: "x", Type: "int16_t"},
+	rvdef.Variable{Name: "y", Type: "int16_t"},
+}
+
+var structTests = []ParseTest{
+	{
+		Name: "nested struct in interface",
+		Input: `struct vec_t { int16_t x, y; };
+				monitor ctrl;
+				interface of ctrl {
+					struct packet_t {
+						uint8_t id;
+						vec_t pos;
+						bool[4] flags;
+					};
+					packet_t pkt;
+				}
+				policy P of ctrl {
+					internals {
+						vec_t last;
+					}
+					states {
+						s0 accepting {
+							-> s0 on pkt.pos.x > 80 and !pkt.flags[2]: last.x := pkt.pos.x;
+						}
+					}
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "ctrl",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "pkt", Type: "packet_t", Fields: []rvdef.Variable{
+						rvdef.Variable{Name: "id", Type: "uint8_t"},
+						rvdef.Variable{Name: "pos", Type: "vec_t", Fields: vecFields},
+						rvdef.Variable{Name: "flags", Type: "bool", ArraySize: "4"},
+					}},
+				},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name: "P",
+						InternalVars: []rvdef.Variable{
+							rvdef.Variable{Name: "last", Type: "vec_t", Fields: vecFields},
+						},
+						States: []rvdef.PState{{Name: "s0", Accepting: true}},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "pkt.pos.x > 80 and !pkt.flags [ 2 ]", Expressions: []rvdef.PExpression{{VarName: "last.x", Value: "pkt.pos.x"}}, DebugInfo: rvdef.DebugInfo{SourceLine: 17, SourceFile: "Test[0]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name: "struct with enum field",
+		Input: `enum Mode { IDLE, HEATING };
+				struct status_t { Mode mode; };
+				monitor ctrl;
+				interface of ctrl {
+					status_t status;
+				}`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name:  "ctrl",
+				Enums: []rvdef.Enum{{Name: "Mode", Members: []string{"IDLE", "HEATING"}}},
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "status", Type: "status_t", Fields: []rvdef.Variable{{Name: "mode", Type: "Mode"}}},
+				},
+			},
+		},
+	},
+	{
+		Name:  "struct with unknown field type",
+		Input: `struct vec_t { asdasd x; };`,
+		Err:   ErrInvalidType,
+	},
+	{
+		Name:  "struct with no fields",
+		Input: `struct vec_t { };`,
+		Err:   ErrInvalidType,
+	},
+	{
+		Name:  "struct with repeated field",
+		Input: `struct vec_t { int16_t x; int16_t x; };`,
+		Err:   ErrNameAlreadyInUse,
+	},
+	{
+		Name: "struct name reused",
+		Input: `enum vec_t { A, B };
+				struct vec_t { int16_t x; };`,
+		Err: ErrNameAlreadyInUse,
+	},
+	{
+		Name: "struct with initial value",
+		Input: `struct vec_t { int16_t x; };
+				monitor ctrl;
+				interface of ctrl {
+					vec_t v := 0;
+				}`,
+		Err: ErrInvalidIOMeta,
+	},
+}
+
+func TestParseStruct(t *testing.T) {
+	runParseTests(t, structTests)
+}
@@ -0,0 +1,234 @@
+package rvparser
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+//policyTemplate is a policy with formal parameters, which can be instantiated against any monitor
+type policyTemplate struct {
+	rvdef.DebugInfo //where the template was defined
+	name            string
+	params          []string
+	body            []string //the items of the policy body, from the open brace to the close brace (without newlines)
+	internals       []string //the names of the internals (and constants) declared in body
+}
+
+//getTemplate will search the pParse slice of templates for one that matches
+// the provided name and return it if found
+func (t *pParse) getTemplate(name string) *policyTemplate {
+	for i := 0; i < len(t.templates); i++ {
+		if t.templates[i].name == name {
+			return &t.templates[i]
+		}
+	}
+	return nil
+}
+
+//parseTemplate shall only be called once we have already parsed the "template" keyword
+// so, we are up to the template name
+//the format is
+// template <name>(<param>[, <param>...]) { <policy body> }
+func (t *pParse) parseTemplate() *ParseError {
+	tmpl := policyTemplate{DebugInfo: t.getCurrentDebugInfo()}
+
+	tmpl.name = t.pop()
+	if tmpl.name == "" {
+		return t.error(ErrUnexpectedEOF)
+	}
+	if !rvdef.IsIdentifier(tmpl.name) {
+		return t.errorWithArgAndReason(ErrInvalidTemplate, tmpl.name, "Expected template name")
+	}
+	if t.getTemplate(tmpl.name) != nil {
+		return t.errorWithArg(ErrNameAlreadyInUse, tmpl.name)
+	}
+
+	//next are the parameters
+	if s := t.pop(); s != pOpenParen {
+		return t.errorUnexpectedWithExpected(s, pOpenParen)
+	}
+	if t.peek() == pCloseParen {
+		t.pop()
+	} else {
+		for {
+			param := t.pop()
+			if param == "" {
+				return t.error(ErrUnexpectedEOF)
+			}
+			if !rvdef.IsIdentifier(param) {
+				return t.errorWithArgAndReason(ErrInvalidTemplate, param, "Expected parameter name")
+			}
+			for _, other := range tmpl.params {
+				if other == param {
+					return t.errorWithArg(ErrNameAlreadyInUse, param)
+				}
+			}
+			tmpl.params = append(tmpl.params, param)
+
+			s := t.pop()
+			if s == pCloseParen {
+				break
+			}
+			if s != pComma {
+				return t.errorUnexpectedWithExpected(s, "Either '"+pComma+"' or '"+pCloseParen+"'")
+			}
+		}
+	}
+
+	//now we store the body, which will be parsed whenever the template is instantiated
+	if s := t.peek(); s != pOpenBrace {
+		return t.errorUnexpectedWithExpected(s, pOpenBrace)
+	}
+	depth := 0
+	for {
+		s := t.pop()
+		if s == "" {
+			return t.error(ErrUnexpectedEOF)
+		}
+		tmpl.body = append(tmpl.body, s)
+		if s == pOpenBrace {
+			depth++
+		} else if s == pCloseBrace {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+
+	tmpl.internals = templateInternals(tmpl.body)
+	t.templates = append(t.templates, tmpl)
+	return nil
+}
+
+//templateInternals returns the names of the internals declared in the body of a template
+//the format of each declaration is the same as in parsePInternal, i.e.
+// [constant] <type>[[<size>]] <name>[, <name>...] [:= <value>];
+func templateInternals(body []string) []string {
+	var names []string
+	depth := 0
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case pOpenBrace:
+			depth++
+			continue
+		case pCloseBrace:
+			depth--
+			continue
+		}
+		if depth != 1 || (body[i] != pInternal && body[i] != pInternals) {
+			continue
+		}
+
+		//either a single declaration, or a brace with many of them
+		braced := i+1 < len(body) && body[i+1] == pOpenBrace
+		if braced {
+			i++
+		}
+		for i+1 < len(body) && body[i+1] != pCloseBrace {
+			i++
+			if body[i] == pConstant {
+				i++
+			}
+			i++ //skip the type
+			if i < len(body) && body[i] == pOpenBracket {
+				for i < len(body) && body[i] != pCloseBracket {
+					i++
+				}
+				i++
+			}
+			for i < len(body) {
+				names = append(names, body[i])
+				if i+1 < len(body) && body[i+1] == pComma {
+					i += 2
+					continue
+				}
+				break
+			}
+			for i < len(body) && body[i] != pSemicolon {
+				i++
+			}
+			if !braced {
+				break
+			}
+		}
+		if braced {
+			i++ //skip the close brace
+		}
+	}
+	return names
+}
+
+//parsePolicyInstance shall only be called once we have already parsed the
+// "policy [name] of [monitor] =" part of the definition
+// so, we are up to the template name
+//the format is
+// policy <name> of <monitor> = <template>(<arg>[, <arg>...]);
+func (t *pParse) parsePolicyInstance(fbIndex int, pName string) *ParseError {
+	name := t.pop()
+	if name == "" {
+		return t.error(ErrUnexpectedEOF)
+	}
+	tmpl := t.getTemplate(name)
+	if tmpl == nil {
+		return t.errorWithArg(ErrUndefinedTemplate, name)
+	}
+
+	//collect the arguments, which are ended by the semicolon
+	var components []string
+	for {
+		s := t.pop()
+		if s == "" {
+			return t.error(ErrUnexpectedEOF)
+		}
+		if s == pSemicolon {
+			break
+		}
+		components = append(components, s)
+	}
+	if len(components) == 0 || components[0] != pOpenParen {
+		return t.errorUnexpectedWithExpected(strings.Join(components, " "), pOpenParen)
+	}
+	var args [][]string
+	end, err := t.splitPredicateArgs(components, 0, &args)
+	if err != nil {
+		return err
+	}
+	if end != len(components)-1 {
+		return t.errorUnexpectedWithExpected(components[end+1], pSemicolon)
+	}
+	if len(args) != len(tmpl.params) {
+		return t.errorWithArgAndReason(ErrInvalidTemplate, name, fmt.Sprintf("Expected %d argument(s) but got %d, %s", len(tmpl.params), len(args), definedAt(tmpl.DebugInfo)))
+	}
+
+	//each instance gets its own copy of the internals (and constants), so that instances don't clash in the monitor
+	//they are renamed before the arguments are substituted, so that an argument with the same name as an internal isn't renamed too
+	var body []string
+	for _, s := range tmpl.body {
+		body = append(body, substituteParam(renameInternal(s, tmpl.internals, pName+"_"), tmpl.params, args)...)
+	}
+	t.items = append(t.items[:t.itemIndex], append(body, t.items[t.itemIndex:]...)...)
+
+	t.funcs[fbIndex].AddPolicy(pName)
+	return t.parsePolicyArchitecture(fbIndex)
+}
+
+//renameInternal adds prefix to an item of a template's body if it names one of the internals
+//(the item can also be negated, or access a member of the internal, like the items that substituteParam replaces)
+func renameInternal(s string, internals []string, prefix string) string {
+	neg := ""
+	name := s
+	if len(s) > 1 && strings.HasPrefix(s, "!") {
+		neg = "!"
+		name = s[1:]
+	}
+	root, _ := rvdef.SplitAccessPath(name)
+	for _, internal := range internals {
+		if internal == root {
+			return neg + prefix + name
+		}
+	}
+	return s
+}
@@ -0,0 +1,169 @@
+package rvparser
+
+import (
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+)
+
+const deadlineTemplate = `template Deadline(A, B, N) {
+					internals {
+						dtimer_t v;
+						constant uint32_t DEADLINE := N;
+					}
+					states {
+						s0 accepting {
+							-> s0 on !A: v := 0;
+							-> s1 on A: v := 0;
+						}
+						s1 rejecting {
+							-> s0 on B;
+							-> s1 on v < DEADLINE;
+						}
+					}
+				}
+				monitor ab;
+				interface of ab {
+					bool A, B, C, D;
+				}
+				`
+
+//boundedResponsePolicy returns the policy that results from instantiating deadlineTemplate on the given line of file
+func boundedResponsePolicy(name string, a string, b string, n string, file string, line int) rvdef.Policy {
+	debug := rvdef.DebugInfo{SourceLine: line, SourceFile: file}
+	return rvdef.Policy{
+		Name: name,
+		InternalVars: []rvdef.Variable{
+			rvdef.Variable{Name: name + "_v", Type: "dtimer_t"},
+			rvdef.Variable{Name: name + "_DEADLINE", Type: "uint32_t", Constant: true, InitialValue: n},
+		},
+		States: []rvdef.PState{{Name: "s0", Accepting: true}, {Name: "s1", Accepting: false}},
+		Transitions: []rvdef.PTransition{
+			rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "!" + a, Expressions: []rvdef.PExpression{{VarName: name + "_v", Value: "0"}}, DebugInfo: debug},
+			rvdef.PTransition{Source: "s0", Destination: "s1", Condition: a, Expressions: []rvdef.PExpression{{VarName: name + "_v", Value: "0"}}, DebugInfo: debug},
+			rvdef.PTransition{Source: "s1", Destination: "s0", Condition: b, DebugInfo: debug},
+			rvdef.PTransition{Source: "s1", Destination: "s1", Condition: name + "_v < " + name + "_DEADLINE", DebugInfo: debug},
+		},
+	}
+}
+
+var templateTests = []ParseTest{
+	{
+		Name: "two instances",
+		Input: deadlineTemplate + `
+				policy AB5 of ab = Deadline(A, B, 5);
+				policy CD10 of ab = Deadline(C, D, 10);`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "ab",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "A", Type: "bool"},
+					rvdef.Variable{Name: "B", Type: "bool"},
+					rvdef.Variable{Name: "C", Type: "bool"},
+					rvdef.Variable{Name: "D", Type: "bool"},
+				},
+				Policies: []rvdef.Policy{
+					boundedResponsePolicy("AB5", "A", "B", "5", "Test[0]", 22),
+					boundedResponsePolicy("CD10", "C", "D", "10", "Test[0]", 23),
+				},
+			},
+		},
+	},
+	{
+		Name: "argument named like an internal",
+		Input: deadlineTemplate + `
+				monitor vw;
+				interface of vw {
+					bool v, w;
+				}
+				policy VW of vw = Deadline(v, w, 3);`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name: "ab",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "A", Type: "bool"},
+					rvdef.Variable{Name: "B", Type: "bool"},
+					rvdef.Variable{Name: "C", Type: "bool"},
+					rvdef.Variable{Name: "D", Type: "bool"},
+				},
+			},
+			rvdef.Monitor{
+				Name: "vw",
+				InterfaceList: []rvdef.Variable{
+					rvdef.Variable{Name: "v", Type: "bool"},
+					rvdef.Variable{Name: "w", Type: "bool"},
+				},
+				Policies: []rvdef.Policy{
+					boundedResponsePolicy("VW", "v", "w", "3", "Test[1]", 26),
+				},
+			},
+		},
+	},
+	{
+		Name: "internal in an initial condition",
+		Input: `template Guarded(A) {
+					internals {
+						uint8_t k := 3;
+					}
+					states {
+						initial s0 accepting on k > 2 else bad {
+							-> s0 on A;
+						}
+						bad rejecting trap;
+					}
+				}
+				monitor ab;
+				interface of ab {
+					bool A;
+				}
+				policy G of ab = Guarded(A);`,
+		Output: []rvdef.Monitor{
+			rvdef.Monitor{
+				Name:          "ab",
+				InterfaceList: []rvdef.Variable{rvdef.Variable{Name: "A", Type: "bool"}},
+				Policies: []rvdef.Policy{
+					rvdef.Policy{
+						Name:         "G",
+						InternalVars: []rvdef.Variable{rvdef.Variable{Name: "G_k", Type: "uint8_t", InitialValue: "3"}},
+						States: []rvdef.PState{
+							{Name: "s0", Accepting: true, Initial: true, InitialCondition: "G_k > 2", InitialElse: "bad"},
+							{Name: "bad", Accepting: false},
+						},
+						Transitions: []rvdef.PTransition{
+							rvdef.PTransition{Source: "s0", Destination: "s0", Condition: "A", DebugInfo: rvdef.DebugInfo{SourceLine: 16, SourceFile: "Test[2]"}},
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:  "undefined template",
+		Input: deadlineTemplate + `policy AB5 of ab = Deadlin(A, B, 5);`,
+		Err:   ErrUndefinedTemplate,
+	},
+	{
+		Name:  "wrong number of arguments",
+		Input: deadlineTemplate + `policy AB5 of ab = Deadline(A, B);`,
+		Err:   ErrInvalidTemplate,
+	},
+	{
+		Name:  "missing semicolon",
+		Input: deadlineTemplate + `policy AB5 of ab = Deadline(A, B, 5) extra;`,
+		Err:   ErrUnexpectedValue,
+	},
+	{
+		Name:  "duplicate template",
+		Input: deadlineTemplate + `template Deadline() { }`,
+		Err:   ErrNameAlreadyInUse,
+	},
+	{
+		Name:  "unterminated template",
+		Input: `template T(A) { states { s0 accepting trap; }`,
+		Err:   ErrUnexpectedEOF,
+	},
+}
+
+func TestParseTemplate(t *testing.T) {
+	runParseTests(t, templateTests)
+}
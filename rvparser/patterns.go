@@ -0,0 +1,21 @@
+package rvparser
+
+import (
+	_ "embed" //for the pattern library
+)
+
+//patternLibrary is the source of the built-in specification pattern library, which is a set of policy templates
+//go:embed patterns.erv
+var patternLibrary string
+
+//patternLibraryName is used as the file name of the pattern library in error messages
+const patternLibraryName = "<patterns>"
+
+//builtinTemplates returns the policy templates of the built-in specification pattern library
+func builtinTemplates() ([]policyTemplate, *ParseError) {
+	t := pParse{items: scanString(patternLibraryName, patternLibrary), currentLine: 1, currentFile: patternLibraryName}
+	if err := t.parseAll(); err != nil {
+		return nil, err
+	}
+	return t.templates, nil
+}
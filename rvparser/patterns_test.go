@@ -0,0 +1,318 @@
+package rvparser
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/PRETgroup/easy-rv/rvdef"
+	"github.com/PRETgroup/stcompilerlib"
+)
+
+//verdicts, as returned by the check_rv_status functions of the generated C
+const (
+	vTrue           = 0 //satisfied, and will stay satisfied
+	vCurrentlyTrue  = 1
+	vCurrentlyFalse = 2
+	vFalse          = 3 //violated, and will stay violated
+)
+
+//patternTest runs a trace through an instance of a pattern, and checks the verdict at the end of it
+type patternTest struct {
+	Pattern string   //the instantiation of the pattern, e.g. AbsenceBefore(p, r)
+	Trace   []string //for each tick, the names of the signals (p, q, r, s) that hold, e.g. "pq"
+	Verdict int
+}
+
+var patternTests = []patternTest{
+	{"Absence(p)", []string{"", "q", ""}, vCurrentlyTrue},
+	{"Absence(p)", []string{"", "p", ""}, vFalse},
+
+	{"AbsenceBefore(p, r)", []string{"", "r", "p"}, vTrue},
+	{"AbsenceBefore(p, r)", []string{"", "p", ""}, vCurrentlyTrue},
+	{"AbsenceBefore(p, r)", []string{"", "p", "r"}, vFalse},
+
+	{"AbsenceAfter(p, q)", []string{"p", "", "p"}, vCurrentlyTrue},
+	{"AbsenceAfter(p, q)", []string{"q", ""}, vCurrentlyTrue},
+	{"AbsenceAfter(p, q)", []string{"q", "", "p"}, vFalse},
+	{"AbsenceAfter(p, q)", []string{"pq"}, vFalse},
+
+	{"AbsenceBetween(p, q, r)", []string{"q", "r", "p"}, vCurrentlyTrue},
+	{"AbsenceBetween(p, q, r)", []string{"q", "p", ""}, vCurrentlyTrue},
+	{"AbsenceBetween(p, q, r)", []string{"q", "p", "r"}, vFalse},
+	{"AbsenceBetween(p, q, r)", []string{"p", "r", "qr", "p"}, vCurrentlyTrue},
+
+	{"AbsenceAfterUntil(p, q, r)", []string{"q", "r", "p"}, vCurrentlyTrue},
+	{"AbsenceAfterUntil(p, q, r)", []string{"q", "p"}, vFalse},
+	{"AbsenceAfterUntil(p, q, r)", []string{"q", "pr"}, vCurrentlyTrue},
+
+	{"Existence(p)", []string{"", ""}, vCurrentlyFalse},
+	{"Existence(p)", []string{"", "p", ""}, vTrue},
+
+	{"ExistenceBefore(p, r)", []string{"", ""}, vCurrentlyTrue},
+	{"ExistenceBefore(p, r)", []string{"p", "r"}, vTrue},
+	{"ExistenceBefore(p, r)", []string{"", "pr"}, vFalse},
+
+	{"ExistenceAfter(p, q)", []string{"p", ""}, vCurrentlyTrue},
+	{"ExistenceAfter(p, q)", []string{"p", "q", ""}, vCurrentlyFalse},
+	{"ExistenceAfter(p, q)", []string{"q", "", "p"}, vTrue},
+
+	{"ExistenceBetween(p, q, r)", []string{"q", ""}, vCurrentlyTrue},
+	{"ExistenceBetween(p, q, r)", []string{"q", "", "r"}, vFalse},
+	{"ExistenceBetween(p, q, r)", []string{"q", "p", "r", "q"}, vCurrentlyTrue},
+
+	{"ExistenceAfterUntil(p, q, r)", []string{"q", ""}, vCurrentlyFalse},
+	{"ExistenceAfterUntil(p, q, r)", []string{"q", "p", ""}, vCurrentlyTrue},
+	{"ExistenceAfterUntil(p, q, r)", []string{"q", "r"}, vFalse},
+
+	{"Universality(p)", []string{"p", "p"}, vCurrentlyTrue},
+	{"Universality(p)", []string{"p", "", "p"}, vFalse},
+
+	{"UniversalityBefore(p, r)", []string{"p", "", "p"}, vCurrentlyTrue},
+	{"UniversalityBefore(p, r)", []string{"p", "", "r"}, vFalse},
+	{"UniversalityBefore(p, r)", []string{"p", "r", ""}, vTrue},
+
+	{"UniversalityAfter(p, q)", []string{"", "pq", "p"}, vCurrentlyTrue},
+	{"UniversalityAfter(p, q)", []string{"", "pq", ""}, vFalse},
+
+	{"UniversalityBetween(p, q, r)", []string{"pq", "", "p"}, vCurrentlyTrue},
+	{"UniversalityBetween(p, q, r)", []string{"pq", "", "r"}, vFalse},
+	{"UniversalityBetween(p, q, r)", []string{"pq", "r", ""}, vCurrentlyTrue},
+
+	{"UniversalityAfterUntil(p, q, r)", []string{"pq", "p", "r", ""}, vCurrentlyTrue},
+	{"UniversalityAfterUntil(p, q, r)", []string{"pq", ""}, vFalse},
+
+	{"Precedence(p, s)", []string{"", "s", "p"}, vTrue},
+	{"Precedence(p, s)", []string{"", "ps"}, vTrue},
+	{"Precedence(p, s)", []string{"", "p", "s"}, vFalse},
+	{"Precedence(p, s)", []string{"", ""}, vCurrentlyTrue},
+
+	{"PrecedenceBefore(p, s, r)", []string{"p", ""}, vCurrentlyTrue},
+	{"PrecedenceBefore(p, s, r)", []string{"p", "r"}, vFalse},
+	{"PrecedenceBefore(p, s, r)", []string{"r", "p"}, vTrue},
+
+	{"PrecedenceAfter(p, s, q)", []string{"p", "q", "s", "p"}, vTrue},
+	{"PrecedenceAfter(p, s, q)", []string{"q", "p"}, vFalse},
+
+	{"PrecedenceBetween(p, s, q, r)", []string{"q", "p", ""}, vCurrentlyTrue},
+	{"PrecedenceBetween(p, s, q, r)", []string{"q", "p", "r"}, vFalse},
+	{"PrecedenceBetween(p, s, q, r)", []string{"q", "s", "p", "r", "q", "p"}, vCurrentlyTrue},
+
+	{"PrecedenceAfterUntil(p, s, q, r)", []string{"q", "p"}, vFalse},
+	{"PrecedenceAfterUntil(p, s, q, r)", []string{"q", "r", "p"}, vCurrentlyTrue},
+
+	{"Response(p, s)", []string{"p", ""}, vCurrentlyFalse},
+	{"Response(p, s)", []string{"p", "", "s"}, vCurrentlyTrue},
+	{"Response(p, s)", []string{"ps"}, vCurrentlyTrue},
+
+	{"ResponseBefore(p, s, r)", []string{"p", ""}, vCurrentlyTrue},
+	{"ResponseBefore(p, s, r)", []string{"p", "r"}, vFalse},
+	{"ResponseBefore(p, s, r)", []string{"p", "s", "r"}, vTrue},
+
+	{"ResponseAfter(p, s, q)", []string{"p", ""}, vCurrentlyTrue},
+	{"ResponseAfter(p, s, q)", []string{"pq", ""}, vCurrentlyFalse},
+	{"ResponseAfter(p, s, q)", []string{"q", "p", "s"}, vCurrentlyTrue},
+
+	{"ResponseBetween(p, s, q, r)", []string{"q", "p", ""}, vCurrentlyTrue},
+	{"ResponseBetween(p, s, q, r)", []string{"q", "p", "rs"}, vFalse},
+	{"ResponseBetween(p, s, q, r)", []string{"q", "p", "s", "r"}, vCurrentlyTrue},
+
+	{"ResponseAfterUntil(p, s, q, r)", []string{"q", "p", ""}, vCurrentlyFalse},
+	{"ResponseAfterUntil(p, s, q, r)", []string{"q", "p", "r"}, vFalse},
+
+	{"BoundedResponse(p, s, 2)", []string{"p", "", "s"}, vCurrentlyTrue},
+	{"BoundedResponse(p, s, 2)", []string{"p", ""}, vCurrentlyFalse},
+	{"BoundedResponse(p, s, 2)", []string{"p", "", ""}, vFalse},
+
+	{"BoundedInvariance(p, s, 2)", []string{"ps", "s", ""}, vCurrentlyTrue},
+	{"BoundedInvariance(p, s, 2)", []string{"ps", ""}, vFalse},
+	{"BoundedInvariance(p, s, 2)", []string{"ps", "ps", "s", ""}, vCurrentlyTrue},
+	{"BoundedInvariance(p, s, 2)", []string{"ps", "ps", ""}, vFalse},
+	{"BoundedInvariance(p, s, 2)", []string{"p"}, vFalse},
+}
+
+//parsePattern instantiates pattern against a monitor with the boolean signals p, q, r, and s
+func parsePattern(t *testing.T, pattern string) (rvdef.Monitor, bool) {
+	fbs, err := ParseString("pattern", `monitor m;
+		interface of m {
+			bool p, q, r, s;
+		}
+		policy P of m = `+pattern+`;`)
+	if err != nil {
+		t.Errorf("%s: Error '%s' occurred when it shouldn't have", pattern, err.Error())
+		return rvdef.Monitor{}, false
+	}
+	fbs[0].Policies[0].FinaliseStates()
+	return fbs[0], true
+}
+
+//evalGuard evaluates a guard with the given values of variables (where booleans are 0 or 1)
+func evalGuard(expr stcompilerlib.STExpression, values map[string]int) int {
+	if v := expr.HasValue(); v != "" {
+		if v == "true" {
+			return 1
+		}
+		if v == "false" {
+			return 0
+		}
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+		return values[v]
+	}
+	args := expr.GetArguments() //note that arguments are in reverse order
+	boolToInt := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	switch expr.HasOperator().GetToken() {
+	case "not":
+		return boolToInt(evalGuard(args[0], values) == 0)
+	case "and":
+		return boolToInt(evalGuard(args[1], values) != 0 && evalGuard(args[0], values) != 0)
+	case "or":
+		return boolToInt(evalGuard(args[1], values) != 0 || evalGuard(args[0], values) != 0)
+	case "<":
+		return boolToInt(evalGuard(args[1], values) < evalGuard(args[0], values))
+	case ">=":
+		return boolToInt(evalGuard(args[1], values) >= evalGuard(args[0], values))
+	}
+	panic("unsupported operator " + expr.HasOperator().GetToken())
+}
+
+//step advances the policy by one tick in the same way as the generated C, returning the new state
+func step(t *testing.T, pol rvdef.Policy, state string, values map[string]int) string {
+	for _, v := range pol.InternalVars {
+		if v.IsDTimer() {
+			values[v.Name]++
+		}
+	}
+	for _, tr := range pol.Transitions {
+		if tr.Source != state {
+			continue
+		}
+		cond := pol.GetTransitionCondition(tr)
+		guard, err := rvdef.ParseSTExpression(cond, cond)
+		if err != nil {
+			t.Fatalf("%s: can't parse guard '%s'", pol.Name, cond)
+		}
+		if evalGuard(guard, values) != 0 {
+			for _, ex := range tr.Expressions {
+				values[ex.VarName], _ = strconv.Atoi(ex.Value)
+			}
+			return tr.Destination
+		}
+	}
+	return state
+}
+
+//verdict returns what check_rv_status would return in the given state
+func verdict(pol rvdef.Policy, state string) int {
+	for _, st := range pol.States {
+		if st.Name != state {
+			continue
+		}
+		if st.Accepting && st.FinalStatusType {
+			return vTrue
+		} else if st.Accepting {
+			return vCurrentlyTrue
+		} else if st.FinalStatusType {
+			return vFalse
+		}
+		return vCurrentlyFalse
+	}
+	return -1
+}
+
+func TestPatternVerdicts(t *testing.T) {
+	for i, test := range patternTests {
+		fb, ok := parsePattern(t, test.Pattern)
+		if !ok {
+			continue
+		}
+		pol := fb.Policies[0]
+		state := pol.GetInitialState().Name
+		values := make(map[string]int)
+		for _, tick := range test.Trace {
+			for _, sig := range []string{"p", "q", "r", "s"} {
+				values[sig] = 0
+				if strings.Contains(tick, sig) {
+					values[sig] = 1
+				}
+			}
+			state = step(t, pol, state, values)
+		}
+		if v := verdict(pol, state); v != test.Verdict {
+			t.Errorf("Test[%d](%s on %q): verdict was %d (in state %s), should have been %d", i, test.Pattern, test.Trace, v, state, test.Verdict)
+		}
+	}
+}
+
+//TestPatternsComplete checks that in every pattern, every non-trap state takes a transition for every input
+func TestPatternsComplete(t *testing.T) {
+	templates, err := builtinTemplates()
+	if err != nil {
+		t.Fatalf("Error '%s' occurred when parsing the pattern library", err.Error())
+	}
+	for _, tmpl := range templates {
+		args := make([]string, len(tmpl.params))
+		for i, param := range tmpl.params {
+			args[i] = strings.ToLower(param)
+			if param == "N" {
+				args[i] = "3"
+			}
+		}
+		pattern := tmpl.name + "(" + strings.Join(args, ", ") + ")"
+		fb, ok := parsePattern(t, pattern)
+		if !ok {
+			continue
+		}
+		pol := fb.Policies[0]
+		if st := pol.GetInitialState(); st == nil || !st.Initial || !st.Accepting && tmpl.name != "Existence" {
+			t.Errorf("%s: should have an initial state, which should be accepting", pattern)
+		}
+		for _, st := range pol.States {
+			if isTrap(pol, st.Name) {
+				continue
+			}
+			for inputs := 0; inputs < 16; inputs++ {
+				for _, elapsed := range []int{1, 3, 4} {
+					values := map[string]int{"p": inputs & 1, "q": inputs >> 1 & 1, "r": inputs >> 2 & 1, "s": inputs >> 3 & 1, "P_elapsed": elapsed}
+					if !takesTransition(t, pol, st.Name, values) {
+						t.Errorf("%s: state %s takes no transition for inputs %04b (elapsed %d)", pattern, st.Name, inputs, elapsed)
+					}
+				}
+			}
+		}
+	}
+}
+
+//isTrap returns true if there are no transitions out of state
+func isTrap(pol rvdef.Policy, state string) bool {
+	for _, tr := range pol.Transitions {
+		if tr.Source == state {
+			return false
+		}
+	}
+	return true
+}
+
+//takesTransition returns true if any transition out of state is enabled with the given values
+func takesTransition(t *testing.T, pol rvdef.Policy, state string, values map[string]int) bool {
+	for _, tr := range pol.Transitions {
+		if tr.Source != state {
+			continue
+		}
+		cond := pol.GetTransitionCondition(tr)
+		guard, err := rvdef.ParseSTExpression(cond, cond)
+		if err != nil {
+			t.Fatalf("%s: can't parse guard '%s'", pol.Name, cond)
+		}
+		if evalGuard(guard, values) != 0 {
+			return true
+		}
+	}
+	return false
+}
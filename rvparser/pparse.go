@@ -1,18 +1,32 @@
 package rvparser
 
 import (
+	"strings"
+
 	"github.com/PRETgroup/easy-rv/rvdef"
 )
 
 //pParse is the containing struct for the parsing code
 type pParse struct {
-	funcs []rvdef.Monitor
+	funcs   []rvdef.Monitor
+	enums   []rvdef.Enum
+	structs []structType
+
+	templates []policyTemplate
 
 	items     []string
 	itemIndex int
 
+	pending    []pendingTransition //transitions of the current policy that are yet to be finished
+	composites []compositeState    //composite states of the current policy
+	statePath  []string            //flattened names of the composite states that are currently being parsed
+	initial    *initialState       //the initial state of the current policy
+
 	currentLine int
 	currentFile string
+
+	importStack []string        //absolute paths of the files currently being parsed (for cycle detection)
+	imported    map[string]bool //absolute paths of all files that have been parsed (for de-duplication)
 }
 
 //getCurrentDebugInfo returns the debug info for the last popped item
@@ -68,6 +82,96 @@ func (t *pParse) done() bool {
 	return t.itemIndex >= len(t.items)
 }
 
+//getEnum will search the pParse slice of enums for one that matches
+// the provided name and return it if found
+func (t *pParse) getEnum(name string) *rvdef.Enum {
+	for i := 0; i < len(t.enums); i++ {
+		if t.enums[i].Name == name {
+			return &t.enums[i]
+		}
+	}
+	return nil
+}
+
+//getStruct will search the pParse slice of structs for one that matches
+// the provided name and return it if found
+func (t *pParse) getStruct(name string) *structType {
+	for i := 0; i < len(t.structs); i++ {
+		if t.structs[i].name == name {
+			return &t.structs[i]
+		}
+	}
+	return nil
+}
+
+//isTypeNameUnused returns true if name isn't a built-in type and hasn't been declared as an enum or a struct
+func (t *pParse) isTypeNameUnused(name string) bool {
+	return !isValidType(name) && t.getEnum(name) == nil && t.getStruct(name) == nil
+}
+
+//isVariableName returns true if name is the name of an interface variable, or of an internal or constant of a policy,
+// of any of the registered functions
+func (t *pParse) isVariableName(name string) bool {
+	for _, fb := range t.funcs {
+		for _, v := range fb.InterfaceList {
+			if v.Name == name {
+				return true
+			}
+		}
+		for _, pol := range fb.Policies {
+			for _, v := range pol.InternalVars {
+				if v.Name == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+//isEnumMember returns true if name is a member of any declared enum
+func (t *pParse) isEnumMember(name string) bool {
+	for _, e := range t.enums {
+		if e.HasMember(name) {
+			return true
+		}
+	}
+	return false
+}
+
+//exprKeywords are the words that have a meaning in guards and expressions (in any case), so can't be used as names there
+var exprKeywords = []string{"not", "and", "or", "xor", "mod", "true", "false", pForall, pExists}
+
+//isExprKeyword returns true if name is one of the exprKeywords
+func isExprKeyword(name string) bool {
+	for _, k := range exprKeywords {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+//useType returns true if typ is a valid type for a data variable in the function identified by fbIndex,
+// and, if typ is a struct, it also returns the fields of that struct.
+// enums (including any used by the fields of a struct) are added to the function so that they can be used there
+func (t *pParse) useType(fbIndex int, typ string) ([]rvdef.Variable, bool) {
+	if isValidType(typ) {
+		return nil, true
+	}
+	if e := t.getEnum(typ); e != nil {
+		t.funcs[fbIndex].AddEnum(*e)
+		return nil, true
+	}
+	if st := t.getStruct(typ); st != nil {
+		for _, field := range st.fields {
+			t.useType(fbIndex, field.Type)
+		}
+		return st.fields, true
+	}
+	return nil, false
+}
+
 //getIndexFromName will search the pParse slice of FBs for one that matches
 // the provided name and return the index if found
 func (t *pParse) getIndexFromName(name string) int {